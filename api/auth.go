@@ -0,0 +1,30 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var errUnauthorized = errors.New("unauthorized")
+
+// Auth wraps admin API handler, requests are passed to handler only with Authorization header
+// containing bearer token equal to token. If token is empty, all requests are passed.
+func Auth(token string, handler http.HandlerFunc) http.HandlerFunc {
+	if len(token) == 0 {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		header := req.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, "Bearer ")), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errUnauthorized)
+			return
+		}
+
+		handler(w, req)
+	}
+}
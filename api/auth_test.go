@@ -0,0 +1,75 @@
+package api
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuth(t *testing.T) {
+	t.Parallel()
+
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, deletedResponse{Deleted: 1})
+	}
+
+	type testTableData struct {
+		tcase          string
+		token          string
+		authorization  string
+		expectedStatus int
+		expectedBody   string
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:          "no token",
+			token:          "",
+			authorization:  "",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"deleted":1}`,
+		},
+		{
+			tcase:          "valid token",
+			token:          "s3cr3t",
+			authorization:  "Bearer s3cr3t",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"deleted":1}`,
+		},
+		{
+			tcase:          "invalid token",
+			token:          "s3cr3t",
+			authorization:  "Bearer secret",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"unauthorized"}`,
+		},
+		{
+			tcase:          "missing token",
+			token:          "s3cr3t",
+			authorization:  "",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"unauthorized"}`,
+		},
+		{
+			tcase:          "basic auth",
+			token:          "s3cr3t",
+			authorization:  "Basic czNjcjN0",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"unauthorized"}`,
+		},
+	}
+
+	for _, testUnit := range testTable {
+		req := httptest.NewRequest(http.MethodDelete, BlocksPath+"?rule=testrule1", nil)
+		if len(testUnit.authorization) > 0 {
+			req.Header.Set("Authorization", testUnit.authorization)
+		}
+
+		w := httptest.NewRecorder()
+		Auth(testUnit.token, handler)(w, req)
+
+		assert.Equal(t, testUnit.expectedStatus, w.Code, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedBody+"\n", w.Body.String(), testUnit.tcase)
+	}
+}
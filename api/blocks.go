@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/krpn/prometheus-alert-webhooker/blocker"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+)
+
+// BlocksPath is a path of blocks admin API.
+const BlocksPath = "/api/blocks"
+
+const context = "api"
+
+var (
+	errNotFound         = errors.New("not found")
+	errBlockNotFound    = errors.New("block not found")
+	errMethodNotAllowed = errors.New("method not allowed")
+	errEmptyRule        = errors.New("rule query parameter is required")
+)
+
+type deletedResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Blocks is a handler for blocks admin API:
+// GET /api/blocks lists blocks, optionally filtered by rule and executor query parameters;
+// DELETE /api/blocks?rule=<rule> deletes all blocks of rule;
+// DELETE /api/blocks/<scope>/<fingerprint> deletes block of task.
+func Blocks(w http.ResponseWriter, req *http.Request, blocks blocks, logger *logrus.Logger) {
+	ctxLogger := logger.WithFields(logrus.Fields{"context": context, "remote_addr": req.RemoteAddr})
+
+	path := strings.Trim(strings.TrimPrefix(req.URL.Path, BlocksPath), "/")
+	if len(path) == 0 {
+		switch req.Method {
+		case http.MethodGet:
+			listBlocks(w, req, blocks, ctxLogger)
+		case http.MethodDelete:
+			deleteRuleBlocks(w, req, blocks, ctxLogger)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		}
+		return
+	}
+
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	if req.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	scope, fingerprint := parts[0], parts[1]
+	blockLogger := ctxLogger.WithFields(logrus.Fields{"scope": scope, "fingerprint": fingerprint})
+
+	deleted, err := blocks.Delete(scope, fingerprint)
+	if err != nil {
+		blockLogger.Errorf("delete block error: %v", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !deleted {
+		writeError(w, http.StatusNotFound, errBlockNotFound)
+		return
+	}
+
+	blockLogger.Info("block is deleted")
+	writeJSON(w, http.StatusOK, deletedResponse{Deleted: 1})
+}
+
+func listBlocks(w http.ResponseWriter, req *http.Request, blocks blocks, logger *logrus.Entry) {
+	entries, err := blocks.Blocks()
+	if err != nil {
+		logger.Errorf("get blocks error: %v", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	rule, executorName := req.URL.Query().Get("rule"), req.URL.Query().Get("executor")
+	filtered := make(blocker.Entries, 0, len(entries))
+	for _, entry := range entries {
+		if len(rule) > 0 && entry.Rule != rule {
+			continue
+		}
+
+		if len(executorName) > 0 && entry.Executor != executorName {
+			continue
+		}
+
+		// blocks could be stored by previous versions without redaction
+		entry.Details = executor.Redact(entry.Details)
+		filtered = append(filtered, entry)
+	}
+
+	writeJSON(w, http.StatusOK, filtered)
+}
+
+func deleteRuleBlocks(w http.ResponseWriter, req *http.Request, blocks blocks, logger *logrus.Entry) {
+	rule := req.URL.Query().Get("rule")
+	if len(rule) == 0 {
+		writeError(w, http.StatusBadRequest, errEmptyRule)
+		return
+	}
+
+	ruleLogger := logger.WithField("rule", rule)
+
+	deleted, err := blocks.DeleteRule(rule)
+	if err != nil {
+		ruleLogger.Errorf("delete rule blocks error: %v", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	ruleLogger.WithField("deleted", deleted).Info("rule blocks are deleted")
+	writeJSON(w, http.StatusOK, deletedResponse{Deleted: deleted})
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+//go:generate mockgen -source=blocks.go -destination=blocks_mocks.go -package=api doc github.com/golang/mock/gomock
+
+type blocks interface {
+	Blocks() (blocker.Entries, error)
+	Delete(scope, fingerprint string) (deleted bool, err error)
+	DeleteRule(rule string) (deleted int, err error)
+}
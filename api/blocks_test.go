@@ -0,0 +1,258 @@
+package api
+
+import (
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/krpn/prometheus-alert-webhooker/blocker"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBlocks(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	expiresAt := time.Unix(1535086351, 0).UTC()
+	entries := blocker.Entries{
+		{
+			Executor:    "jenkins",
+			Scope:       "jenkins",
+			Fingerprint: "fp1",
+			Rule:        "testrule1",
+			Alert:       "testalert1",
+			EventID:     "testid1",
+			Details:     map[string]interface{}{"job": "testjob"},
+			InProgress:  true,
+		},
+		{
+			Executor:    "shell",
+			Scope:       "shell",
+			Fingerprint: "fp2",
+			Rule:        "testrule2",
+			Alert:       "testalert2",
+			EventID:     "testid2",
+			Details:     map[string]interface{}{"command": "ls"},
+			ExpiresAt:   &expiresAt,
+		},
+	}
+
+	type testTableData struct {
+		tcase          string
+		method         string
+		target         string
+		expectFunc     func(m *Mockblocks)
+		expectedStatus int
+		expectedBody   string
+		expectedLogs   []string
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:  "list",
+			method: http.MethodGet,
+			target: "/api/blocks",
+			expectFunc: func(m *Mockblocks) {
+				m.EXPECT().Blocks().Return(entries, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"executor":"jenkins","scope":"jenkins","fingerprint":"fp1","rule":"testrule1","alert":"testalert1","event_id":"testid1","details":{"job":"testjob"},"in_progress":true,"expires_at":null},{"executor":"shell","scope":"shell","fingerprint":"fp2","rule":"testrule2","alert":"testalert2","event_id":"testid2","details":{"command":"ls"},"in_progress":false,"expires_at":"2018-08-24T04:52:31Z"}]`,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase:  "list filtered",
+			method: http.MethodGet,
+			target: "/api/blocks/?rule=testrule2&executor=shell",
+			expectFunc: func(m *Mockblocks) {
+				m.EXPECT().Blocks().Return(entries, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"executor":"shell","scope":"shell","fingerprint":"fp2","rule":"testrule2","alert":"testalert2","event_id":"testid2","details":{"command":"ls"},"in_progress":false,"expires_at":"2018-08-24T04:52:31Z"}]`,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase:  "list empty",
+			method: http.MethodGet,
+			target: "/api/blocks?rule=unknown",
+			expectFunc: func(m *Mockblocks) {
+				m.EXPECT().Blocks().Return(entries, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[]`,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase:  "list redacts details",
+			method: http.MethodGet,
+			target: "/api/blocks",
+			expectFunc: func(m *Mockblocks) {
+				m.EXPECT().Blocks().Return(blocker.Entries{
+					{
+						Executor:    "http",
+						Scope:       "http",
+						Fingerprint: "fp3",
+						Rule:        "testrule3",
+						Alert:       "testalert3",
+						EventID:     "testid3",
+						Details:     map[string]interface{}{"headers": map[string]interface{}{"Authorization": "Bearer s3cr3t"}},
+					},
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"executor":"http","scope":"http","fingerprint":"fp3","rule":"testrule3","alert":"testalert3","event_id":"testid3","details":{"headers":{"Authorization":"redacted"}},"in_progress":false,"expires_at":null}]`,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase:  "list error",
+			method: http.MethodGet,
+			target: "/api/blocks",
+			expectFunc: func(m *Mockblocks) {
+				m.EXPECT().Blocks().Return(nil, errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"connection refused"}`,
+			expectedLogs: []string{
+				`{"context":"api","level":"error","msg":"get blocks error: connection refused","remote_addr":"192.0.2.1:1234"}`,
+			},
+		},
+		{
+			tcase:  "delete",
+			method: http.MethodDelete,
+			target: "/api/blocks/jenkins/fp1",
+			expectFunc: func(m *Mockblocks) {
+				m.EXPECT().Delete("jenkins", "fp1").Return(true, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"deleted":1}`,
+			expectedLogs: []string{
+				`{"context":"api","fingerprint":"fp1","level":"info","msg":"block is deleted","remote_addr":"192.0.2.1:1234","scope":"jenkins"}`,
+			},
+		},
+		{
+			tcase:  "delete not found",
+			method: http.MethodDelete,
+			target: "/api/blocks/jenkins/fp1",
+			expectFunc: func(m *Mockblocks) {
+				m.EXPECT().Delete("jenkins", "fp1").Return(false, nil)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"block not found"}`,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase:  "delete error",
+			method: http.MethodDelete,
+			target: "/api/blocks/jenkins/fp1",
+			expectFunc: func(m *Mockblocks) {
+				m.EXPECT().Delete("jenkins", "fp1").Return(false, errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"connection refused"}`,
+			expectedLogs: []string{
+				`{"context":"api","fingerprint":"fp1","level":"error","msg":"delete block error: connection refused","remote_addr":"192.0.2.1:1234","scope":"jenkins"}`,
+			},
+		},
+		{
+			tcase:  "delete by rule",
+			method: http.MethodDelete,
+			target: "/api/blocks?rule=testrule1",
+			expectFunc: func(m *Mockblocks) {
+				m.EXPECT().DeleteRule("testrule1").Return(3, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"deleted":3}`,
+			expectedLogs: []string{
+				`{"context":"api","deleted":3,"level":"info","msg":"rule blocks are deleted","remote_addr":"192.0.2.1:1234","rule":"testrule1"}`,
+			},
+		},
+		{
+			tcase:  "delete by rule error",
+			method: http.MethodDelete,
+			target: "/api/blocks?rule=testrule1",
+			expectFunc: func(m *Mockblocks) {
+				m.EXPECT().DeleteRule("testrule1").Return(0, errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"connection refused"}`,
+			expectedLogs: []string{
+				`{"context":"api","level":"error","msg":"delete rule blocks error: connection refused","remote_addr":"192.0.2.1:1234","rule":"testrule1"}`,
+			},
+		},
+		{
+			tcase:          "delete without rule",
+			method:         http.MethodDelete,
+			target:         "/api/blocks",
+			expectFunc:     func(m *Mockblocks) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"rule query parameter is required"}`,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase:          "list method not allowed",
+			method:         http.MethodPost,
+			target:         "/api/blocks",
+			expectFunc:     func(m *Mockblocks) {},
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedBody:   `{"error":"method not allowed"}`,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase:          "block method not allowed",
+			method:         http.MethodGet,
+			target:         "/api/blocks/jenkins/fp1",
+			expectFunc:     func(m *Mockblocks) {},
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedBody:   `{"error":"method not allowed"}`,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase:          "no fingerprint",
+			method:         http.MethodDelete,
+			target:         "/api/blocks/jenkins",
+			expectFunc:     func(m *Mockblocks) {},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"not found"}`,
+			expectedLogs:   []string{},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		logger, hook := test.NewNullLogger()
+		logger.Formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+
+		blocks := NewMockblocks(ctrl)
+		testUnit.expectFunc(blocks)
+
+		w := httptest.NewRecorder()
+		Blocks(w, httptest.NewRequest(testUnit.method, testUnit.target, nil), blocks, logger)
+
+		assert.Equal(t, testUnit.expectedStatus, w.Code, testUnit.tcase)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"), testUnit.tcase)
+		assert.Equal(t, testUnit.expectedBody+"\n", w.Body.String(), testUnit.tcase)
+		assert.Equal(t, expectedLogsFix(testUnit.expectedLogs), logsFromHook(t, hook), testUnit.tcase)
+	}
+}
+
+func logsFromHook(t *testing.T, hook *test.Hook) (logs []string) {
+	logs = make([]string, len(hook.Entries))
+	for i, entry := range hook.Entries {
+		log, err := entry.String()
+		assert.Equal(t, nil, err)
+		logs[i] = log
+	}
+	return
+}
+
+func expectedLogsFix(logs []string) (expectedLogs []string) {
+	expectedLogs = make([]string, len(logs))
+	for i, log := range logs {
+		expectedLogs[i] = log + "\n"
+	}
+	return
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/krpn/prometheus-alert-webhooker/history"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ExecutionsPath is a path of executions history API.
+const ExecutionsPath = "/api/executions"
+
+var errInvalidLimit = errors.New("limit query parameter should be non-negative integer")
+
+// Executions is a handler for executions history API:
+// GET /api/executions lists executions newest first, optionally filtered by
+// rule, alert, result, from and to (RFC 3339 time of execution start) query parameters,
+// quantity of returned executions is limited by limit query parameter.
+func Executions(w http.ResponseWriter, req *http.Request, executions executions, logger *logrus.Logger) {
+	ctxLogger := logger.WithFields(logrus.Fields{"context": context, "remote_addr": req.RemoteAddr})
+
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	filter, err := executionsFilter(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	entries, err := executions.Executions(filter)
+	if err != nil {
+		ctxLogger.Errorf("get executions error: %v", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// executions could be recorded by previous versions without redaction
+	for _, entry := range entries {
+		for i := range entry.Tasks {
+			entry.Tasks[i].Details = executor.Redact(entry.Tasks[i].Details)
+			entry.Tasks[i].Output, _ = executor.Redact(entry.Tasks[i].Output).(map[string]interface{})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func executionsFilter(req *http.Request) (filter history.Filter, err error) {
+	query := req.URL.Query()
+	filter = history.Filter{
+		Rule:   query.Get("rule"),
+		Alert:  query.Get("alert"),
+		Result: query.Get("result"),
+	}
+
+	for param, value := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if len(query.Get(param)) == 0 {
+			continue
+		}
+
+		*value, err = time.Parse(time.RFC3339, query.Get(param))
+		if err != nil {
+			return filter, fmt.Errorf("%v query parameter should be RFC 3339 time", param)
+		}
+	}
+
+	if len(query.Get("limit")) > 0 {
+		filter.Limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil || filter.Limit < 0 {
+			return filter, errInvalidLimit
+		}
+	}
+
+	return filter, nil
+}
+
+//go:generate mockgen -source=executions.go -destination=executions_mocks.go -package=api doc github.com/golang/mock/gomock
+
+type executions interface {
+	Executions(filter history.Filter) ([]history.Execution, error)
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/krpn/prometheus-alert-webhooker/history"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExecutions(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	startedAt := time.Unix(1535086351, 0).UTC()
+	executions := []history.Execution{
+		{
+			ID:         2,
+			EventID:    "testid1",
+			Rule:       "testrule1",
+			Alert:      "testalert1",
+			Result:     "exec_error",
+			Error:      "exit status 1",
+			StartedAt:  startedAt,
+			FinishedAt: startedAt.Add(time.Second),
+			Tasks: []history.TaskExecution{
+				{
+					Executor:   "shell",
+					Details:    map[string]interface{}{"command": "ls"},
+					Result:     "exec_error",
+					Error:      "exit status 1",
+					Output:     map[string]interface{}{"stdout": "fail"},
+					StartedAt:  startedAt,
+					FinishedAt: startedAt.Add(time.Second),
+				},
+			},
+		},
+	}
+
+	type testTableData struct {
+		tcase          string
+		method         string
+		target         string
+		expectFunc     func(m *Mockexecutions)
+		expectedStatus int
+		expectedBody   string
+		expectedLogs   []string
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:  "list",
+			method: http.MethodGet,
+			target: "/api/executions",
+			expectFunc: func(m *Mockexecutions) {
+				m.EXPECT().Executions(history.Filter{}).Return(executions, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"id":2,"event_id":"testid1","rule":"testrule1","alert":"testalert1","result":"exec_error","error":"exit status 1","started_at":"2018-08-24T04:52:31Z","finished_at":"2018-08-24T04:52:32Z","tasks":[{"executor":"shell","details":{"command":"ls"},"result":"exec_error","error":"exit status 1","output":{"stdout":"fail"},"started_at":"2018-08-24T04:52:31Z","finished_at":"2018-08-24T04:52:32Z"}]}]`,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase:  "list redacts details and output",
+			method: http.MethodGet,
+			target: "/api/executions",
+			expectFunc: func(m *Mockexecutions) {
+				m.EXPECT().Executions(history.Filter{}).Return([]history.Execution{
+					{
+						ID:         3,
+						EventID:    "testid2",
+						Rule:       "testrule2",
+						Alert:      "testalert2",
+						Result:     "success",
+						StartedAt:  startedAt,
+						FinishedAt: startedAt,
+						Tasks: []history.TaskExecution{
+							{
+								Executor:   "http",
+								Details:    map[string]interface{}{"headers": map[string]interface{}{"X-Api-Key": "123"}},
+								Result:     "success",
+								Output:     map[string]interface{}{"access_token": "456", "status": "ok"},
+								StartedAt:  startedAt,
+								FinishedAt: startedAt,
+							},
+						},
+					},
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"id":3,"event_id":"testid2","rule":"testrule2","alert":"testalert2","result":"success","started_at":"2018-08-24T04:52:31Z","finished_at":"2018-08-24T04:52:31Z","tasks":[{"executor":"http","details":{"headers":{"X-Api-Key":"redacted"}},"result":"success","output":{"access_token":"redacted","status":"ok"},"started_at":"2018-08-24T04:52:31Z","finished_at":"2018-08-24T04:52:31Z"}]}]`,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase:  "list filtered",
+			method: http.MethodGet,
+			target: "/api/executions?rule=testrule1&alert=testalert1&result=success&from=2018-08-24T04:00:00Z&to=2018-08-24T05:00:00Z&limit=10",
+			expectFunc: func(m *Mockexecutions) {
+				m.EXPECT().Executions(history.Filter{
+					Rule:   "testrule1",
+					Alert:  "testalert1",
+					Result: "success",
+					From:   time.Date(2018, 8, 24, 4, 0, 0, 0, time.UTC),
+					To:     time.Date(2018, 8, 24, 5, 0, 0, 0, time.UTC),
+					Limit:  10,
+				}).Return([]history.Execution{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[]`,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase:          "invalid from",
+			method:         http.MethodGet,
+			target:         "/api/executions?from=yesterday",
+			expectFunc:     func(m *Mockexecutions) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"from query parameter should be RFC 3339 time"}`,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase:          "invalid limit",
+			method:         http.MethodGet,
+			target:         "/api/executions?limit=-1",
+			expectFunc:     func(m *Mockexecutions) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"limit query parameter should be non-negative integer"}`,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase:  "store error",
+			method: http.MethodGet,
+			target: "/api/executions",
+			expectFunc: func(m *Mockexecutions) {
+				m.EXPECT().Executions(history.Filter{}).Return(nil, errors.New("database is locked"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"database is locked"}`,
+			expectedLogs: []string{
+				`{"context":"api","level":"error","msg":"get executions error: database is locked","remote_addr":"192.0.2.1:1234"}`,
+			},
+		},
+		{
+			tcase:          "method not allowed",
+			method:         http.MethodDelete,
+			target:         "/api/executions",
+			expectFunc:     func(m *Mockexecutions) {},
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedBody:   `{"error":"method not allowed"}`,
+			expectedLogs:   []string{},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		logger, hook := test.NewNullLogger()
+		logger.Formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+
+		store := NewMockexecutions(ctrl)
+		testUnit.expectFunc(store)
+
+		w := httptest.NewRecorder()
+		Executions(w, httptest.NewRequest(testUnit.method, testUnit.target, nil), store, logger)
+
+		assert.Equal(t, testUnit.expectedStatus, w.Code, testUnit.tcase)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"), testUnit.tcase)
+		assert.Equal(t, testUnit.expectedBody+"\n", w.Body.String(), testUnit.tcase)
+		assert.Equal(t, expectedLogsFix(testUnit.expectedLogs), logsFromHook(t, hook), testUnit.tcase)
+	}
+}
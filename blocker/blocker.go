@@ -1,41 +1,71 @@
 package blocker
 
 import (
+	"errors"
 	"fmt"
+	"github.com/coocood/freecache"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
 	"strings"
 	"sync"
 	"time"
 )
 
-const (
-	foreverTTL = 0
-	defValue   = "l"
-)
+const foreverTTL = 0
+
+var errBlockNotOwned = errors.New("block is owned by another execution")
 
-// Blocker blocks tasks by executor name and fingerprint.
+// Blocker blocks tasks by scope and fingerprint.
+// Owner of block is kept in entry.
 type Blocker struct {
-	cache    cacher
-	mt       *sync.Mutex
-	defValue []byte
+	cache   cacher
+	mt      *sync.Mutex
+	owners  *owners
+	nowFunc func() time.Time
 }
 
-// BlockInProgress blocks task by executor name and fingeprint while executing.
-func (b *Blocker) BlockInProgress(executor, fingerprint string) (blockedSuccessfully bool, err error) {
+// BlockInProgress blocks task by scope and fingeprint while executing.
+// It returns owner of the block or empty owner if task is already blocked.
+// Block expires after lease unless it is renewed. Block does not expire if lease is not positive.
+func (b *Blocker) BlockInProgress(task executor.Task, lease time.Duration) (owner string, err error) {
 	b.mt.Lock()
 	defer b.mt.Unlock()
 
-	key := getBlockKey(executor, fingerprint)
+	entry := newEntry(task, true, lease, b.nowFunc())
+	key := getBlockKey(entry.Scope, entry.Fingerprint)
 
 	res, err := b.cache.Get(key)
 	if len(res) != 0 {
-		return false, nil
+		return "", nil
 	}
 
-	if err != nil && !strings.Contains(err.Error(), "not found") {
+	if err != nil && !isNotFound(err) {
+		return "", err
+	}
+
+	entry.Owner = b.owners.next()
+	err = b.set(key, entry, leaseSeconds(lease))
+	if err != nil {
+		return "", err
+	}
+
+	return entry.Owner, nil
+}
+
+// Renew prolongs in-progress block by scope and fingeprint for lease.
+// Block is not set again if it has already expired or is owned by another execution.
+func (b *Blocker) Renew(scope, fingerprint, owner string, lease time.Duration) (renewed bool, err error) {
+	b.mt.Lock()
+	defer b.mt.Unlock()
+
+	key := getBlockKey(scope, fingerprint)
+
+	entry, ok, err := b.get(key)
+	if err != nil || !ok || entry.Owner != owner {
 		return false, err
 	}
+	entry.ExpiresAt = expiresAt(lease, b.nowFunc())
 
-	err = b.cache.Set(key, b.defValue, foreverTTL)
+	err = b.set(key, entry, leaseSeconds(lease))
 	if err != nil {
 		return false, err
 	}
@@ -43,33 +73,168 @@ func (b *Blocker) BlockInProgress(executor, fingerprint string) (blockedSuccessf
 	return true, nil
 }
 
-// BlockForTTL blocks task by executor name and fingeprint for needed TTL.
-func (b *Blocker) BlockForTTL(executor, fingerprint string, ttl time.Duration) error {
+// BlockForTTL blocks task by scope and fingeprint for needed TTL.
+// TTL is rounded up to seconds, block does not expire if TTL is not positive.
+// Block owned by another execution is not rewritten.
+func (b *Blocker) BlockForTTL(task executor.Task, owner string, ttl time.Duration) error {
 	b.mt.Lock()
 	defer b.mt.Unlock()
 
-	return b.cache.Set(getBlockKey(executor, fingerprint), b.defValue, int(ttl.Seconds()))
+	entry := newEntry(task, false, ttl, b.nowFunc())
+	entry.Owner = owner
+	key := getBlockKey(entry.Scope, entry.Fingerprint)
+
+	current, ok, err := b.get(key)
+	if err != nil {
+		return err
+	}
+
+	if ok && current.Owner != owner {
+		return errBlockNotOwned
+	}
+
+	return b.set(key, entry, leaseSeconds(ttl))
 }
 
-// Unblock unblocks task by executor name and fingeprint.
-func (b *Blocker) Unblock(executor, fingerprint string) {
+// Unblock unblocks task by scope and fingeprint.
+// Block owned by another execution is not released.
+func (b *Blocker) Unblock(scope, fingerprint, owner string) {
 	b.mt.Lock()
 	defer b.mt.Unlock()
 
-	_ = b.cache.Del(getBlockKey(executor, fingerprint))
+	key := getBlockKey(scope, fingerprint)
+
+	entry, ok, err := b.get(key)
+	if err != nil || !ok || entry.Owner != owner {
+		return
+	}
+
+	b.cache.Del(key)
 }
 
-// New creates Blocker instance.
-func New(cache cacher) *Blocker {
+// set stores entry of block key. Entry too large for cache (e.g. with long HTTP body in details)
+// is stored without details, so task is blocked anyway.
+func (b *Blocker) set(key []byte, entry Entry, expireSeconds int) error {
+	err := b.cache.Set(key, entry.encode(), expireSeconds)
+	if err != freecache.ErrLargeEntry {
+		return err
+	}
+
+	entry.Details = nil
+	return b.cache.Set(key, entry.encode(), expireSeconds)
+}
+
+// get returns entry of block key and reports whether block exists.
+// Value of block set before entries were introduced is returned as entry without owner.
+func (b *Blocker) get(key []byte) (entry Entry, ok bool, err error) {
+	res, err := b.cache.Get(key)
+	if err != nil && !isNotFound(err) {
+		return Entry{}, false, err
+	}
+
+	if len(res) == 0 {
+		return Entry{}, false, nil
+	}
+
+	entry, _ = decodeEntry(res)
+	return entry, true, nil
+}
+
+// Delete unblocks task by scope and fingeprint and reports whether block existed.
+func (b *Blocker) Delete(scope, fingerprint string) (deleted bool, err error) {
+	b.mt.Lock()
+	defer b.mt.Unlock()
+
+	return b.cache.Del(getBlockKey(scope, fingerprint)), nil
+}
+
+// DeleteRule unblocks all tasks of rule.
+func (b *Blocker) DeleteRule(rule string) (deleted int, err error) {
+	entries, err := b.Blocks()
+	if err != nil {
+		return 0, err
+	}
+
+	b.mt.Lock()
+	defer b.mt.Unlock()
+
+	for _, entry := range entries {
+		if entry.Rule != rule {
+			continue
+		}
+
+		if b.cache.Del(getBlockKey(entry.Scope, entry.Fingerprint)) {
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// Blocks returns all not expired blocks sorted by scope and fingerprint.
+func (b *Blocker) Blocks() (Entries, error) {
+	b.mt.Lock()
+	defer b.mt.Unlock()
+
+	now := b.nowFunc()
+	entries := make(Entries, 0)
+	for _, value := range b.cache.Values() {
+		entry, err := decodeEntry(value)
+		if err != nil {
+			// value of block set before entries were introduced
+			continue
+		}
+
+		if entry.ExpiresAt != nil && !now.Before(*entry.ExpiresAt) {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	entries.sort()
+	return entries, nil
+}
+
+// New creates Blocker instance with random owners.
+func New(cache cacher, nowFunc func() time.Time) *Blocker {
 	return &Blocker{
-		cache:    cache,
-		mt:       &sync.Mutex{},
-		defValue: []byte(defValue),
+		cache:   cache,
+		mt:      &sync.Mutex{},
+		owners:  newOwners(),
+		nowFunc: nowFunc,
 	}
 }
 
-func getBlockKey(executor, fingerprint string) []byte {
-	return []byte(fmt.Sprintf("%v_%v", executor, fingerprint))
+// FreeCache is a freecache.Cache able to return all values.
+type FreeCache struct {
+	*freecache.Cache
+}
+
+// Values returns all not expired values.
+func (c FreeCache) Values() (values [][]byte) {
+	it := c.NewIterator()
+	for entry := it.Next(); entry != nil; entry = it.Next() {
+		values = append(values, entry.Value)
+	}
+	return
+}
+
+// leaseSeconds rounds lease up to seconds, cache does not support shorter TTL.
+func leaseSeconds(lease time.Duration) int {
+	if lease <= 0 {
+		return foreverTTL
+	}
+
+	return int((lease + time.Second - 1) / time.Second)
+}
+
+func isNotFound(err error) bool {
+	return strings.Contains(err.Error(), "not found")
+}
+
+func getBlockKey(scope, fingerprint string) []byte {
+	return []byte(fmt.Sprintf("%v_%v", scope, fingerprint))
 }
 
 //go:generate mockgen -source=blocker.go -destination=blocker_mocks.go -package=blocker doc github.com/golang/mock/gomock
@@ -78,4 +243,5 @@ type cacher interface {
 	Get(key []byte) (value []byte, err error)
 	Set(key, value []byte, expireSeconds int) (err error)
 	Del(key []byte) (affected bool)
+	Values() (values [][]byte)
 }
@@ -68,3 +68,15 @@ func (m *Mockcacher) Del(key []byte) bool {
 func (mr *MockcacherMockRecorder) Del(key interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Del", reflect.TypeOf((*Mockcacher)(nil).Del), key)
 }
+
+// Values mocks base method
+func (m *Mockcacher) Values() [][]byte {
+	ret := m.ctrl.Call(m, "Values")
+	ret0, _ := ret[0].([][]byte)
+	return ret0
+}
+
+// Values indicates an expected call of Values
+func (mr *MockcacherMockRecorder) Values() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Values", reflect.TypeOf((*Mockcacher)(nil).Values))
+}
@@ -4,11 +4,48 @@ import (
 	"errors"
 	"github.com/coocood/freecache"
 	"github.com/golang/mock/gomock"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
 	"github.com/stretchr/testify/assert"
 	"testing"
 	"time"
 )
 
+var testNow = time.Unix(1535086351, 0)
+
+func testNowFunc() time.Time {
+	return testNow
+}
+
+func newTestTask(ctrl *gomock.Controller, rule, fingerprint string) *executor.MockTask {
+	task := executor.NewMockTask(ctrl)
+	task.EXPECT().ExecutorName().Return("jenkins").AnyTimes()
+	task.EXPECT().Fingerprint().Return(fingerprint).AnyTimes()
+	task.EXPECT().Rule().Return(rule).AnyTimes()
+	task.EXPECT().Alert().Return("testalert1").AnyTimes()
+	task.EXPECT().EventID().Return("testid1").AnyTimes()
+	task.EXPECT().ExecutorDetails().Return(map[string]interface{}{"job": "testjob"}).AnyTimes()
+	return task
+}
+
+func testEntry(rule, fingerprint string, inProgress bool, ttl time.Duration) Entry {
+	return Entry{
+		Executor:    "jenkins",
+		Scope:       "jenkins",
+		Fingerprint: fingerprint,
+		Rule:        rule,
+		Alert:       "testalert1",
+		EventID:     "testid1",
+		Details:     map[string]interface{}{"job": "testjob"},
+		InProgress:  inProgress,
+		ExpiresAt:   expiresAt(ttl, testNow),
+	}
+}
+
+func ownedEntry(entry Entry, owner string) Entry {
+	entry.Owner = owner
+	return entry
+}
+
 func TestBlocker_BlockInProgress(t *testing.T) {
 	t.Parallel()
 
@@ -16,67 +53,61 @@ func TestBlocker_BlockInProgress(t *testing.T) {
 	defer ctrl.Finish()
 
 	cache := NewMockcacher(ctrl)
-	blocker := New(cache)
+	blocker := New(cache, testNowFunc)
+	blocker.owners = &owners{instance: "owner1"}
+	task := newTestTask(ctrl, "testrule1", "test")
+	value := func(owner string) []byte {
+		return ownedEntry(testEntry("testrule1", "test", true, time.Minute), owner).encode()
+	}
 
 	type testTableData struct {
-		tcase                       string
-		executor                    string
-		fingerprint                 string
-		expectFunc                  func(m *Mockcacher, key []byte)
-		expectedBlockedSuccessfully bool
-		expectedErr                 error
+		tcase         string
+		expectFunc    func(m *Mockcacher, key []byte)
+		expectedOwner string
+		expectedErr   error
 	}
 
 	testTable := []testTableData{
 		{
-			tcase:       "blocked successfully",
-			executor:    "jenkins",
-			fingerprint: "test",
+			tcase: "blocked successfully",
 			expectFunc: func(m *Mockcacher, key []byte) {
 				m.EXPECT().Get(key).Return(nil, freecache.ErrNotFound)
-				m.EXPECT().Set(key, []byte(defValue), foreverTTL).Return(nil)
+				m.EXPECT().Set(key, value("owner1-1"), 60).Return(nil)
 			},
-			expectedBlockedSuccessfully: true,
-			expectedErr:                 nil,
+			expectedOwner: "owner1-1",
+			expectedErr:   nil,
 		},
 		{
-			tcase:       "block error",
-			executor:    "jenkins",
-			fingerprint: "test",
+			tcase: "block error",
 			expectFunc: func(m *Mockcacher, key []byte) {
 				m.EXPECT().Get(key).Return(nil, freecache.ErrNotFound)
-				m.EXPECT().Set(key, []byte(defValue), foreverTTL).Return(errors.New("set error"))
+				m.EXPECT().Set(key, value("owner1-2"), 60).Return(errors.New("set error"))
 			},
-			expectedBlockedSuccessfully: false,
-			expectedErr:                 errors.New("set error"),
+			expectedOwner: "",
+			expectedErr:   errors.New("set error"),
 		},
 		{
-			tcase:       "already blocked",
-			executor:    "jenkins",
-			fingerprint: "test",
+			tcase: "already blocked",
 			expectFunc: func(m *Mockcacher, key []byte) {
-				m.EXPECT().Get(key).Return([]byte(defValue), nil)
+				m.EXPECT().Get(key).Return(value("owner1-1"), nil)
 			},
-			expectedBlockedSuccessfully: false,
-			expectedErr:                 nil,
+			expectedOwner: "",
+			expectedErr:   nil,
 		},
 		{
-			tcase:       "check block error",
-			executor:    "jenkins",
-			fingerprint: "test",
+			tcase: "check block error",
 			expectFunc: func(m *Mockcacher, key []byte) {
 				m.EXPECT().Get(key).Return(nil, errors.New("get error"))
 			},
-			expectedBlockedSuccessfully: false,
-			expectedErr:                 errors.New("get error"),
+			expectedOwner: "",
+			expectedErr:   errors.New("get error"),
 		},
 	}
 
 	for _, testUnit := range testTable {
-		key := getBlockKey(testUnit.executor, testUnit.fingerprint)
-		testUnit.expectFunc(cache, key)
-		blockedSuccessfully, err := blocker.BlockInProgress(testUnit.executor, testUnit.fingerprint)
-		assert.Equal(t, testUnit.expectedBlockedSuccessfully, blockedSuccessfully, testUnit.tcase)
+		testUnit.expectFunc(cache, getBlockKey("jenkins", "test"))
+		owner, err := blocker.BlockInProgress(task, time.Minute)
+		assert.Equal(t, testUnit.expectedOwner, owner, testUnit.tcase)
 		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
 	}
 }
@@ -88,52 +119,125 @@ func TestBlocker_BlockForTTL(t *testing.T) {
 	defer ctrl.Finish()
 
 	cache := NewMockcacher(ctrl)
-	blocker := New(cache)
+	blocker := New(cache, testNowFunc)
+	task := newTestTask(ctrl, "testrule1", "test")
+	inProgress := func(owner string) []byte {
+		return ownedEntry(testEntry("testrule1", "test", true, time.Minute), owner).encode()
+	}
 
 	type testTableData struct {
 		tcase       string
-		executor    string
-		fingerprint string
 		ttl         time.Duration
-		expectFunc  func(m *Mockcacher, key []byte, ttl time.Duration)
+		expectFunc  func(m *Mockcacher, key, value []byte)
 		expectedErr error
 	}
 
 	testTable := []testTableData{
 		{
-			tcase:       "success",
-			executor:    "jenkins",
-			fingerprint: "test",
-			ttl:         10 * time.Second,
-			expectFunc: func(m *Mockcacher, key []byte, ttl time.Duration) {
-				m.EXPECT().Set(key, []byte(defValue), int(ttl.Seconds())).Return(nil)
+			tcase: "success",
+			ttl:   10 * time.Second,
+			expectFunc: func(m *Mockcacher, key, value []byte) {
+				m.EXPECT().Get(key).Return(inProgress("owner1"), nil)
+				m.EXPECT().Set(key, value, 10).Return(nil)
 			},
 			expectedErr: nil,
 		},
 		{
-			tcase:       "error",
-			executor:    "jenkins",
-			fingerprint: "test",
-			ttl:         10 * time.Second,
-			expectFunc: func(m *Mockcacher, key []byte, ttl time.Duration) {
-				m.EXPECT().Set(key, []byte(defValue), int(ttl.Seconds())).Return(errors.New("some error"))
+			tcase: "ttl shorter than second is rounded up",
+			ttl:   500 * time.Millisecond,
+			expectFunc: func(m *Mockcacher, key, value []byte) {
+				m.EXPECT().Get(key).Return(nil, freecache.ErrNotFound)
+				m.EXPECT().Set(key, value, 1).Return(nil)
+			},
+			expectedErr: nil,
+		},
+		{
+			tcase: "owned by another execution",
+			ttl:   10 * time.Second,
+			expectFunc: func(m *Mockcacher, key, value []byte) {
+				m.EXPECT().Get(key).Return(inProgress("owner2"), nil)
+			},
+			expectedErr: errBlockNotOwned,
+		},
+		{
+			tcase: "get error",
+			ttl:   10 * time.Second,
+			expectFunc: func(m *Mockcacher, key, value []byte) {
+				m.EXPECT().Get(key).Return(nil, errors.New("get error"))
+			},
+			expectedErr: errors.New("get error"),
+		},
+		{
+			tcase: "error",
+			ttl:   10 * time.Second,
+			expectFunc: func(m *Mockcacher, key, value []byte) {
+				m.EXPECT().Get(key).Return(inProgress("owner1"), nil)
+				m.EXPECT().Set(key, value, 10).Return(errors.New("some error"))
 			},
 			expectedErr: errors.New("some error"),
 		},
 	}
 
 	for _, testUnit := range testTable {
-		key := getBlockKey(testUnit.executor, testUnit.fingerprint)
-		testUnit.expectFunc(cache, key, testUnit.ttl)
-		err := blocker.BlockForTTL(testUnit.executor, testUnit.fingerprint, testUnit.ttl)
+		testUnit.expectFunc(cache, getBlockKey("jenkins", "test"), ownedEntry(testEntry("testrule1", "test", false, testUnit.ttl), "owner1").encode())
+		err := blocker.BlockForTTL(task, "owner1", testUnit.ttl)
 		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
 	}
 }
 
+func TestBlocker_LargeEntry(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cache := NewMockcacher(ctrl)
+	blocker := New(cache, testNowFunc)
+	task := newTestTask(ctrl, "testrule1", "test")
+	key := getBlockKey("jenkins", "test")
+	entry := ownedEntry(testEntry("testrule1", "test", false, time.Minute), "owner1")
+	withoutDetails := entry
+	withoutDetails.Details = nil
+
+	// entry is stored without details if it is too large for cache
+	cache.EXPECT().Get(key).Return(nil, freecache.ErrNotFound)
+	cache.EXPECT().Set(key, entry.encode(), 60).Return(freecache.ErrLargeEntry)
+	cache.EXPECT().Set(key, withoutDetails.encode(), 60).Return(nil)
+	assert.Equal(t, nil, blocker.BlockForTTL(task, "owner1", time.Minute))
+
+	cache.EXPECT().Get(key).Return(nil, freecache.ErrNotFound)
+	cache.EXPECT().Set(key, entry.encode(), 60).Return(freecache.ErrLargeEntry)
+	cache.EXPECT().Set(key, withoutDetails.encode(), 60).Return(errors.New("set error"))
+	assert.Equal(t, errors.New("set error"), blocker.BlockForTTL(task, "owner1", time.Minute))
+
+	// real cache rejects entry larger than 1/1024 of its size
+	blocker = New(FreeCache{Cache: freecache.NewCache(512 * 1024)}, testNowFunc)
+	large := executor.NewMockTask(ctrl)
+	large.EXPECT().ExecutorName().Return("http").AnyTimes()
+	large.EXPECT().Fingerprint().Return("test").AnyTimes()
+	large.EXPECT().Rule().Return("testrule1").AnyTimes()
+	large.EXPECT().Alert().Return("testalert1").AnyTimes()
+	large.EXPECT().EventID().Return("testid1").AnyTimes()
+	large.EXPECT().ExecutorDetails().Return(map[string]interface{}{"body": string(make([]byte, 1024))}).AnyTimes()
+
+	owner, err := blocker.BlockInProgress(large, time.Minute)
+	assert.NotEqual(t, "", owner)
+	assert.Equal(t, nil, err)
+
+	entries, err := blocker.Blocks()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, nil, entries[0].Details)
+}
+
 func TestBlocker_BlockAsync(t *testing.T) {
 	t.Parallel()
 
-	blocker := New(freecache.NewCache(1 * 1024 * 1024))
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	blocker := New(FreeCache{Cache: freecache.NewCache(1 * 1024 * 1024)}, time.Now)
+	task := newTestTask(ctrl, "testrule1", "test")
 
 	interations := 1000
 
@@ -141,8 +245,8 @@ func TestBlocker_BlockAsync(t *testing.T) {
 	errCh := make(chan error, interations)
 
 	ablock := func(bCh chan bool, eCh chan error) {
-		b, e := blocker.BlockInProgress("jenkins", "test")
-		bCh <- b
+		owner, e := blocker.BlockInProgress(task, 0)
+		bCh <- len(owner) > 0
 		eCh <- e
 	}
 
@@ -177,13 +281,155 @@ func TestBlocker_Unblock(t *testing.T) {
 	defer ctrl.Finish()
 
 	cache := NewMockcacher(ctrl)
-	blocker := New(cache)
+	blocker := New(cache, testNowFunc)
 
 	key := getBlockKey("jenkins", "test")
+	value := ownedEntry(testEntry("testrule1", "test", true, time.Minute), "owner1").encode()
 
+	// block owned by another execution is not released
+	cache.EXPECT().Get(key).Return(value, nil)
+	blocker.Unblock("jenkins", "test", "owner2")
+
+	cache.EXPECT().Get(key).Return(value, nil)
 	cache.EXPECT().Del(key).Return(true)
+	blocker.Unblock("jenkins", "test", "owner1")
+
+	cache.EXPECT().Get(key).Return(nil, freecache.ErrNotFound)
+	blocker.Unblock("jenkins", "test", "owner1")
+}
+
+func TestBlocker_Renew(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cache := NewMockcacher(ctrl)
+	blocker := New(cache, testNowFunc)
+
+	value := ownedEntry(testEntry("testrule1", "test", true, time.Second), "owner1").encode()
+	renewedValue := ownedEntry(testEntry("testrule1", "test", true, 30*time.Second), "owner1").encode()
+
+	type testTableData struct {
+		tcase           string
+		expectFunc      func(m *Mockcacher, key []byte)
+		expectedRenewed bool
+		expectedErr     error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "renewed",
+			expectFunc: func(m *Mockcacher, key []byte) {
+				m.EXPECT().Get(key).Return(value, nil)
+				m.EXPECT().Set(key, renewedValue, 30).Return(nil)
+			},
+			expectedRenewed: true,
+			expectedErr:     nil,
+		},
+		{
+			tcase: "expired",
+			expectFunc: func(m *Mockcacher, key []byte) {
+				m.EXPECT().Get(key).Return(nil, freecache.ErrNotFound)
+			},
+			expectedRenewed: false,
+			expectedErr:     nil,
+		},
+		{
+			tcase: "owned by another execution",
+			expectFunc: func(m *Mockcacher, key []byte) {
+				m.EXPECT().Get(key).Return(ownedEntry(testEntry("testrule1", "test", true, time.Second), "owner2").encode(), nil)
+			},
+			expectedRenewed: false,
+			expectedErr:     nil,
+		},
+		{
+			tcase: "get error",
+			expectFunc: func(m *Mockcacher, key []byte) {
+				m.EXPECT().Get(key).Return(nil, errors.New("get error"))
+			},
+			expectedRenewed: false,
+			expectedErr:     errors.New("get error"),
+		},
+		{
+			tcase: "set error",
+			expectFunc: func(m *Mockcacher, key []byte) {
+				m.EXPECT().Get(key).Return(value, nil)
+				m.EXPECT().Set(key, renewedValue, 30).Return(errors.New("set error"))
+			},
+			expectedRenewed: false,
+			expectedErr:     errors.New("set error"),
+		},
+	}
+
+	for _, testUnit := range testTable {
+		testUnit.expectFunc(cache, getBlockKey("jenkins", "test"))
+		renewed, err := blocker.Renew("jenkins", "test", "owner1", 30*time.Second)
+		assert.Equal(t, testUnit.expectedRenewed, renewed, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
+	}
+}
+
+func TestBlocker_Blocks(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := testNow
+	blocker := New(FreeCache{Cache: freecache.NewCache(1 * 1024 * 1024)}, func() time.Time { return now })
+	blocker.owners = &owners{instance: "owner1"}
+
+	_, err := blocker.BlockInProgress(newTestTask(ctrl, "testrule1", "fp2"), 0)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, blocker.BlockForTTL(newTestTask(ctrl, "testrule1", "fp1"), "owner2", time.Hour))
+	assert.Equal(t, nil, blocker.BlockForTTL(newTestTask(ctrl, "testrule2", "fp3"), "owner3", time.Hour))
+
+	entries, err := blocker.Blocks()
+	assert.Equal(t, Entries{
+		ownedEntry(testEntry("testrule1", "fp1", false, time.Hour), "owner2"),
+		ownedEntry(testEntry("testrule1", "fp2", true, 0), "owner1-1"),
+		ownedEntry(testEntry("testrule2", "fp3", false, time.Hour), "owner3"),
+	}, entries)
+	assert.Equal(t, nil, err)
+
+	deleted, err := blocker.DeleteRule("testrule1")
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, nil, err)
+
+	deleted, err = blocker.DeleteRule("testrule1")
+	assert.Equal(t, 0, deleted)
+	assert.Equal(t, nil, err)
+
+	// entry is expired by stored expiration even if cache has not expired it yet
+	now = now.Add(time.Hour)
+	entries, err = blocker.Blocks()
+	assert.Equal(t, Entries{}, entries)
+	assert.Equal(t, nil, err)
+}
+
+func TestBlocker_Delete(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cache := NewMockcacher(ctrl)
+	blocker := New(cache, testNowFunc)
+
+	cache.EXPECT().Del(getBlockKey("jenkins", "test")).Return(false)
+
+	deleted, err := blocker.Delete("jenkins", "test")
+	assert.Equal(t, false, deleted)
+	assert.Equal(t, nil, err)
+}
+
+func Test_leaseSeconds(t *testing.T) {
+	t.Parallel()
 
-	blocker.Unblock("jenkins", "test")
+	assert.Equal(t, foreverTTL, leaseSeconds(0))
+	assert.Equal(t, 1, leaseSeconds(100*time.Millisecond))
+	assert.Equal(t, 60, leaseSeconds(time.Minute))
 }
 
 func Test_GetKey(t *testing.T) {
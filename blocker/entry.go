@@ -0,0 +1,80 @@
+package blocker
+
+import (
+	"encoding/json"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"sort"
+	"time"
+)
+
+// Entry describes block of task.
+// Details are redacted, so entries do not keep secrets in block stores.
+type Entry struct {
+	Executor string `json:"executor"`
+
+	// Scope is a scope the task is blocked within, equals executor name by default.
+	Scope       string      `json:"scope"`
+	Fingerprint string      `json:"fingerprint"`
+	Rule        string      `json:"rule"`
+	Alert       string      `json:"alert"`
+	EventID     string      `json:"event_id"`
+	Details     interface{} `json:"details"`
+	InProgress  bool        `json:"in_progress"`
+
+	// Owner is an execution holding the block, it is kept in entry by Blocker only.
+	Owner string `json:"owner,omitempty"`
+
+	// ExpiresAt is nil if block does not expire.
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Entries is a slice of Entry.
+type Entries []Entry
+
+func newEntry(task executor.Task, inProgress bool, ttl time.Duration, now time.Time) Entry {
+	return Entry{
+		Executor:    task.ExecutorName(),
+		Scope:       executor.BlockScope(task),
+		Fingerprint: task.Fingerprint(),
+		Rule:        task.Rule(),
+		Alert:       task.Alert(),
+		EventID:     task.EventID(),
+		Details:     executor.Redact(task.ExecutorDetails()),
+		InProgress:  inProgress,
+		ExpiresAt:   expiresAt(ttl, now),
+	}
+}
+
+func expiresAt(ttl time.Duration, now time.Time) *time.Time {
+	if ttl <= 0 {
+		return nil
+	}
+
+	t := now.Add(ttl).UTC()
+	return &t
+}
+
+func (e Entry) encode() []byte {
+	// Entry contains JSON compatible types only
+	b, _ := json.Marshal(e)
+	return b
+}
+
+func decodeEntry(b []byte) (e Entry, err error) {
+	err = json.Unmarshal(b, &e)
+	if len(e.Scope) == 0 {
+		// block set before scopes were introduced
+		e.Scope = e.Executor
+	}
+	return
+}
+
+// sort sorts entries by scope and fingerprint.
+func (entries Entries) sort() {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Scope != entries[j].Scope {
+			return entries[i].Scope < entries[j].Scope
+		}
+		return entries[i].Fingerprint < entries[j].Fingerprint
+	})
+}
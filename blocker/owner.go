@@ -0,0 +1,38 @@
+package blocker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// owners generates owners of blocks, owner is unique for every execution holding in-progress block.
+// Execution renews, rewrites or releases block only if it owns the block, so execution which lease
+// has expired does not act on block taken by another execution.
+type owners struct {
+	// seq is the first field to be aligned for atomic operations
+	seq      uint64
+	instance string
+}
+
+func (o *owners) next() string {
+	return fmt.Sprintf("%v-%v", o.instance, atomic.AddUint64(&o.seq, 1))
+}
+
+// newOwners creates owners with random instance prefix, so owners of instances sharing block store do not match.
+func newOwners() *owners {
+	return &owners{instance: newInstanceOwner()}
+}
+
+func newInstanceOwner() string {
+	b := make([]byte, 16)
+	// error is possible only if system random source is broken, time based owner is unique enough then
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,292 @@
+package blocker
+
+import (
+	"errors"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedisBlocker blocks tasks by scope and fingerprint in Redis.
+// Blocks are shared by all webhooker instances using the same Redis.
+// Value of block key is prefixed with owner of execution holding the block,
+// so execution does not renew, rewrite or release block set by another execution
+// of this or another instance after its lease expired.
+type RedisBlocker struct {
+	client    redisClient
+	keyPrefix string
+	owners    *owners
+	nowFunc   func() time.Time
+}
+
+const redisScanCount = "100"
+
+// Scripts check owner prefix of block value (ARGV[1]) before acting on block key (KEYS[1]).
+const (
+	redisOwnedCheck = `local value = redis.call("GET", KEYS[1])
+local owned = value and string.sub(value, 1, string.len(ARGV[1])) == ARGV[1]
+`
+
+	// redisRenewScript prolongs block for ARGV[2] milliseconds if it is owned.
+	redisRenewScript = redisOwnedCheck + `if owned then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`
+
+	// redisUnblockScript deletes block if it is owned.
+	redisUnblockScript = redisOwnedCheck + `if owned then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`
+
+	// redisBlockForTTLScript sets block to ARGV[2] for ARGV[3] milliseconds if it does not exist or is owned.
+	redisBlockForTTLScript = redisOwnedCheck + `if value and not owned then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+return 1`
+)
+
+// BlockInProgress blocks task by scope and fingeprint while executing.
+// Block is set only if it does not exist, so only one instance executes task.
+// It returns owner of the block or empty owner if task is already blocked.
+// Block expires after lease unless it is renewed. Block does not expire if lease is not positive.
+func (b *RedisBlocker) BlockInProgress(task executor.Task, lease time.Duration) (owner string, err error) {
+	owner = b.owners.next()
+	entry := newEntry(task, true, lease, b.nowFunc())
+	args := []string{"SET", b.key(entry.Scope, entry.Fingerprint), value(owner, entry), "NX"}
+	if ms := milliseconds(lease); ms > 0 {
+		args = append(args, "PX", strconv.FormatInt(ms, 10))
+	}
+
+	reply, err := b.client.Do(args...)
+	if err != nil {
+		return "", err
+	}
+
+	// null reply means key already exists
+	if reply == nil {
+		return "", nil
+	}
+
+	return owner, nil
+}
+
+// Renew prolongs in-progress block by scope and fingeprint for lease.
+// Block is not set again if it has already expired or is owned by another execution.
+// Expiration of entry is got from key TTL, so entry is not rewritten.
+func (b *RedisBlocker) Renew(scope, fingerprint, owner string, lease time.Duration) (renewed bool, err error) {
+	reply, err := b.eval(redisRenewScript, b.key(scope, fingerprint), owner, strconv.FormatInt(milliseconds(lease), 10))
+	if err != nil {
+		return false, err
+	}
+
+	// zero reply means key does not exist or is not owned
+	return reply == int64(1), nil
+}
+
+// BlockForTTL blocks task by scope and fingeprint for needed TTL.
+// Block owned by another execution is not rewritten.
+func (b *RedisBlocker) BlockForTTL(task executor.Task, owner string, ttl time.Duration) error {
+	entry := newEntry(task, false, ttl, b.nowFunc())
+	key := b.key(entry.Scope, entry.Fingerprint)
+
+	ms := milliseconds(ttl)
+	if ms <= 0 {
+		_, err := b.eval(redisUnblockScript, key, owner)
+		return err
+	}
+
+	reply, err := b.eval(redisBlockForTTLScript, key, owner, value(owner, entry), strconv.FormatInt(ms, 10))
+	if err != nil {
+		return err
+	}
+
+	if reply != int64(1) {
+		return errBlockNotOwned
+	}
+
+	return nil
+}
+
+// Unblock unblocks task by scope and fingeprint.
+// Block owned by another execution is not released.
+func (b *RedisBlocker) Unblock(scope, fingerprint, owner string) {
+	_, _ = b.eval(redisUnblockScript, b.key(scope, fingerprint), owner)
+}
+
+// Delete unblocks task by scope and fingeprint and reports whether block existed.
+func (b *RedisBlocker) Delete(scope, fingerprint string) (deleted bool, err error) {
+	return b.del(b.key(scope, fingerprint))
+}
+
+// DeleteRule unblocks all tasks of rule.
+func (b *RedisBlocker) DeleteRule(rule string) (deleted int, err error) {
+	entries, err := b.Blocks()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		if entry.Rule != rule {
+			continue
+		}
+
+		ok, err := b.del(b.key(entry.Scope, entry.Fingerprint))
+		if err != nil {
+			return deleted, err
+		}
+
+		if ok {
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// Blocks returns all not expired blocks sorted by scope and fingerprint.
+// Expiration is got from key TTL.
+func (b *RedisBlocker) Blocks() (Entries, error) {
+	keys, err := b.keys()
+	if err != nil {
+		return nil, err
+	}
+
+	now := b.nowFunc()
+	entries := make(Entries, 0, len(keys))
+	for _, key := range keys {
+		value, err := b.client.Do("GET", key)
+		if err != nil {
+			return nil, err
+		}
+
+		s, ok := value.(string)
+		if !ok {
+			// expired after scan
+			continue
+		}
+
+		entry, err := decodeEntry([]byte(b.entryValue(s)))
+		if err != nil {
+			// value of block set before entries were introduced
+			continue
+		}
+
+		ttl, err := b.client.Do("PTTL", key)
+		if err != nil {
+			return nil, err
+		}
+
+		ms, _ := ttl.(int64)
+		switch {
+		case ms == -2:
+			// expired after getting value
+			continue
+		case ms < 0:
+			entry.ExpiresAt = nil
+		default:
+			entry.ExpiresAt = expiresAt(time.Duration(ms)*time.Millisecond, now)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	entries.sort()
+	return entries, nil
+}
+
+// keys returns all block keys using SCAN, so Redis is not blocked by long command.
+func (b *RedisBlocker) keys() (keys []string, err error) {
+	cursor := "0"
+	for {
+		reply, err := b.client.Do("SCAN", cursor, "MATCH", escapeRedisPattern(b.keyPrefix)+"*", "COUNT", redisScanCount)
+		if err != nil {
+			return nil, err
+		}
+
+		array, ok := reply.([]interface{})
+		if !ok || len(array) != 2 {
+			return nil, errUnexpectedRedisReply
+		}
+
+		cursor, _ = array[0].(string)
+		batch, _ := array[1].([]interface{})
+		for _, key := range batch {
+			if s, ok := key.(string); ok {
+				keys = append(keys, s)
+			}
+		}
+
+		if cursor == "0" || len(cursor) == 0 {
+			return keys, nil
+		}
+	}
+}
+
+func (b *RedisBlocker) del(key string) (deleted bool, err error) {
+	reply, err := b.client.Do("DEL", key)
+	if err != nil {
+		return false, err
+	}
+
+	return reply == int64(1), nil
+}
+
+func (b *RedisBlocker) key(scope, fingerprint string) string {
+	return b.keyPrefix + string(getBlockKey(scope, fingerprint))
+}
+
+// eval executes script on key with owner prefix and args as ARGV.
+func (b *RedisBlocker) eval(script, key, owner string, args ...string) (reply interface{}, err error) {
+	return b.client.Do(append([]string{"EVAL", script, "1", key, ownerPrefix(owner)}, args...)...)
+}
+
+// entryValue returns entry of block value without owner prefix.
+// Value without owner prefix is set before owners were introduced.
+func (b *RedisBlocker) entryValue(value string) string {
+	if i := strings.IndexByte(value, ' '); i >= 0 && !strings.HasPrefix(value, "{") {
+		return value[i+1:]
+	}
+
+	return value
+}
+
+// value returns block value: entry prefixed with owner.
+func value(owner string, entry Entry) string {
+	return ownerPrefix(owner) + string(entry.encode())
+}
+
+func ownerPrefix(owner string) string {
+	return owner + " "
+}
+
+var errUnexpectedRedisReply = errors.New("unexpected redis scan reply")
+
+// escapeRedisPattern escapes glob-style pattern special characters.
+func escapeRedisPattern(s string) string {
+	return redisPatternReplacer.Replace(s)
+}
+
+var redisPatternReplacer = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+func milliseconds(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
+// NewRedis creates RedisBlocker instance with random owners. All keys are prefixed with keyPrefix.
+func NewRedis(client redisClient, keyPrefix string, nowFunc func() time.Time) *RedisBlocker {
+	return &RedisBlocker{
+		client:    client,
+		keyPrefix: keyPrefix,
+		owners:    newOwners(),
+		nowFunc:   nowFunc,
+	}
+}
+
+//go:generate mockgen -source=redis.go -destination=redis_mocks.go -package=blocker doc github.com/golang/mock/gomock
+
+type redisClient interface {
+	Do(args ...string) (reply interface{}, err error)
+}
@@ -0,0 +1,384 @@
+package blocker
+
+import (
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/krpn/prometheus-alert-webhooker/redisclient"
+	"github.com/krpn/prometheus-alert-webhooker/redisclient/redistest"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRedisTestServer starts Redis stand-in with emulation of blocker scripts.
+func newRedisTestServer(t *testing.T, password string, nowFunc func() time.Time) *redistest.Server {
+	server, err := redistest.NewServer(password, nowFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owned := func(data redistest.Data, key, owner string) (exists, owned bool) {
+		value, ok := data.Get(key)
+		return ok, ok && strings.HasPrefix(value, owner)
+	}
+
+	server.Script(redisRenewScript, func(data redistest.Data, keys, args []string) int64 {
+		ms, _ := strconv.ParseInt(args[1], 10, 64)
+		if _, ok := owned(data, keys[0], args[0]); ok && data.PExpire(keys[0], time.Duration(ms)*time.Millisecond) {
+			return 1
+		}
+		return 0
+	})
+	server.Script(redisUnblockScript, func(data redistest.Data, keys, args []string) int64 {
+		if _, ok := owned(data, keys[0], args[0]); ok && data.Del(keys[0]) {
+			return 1
+		}
+		return 0
+	})
+	server.Script(redisBlockForTTLScript, func(data redistest.Data, keys, args []string) int64 {
+		if exists, ok := owned(data, keys[0], args[0]); exists && !ok {
+			return 0
+		}
+		ms, _ := strconv.ParseInt(args[2], 10, 64)
+		data.Set(keys[0], args[1], time.Duration(ms)*time.Millisecond)
+		return 1
+	})
+
+	return server
+}
+
+func TestRedisBlocker_BlockInProgress(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := NewMockredisClient(ctrl)
+	blocker := NewRedis(client, "webhooker:", testNowFunc)
+	blocker.owners = &owners{instance: "owner1"}
+	task := newTestTask(ctrl, "testrule1", "test")
+	value := func(owner string) string {
+		return owner + " " + string(testEntry("testrule1", "test", true, time.Minute).encode())
+	}
+
+	type testTableData struct {
+		tcase         string
+		expectFunc    func(m *MockredisClient)
+		expectedOwner string
+		expectedErr   error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "blocked successfully",
+			expectFunc: func(m *MockredisClient) {
+				m.EXPECT().Do("SET", "webhooker:jenkins_test", value("owner1-1"), "NX", "PX", "60000").Return("OK", nil)
+			},
+			expectedOwner: "owner1-1",
+			expectedErr:   nil,
+		},
+		{
+			tcase: "already blocked",
+			expectFunc: func(m *MockredisClient) {
+				m.EXPECT().Do("SET", "webhooker:jenkins_test", value("owner1-2"), "NX", "PX", "60000").Return(nil, nil)
+			},
+			expectedOwner: "",
+			expectedErr:   nil,
+		},
+		{
+			tcase: "error",
+			expectFunc: func(m *MockredisClient) {
+				m.EXPECT().Do("SET", "webhooker:jenkins_test", value("owner1-3"), "NX", "PX", "60000").Return(nil, errors.New("connection refused"))
+			},
+			expectedOwner: "",
+			expectedErr:   errors.New("connection refused"),
+		},
+	}
+
+	for _, testUnit := range testTable {
+		testUnit.expectFunc(client)
+		owner, err := blocker.BlockInProgress(task, time.Minute)
+		assert.Equal(t, testUnit.expectedOwner, owner, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
+	}
+}
+
+func TestRedisBlocker_Renew(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := NewMockredisClient(ctrl)
+	blocker := NewRedis(client, "", testNowFunc)
+
+	type testTableData struct {
+		tcase           string
+		expectFunc      func(m *MockredisClient)
+		expectedRenewed bool
+		expectedErr     error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "renewed",
+			expectFunc: func(m *MockredisClient) {
+				m.EXPECT().Do("EVAL", redisRenewScript, "1", "jenkins_test", "owner1 ", "30000").Return(int64(1), nil)
+			},
+			expectedRenewed: true,
+			expectedErr:     nil,
+		},
+		{
+			tcase: "expired or owned by another execution",
+			expectFunc: func(m *MockredisClient) {
+				m.EXPECT().Do("EVAL", redisRenewScript, "1", "jenkins_test", "owner1 ", "30000").Return(int64(0), nil)
+			},
+			expectedRenewed: false,
+			expectedErr:     nil,
+		},
+		{
+			tcase: "error",
+			expectFunc: func(m *MockredisClient) {
+				m.EXPECT().Do("EVAL", redisRenewScript, "1", "jenkins_test", "owner1 ", "30000").Return(nil, errors.New("some error"))
+			},
+			expectedRenewed: false,
+			expectedErr:     errors.New("some error"),
+		},
+	}
+
+	for _, testUnit := range testTable {
+		testUnit.expectFunc(client)
+		renewed, err := blocker.Renew("jenkins", "test", "owner1", 30*time.Second)
+		assert.Equal(t, testUnit.expectedRenewed, renewed, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
+	}
+}
+
+func TestRedisBlocker_BlockForTTL(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := NewMockredisClient(ctrl)
+	blocker := NewRedis(client, "", testNowFunc)
+	task := newTestTask(ctrl, "testrule1", "test")
+	value := "owner1 " + string(testEntry("testrule1", "test", false, 10*time.Second).encode())
+
+	type testTableData struct {
+		tcase       string
+		ttl         time.Duration
+		expectFunc  func(m *MockredisClient)
+		expectedErr error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "success",
+			ttl:   10 * time.Second,
+			expectFunc: func(m *MockredisClient) {
+				m.EXPECT().Do("EVAL", redisBlockForTTLScript, "1", "jenkins_test", "owner1 ", value, "10000").Return(int64(1), nil)
+			},
+			expectedErr: nil,
+		},
+		{
+			tcase: "owned by another execution",
+			ttl:   10 * time.Second,
+			expectFunc: func(m *MockredisClient) {
+				m.EXPECT().Do("EVAL", redisBlockForTTLScript, "1", "jenkins_test", "owner1 ", value, "10000").Return(int64(0), nil)
+			},
+			expectedErr: errBlockNotOwned,
+		},
+		{
+			tcase: "zero ttl",
+			ttl:   0,
+			expectFunc: func(m *MockredisClient) {
+				m.EXPECT().Do("EVAL", redisUnblockScript, "1", "jenkins_test", "owner1 ").Return(int64(1), nil)
+			},
+			expectedErr: nil,
+		},
+		{
+			tcase: "error",
+			ttl:   10 * time.Second,
+			expectFunc: func(m *MockredisClient) {
+				m.EXPECT().Do("EVAL", redisBlockForTTLScript, "1", "jenkins_test", "owner1 ", value, "10000").Return(nil, errors.New("some error"))
+			},
+			expectedErr: errors.New("some error"),
+		},
+	}
+
+	for _, testUnit := range testTable {
+		testUnit.expectFunc(client)
+		err := blocker.BlockForTTL(task, "owner1", testUnit.ttl)
+		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
+	}
+}
+
+func TestRedisBlocker_Replicas(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1535086351, 0)
+	server := newRedisTestServer(t, "secret", func() time.Time { return now })
+	defer server.Close()
+
+	client1 := redisclient.New(server.Addr(), "secret", 0, time.Second)
+	defer client1.Close()
+	client2 := redisclient.New(server.Addr(), "secret", 0, time.Second)
+	defer client2.Close()
+
+	nowFunc := func() time.Time { return now }
+	replica1, replica2 := NewRedis(client1, "webhooker:", nowFunc), NewRedis(client2, "webhooker:", nowFunc)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	task := newTestTask(ctrl, "testrule1", "test")
+
+	owner1, err := replica1.BlockInProgress(task, time.Minute)
+	assert.NotEqual(t, "", owner1)
+	assert.Equal(t, nil, err)
+
+	owner, err := replica2.BlockInProgress(task, time.Minute)
+	assert.Equal(t, "", owner)
+	assert.Equal(t, nil, err)
+
+	now = now.Add(50 * time.Second)
+	renewed, err := replica1.Renew("jenkins", "test", owner1, time.Minute)
+	assert.Equal(t, true, renewed)
+	assert.Equal(t, nil, err)
+
+	// lease is renewed
+	now = now.Add(50 * time.Second)
+	owner, err = replica2.BlockInProgress(task, time.Minute)
+	assert.Equal(t, "", owner)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, nil, replica1.BlockForTTL(task, owner1, time.Minute))
+	assert.Equal(t, []string{"webhooker:jenkins_test"}, server.Keys())
+
+	owner, err = replica2.BlockInProgress(task, time.Minute)
+	assert.Equal(t, "", owner)
+	assert.Equal(t, nil, err)
+
+	now = now.Add(time.Minute)
+	owner2, err := replica2.BlockInProgress(task, time.Minute)
+	assert.NotEqual(t, "", owner2)
+	assert.Equal(t, nil, err)
+
+	// lease expires if not renewed
+	now = now.Add(time.Minute)
+	renewed, err = replica2.Renew("jenkins", "test", owner2, time.Minute)
+	assert.Equal(t, false, renewed)
+	assert.Equal(t, nil, err)
+
+	owner2, err = replica2.BlockInProgress(task, time.Minute)
+	assert.NotEqual(t, "", owner2)
+	assert.Equal(t, nil, err)
+
+	// replica with expired lease does not touch block of another replica
+	now = now.Add(time.Minute)
+	owner1, err = replica1.BlockInProgress(task, time.Minute)
+	assert.NotEqual(t, "", owner1)
+	assert.Equal(t, nil, err)
+
+	renewed, err = replica2.Renew("jenkins", "test", owner2, time.Minute)
+	assert.Equal(t, false, renewed)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, errBlockNotOwned, replica2.BlockForTTL(task, owner2, time.Hour))
+	replica2.Unblock("jenkins", "test", owner2)
+
+	entries, err := replica2.Blocks()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, true, entries[0].InProgress)
+	assert.Equal(t, time.Minute, entries[0].ExpiresAt.Sub(now))
+
+	// execution with expired lease does not touch block of another execution of the same replica
+	now = now.Add(time.Minute)
+	owner, err = replica1.BlockInProgress(task, time.Minute)
+	assert.NotEqual(t, owner1, owner)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, errBlockNotOwned, replica1.BlockForTTL(task, owner1, time.Hour))
+	replica1.Unblock("jenkins", "test", owner1)
+	assert.Equal(t, []string{"webhooker:jenkins_test"}, server.Keys())
+
+	replica1.Unblock("jenkins", "test", owner)
+	assert.Equal(t, []string{}, server.Keys())
+}
+
+func TestRedisBlocker_Blocks(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := newRedisTestServer(t, "", testNowFunc)
+	defer server.Close()
+
+	client := redisclient.New(server.Addr(), "", 0, time.Second)
+	defer client.Close()
+
+	blocker := NewRedis(client, "webhooker*:", testNowFunc)
+	other := NewRedis(client, "other:", testNowFunc)
+
+	_, err := blocker.BlockInProgress(newTestTask(ctrl, "testrule1", "fp2"), 0)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, blocker.BlockForTTL(newTestTask(ctrl, "testrule1", "fp1"), "owner1", time.Hour))
+	assert.Equal(t, nil, blocker.BlockForTTL(newTestTask(ctrl, "testrule2", "fp3"), "owner1", time.Hour))
+	assert.Equal(t, nil, other.BlockForTTL(newTestTask(ctrl, "testrule1", "fp4"), "owner1", time.Hour))
+
+	// lease renew changes expiration
+	owner, err := blocker.BlockInProgress(newTestTask(ctrl, "testrule3", "fp5"), time.Minute)
+	assert.Equal(t, nil, err)
+	_, err = blocker.Renew("jenkins", "fp5", owner, time.Hour)
+	assert.Equal(t, nil, err)
+	renewedEntry := testEntry("testrule3", "fp5", true, time.Hour)
+
+	entries, err := blocker.Blocks()
+	assert.Equal(t, Entries{
+		testEntry("testrule1", "fp1", false, time.Hour),
+		testEntry("testrule1", "fp2", true, 0),
+		testEntry("testrule2", "fp3", false, time.Hour),
+		renewedEntry,
+	}, entries)
+	assert.Equal(t, nil, err)
+
+	deleted, err := blocker.DeleteRule("testrule1")
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, nil, err)
+
+	ok, err := blocker.Delete("jenkins", "fp3")
+	assert.Equal(t, true, ok)
+	assert.Equal(t, nil, err)
+
+	ok, err = blocker.Delete("jenkins", "fp3")
+	assert.Equal(t, false, ok)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, []string{"other:jenkins_fp4", "webhooker*:jenkins_fp5"}, server.Keys())
+}
+
+func TestRedisBlocker_Blocks_Error(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := NewMockredisClient(ctrl)
+	blocker := NewRedis(client, "", testNowFunc)
+
+	client.EXPECT().Do("SCAN", "0", "MATCH", "*", "COUNT", redisScanCount).Return(nil, errors.New("some error"))
+	_, err := blocker.Blocks()
+	assert.Equal(t, errors.New("some error"), err)
+
+	client.EXPECT().Do("SCAN", "0", "MATCH", "*", "COUNT", redisScanCount).Return("OK", nil)
+	_, err = blocker.DeleteRule("testrule1")
+	assert.Equal(t, errUnexpectedRedisReply, err)
+}
+
+func Test_escapeRedisPattern(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, `webhooker\*\?\[a\]\\:`, escapeRedisPattern(`webhooker*?[a]\:`))
+}
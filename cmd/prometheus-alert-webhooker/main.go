@@ -3,15 +3,24 @@ package main
 import (
 	"github.com/alecthomas/kingpin"
 	"github.com/coocood/freecache"
+	"github.com/krpn/prometheus-alert-webhooker/api"
 	blc "github.com/krpn/prometheus-alert-webhooker/blocker"
 	cfg "github.com/krpn/prometheus-alert-webhooker/config"
+	"github.com/krpn/prometheus-alert-webhooker/cooldown"
 	"github.com/krpn/prometheus-alert-webhooker/executor"
 	"github.com/krpn/prometheus-alert-webhooker/executor/http"
 	"github.com/krpn/prometheus-alert-webhooker/executor/jenkins"
 	"github.com/krpn/prometheus-alert-webhooker/executor/shell"
+	"github.com/krpn/prometheus-alert-webhooker/executor/slack"
 	"github.com/krpn/prometheus-alert-webhooker/executor/telegram"
+	"github.com/krpn/prometheus-alert-webhooker/filecache"
+	"github.com/krpn/prometheus-alert-webhooker/flapping"
+	"github.com/krpn/prometheus-alert-webhooker/history"
+	lmtr "github.com/krpn/prometheus-alert-webhooker/limiter"
 	mtrc "github.com/krpn/prometheus-alert-webhooker/metric"
 	"github.com/krpn/prometheus-alert-webhooker/model"
+	"github.com/krpn/prometheus-alert-webhooker/queue"
+	"github.com/krpn/prometheus-alert-webhooker/redisclient"
 	"github.com/krpn/prometheus-alert-webhooker/runner"
 	"github.com/krpn/prometheus-alert-webhooker/webhook"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -20,7 +29,11 @@ import (
 	_ "github.com/spf13/viper/remote"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"os/exec"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -29,18 +42,26 @@ var (
 	configProvider = kingpin.Flag("provider", "Config provider: file, etcd, consul").Default(cfg.ProviderFile).Short('p').String()
 	configPath     = kingpin.Flag("config", "Path to config file with extension, can be link for etcd, consul providers").Default("config/config.yaml").Short('c').String()
 	verbose        = kingpin.Flag("verbose", "Enable verbose logging").Default("false").Short('v').Bool()
+	apiListenAddr  = kingpin.Flag("api-listen", "HTTP port to listen on for admin API, admin API is served on webhook port with api-token only if not set").String()
+	apiToken       = kingpin.Flag("api-token", "Bearer token required by admin API").Envar("WEBHOOKER_API_TOKEN").String()
+
+	approvals = telegram.NewApprovals()
 
 	taskExecutors = map[string]executor.TaskExecutor{
-		"shell":    shell.NewExecutor(exec.Command),
-		"jenkins":  jenkins.NewExecutor(),
-		"telegram": telegram.NewExecutor(&http.Client{}),
-		"http": httpe.NewExecutor(func(timeout time.Duration) httpe.Doer {
-			return &http.Client{Timeout: timeout}
-		}),
+		"shell":             shell.NewExecutor(exec.Command),
+		"jenkins":           jenkins.NewExecutor(),
+		"telegram":          telegram.NewExecutor(&http.Client{}),
+		"telegram_approval": telegram.NewApprovalExecutor(&http.Client{}, approvals),
+		"http":              httpe.NewExecutor(httpe.NewClient),
+		"slack":             slack.NewExecutor(&http.Client{Timeout: 10 * time.Second}, slack.DefaultAPIURL),
 	}
 )
 
 const context = "startup"
+const shutdownContext = "shutdown"
+
+// abandonTimeout is how long executing tasks are waited for after grace period before stores are closed.
+const abandonTimeout = 10 * time.Second
 const realRun = 0
 
 func main() {
@@ -58,11 +79,14 @@ func main() {
 			"listenAddr":     listenAddr,
 			"configProvider": configProvider,
 			"configPath":     configPath,
+			"apiListenAddr":  apiListenAddr,
 		},
 	})
 
 	ctxLogger.Debug("starting up service, prepare config")
 
+	// refreshed configs are applied after all components are created
+	refreshed := make(chan *cfg.Config, 1)
 	config, err := cfg.New(
 		ioutil.ReadFile,
 		viper.New(),
@@ -70,6 +94,7 @@ func main() {
 		*configPath,
 		logger,
 		taskExecutors,
+		func(config *cfg.Config) { refreshed <- config },
 		realRun,
 	)
 	if err != nil {
@@ -79,21 +104,195 @@ func main() {
 	ctxLogger = ctxLogger.WithField("config", config)
 	ctxLogger.Debug("config prepared")
 
+	// closers are called on shutdown to flush and close stores
+	var closers []func() error
+
+	var blocker taskBlocker
+	switch config.BlockStore {
+	case cfg.BlockStoreFile:
+		cache, err := filecache.New(config.BlockStorePath, logger, time.Now)
+		if err != nil {
+			ctxLogger.Fatalf("create block store error: %v", err)
+		}
+		blocker = blc.New(cache, time.Now)
+		closers = append(closers, cache.Close)
+	case cfg.BlockStoreRedis:
+		redisSettings := config.BlockStoreRedis
+		client := redisclient.New(redisSettings.Address, redisSettings.Password, redisSettings.DB, redisSettings.Timeout)
+		blocker = blc.NewRedis(client, redisSettings.KeyPrefix, time.Now)
+		closers = append(closers, func() error {
+			client.Close()
+			return nil
+		})
+	default:
+		blocker = blc.New(blc.FreeCache{Cache: freecache.NewCache(config.BlockCacheSize)}, time.Now)
+	}
+
+	var tasksQueue taskQueue
+	if config.TaskQueue != nil {
+		tasksQueue, err = queue.New(config.TaskQueue.Path)
+		if err != nil {
+			ctxLogger.Fatalf("create task queue error: %v", err)
+		}
+		closers = append(closers, tasksQueue.Close)
+	}
+
+	var executions executionHistory = history.NewRing(config.ExecutionHistory.Size)
+	if len(config.ExecutionHistory.SQLitePath) > 0 {
+		sqlite, err := history.NewSQLite(config.ExecutionHistory.SQLitePath, config.ExecutionHistory.Size)
+		if err != nil {
+			ctxLogger.Fatalf("create execution history error: %v", err)
+		}
+		executions = sqlite
+		closers = append(closers, sqlite.Close)
+	}
+
 	var (
-		tasksCh = make(chan model.Tasks, config.PoolSize)
-		blocker = blc.New(freecache.NewCache(config.BlockCacheSize))
-		metric  = mtrc.New()
+		tasksCh   = make(chan model.QueuedTasks, config.PoolSize)
+		limiter   = lmtr.New(config.Rules, config.ExecutorRateLimits, config.CircuitBreaker, time.Now)
+		cooldowns = cooldown.New()
+		flapper   = flapping.New(config.Flapping)
+		metric    = mtrc.New(func() int { return cooldowns.CooldownQty(time.Now()) })
+		rules     = &atomic.Value{}
+		settings  = runner.NewSettings(config.Rules, config.ExecutorConcurrency)
 	)
 
+	rules.Store(config.Rules)
+	go func() {
+		for config := range refreshed {
+			rules.Store(config.Rules)
+			limiter.Update(config.Rules, config.ExecutorRateLimits, config.CircuitBreaker)
+			flapper.Update(config.Flapping)
+			settings.Update(config.Rules, config.ExecutorConcurrency)
+		}
+	}()
+
 	// runner
 	ctxLogger.Debug("starting up runners")
-	go runner.Start(config.Runners, tasksCh, blocker, metric, logger, time.Now)
+	stop, drained, finished := make(chan struct{}), make(chan struct{}), true
+	go func() {
+		finished = runner.Start(config.Runners, tasksCh, stop, abandonTimeout, settings, tasksQueue, executions, blocker, limiter, cooldowns, config.BlockLease, metric, logger, time.Now)
+		close(drained)
+	}()
+
+	if tasksQueue != nil {
+		ctxLogger.Debug("recovering queued tasks")
+		webhook.Recover(tasksQueue, config.Rules, config.TaskQueue.Expire, tasksCh, logger, time.Now)
+	}
 
 	// HTTP
 	ctxLogger.Debug("starting up wehbook")
 	http.Handle("/metrics", promhttp.Handler())
-	http.HandleFunc("/webhooker", func(_ http.ResponseWriter, r *http.Request) {
-		webhook.Webhook(r, config.Rules, tasksCh, metric, logger, time.Now)
+
+	// admin API releases blocks and shows task details, so it is not served on public webhook port without token
+	var apiServer *http.Server
+	apiMux := http.DefaultServeMux
+	if len(*apiListenAddr) > 0 {
+		apiMux = http.NewServeMux()
+		apiServer = &http.Server{Addr: *apiListenAddr, Handler: apiMux}
+	}
+	if apiServer != nil || len(*apiToken) > 0 {
+		blocksHandler := api.Auth(*apiToken, func(w http.ResponseWriter, r *http.Request) {
+			api.Blocks(w, r, blocker, logger)
+		})
+		apiMux.HandleFunc(api.BlocksPath, blocksHandler)
+		apiMux.HandleFunc(api.BlocksPath+"/", blocksHandler)
+		apiMux.HandleFunc(api.ExecutionsPath, api.Auth(*apiToken, func(w http.ResponseWriter, r *http.Request) {
+			api.Executions(w, r, executions, logger)
+		}))
+	} else {
+		ctxLogger.Warn("admin API is disabled, set api-token or api-listen flag to enable it")
+	}
+
+	http.HandleFunc(telegram.CallbackPath, func(w http.ResponseWriter, r *http.Request) {
+		approvals.Callback(w, r, logger)
 	})
-	ctxLogger.Fatalf("http server startup error: %v", http.ListenAndServe(*listenAddr, nil))
+	drainer := webhook.NewDrainer()
+	http.HandleFunc("/webhooker", drainer.Handler(func(w http.ResponseWriter, r *http.Request) {
+		webhook.Webhook(w, r, rules.Load().(model.Rules), tasksCh, tasksQueue, flapper, metric, logger, time.Now)
+	}))
+
+	server := &http.Server{Addr: *listenAddr}
+	go func() {
+		err := server.ListenAndServe()
+		if err != http.ErrServerClosed {
+			ctxLogger.Fatalf("http server startup error: %v", err)
+		}
+	}()
+
+	if apiServer != nil {
+		go func() {
+			err := apiServer.ListenAndServe()
+			if err != http.ErrServerClosed {
+				ctxLogger.Fatalf("api http server startup error: %v", err)
+			}
+		}()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+
+	shutdownLogger := logger.WithField("context", shutdownContext)
+	shutdownLogger.Infof("got %v signal, draining tasks", sig)
+
+	// new payloads are rejected, Alertmanager retries them
+	drainer.Drain()
+	close(tasksCh)
+
+	select {
+	case <-drained:
+		shutdownLogger.Info("all tasks are executed")
+	case <-time.After(config.ShutdownGracePeriod):
+		shutdownLogger.Warnf("tasks are not executed within grace period %v, stopping runners", config.ShutdownGracePeriod)
+		close(stop)
+		<-drained
+		if !finished {
+			shutdownLogger.Warnf("tasks are still executing after %v, they are abandoned and their results are not recorded", abandonTimeout)
+		}
+	}
+
+	err = server.Close()
+	if err != nil {
+		shutdownLogger.Errorf("http server close error: %v", err)
+	}
+
+	if apiServer != nil {
+		err = apiServer.Close()
+		if err != nil {
+			shutdownLogger.Errorf("api http server close error: %v", err)
+		}
+	}
+
+	for _, closer := range closers {
+		err = closer()
+		if err != nil {
+			shutdownLogger.Errorf("store close error: %v", err)
+		}
+	}
+
+	shutdownLogger.Info("shutdown is completed")
+}
+
+type taskBlocker interface {
+	BlockInProgress(task executor.Task, lease time.Duration) (owner string, err error)
+	Renew(scope, fingerprint, owner string, lease time.Duration) (renewed bool, err error)
+	BlockForTTL(task executor.Task, owner string, ttl time.Duration) error
+	Unblock(scope, fingerprint, owner string)
+	Blocks() (blc.Entries, error)
+	Delete(scope, fingerprint string) (deleted bool, err error)
+	DeleteRule(rule string) (deleted int, err error)
+}
+
+type taskQueue interface {
+	Append(eventID string, receivedAt time.Time, alerts model.Alerts, groups []string) (id string, err error)
+	Done(id string, group int) error
+	Drop(id string) error
+	Pending() []queue.Record
+	Close() error
+}
+
+type executionHistory interface {
+	Record(execution history.Execution) error
+	Executions(filter history.Filter) ([]history.Execution, error)
 }
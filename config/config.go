@@ -2,8 +2,8 @@ package config
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"github.com/jinzhu/copier"
 	"github.com/krpn/prometheus-alert-webhooker/executor"
 	"github.com/krpn/prometheus-alert-webhooker/model"
 	"github.com/krpn/prometheus-alert-webhooker/utils"
@@ -11,6 +11,7 @@ import (
 	"github.com/spf13/viper"
 	"io"
 	"reflect"
+	"strings"
 	"time"
 )
 
@@ -18,8 +19,19 @@ import (
 // It contains common settings and rules.
 type Config struct {
 	BlockCacheSize              int                               `mapstructure:"block_cache_size"`
+	BlockStore                  string                            `mapstructure:"block_store"`
+	BlockStorePath              string                            `mapstructure:"block_store_path"`
+	BlockStoreRedis             *RedisSettings                    `mapstructure:"block_store_redis"`
+	BlockLease                  model.Lease                       `mapstructure:"block_lease"`
 	PoolSize                    int                               `mapstructure:"pool_size"`
 	Runners                     int                               `mapstructure:"runners"`
+	TaskQueue                   *model.TaskQueue                  `mapstructure:"task_queue"`
+	ShutdownGracePeriod         time.Duration                     `mapstructure:"shutdown_grace_period"`
+	ExecutionHistory            model.ExecutionHistory            `mapstructure:"execution_history"`
+	ExecutorRateLimits          map[string]model.RateLimit        `mapstructure:"executor_rate_limits"`
+	ExecutorConcurrency         map[string]int                    `mapstructure:"executor_concurrency"`
+	CircuitBreaker              *model.CircuitBreaker             `mapstructure:"circuit_breaker"`
+	Flapping                    *model.Flapping                   `mapstructure:"flapping"`
 	RemoteConfigRefreshInterval time.Duration                     `mapstructure:"remote_config_refresh_interval"`
 	CommonParameters            map[string]map[string]interface{} `mapstructure:"common_parameters"`
 	Rules                       model.Rules                       `mapstructure:"rules"`
@@ -31,19 +43,49 @@ const (
 	defaultPoolSize       = 100
 	defaultRunners        = 10
 
+	defaultShutdownGracePeriod = 30 * time.Second
+
 	// ProviderFile constant represents correct string value of program parameter.
 	ProviderFile = "file"
 
+	// BlockStoreMemory is a block store keeping blocks in memory.
+	BlockStoreMemory = "memory"
+
+	// BlockStoreFile is a block store keeping blocks in memory and persisting them to file.
+	BlockStoreFile = "file"
+
+	// BlockStoreRedis is a block store keeping blocks in Redis shared by webhooker instances.
+	BlockStoreRedis = "redis"
+
+	defaultRedisTimeout = 5 * time.Second
+
 	context = "startup"
 )
 
+var (
+	errConfigValidateEmptyBlockStorePath  = errors.New("empty block store path")
+	errConfigValidateEmptyBlockStoreRedis = errors.New("empty block store redis address")
+)
+
+// RedisSettings represents settings of Redis connection.
+type RedisSettings struct {
+	Address   string        `mapstructure:"address"`
+	Password  string        `mapstructure:"password"`
+	DB        int           `mapstructure:"db"`
+	KeyPrefix string        `mapstructure:"key_prefix"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+}
+
 // New creates Config instance.
+// Remote config is refreshed in background, returned Config is not changed:
+// onChange is called with refreshed copy of config if it is changed.
 func New(
 	readFileFunc func(string) ([]byte, error),
 	configer configer,
 	provider, rawPath string,
 	logger *logrus.Logger,
 	taskExecutors map[string]executor.TaskExecutor,
+	onChange func(config *Config),
 	refreshIterations int,
 ) (*Config, error) {
 
@@ -70,8 +112,11 @@ func New(
 		return nil, err
 	}
 
+	warnUnreachableRules(conf.Rules, logger.WithField("context", context))
+
 	if conf.RemoteConfigRefreshInterval > 0 && provider != ProviderFile {
-		go refreshDaemon(conf, provider, path, configer, logger, taskExecutors, refreshIterations)
+		current := *conf
+		go refreshDaemon(&current, provider, path, configer, logger, taskExecutors, onChange, refreshIterations)
 	}
 
 	return conf, nil
@@ -104,7 +149,7 @@ func readConfig(readFileFunc func(string) ([]byte, error), configer configer, pr
 	return configer.ReadRemoteConfig()
 }
 
-func refreshDaemon(config *Config, provider, path string, configer configer, logger *logrus.Logger, taskExecutors map[string]executor.TaskExecutor, refreshIterations int) {
+func refreshDaemon(config *Config, provider, path string, configer configer, logger *logrus.Logger, taskExecutors map[string]executor.TaskExecutor, onChange func(config *Config), refreshIterations int) {
 	i := 1
 
 	ctxLogger := logger.WithFields(logrus.Fields{
@@ -134,6 +179,12 @@ func refreshDaemon(config *Config, provider, path string, configer configer, log
 		} else {
 			if changed {
 				ctxLogger.Info("successfully done refreshing config: config changed")
+				warnUnreachableRules(config.Rules, ctxLogger)
+				if onChange != nil {
+					// fields are replaced but not modified by refresh, so copy can be used concurrently
+					refreshed := *config
+					onChange(&refreshed)
+				}
 			} else {
 				ctxLogger.Debug("successfully done refreshing config: no changes")
 			}
@@ -165,8 +216,7 @@ func refresh(currConfig *Config, configer configer, taskExecutors map[string]exe
 	}
 
 	if !reflect.DeepEqual(newConfig.Rules, currConfig.Rules) {
-		// we can't replace config completely because handler and runners depends on rules pointer
-		err = copier.Copy(&currConfig.Rules, &newConfig.Rules)
+		currConfig.Rules = newConfig.Rules
 		changed = true
 	}
 
@@ -175,6 +225,26 @@ func refresh(currConfig *Config, configer configer, taskExecutors map[string]exe
 		changed = true
 	}
 
+	if !reflect.DeepEqual(currConfig.ExecutorRateLimits, newConfig.ExecutorRateLimits) {
+		currConfig.ExecutorRateLimits = newConfig.ExecutorRateLimits
+		changed = true
+	}
+
+	if !reflect.DeepEqual(currConfig.CircuitBreaker, newConfig.CircuitBreaker) {
+		currConfig.CircuitBreaker = newConfig.CircuitBreaker
+		changed = true
+	}
+
+	if !reflect.DeepEqual(currConfig.ExecutorConcurrency, newConfig.ExecutorConcurrency) {
+		currConfig.ExecutorConcurrency = newConfig.ExecutorConcurrency
+		changed = true
+	}
+
+	if !reflect.DeepEqual(currConfig.Flapping, newConfig.Flapping) {
+		currConfig.Flapping = newConfig.Flapping
+		changed = true
+	}
+
 	if currConfig.RemoteConfigRefreshInterval != newConfig.RemoteConfigRefreshInterval {
 		currConfig.RemoteConfigRefreshInterval = newConfig.RemoteConfigRefreshInterval
 		changed = true
@@ -183,12 +253,99 @@ func refresh(currConfig *Config, configer configer, taskExecutors map[string]exe
 	return
 }
 
+func warnUnreachableRules(rules model.Rules, logger *logrus.Entry) {
+	unreachable := rules.Unreachable()
+	for i, rule := range rules {
+		if shadowing, ok := unreachable[i]; ok {
+			logger.Warnf("rule %v is unreachable because of higher priority catch-all rule %v", rule.Name, shadowing)
+		}
+	}
+}
+
 func (c *Config) prepare(taskExecutors map[string]executor.TaskExecutor) (err error) {
 
 	// default values
 	c.fillDefaults()
 
-	return c.Rules.Prepare(c.CommonParameters, taskExecutors)
+	err = c.validateBlockStore()
+	if err != nil {
+		return
+	}
+
+	err = c.BlockLease.Prepare()
+	if err != nil {
+		return
+	}
+
+	if c.TaskQueue != nil {
+		err = c.TaskQueue.Prepare()
+		if err != nil {
+			return
+		}
+	}
+
+	err = c.ExecutionHistory.Prepare()
+	if err != nil {
+		return
+	}
+
+	err = c.Rules.Prepare(c.CommonParameters, taskExecutors)
+	if err != nil {
+		return
+	}
+
+	for executorName, limit := range c.ExecutorRateLimits {
+		if _, ok := taskExecutors[strings.ToLower(executorName)]; !ok {
+			return fmt.Errorf("executor %v rate limit: executor not found", executorName)
+		}
+
+		err = limit.Validate()
+		if err != nil {
+			return fmt.Errorf("executor %v rate limit: %v", executorName, err)
+		}
+	}
+
+	for executorName, limit := range c.ExecutorConcurrency {
+		if _, ok := taskExecutors[strings.ToLower(executorName)]; !ok {
+			return fmt.Errorf("executor %v concurrency: executor not found", executorName)
+		}
+
+		if limit <= 0 {
+			return fmt.Errorf("executor %v concurrency: should be positive", executorName)
+		}
+	}
+
+	if c.CircuitBreaker != nil {
+		err = c.CircuitBreaker.Prepare(c.CommonParameters, taskExecutors)
+		if err != nil {
+			return
+		}
+	}
+
+	if c.Flapping != nil {
+		return c.Flapping.Prepare()
+	}
+
+	return nil
+}
+
+func (c *Config) validateBlockStore() error {
+	switch c.BlockStore {
+	case BlockStoreMemory:
+		return nil
+	case BlockStoreFile:
+		if len(c.BlockStorePath) == 0 {
+			return errConfigValidateEmptyBlockStorePath
+		}
+		return nil
+	case BlockStoreRedis:
+		if c.BlockStoreRedis == nil || len(c.BlockStoreRedis.Address) == 0 {
+			return errConfigValidateEmptyBlockStoreRedis
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown block store %v", c.BlockStore)
+	}
 }
 
 func (c *Config) fillDefaults() {
@@ -196,6 +353,14 @@ func (c *Config) fillDefaults() {
 		c.BlockCacheSize = defaultBlockCacheSize
 	}
 
+	if len(c.BlockStore) == 0 {
+		c.BlockStore = BlockStoreMemory
+	}
+
+	if c.BlockStoreRedis != nil && c.BlockStoreRedis.Timeout <= 0 {
+		c.BlockStoreRedis.Timeout = defaultRedisTimeout
+	}
+
 	if c.PoolSize <= 0 {
 		c.PoolSize = defaultPoolSize
 	}
@@ -203,6 +368,10 @@ func (c *Config) fillDefaults() {
 	if c.Runners <= 0 {
 		c.Runners = defaultRunners
 	}
+
+	if c.ShutdownGracePeriod <= 0 {
+		c.ShutdownGracePeriod = defaultShutdownGracePeriod
+	}
 }
 
 //go:generate mockgen -source=config.go -destination=config_mocks.go -package=config doc github.com/golang/mock/gomock
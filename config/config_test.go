@@ -205,6 +205,9 @@ func TestNew(t *testing.T) {
 		expectedConfig    func() *Config
 		expectedErr       error
 		expectedLogs      []string
+
+		// expectedRefreshedRules are rules of config passed to onChange if config is changed by refresh
+		expectedRefreshedRules model.Rules
 	}
 
 	testTable := []testTableData{
@@ -392,10 +395,10 @@ func TestNew(t *testing.T) {
 			expectedConfig: func() *Config { return getExpectedConfigCompiled(taskExecutors) },
 			expectedErr:    nil,
 			expectedLogs: []string{
-				`{"config":{"BlockCacheSize":104857600,"PoolSize":100,"Runners":30,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}}},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"TaskExecutor":{}}]},{"Name":"AnyAlertFix","Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}}},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"TaskExecutor":{}}]}]},"context":"startup","iteration":1,"level":"debug","msg":"starts refreshing config","params":{"configPath":"common/webhooker.json","configProvider":"consul"}}`,
-				`{"config":{"BlockCacheSize":104857600,"PoolSize":100,"Runners":30,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}}},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"TaskExecutor":{}}]},{"Name":"AnyAlertFix","Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}}},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"TaskExecutor":{}}]}]},"context":"startup","iteration":1,"level":"debug","msg":"successfully done refreshing config: no changes","params":{"configPath":"common/webhooker.json","configProvider":"consul"}}`,
-				`{"config":{"BlockCacheSize":104857600,"PoolSize":100,"Runners":30,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}}},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"TaskExecutor":{}}]},{"Name":"AnyAlertFix","Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}}},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"TaskExecutor":{}}]}]},"context":"startup","iteration":2,"level":"debug","msg":"starts refreshing config","params":{"configPath":"common/webhooker.json","configProvider":"consul"}}`,
-				`{"config":{"BlockCacheSize":104857600,"PoolSize":100,"Runners":30,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}}},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"TaskExecutor":{}}]},{"Name":"AnyAlertFix","Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}}},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"TaskExecutor":{}}]}]},"context":"startup","iteration":2,"level":"error","msg":"config refresh error: watch remote config error","params":{"configPath":"common/webhooker.json","configProvider":"consul"}}`,
+				`{"config":{"BlockCacheSize":104857600,"BlockStore":"memory","BlockStorePath":"","BlockStoreRedis":null,"BlockLease":{"TTL":60000000000,"MaxDuration":3600000000000},"PoolSize":100,"Runners":30,"TaskQueue":null,"ShutdownGracePeriod":30000000000,"ExecutionHistory":{"Size":1000,"SQLitePath":""},"ExecutorRateLimits":null,"ExecutorConcurrency":null,"CircuitBreaker":null,"Flapping":null,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}},"Group":null},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":{}}],"FlappingActions":null,"Approval":null},{"Name":"AnyAlertFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}},"Group":null},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":{}}],"FlappingActions":null,"Approval":null}]},"context":"startup","iteration":1,"level":"debug","msg":"starts refreshing config","params":{"configPath":"common/webhooker.json","configProvider":"consul"}}`,
+				`{"config":{"BlockCacheSize":104857600,"BlockStore":"memory","BlockStorePath":"","BlockStoreRedis":null,"BlockLease":{"TTL":60000000000,"MaxDuration":3600000000000},"PoolSize":100,"Runners":30,"TaskQueue":null,"ShutdownGracePeriod":30000000000,"ExecutionHistory":{"Size":1000,"SQLitePath":""},"ExecutorRateLimits":null,"ExecutorConcurrency":null,"CircuitBreaker":null,"Flapping":null,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}},"Group":null},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":{}}],"FlappingActions":null,"Approval":null},{"Name":"AnyAlertFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}},"Group":null},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":{}}],"FlappingActions":null,"Approval":null}]},"context":"startup","iteration":1,"level":"debug","msg":"successfully done refreshing config: no changes","params":{"configPath":"common/webhooker.json","configProvider":"consul"}}`,
+				`{"config":{"BlockCacheSize":104857600,"BlockStore":"memory","BlockStorePath":"","BlockStoreRedis":null,"BlockLease":{"TTL":60000000000,"MaxDuration":3600000000000},"PoolSize":100,"Runners":30,"TaskQueue":null,"ShutdownGracePeriod":30000000000,"ExecutionHistory":{"Size":1000,"SQLitePath":""},"ExecutorRateLimits":null,"ExecutorConcurrency":null,"CircuitBreaker":null,"Flapping":null,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}},"Group":null},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":{}}],"FlappingActions":null,"Approval":null},{"Name":"AnyAlertFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}},"Group":null},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":{}}],"FlappingActions":null,"Approval":null}]},"context":"startup","iteration":2,"level":"debug","msg":"starts refreshing config","params":{"configPath":"common/webhooker.json","configProvider":"consul"}}`,
+				`{"config":{"BlockCacheSize":104857600,"BlockStore":"memory","BlockStorePath":"","BlockStoreRedis":null,"BlockLease":{"TTL":60000000000,"MaxDuration":3600000000000},"PoolSize":100,"Runners":30,"TaskQueue":null,"ShutdownGracePeriod":30000000000,"ExecutionHistory":{"Size":1000,"SQLitePath":""},"ExecutorRateLimits":null,"ExecutorConcurrency":null,"CircuitBreaker":null,"Flapping":null,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}},"Group":null},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":{}}],"FlappingActions":null,"Approval":null},{"Name":"AnyAlertFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}},"Group":null},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":{}}],"FlappingActions":null,"Approval":null}]},"context":"startup","iteration":2,"level":"error","msg":"config refresh error: watch remote config error","params":{"configPath":"common/webhooker.json","configProvider":"consul"}}`,
 			},
 		},
 		{
@@ -442,14 +445,13 @@ func TestNew(t *testing.T) {
 				}).Return(nil)
 			},
 			expectedConfig: func() *Config {
-				config := getExpectedConfigCompiled(taskExecutors)
-				config.Rules = model.Rules{getTestRuleCompiled(1, taskExecutors)}
-				return config
+				return getExpectedConfigCompiled(taskExecutors)
 			},
-			expectedErr: nil,
+			expectedRefreshedRules: model.Rules{getTestRuleCompiled(1, taskExecutors)},
+			expectedErr:            nil,
 			expectedLogs: []string{
-				`{"config":{"BlockCacheSize":104857600,"PoolSize":100,"Runners":30,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}}},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"TaskExecutor":{}}]},{"Name":"AnyAlertFix","Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}}},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"TaskExecutor":{}}]}]},"context":"startup","iteration":1,"level":"debug","msg":"starts refreshing config","params":{"configPath":"common/webhooker.json","configProvider":"consul"}}`,
-				`{"config":{"BlockCacheSize":104857600,"PoolSize":100,"Runners":30,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"testrule1","Conditions":{"AlertStatus":"firing","AlertLabels":{"a":"b"},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"aa":{}}},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"${LABEL_BLOCK} | ${URLENCODE_LABEL_ERROR} | ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE} | ${ANNOTATION_TITLE}"},"Block":10000000000,"TaskExecutor":{}}]}]},"context":"startup","iteration":1,"level":"info","msg":"successfully done refreshing config: config changed","params":{"configPath":"common/webhooker.json","configProvider":"consul"}}`,
+				`{"config":{"BlockCacheSize":104857600,"BlockStore":"memory","BlockStorePath":"","BlockStoreRedis":null,"BlockLease":{"TTL":60000000000,"MaxDuration":3600000000000},"PoolSize":100,"Runners":30,"TaskQueue":null,"ShutdownGracePeriod":30000000000,"ExecutionHistory":{"Size":1000,"SQLitePath":""},"ExecutorRateLimits":null,"ExecutorConcurrency":null,"CircuitBreaker":null,"Flapping":null,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}},"Group":null},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":{}}],"FlappingActions":null,"Approval":null},{"Name":"AnyAlertFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}},"Group":null},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":{}}],"FlappingActions":null,"Approval":null}]},"context":"startup","iteration":1,"level":"debug","msg":"starts refreshing config","params":{"configPath":"common/webhooker.json","configProvider":"consul"}}`,
+				`{"config":{"BlockCacheSize":104857600,"BlockStore":"memory","BlockStorePath":"","BlockStoreRedis":null,"BlockLease":{"TTL":60000000000,"MaxDuration":3600000000000},"PoolSize":100,"Runners":30,"TaskQueue":null,"ShutdownGracePeriod":30000000000,"ExecutionHistory":{"Size":1000,"SQLitePath":""},"ExecutorRateLimits":null,"ExecutorConcurrency":null,"CircuitBreaker":null,"Flapping":null,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"testrule1","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{"a":"b"},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"aa":{}},"Group":null},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"${LABEL_BLOCK} | ${URLENCODE_LABEL_ERROR} | ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE} | ${ANNOTATION_TITLE}"},"Block":10000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":{}}],"FlappingActions":null,"Approval":null}]},"context":"startup","iteration":1,"level":"info","msg":"successfully done refreshing config: config changed","params":{"configPath":"common/webhooker.json","configProvider":"consul"}}`,
 			},
 		},
 		{
@@ -499,8 +501,12 @@ func TestNew(t *testing.T) {
 			expectedConfig: func() *Config {
 				return &Config{
 					BlockCacheSize:              104857600,
+					BlockStore:                  BlockStoreMemory,
+					BlockLease:                  model.Lease{TTL: time.Minute, MaxDuration: time.Hour},
 					PoolSize:                    100,
 					Runners:                     30,
+					ShutdownGracePeriod:         30 * time.Second,
+					ExecutionHistory:            model.ExecutionHistory{Size: 1000},
 					RemoteConfigRefreshInterval: 1 * time.Nanosecond,
 					Rules: []model.Rule{
 						{
@@ -549,8 +555,12 @@ func TestNew(t *testing.T) {
 			expectedConfig: func() *Config {
 				return &Config{
 					BlockCacheSize:              104857600,
+					BlockStore:                  BlockStoreMemory,
+					BlockLease:                  model.Lease{TTL: time.Minute, MaxDuration: time.Hour},
 					PoolSize:                    100,
 					Runners:                     30,
+					ShutdownGracePeriod:         30 * time.Second,
+					ExecutionHistory:            model.ExecutionHistory{Size: 1000},
 					RemoteConfigRefreshInterval: 1 * time.Nanosecond,
 					Rules: []model.Rule{
 						{
@@ -607,6 +617,7 @@ func TestNew(t *testing.T) {
 			return nil, errors.New("readFileFunc error")
 		}
 
+		refreshed := make(chan *Config, 1)
 		config, err := New(
 			readFileFunc,
 			testUnit.configer,
@@ -614,6 +625,7 @@ func TestNew(t *testing.T) {
 			testUnit.configPath,
 			logger,
 			taskExecutors,
+			func(config *Config) { refreshed <- config },
 			testUnit.refreshIterations,
 		)
 
@@ -621,7 +633,15 @@ func TestNew(t *testing.T) {
 			time.Sleep(1 * time.Millisecond) // testing refresh daemon
 		}
 
+		var refreshedRules model.Rules
+		select {
+		case config := <-refreshed:
+			refreshedRules = config.Rules
+		default:
+		}
+
 		assert.Equal(t, testUnit.expectedConfig(), config, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedRefreshedRules, refreshedRules, testUnit.tcase)
 		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
 		assert.Equal(t, expectedLogsFix(testUnit.expectedLogs), logsFromHook(t, hook), testUnit.tcase)
 	}
@@ -648,6 +668,7 @@ func TestRefreshDaemon(t *testing.T) {
 		expectFunc        func(c *Mockconfiger, e *executor.MockTaskExecutor, ec *Config)
 		newConfig         func() *Config
 		expectedRules     model.Rules
+		expectedChanges   int
 		expectedLogs      []string
 	}
 
@@ -669,10 +690,11 @@ func TestRefreshDaemon(t *testing.T) {
 				config.Rules = model.Rules{getTestRuleUncompiled(1)}
 				return config
 			},
-			expectedRules: model.Rules{getTestRuleCompiled(1, taskExecutors)},
+			expectedRules:   model.Rules{getTestRuleCompiled(1, taskExecutors)},
+			expectedChanges: 1,
 			expectedLogs: []string{
-				`{"config":{"BlockCacheSize":104857600,"PoolSize":100,"Runners":30,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}}},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"TaskExecutor":{}}]},{"Name":"AnyAlertFix","Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}}},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"TaskExecutor":null}]}]},"context":"startup","iteration":1,"level":"debug","msg":"starts refreshing config","params":{"configPath":"https://consul/test.json","configProvider":"consul"}}`,
-				`{"config":{"BlockCacheSize":104857600,"PoolSize":100,"Runners":30,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"testrule1","Conditions":{"AlertStatus":"firing","AlertLabels":{"a":"b"},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"aa":{}}},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"${LABEL_BLOCK} | ${URLENCODE_LABEL_ERROR} | ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE} | ${ANNOTATION_TITLE}"},"Block":10000000000,"TaskExecutor":{}}]}]},"context":"startup","iteration":1,"level":"info","msg":"successfully done refreshing config: config changed","params":{"configPath":"https://consul/test.json","configProvider":"consul"}}`,
+				`{"config":{"BlockCacheSize":104857600,"BlockStore":"memory","BlockStorePath":"","BlockStoreRedis":null,"BlockLease":{"TTL":60000000000,"MaxDuration":3600000000000},"PoolSize":100,"Runners":30,"TaskQueue":null,"ShutdownGracePeriod":30000000000,"ExecutionHistory":{"Size":1000,"SQLitePath":""},"ExecutorRateLimits":null,"ExecutorConcurrency":null,"CircuitBreaker":null,"Flapping":null,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}},"Group":null},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":{}}],"FlappingActions":null,"Approval":null},{"Name":"AnyAlertFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}},"Group":null},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":null}],"FlappingActions":null,"Approval":null}]},"context":"startup","iteration":1,"level":"debug","msg":"starts refreshing config","params":{"configPath":"https://consul/test.json","configProvider":"consul"}}`,
+				`{"config":{"BlockCacheSize":104857600,"BlockStore":"memory","BlockStorePath":"","BlockStoreRedis":null,"BlockLease":{"TTL":60000000000,"MaxDuration":3600000000000},"PoolSize":100,"Runners":30,"TaskQueue":null,"ShutdownGracePeriod":30000000000,"ExecutionHistory":{"Size":1000,"SQLitePath":""},"ExecutorRateLimits":null,"ExecutorConcurrency":null,"CircuitBreaker":null,"Flapping":null,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"testrule1","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{"a":"b"},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"aa":{}},"Group":null},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"${LABEL_BLOCK} | ${URLENCODE_LABEL_ERROR} | ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE} | ${ANNOTATION_TITLE}"},"Block":10000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":{}}],"FlappingActions":null,"Approval":null}]},"context":"startup","iteration":1,"level":"info","msg":"successfully done refreshing config: config changed","params":{"configPath":"https://consul/test.json","configProvider":"consul"}}`,
 			},
 		},
 		{
@@ -686,8 +708,8 @@ func TestRefreshDaemon(t *testing.T) {
 			newConfig:     func() *Config { return nil },
 			expectedRules: getExpectedConfigCompiled(taskExecutors).Rules,
 			expectedLogs: []string{
-				`{"config":{"BlockCacheSize":104857600,"PoolSize":100,"Runners":30,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}}},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"TaskExecutor":{}}]},{"Name":"AnyAlertFix","Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}}},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"TaskExecutor":null}]}]},"context":"startup","iteration":1,"level":"debug","msg":"starts refreshing config","params":{"configPath":"https://consul/test.json","configProvider":"consul"}}`,
-				`{"config":{"BlockCacheSize":104857600,"PoolSize":100,"Runners":30,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}}},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"TaskExecutor":{}}]},{"Name":"AnyAlertFix","Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}}},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"TaskExecutor":null}]}]},"context":"startup","iteration":1,"level":"error","msg":"config refresh error: error","params":{"configPath":"https://consul/test.json","configProvider":"consul"}}`,
+				`{"config":{"BlockCacheSize":104857600,"BlockStore":"memory","BlockStorePath":"","BlockStoreRedis":null,"BlockLease":{"TTL":60000000000,"MaxDuration":3600000000000},"PoolSize":100,"Runners":30,"TaskQueue":null,"ShutdownGracePeriod":30000000000,"ExecutionHistory":{"Size":1000,"SQLitePath":""},"ExecutorRateLimits":null,"ExecutorConcurrency":null,"CircuitBreaker":null,"Flapping":null,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}},"Group":null},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":{}}],"FlappingActions":null,"Approval":null},{"Name":"AnyAlertFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}},"Group":null},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":null}],"FlappingActions":null,"Approval":null}]},"context":"startup","iteration":1,"level":"debug","msg":"starts refreshing config","params":{"configPath":"https://consul/test.json","configProvider":"consul"}}`,
+				`{"config":{"BlockCacheSize":104857600,"BlockStore":"memory","BlockStorePath":"","BlockStoreRedis":null,"BlockLease":{"TTL":60000000000,"MaxDuration":3600000000000},"PoolSize":100,"Runners":30,"TaskQueue":null,"ShutdownGracePeriod":30000000000,"ExecutionHistory":{"Size":1000,"SQLitePath":""},"ExecutorRateLimits":null,"ExecutorConcurrency":null,"CircuitBreaker":null,"Flapping":null,"RemoteConfigRefreshInterval":1,"CommonParameters":{"jenkins1":{"endpoint":"https://j.company.com/","login":"admin","password":"qwerty123"}},"Rules":[{"Name":"LowDiskSpaceFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{"alertname":"LowDiskSpace"},"AlertLabelsRegexp":{"instance":{}},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_enabled":{}},"Group":null},"Actions":[{"Executor":"shell","CommonParameters":"","Parameters":{"command":"./clean_server.sh ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}"},"Block":600000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":{}}],"FlappingActions":null,"Approval":null},{"Name":"AnyAlertFix","Priority":0,"Continue":null,"ActiveTimeIntervals":null,"MuteTimeIntervals":null,"RateLimit":null,"MaxConcurrency":0,"Conditions":{"AlertStatus":"firing","AlertLabels":{},"AlertLabelsRegexp":{},"AlertAnnotations":{},"AlertAnnotationsRegexp":{"webhooker_job":{}},"Group":null},"Actions":[{"Executor":"jenkins","CommonParameters":"jenkins1","Parameters":{"endpoint":"https://j.company.com/","instance":"${CUT_AFTER_LAST_COLON_LABEL_INSTANCE}","job_name":"${ANNOTATIONS_WEBHOOKER_JOB}","login":"admin","password":"qwerty123"},"Block":300000000000,"BlockOnFailure":0,"BlockKey":"","BlockScope":"","TaskExecutor":null}],"FlappingActions":null,"Approval":null}]},"context":"startup","iteration":1,"level":"error","msg":"config refresh error: error","params":{"configPath":"https://consul/test.json","configProvider":"consul"}}`,
 			},
 		},
 	}
@@ -703,9 +725,15 @@ func TestRefreshDaemon(t *testing.T) {
 
 		testUnit.expectFunc(configerMock, executorMock, testUnit.newConfig())
 		config := getExpectedConfigCompiled(taskExecutors)
-		refreshDaemon(config, testUnit.provider, testUnit.path, configerMock, logger, taskExecutors, testUnit.refreshIterations)
+		var changes []*Config
+		onChange := func(config *Config) { changes = append(changes, config) }
+		refreshDaemon(config, testUnit.provider, testUnit.path, configerMock, logger, taskExecutors, onChange, testUnit.refreshIterations)
 
 		assert.Equal(t, testUnit.expectedRules, config.Rules, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedChanges, len(changes), testUnit.tcase)
+		for _, changed := range changes {
+			assert.Equal(t, testUnit.expectedRules, changed.Rules, testUnit.tcase)
+		}
 		assert.Equal(t, expectedLogsFix(testUnit.expectedLogs), logsFromHook(t, hook), testUnit.tcase)
 	}
 }
@@ -930,9 +958,11 @@ func TestConfig_fillDefaults(t *testing.T) {
 				Runners:  10,
 			},
 			expected: Config{
-				BlockCacheSize: defaultBlockCacheSize,
-				PoolSize:       100,
-				Runners:        10,
+				BlockCacheSize:      defaultBlockCacheSize,
+				BlockStore:          BlockStoreMemory,
+				PoolSize:            100,
+				Runners:             10,
+				ShutdownGracePeriod: defaultShutdownGracePeriod,
 			},
 		},
 		{
@@ -942,9 +972,11 @@ func TestConfig_fillDefaults(t *testing.T) {
 				Runners:        10,
 			},
 			expected: Config{
-				BlockCacheSize: 10 * 1024 * 1024,
-				PoolSize:       defaultPoolSize,
-				Runners:        10,
+				BlockCacheSize:      10 * 1024 * 1024,
+				BlockStore:          BlockStoreMemory,
+				PoolSize:            defaultPoolSize,
+				Runners:             10,
+				ShutdownGracePeriod: defaultShutdownGracePeriod,
 			},
 		},
 		{
@@ -954,9 +986,61 @@ func TestConfig_fillDefaults(t *testing.T) {
 				PoolSize:       100,
 			},
 			expected: Config{
+				BlockCacheSize:      10 * 1024 * 1024,
+				BlockStore:          BlockStoreMemory,
+				PoolSize:            100,
+				Runners:             defaultRunners,
+				ShutdownGracePeriod: defaultShutdownGracePeriod,
+			},
+		},
+		{
+			tcase: "keep BlockStore",
+			config: Config{
 				BlockCacheSize: 10 * 1024 * 1024,
+				BlockStore:     BlockStoreFile,
 				PoolSize:       100,
-				Runners:        defaultRunners,
+				Runners:        10,
+			},
+			expected: Config{
+				BlockCacheSize:      10 * 1024 * 1024,
+				BlockStore:          BlockStoreFile,
+				PoolSize:            100,
+				Runners:             10,
+				ShutdownGracePeriod: defaultShutdownGracePeriod,
+			},
+		},
+		{
+			tcase: "fill BlockStoreRedis timeout",
+			config: Config{
+				BlockCacheSize:  10 * 1024 * 1024,
+				BlockStore:      BlockStoreRedis,
+				BlockStoreRedis: &RedisSettings{Address: "redis:6379"},
+				PoolSize:        100,
+				Runners:         10,
+			},
+			expected: Config{
+				BlockCacheSize:      10 * 1024 * 1024,
+				BlockStore:          BlockStoreRedis,
+				BlockStoreRedis:     &RedisSettings{Address: "redis:6379", Timeout: defaultRedisTimeout},
+				PoolSize:            100,
+				Runners:             10,
+				ShutdownGracePeriod: defaultShutdownGracePeriod,
+			},
+		},
+		{
+			tcase: "keep ShutdownGracePeriod",
+			config: Config{
+				BlockCacheSize:      10 * 1024 * 1024,
+				PoolSize:            100,
+				Runners:             10,
+				ShutdownGracePeriod: time.Minute,
+			},
+			expected: Config{
+				BlockCacheSize:      10 * 1024 * 1024,
+				BlockStore:          BlockStoreMemory,
+				PoolSize:            100,
+				Runners:             10,
+				ShutdownGracePeriod: time.Minute,
 			},
 		},
 	}
@@ -1055,8 +1139,12 @@ func getExpectedConfigCompiled(taskExecutors map[string]executor.TaskExecutor) *
 func getExpectedConfigUncompiled() *Config {
 	return &Config{
 		BlockCacheSize:              104857600,
+		BlockStore:                  BlockStoreMemory,
+		BlockLease:                  model.Lease{TTL: time.Minute, MaxDuration: time.Hour},
 		PoolSize:                    100,
 		Runners:                     30,
+		ShutdownGracePeriod:         30 * time.Second,
+		ExecutionHistory:            model.ExecutionHistory{Size: 1000},
 		RemoteConfigRefreshInterval: 1 * time.Nanosecond,
 		CommonParameters: map[string]map[string]interface{}{
 			"jenkins1": {
@@ -1184,3 +1272,133 @@ func TestMockConfigerCoverage(t *testing.T) {
 	configerMock.EXPECT().Unmarshal(nil, nil)
 	configerMock.Unmarshal(nil, nil)
 }
+
+func TestWarnUnreachableRules(t *testing.T) {
+	t.Parallel()
+
+	logger, hook := test.NewNullLogger()
+	logger.Formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+
+	stop := false
+	catchAll := getTestRuleUncompiled(1)
+	catchAll.Continue = &stop
+	catchAll.Conditions.AlertLabels = nil
+	catchAll.Conditions.AlertAnnotations = nil
+
+	warnUnreachableRules(model.Rules{catchAll, getTestRuleUncompiled(2)}, logger.WithField("context", context))
+
+	assert.Equal(t, expectedLogsFix([]string{
+		`{"context":"startup","level":"warning","msg":"rule testrule2 is unreachable because of higher priority catch-all rule testrule1"}`,
+	}), logsFromHook(t, hook))
+}
+
+func TestConfig_prepare_Limits(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executorMock := executor.NewMockTaskExecutor(ctrl)
+	executorMock.EXPECT().ValidateParameters(gomock.Any()).Return(nil).AnyTimes()
+	taskExecutors := map[string]executor.TaskExecutor{"shell": executorMock}
+
+	type testTableData struct {
+		tcase       string
+		config      Config
+		expectedErr error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "valid",
+			config: Config{
+				ExecutorRateLimits:  map[string]model.RateLimit{"Shell": {Max: 10, Window: time.Minute}},
+				ExecutorConcurrency: map[string]int{"Shell": 2},
+				CircuitBreaker:      &model.CircuitBreaker{MaxActions: 50, Window: 5 * time.Minute},
+			},
+			expectedErr: nil,
+		},
+		{
+			tcase: "unknown executor",
+			config: Config{
+				ExecutorRateLimits: map[string]model.RateLimit{"jenkins": {Max: 10, Window: time.Minute}},
+			},
+			expectedErr: errors.New("executor jenkins rate limit: executor not found"),
+		},
+		{
+			tcase: "invalid executor rate limit",
+			config: Config{
+				ExecutorRateLimits: map[string]model.RateLimit{"shell": {Window: time.Minute}},
+			},
+			expectedErr: errors.New("executor shell rate limit: rate limit max should be positive"),
+		},
+		{
+			tcase: "unknown concurrency executor",
+			config: Config{
+				ExecutorConcurrency: map[string]int{"jenkins": 2},
+			},
+			expectedErr: errors.New("executor jenkins concurrency: executor not found"),
+		},
+		{
+			tcase: "invalid executor concurrency",
+			config: Config{
+				ExecutorConcurrency: map[string]int{"Shell": 0},
+			},
+			expectedErr: errors.New("executor Shell concurrency: should be positive"),
+		},
+		{
+			tcase: "invalid circuit breaker",
+			config: Config{
+				CircuitBreaker: &model.CircuitBreaker{Window: 5 * time.Minute},
+			},
+			expectedErr: errors.New("circuit breaker max actions should be positive"),
+		},
+		{
+			tcase:       "file block store without path",
+			config:      Config{BlockStore: BlockStoreFile},
+			expectedErr: errConfigValidateEmptyBlockStorePath,
+		},
+		{
+			tcase:       "redis block store without address",
+			config:      Config{BlockStore: BlockStoreRedis, BlockStoreRedis: &RedisSettings{}},
+			expectedErr: errConfigValidateEmptyBlockStoreRedis,
+		},
+		{
+			tcase:       "invalid block lease",
+			config:      Config{BlockLease: model.Lease{TTL: time.Hour, MaxDuration: time.Minute}},
+			expectedErr: errors.New("block lease max duration should not be less than ttl"),
+		},
+		{
+			tcase:       "too short block lease ttl",
+			config:      Config{BlockLease: model.Lease{TTL: time.Millisecond}},
+			expectedErr: errors.New("block lease ttl should be at least 1s"),
+		},
+		{
+			tcase:       "task queue without path",
+			config:      Config{TaskQueue: &model.TaskQueue{}},
+			expectedErr: errors.New("empty task queue path"),
+		},
+		{
+			tcase:       "negative execution history size",
+			config:      Config{ExecutionHistory: model.ExecutionHistory{Size: -1}},
+			expectedErr: errors.New("execution history size should not be negative"),
+		},
+		{
+			tcase:       "unknown block store",
+			config:      Config{BlockStore: "mysql"},
+			expectedErr: errors.New("unknown block store mysql"),
+		},
+		{
+			tcase: "invalid flapping",
+			config: Config{
+				Flapping: &model.Flapping{Transitions: 1, Window: time.Hour},
+			},
+			expectedErr: errors.New("flapping transitions should be greater than 1"),
+		},
+	}
+
+	for _, testUnit := range testTable {
+		testUnit.config.Rules = model.Rules{getTestRuleUncompiled(1)}
+		assert.Equal(t, testUnit.expectedErr, testUnit.config.prepare(taskExecutors), testUnit.tcase)
+	}
+}
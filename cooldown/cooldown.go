@@ -0,0 +1,83 @@
+package cooldown
+
+import (
+	"sync"
+	"time"
+)
+
+// MaxFactor is a maximum factor of failure cooldown to base cooldown.
+const MaxFactor = 64
+
+// Tracker tracks consecutive failures of tasks and calculates exponentially growing failure cooldown.
+type Tracker struct {
+	mt    *sync.Mutex
+	tasks map[string]*taskState
+}
+
+type taskState struct {
+	failures int
+	base     time.Duration
+	until    time.Time
+}
+
+// Fail registers task failure and returns cooldown the task should be blocked for.
+// Cooldown is doubled for each consecutive failure up to MaxFactor of base.
+func (t *Tracker) Fail(key string, base time.Duration, now time.Time) (cooldown time.Duration, failures int) {
+	t.mt.Lock()
+	defer t.mt.Unlock()
+
+	state, ok := t.tasks[key]
+	if !ok {
+		state = &taskState{}
+		t.tasks[key] = state
+	}
+
+	state.failures++
+	state.base = base
+
+	factor := 1
+	for i := 1; i < state.failures && factor < MaxFactor; i++ {
+		factor *= 2
+	}
+
+	cooldown = time.Duration(factor) * base
+	state.until = now.Add(cooldown)
+
+	return cooldown, state.failures
+}
+
+// Succeed resets consecutive failures of task.
+func (t *Tracker) Succeed(key string) {
+	t.mt.Lock()
+	defer t.mt.Unlock()
+
+	delete(t.tasks, key)
+}
+
+// CooldownQty returns quantity of tasks currently in failure cooldown.
+// It also forgets tasks which have not failed for maximum cooldown after their cooldown ended.
+func (t *Tracker) CooldownQty(now time.Time) (qty int) {
+	t.mt.Lock()
+	defer t.mt.Unlock()
+
+	for key, state := range t.tasks {
+		if now.Before(state.until) {
+			qty++
+			continue
+		}
+
+		if now.Sub(state.until) > MaxFactor*state.base {
+			delete(t.tasks, key)
+		}
+	}
+
+	return
+}
+
+// New creates Tracker instance.
+func New() *Tracker {
+	return &Tracker{
+		mt:    &sync.Mutex{},
+		tasks: make(map[string]*taskState),
+	}
+}
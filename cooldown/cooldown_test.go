@@ -0,0 +1,100 @@
+package cooldown
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTracker_Fail(t *testing.T) {
+	t.Parallel()
+
+	type step struct {
+		key              string
+		succeed          bool
+		expectedCooldown time.Duration
+		expectedFailures int
+	}
+
+	type testTableData struct {
+		tcase string
+		steps []step
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "exponential cooldown",
+			steps: []step{
+				{key: "fp1", expectedCooldown: time.Minute, expectedFailures: 1},
+				{key: "fp1", expectedCooldown: 2 * time.Minute, expectedFailures: 2},
+				{key: "fp2", expectedCooldown: time.Minute, expectedFailures: 1},
+				{key: "fp1", expectedCooldown: 4 * time.Minute, expectedFailures: 3},
+			},
+		},
+		{
+			tcase: "reset by success",
+			steps: []step{
+				{key: "fp1", expectedCooldown: time.Minute, expectedFailures: 1},
+				{key: "fp1", expectedCooldown: 2 * time.Minute, expectedFailures: 2},
+				{key: "fp1", succeed: true},
+				{key: "fp1", expectedCooldown: time.Minute, expectedFailures: 1},
+			},
+		},
+		{
+			tcase: "max factor",
+			steps: []step{
+				{key: "fp1", expectedCooldown: time.Minute, expectedFailures: 1},
+				{key: "fp1", expectedCooldown: 2 * time.Minute, expectedFailures: 2},
+				{key: "fp1", expectedCooldown: 4 * time.Minute, expectedFailures: 3},
+				{key: "fp1", expectedCooldown: 8 * time.Minute, expectedFailures: 4},
+				{key: "fp1", expectedCooldown: 16 * time.Minute, expectedFailures: 5},
+				{key: "fp1", expectedCooldown: 32 * time.Minute, expectedFailures: 6},
+				{key: "fp1", expectedCooldown: 64 * time.Minute, expectedFailures: 7},
+				{key: "fp1", expectedCooldown: 64 * time.Minute, expectedFailures: 8},
+			},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		now := time.Unix(1535086351, 0)
+		tracker := New()
+		for i, s := range testUnit.steps {
+			if s.succeed {
+				tracker.Succeed(s.key)
+				continue
+			}
+
+			cooldown, failures := tracker.Fail(s.key, time.Minute, now)
+			assert.Equal(t, s.expectedCooldown, cooldown, "%v: step #%v", testUnit.tcase, i+1)
+			assert.Equal(t, s.expectedFailures, failures, "%v: step #%v", testUnit.tcase, i+1)
+		}
+	}
+}
+
+func TestTracker_CooldownQty(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1535086351, 0)
+	tracker := New()
+
+	tracker.Fail("fp1", time.Minute, now)
+	tracker.Fail("fp2", time.Hour, now)
+	assert.Equal(t, 2, tracker.CooldownQty(now))
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, 1, tracker.CooldownQty(now))
+	assert.Equal(t, 2, len(tracker.tasks))
+
+	// failures are remembered until max cooldown passes
+	cooldown, failures := tracker.Fail("fp1", time.Minute, now)
+	assert.Equal(t, 2*time.Minute, cooldown)
+	assert.Equal(t, 2, failures)
+
+	// fp1 is forgotten, fp2 is remembered
+	now = now.Add(2*time.Minute + MaxFactor*time.Minute + time.Second)
+	assert.Equal(t, 0, tracker.CooldownQty(now))
+	assert.Equal(t, 1, len(tracker.tasks))
+
+	tracker.Succeed("fp2")
+	assert.Equal(t, 0, len(tracker.tasks))
+}
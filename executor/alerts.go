@@ -0,0 +1,30 @@
+package executor
+
+import "github.com/prometheus/alertmanager/template"
+
+// Alerts describes alerts the task is created for.
+type Alerts struct {
+	// Alert is the alert matched rule.
+	// For group rules it has status, labels and annotations common for all alerts of the group.
+	Alert template.Alert
+
+	// Matched is the payload from Alertmanager with alerts matched rule only:
+	// the matched alert or all alerts of the group for group rules.
+	Matched template.Data
+
+	// Payload is the whole payload from Alertmanager.
+	Payload template.Data
+}
+
+// alertsTask is the interface implemented by tasks using alerts they are created for.
+type alertsTask interface {
+	SetAlerts(alerts Alerts)
+}
+
+// SetAlerts passes alerts to the task if it uses them, for example to send them in request body.
+// Alerts are built by alertsFunc only if task uses them.
+func SetAlerts(task Task, alertsFunc func() Alerts) {
+	if t, ok := task.(alertsTask); ok {
+		t.SetAlerts(alertsFunc())
+	}
+}
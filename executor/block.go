@@ -0,0 +1,34 @@
+package executor
+
+import "time"
+
+// scopedTask is the interface implemented by tasks blocked within other scope than executor.
+type scopedTask interface {
+	BlockScope() string
+}
+
+// BlockScope returns scope the task is blocked within.
+// Tasks with the same fingerprint within the same scope block each other.
+// Scope is executor name unless task overrides it.
+func BlockScope(task Task) string {
+	if scoped, ok := task.(scopedTask); ok {
+		return scoped.BlockScope()
+	}
+
+	return task.ExecutorName()
+}
+
+// failureBlockedTask is the interface implemented by tasks blocked after failure.
+type failureBlockedTask interface {
+	BlockOnFailure() time.Duration
+}
+
+// BlockOnFailure returns base TTL for blocking task after failed execute.
+// If return 0, task is not blocked after failure.
+func BlockOnFailure(task Task) time.Duration {
+	if blocked, ok := task.(failureBlockedTask); ok {
+		return blocked.BlockOnFailure()
+	}
+
+	return 0
+}
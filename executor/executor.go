@@ -44,7 +44,7 @@ type TaskExecutor interface {
 	ValidateParameters(parameters map[string]interface{}) error
 }
 
-// TaskDetails returns task details as a map.
+// TaskDetails returns task details as a map with redacted executor details.
 // It used for logging.
 func TaskDetails(task Task) map[string]interface{} {
 	return map[string]interface{}{
@@ -52,7 +52,7 @@ func TaskDetails(task Task) map[string]interface{} {
 		"rule":     task.Rule(),
 		"alert":    task.Alert(),
 		"executor": task.ExecutorName(),
-		"details":  task.ExecutorDetails(),
+		"details":  Redact(task.ExecutorDetails()),
 	}
 }
 
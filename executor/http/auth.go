@@ -0,0 +1,391 @@
+package httpe
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	paramBasicAuthUsername  = "basic_auth_username"
+	paramBasicAuthPassword  = "basic_auth_password"
+	paramBearerToken        = "bearer_token"
+	paramHMACSecret         = "hmac_secret"
+	paramHMACHeader         = "hmac_header"
+	paramHMACAlgorithm      = "hmac_algorithm"
+	paramOAuth2TokenURL     = "oauth2_token_url"
+	paramOAuth2ClientID     = "oauth2_client_id"
+	paramOAuth2ClientSecret = "oauth2_client_secret"
+	paramOAuth2Scopes       = "oauth2_scopes"
+
+	// secretFileSuffix is a suffix of parameters with path to file containing secret.
+	secretFileSuffix = "_file"
+
+	defaultHMACHeader    = "X-Signature"
+	defaultHMACAlgorithm = "sha256"
+
+	// tokenExpiryMargin is a time before token expiration when token is refreshed.
+	tokenExpiryMargin = 30 * time.Second
+)
+
+var (
+	authStringParameters = []string{
+		paramBasicAuthUsername,
+		paramBasicAuthPassword,
+		paramBasicAuthPassword + secretFileSuffix,
+		paramBearerToken,
+		paramBearerToken + secretFileSuffix,
+		paramHMACSecret,
+		paramHMACSecret + secretFileSuffix,
+		paramHMACHeader,
+		paramHMACAlgorithm,
+		paramOAuth2TokenURL,
+		paramOAuth2ClientID,
+		paramOAuth2ClientSecret,
+		paramOAuth2ClientSecret + secretFileSuffix,
+		paramOAuth2Scopes,
+	}
+
+	hmacAlgorithms = map[string]func() hash.Hash{
+		"sha1":   sha1.New,
+		"sha256": sha256.New,
+		"sha512": sha512.New,
+	}
+
+	errAuthBasicWithoutPassword = errors.New("basic_auth_username requires basic_auth_password or basic_auth_password_file")
+	errAuthOAuth2Incomplete     = errors.New("oauth2 requires oauth2_token_url, oauth2_client_id and oauth2_client_secret or oauth2_client_secret_file")
+	errAuthMultiple             = errors.New("only one of basic auth, bearer token and oauth2 can be set")
+)
+
+// secret is a secret parameter set by value or by path to file read on every use, so secret can be rotated.
+type secret struct {
+	value string
+	file  string
+}
+
+func newSecret(parameters map[string]interface{}, param string) secret {
+	s := secret{}
+	s.value, _ = parameters[param].(string)
+	s.file, _ = parameters[param+secretFileSuffix].(string)
+	return s
+}
+
+func (s secret) isSet() bool {
+	return len(s.value) > 0 || len(s.file) > 0
+}
+
+func (s secret) get() (string, error) {
+	if len(s.file) == 0 {
+		return s.value, nil
+	}
+
+	b, err := ioutil.ReadFile(s.file)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// auth describes authorization of requests.
+// Basic auth, bearer token and oauth2 are mutually exclusive, HMAC signature can be combined with any of them.
+type auth struct {
+	basicUsername string
+	basicPassword secret
+	bearerToken   secret
+	hmacSecret    secret
+	hmacHeader    string
+	hmacAlgorithm string
+	oauth2        *oauth2Config
+}
+
+// oauth2Config describes OAuth2 client credentials grant.
+type oauth2Config struct {
+	tokenURL     string
+	clientID     string
+	clientSecret secret
+	scopes       string
+	tokens       *tokens
+}
+
+func (config oauth2Config) key() string {
+	return strings.Join([]string{config.tokenURL, config.clientID, config.clientSecret.value, config.clientSecret.file, config.scopes}, "\x00")
+}
+
+func newAuth(parameters map[string]interface{}, tokens *tokens) auth {
+	a := auth{
+		basicPassword: newSecret(parameters, paramBasicAuthPassword),
+		bearerToken:   newSecret(parameters, paramBearerToken),
+		hmacSecret:    newSecret(parameters, paramHMACSecret),
+	}
+	a.basicUsername, _ = parameters[paramBasicAuthUsername].(string)
+	a.hmacHeader, _ = parameters[paramHMACHeader].(string)
+	a.hmacAlgorithm, _ = parameters[paramHMACAlgorithm].(string)
+
+	if tokenURL, ok := parameters[paramOAuth2TokenURL].(string); ok && len(tokenURL) > 0 {
+		a.oauth2 = &oauth2Config{
+			tokenURL:     tokenURL,
+			clientSecret: newSecret(parameters, paramOAuth2ClientSecret),
+			tokens:       tokens,
+		}
+		a.oauth2.clientID, _ = parameters[paramOAuth2ClientID].(string)
+		a.oauth2.scopes, _ = parameters[paramOAuth2Scopes].(string)
+	}
+
+	return a
+}
+
+// validateAuth validates auth parameters.
+func validateAuth(parameters map[string]interface{}) error {
+	for _, param := range authStringParameters {
+		if value, ok := parameters[param]; ok {
+			if _, ok := value.(string); !ok {
+				return fmt.Errorf("%v parameter value is not a string", param)
+			}
+		}
+	}
+
+	a := newAuth(parameters, nil)
+
+	kinds := 0
+	if len(a.basicUsername) > 0 {
+		if !a.basicPassword.isSet() {
+			return errAuthBasicWithoutPassword
+		}
+		kinds++
+	}
+
+	if a.bearerToken.isSet() {
+		kinds++
+	}
+
+	tokenURL, _ := parameters[paramOAuth2TokenURL].(string)
+	clientID, _ := parameters[paramOAuth2ClientID].(string)
+	clientSecret := newSecret(parameters, paramOAuth2ClientSecret)
+	if len(tokenURL) > 0 || len(clientID) > 0 || clientSecret.isSet() {
+		if len(tokenURL) == 0 || len(clientID) == 0 || !clientSecret.isSet() {
+			return errAuthOAuth2Incomplete
+		}
+		kinds++
+	}
+
+	if kinds > 1 {
+		return errAuthMultiple
+	}
+
+	if _, ok := hmacAlgorithms[a.algorithm()]; !ok {
+		return fmt.Errorf("%v parameter value %v is not one of sha1, sha256, sha512", paramHMACAlgorithm, a.hmacAlgorithm)
+	}
+
+	return nil
+}
+
+// authorize sets authorization headers of request with body.
+func (a auth) authorize(req *http.Request, body string, client Doer) error {
+	switch {
+	case len(a.basicUsername) > 0:
+		password, err := a.basicPassword.get()
+		if err != nil {
+			return fmt.Errorf("basic auth password error: %v", err)
+		}
+		req.SetBasicAuth(a.basicUsername, password)
+	case a.bearerToken.isSet():
+		token, err := a.bearerToken.get()
+		if err != nil {
+			return fmt.Errorf("bearer token error: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case a.oauth2 != nil:
+		token, err := a.oauth2.tokens.token(*a.oauth2, client)
+		if err != nil {
+			return fmt.Errorf("oauth2 token error: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if a.hmacSecret.isSet() {
+		key, err := a.hmacSecret.get()
+		if err != nil {
+			return fmt.Errorf("hmac secret error: %v", err)
+		}
+
+		header := a.hmacHeader
+		if len(header) == 0 {
+			header = defaultHMACHeader
+		}
+
+		mac := hmac.New(hmacAlgorithms[a.algorithm()], []byte(key))
+		_, _ = mac.Write([]byte(body))
+		req.Header.Set(header, a.algorithm()+"="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	return nil
+}
+
+func (a auth) algorithm() string {
+	if len(a.hmacAlgorithm) == 0 {
+		return defaultHMACAlgorithm
+	}
+	return strings.ToLower(a.hmacAlgorithm)
+}
+
+// kind returns auth kind shown in task details, secrets are not shown.
+func (a auth) kind() string {
+	var kinds []string
+	switch {
+	case len(a.basicUsername) > 0:
+		kinds = append(kinds, "basic")
+	case a.bearerToken.isSet():
+		kinds = append(kinds, "bearer")
+	case a.oauth2 != nil:
+		kinds = append(kinds, "oauth2")
+	}
+
+	if a.hmacSecret.isSet() {
+		kinds = append(kinds, "hmac")
+	}
+
+	return strings.Join(kinds, ",")
+}
+
+type token struct {
+	accessToken string
+	expiresAt   time.Time
+}
+
+// tokens caches OAuth2 access tokens until they expire.
+// Map lock is not held while token is requested, tasks of the same client wait for one request by its entry lock.
+type tokens struct {
+	mt      *sync.Mutex
+	tokens  map[string]*tokenEntry
+	nowFunc func() time.Time
+}
+
+// tokenEntry is a cached token of one client, lock is held while token is requested.
+type tokenEntry struct {
+	mt    *sync.Mutex
+	token token
+}
+
+// token returns cached access token or requests new one with client credentials.
+func (t *tokens) token(config oauth2Config, client Doer) (string, error) {
+	entry := t.entry(config.key())
+
+	entry.mt.Lock()
+	defer entry.mt.Unlock()
+
+	if t.nowFunc().Before(entry.token.expiresAt) {
+		return entry.token.accessToken, nil
+	}
+
+	requested, err := t.request(config, client)
+	if err != nil {
+		return "", err
+	}
+
+	// token without expiration is not cached
+	if requested.expiresAt.IsZero() {
+		return requested.accessToken, nil
+	}
+
+	entry.token = requested
+	return requested.accessToken, nil
+}
+
+func (t *tokens) entry(key string) *tokenEntry {
+	t.mt.Lock()
+	defer t.mt.Unlock()
+
+	entry, ok := t.tokens[key]
+	if !ok {
+		entry = &tokenEntry{mt: &sync.Mutex{}}
+		t.tokens[key] = entry
+	}
+
+	return entry
+}
+
+// request requests new access token from token endpoint.
+// Expiration is not set if token endpoint does not return it.
+func (t *tokens) request(config oauth2Config, client Doer) (token, error) {
+	clientSecret, err := config.clientSecret.get()
+	if err != nil {
+		return token{}, err
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(config.scopes) > 0 {
+		form.Set("scope", config.scopes)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, config.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(config.clientID), url.QueryEscape(clientSecret))
+
+	requestedAt := t.nowFunc()
+	resp, err := client.Do(req)
+	if err != nil {
+		return token{}, err
+	}
+
+	body, err := readBody(resp.Body)
+	if err != nil {
+		return token{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return token{}, fmt.Errorf("token endpoint returned HTTP status: %v, body: %v", resp.StatusCode, snippet(body))
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	err = json.Unmarshal(body, &tokenResp)
+	if err != nil {
+		return token{}, fmt.Errorf("token endpoint response is not JSON: %v", err)
+	}
+
+	if len(tokenResp.AccessToken) == 0 {
+		return token{}, errors.New("token endpoint response has no access_token")
+	}
+
+	requested := token{accessToken: tokenResp.AccessToken}
+	if tokenResp.ExpiresIn > 0 {
+		requested.expiresAt = requestedAt.Add(time.Duration(tokenResp.ExpiresIn)*time.Second - tokenExpiryMargin)
+	}
+
+	return requested, nil
+}
+
+// invalidate removes cached token, e.g. after it is rejected, so new token is requested on next use.
+// It does not wait for token being requested.
+func (t *tokens) invalidate(config oauth2Config) {
+	t.mt.Lock()
+	defer t.mt.Unlock()
+
+	delete(t.tokens, config.key())
+}
+
+func newTokens(nowFunc func() time.Time) *tokens {
+	return &tokens{
+		mt:      &sync.Mutex{},
+		tokens:  make(map[string]*tokenEntry),
+		nowFunc: nowFunc,
+	}
+}
@@ -0,0 +1,111 @@
+package httpe
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ClientSettings describes HTTP client of tasks.
+// Tasks with equal settings share the same client.
+type ClientSettings struct {
+	Timeout               time.Duration
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+	ProxyURL              string
+}
+
+var errClientCertWithoutKey = errors.New("tls_cert_file and tls_key_file should be set together")
+
+// NewClient creates HTTP client with settings. TLS files are read once on creating.
+func NewClient(settings ClientSettings) (Doer, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: settings.TLSInsecureSkipVerify}
+
+	if len(settings.TLSCAFile) > 0 {
+		ca, err := ioutil.ReadFile(settings.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file error: %v", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in CA file %v", settings.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(settings.TLSCertFile) > 0 || len(settings.TLSKeyFile) > 0 {
+		if len(settings.TLSCertFile) == 0 || len(settings.TLSKeyFile) == 0 {
+			return nil, errClientCertWithoutKey
+		}
+
+		cert, err := tls.LoadX509KeyPair(settings.TLSCertFile, settings.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate error: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if len(settings.ProxyURL) > 0 {
+		proxyURL, err := url.Parse(settings.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("parse proxy URL error: %v", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Client{
+		Timeout: settings.Timeout,
+		Transport: &http.Transport{
+			Proxy:                 proxy,
+			TLSClientConfig:       tlsConfig,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}, nil
+}
+
+// clients caches HTTP clients by settings, so connections are reused by tasks.
+type clients struct {
+	mt        *sync.Mutex
+	clients   map[ClientSettings]Doer
+	clientGen func(ClientSettings) (Doer, error)
+}
+
+// get returns cached client or creates new one.
+// Failed creating is not cached, so fixed TLS files are read on next call.
+func (c *clients) get(settings ClientSettings) (Doer, error) {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+
+	if client, ok := c.clients[settings]; ok {
+		return client, nil
+	}
+
+	client, err := c.clientGen(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	c.clients[settings] = client
+	return client, nil
+}
+
+func newClients(clientGen func(ClientSettings) (Doer, error)) *clients {
+	return &clients{
+		mt:        &sync.Mutex{},
+		clients:   make(map[ClientSettings]Doer),
+		clientGen: clientGen,
+	}
+}
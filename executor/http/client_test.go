@@ -0,0 +1,182 @@
+package httpe
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTLSFiles(t *testing.T, server *httptest.Server) (dir, certFile, keyFile string) {
+	dir, err := ioutil.TempDir("", "httpe")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := server.TLS.Certificates[0]
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir, certFile, keyFile
+}
+
+func TestNewClient(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// proxy gets absolute URL of target
+		w.Header().Set("X-Proxied", req.URL.String())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	dir, certFile, keyFile := writeTLSFiles(t, server)
+	defer os.RemoveAll(dir)
+
+	type testTableData struct {
+		tcase          string
+		settings       ClientSettings
+		url            string
+		expectedErr    error
+		expectedReqErr bool
+		expectedHeader string
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:          "unknown CA",
+			settings:       ClientSettings{Timeout: time.Second},
+			url:            server.URL,
+			expectedErr:    nil,
+			expectedReqErr: true,
+		},
+		{
+			tcase:          "trusted CA",
+			settings:       ClientSettings{Timeout: time.Second, TLSCAFile: certFile},
+			url:            server.URL,
+			expectedErr:    nil,
+			expectedReqErr: false,
+		},
+		{
+			tcase:          "insecure skip verify",
+			settings:       ClientSettings{Timeout: time.Second, TLSInsecureSkipVerify: true},
+			url:            server.URL,
+			expectedErr:    nil,
+			expectedReqErr: false,
+		},
+		{
+			tcase:          "client certificate",
+			settings:       ClientSettings{Timeout: time.Second, TLSCAFile: certFile, TLSCertFile: certFile, TLSKeyFile: keyFile},
+			url:            server.URL,
+			expectedErr:    nil,
+			expectedReqErr: false,
+		},
+		{
+			tcase:          "proxy",
+			settings:       ClientSettings{Timeout: time.Second, ProxyURL: proxy.URL},
+			url:            "http://automation.example.com/restart",
+			expectedErr:    nil,
+			expectedReqErr: false,
+			expectedHeader: "http://automation.example.com/restart",
+		},
+		{
+			tcase:       "CA file not found",
+			settings:    ClientSettings{TLSCAFile: filepath.Join(dir, "ca.pem")},
+			expectedErr: errors.New("read CA file error: open " + filepath.Join(dir, "ca.pem") + ": no such file or directory"),
+		},
+		{
+			tcase:       "CA file without certificates",
+			settings:    ClientSettings{TLSCAFile: keyFile},
+			expectedErr: errors.New("no certificates found in CA file " + keyFile),
+		},
+		{
+			tcase:       "certificate without key",
+			settings:    ClientSettings{TLSCertFile: certFile},
+			expectedErr: errClientCertWithoutKey,
+		},
+		{
+			tcase:       "wrong key",
+			settings:    ClientSettings{TLSCertFile: certFile, TLSKeyFile: certFile},
+			expectedErr: errors.New("load client certificate error: tls: found a certificate rather than a key in the PEM for the private key"),
+		},
+		{
+			tcase:       "wrong proxy URL",
+			settings:    ClientSettings{ProxyURL: "://proxy"},
+			expectedErr: errors.New(`parse proxy URL error: parse "://proxy": missing protocol scheme`),
+		},
+	}
+
+	for _, testUnit := range testTable {
+		client, err := NewClient(testUnit.settings)
+		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
+		if err != nil {
+			continue
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, testUnit.url, nil)
+		resp, err := client.Do(req)
+		assert.Equal(t, testUnit.expectedReqErr, err != nil, testUnit.tcase)
+		if err != nil {
+			continue
+		}
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedHeader, resp.Header.Get("X-Proxied"), testUnit.tcase)
+		_ = resp.Body.Close()
+	}
+}
+
+func TestClients(t *testing.T) {
+	t.Parallel()
+
+	created := 0
+	c := newClients(func(settings ClientSettings) (Doer, error) {
+		if len(settings.TLSCAFile) > 0 {
+			return nil, errors.New("read CA file error")
+		}
+
+		created++
+		return &http.Client{Timeout: settings.Timeout}, nil
+	})
+
+	client1, err := c.get(ClientSettings{Timeout: time.Second})
+	assert.Equal(t, nil, err)
+	client2, err := c.get(ClientSettings{Timeout: time.Second})
+	assert.Equal(t, nil, err)
+	client3, err := c.get(ClientSettings{Timeout: time.Minute})
+	assert.Equal(t, nil, err)
+
+	// the same settings share client
+	assert.True(t, client1 == client2)
+	assert.False(t, client1 == client3)
+	assert.Equal(t, 2, created)
+
+	client, err := c.get(ClientSettings{TLSCAFile: "/etc/ca.pem"})
+	assert.Equal(t, nil, client)
+	assert.Equal(t, errors.New("read CA file error"), err)
+}
@@ -2,11 +2,15 @@ package httpe
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/krpn/prometheus-alert-webhooker/executor"
 	"github.com/krpn/prometheus-alert-webhooker/utils"
+	"github.com/prometheus/common/model"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -16,19 +20,46 @@ const (
 	paramMethod            = "method"
 	paramURL               = "url"
 	paramBody              = "body"
+	paramBodyMode          = "body_mode"
 	paramHeaderPrefix      = "header "
 	paramTimeout           = "timeout"
 	paramSuccessHTTPStatus = "success_http_status"
+	paramBodyContains      = "body_contains"
+	paramBodyRegexp        = "body_regexp"
+	paramBodyJSONPrefix    = "body_json "
+	paramOutputPrefix      = "output "
+
+	paramTLSCAFile             = "tls_ca_file"
+	paramTLSCertFile           = "tls_cert_file"
+	paramTLSKeyFile            = "tls_key_file"
+	paramTLSInsecureSkipVerify = "tls_insecure_skip_verify"
+	paramProxyURL              = "proxy_url"
 
 	defaultMethod            = http.MethodGet
 	defaultTimeout           = 1 * time.Second
 	defaultSuccessHTTPStatus = http.StatusOK
 )
 
+// Body modes send alerts the task is created for as JSON request body.
+const (
+	bodyModeAlert   = "alert"
+	bodyModeMatched = "matched"
+	bodyModePayload = "payload"
+)
+
+var errBodyWithBodyMode = errors.New("body and body_mode parameters can not be set together")
+
 var stringParameters = []string{
 	paramMethod,
 	paramURL,
 	paramBody,
+	paramBodyMode,
+	paramBodyContains,
+	paramBodyRegexp,
+	paramTLSCAFile,
+	paramTLSCertFile,
+	paramTLSKeyFile,
+	paramProxyURL,
 }
 
 //go:generate mockgen -source=http.go -destination=http_mocks.go -package=httpe doc github.com/golang/mock/gomock
@@ -40,12 +71,41 @@ type Doer interface {
 
 type task struct {
 	executor.TaskBase
-	method            string
-	url               string
-	body              string
-	headers           map[string]string
-	successHTTPStatus int
-	client            Doer
+	method  string
+	url     string
+	body    string
+	headers map[string]string
+	client  Doer
+
+	// bodyMode is set if body is made of alerts.
+	bodyMode string
+
+	// alertFingerprint identifies alert the body is made of, it is used in fingerprint instead of body.
+	alertFingerprint string
+
+	// bodyErr is an error of encoding alerts to body, returned on execute.
+	bodyErr error
+
+	// clientErr is an error of creating client, returned on execute.
+	clientErr error
+
+	// checkErr is an error of parsing response checks with filled placeholders, returned on execute.
+	checkErr error
+
+	auth auth
+
+	successHTTPStatuses statusRanges
+	bodyContains        string
+	bodyRegexp          *regexp.Regexp
+
+	// bodyJSON is expected values of response JSON by JSONPath.
+	bodyJSON map[string]string
+
+	// outputs is JSONPath of response JSON values by output name.
+	outputs map[string]string
+
+	// output is set after execute.
+	output map[string]interface{}
 }
 
 func (task *task) ExecutorName() string {
@@ -58,7 +118,11 @@ func (task *task) ExecutorDetails() interface{} {
 		"url":    task.url,
 	}
 
-	if task.body != "" {
+	// body made of alerts is too long for details
+	switch {
+	case task.bodyMode != "":
+		d["body_mode"] = task.bodyMode
+	case task.body != "":
 		d["body"] = task.body
 	}
 
@@ -66,11 +130,19 @@ func (task *task) ExecutorDetails() interface{} {
 		d["headers"] = task.headers
 	}
 
+	if kind := task.auth.kind(); len(kind) > 0 {
+		d["auth"] = kind
+	}
+
 	return d
 }
 
 func (task *task) Fingerprint() string {
 	base := fmt.Sprintf("%v|%v|%v", task.method, task.url, task.body)
+	if task.bodyMode != "" {
+		// body made of alerts changes with every payload (e.g. timestamps), so alert identity is used
+		base = fmt.Sprintf("%v|%v|%v|%v", task.method, task.url, task.bodyMode, task.alertFingerprint)
+	}
 	// order is important
 	if len(task.headers) > 0 {
 		keys := make([]string, len(task.headers))
@@ -86,6 +158,18 @@ func (task *task) Fingerprint() string {
 }
 
 func (task *task) Exec(logger *logrus.Logger) error {
+	if task.clientErr != nil {
+		return task.clientErr
+	}
+
+	if task.bodyErr != nil {
+		return task.bodyErr
+	}
+
+	if task.checkErr != nil {
+		return task.checkErr
+	}
+
 	var (
 		req *http.Request
 		err error
@@ -99,29 +183,77 @@ func (task *task) Exec(logger *logrus.Logger) error {
 		return err
 	}
 
+	if task.bodyMode != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
 	for key, val := range task.headers {
 		req.Header.Set(key, val)
 	}
 
+	err = task.auth.authorize(req, task.body, task.client)
+	if err != nil {
+		return err
+	}
+
 	resp, err := task.client.Do(req)
 	if err != nil {
 		return err
 	}
 
-	if resp.StatusCode != task.successHTTPStatus {
-		return fmt.Errorf("returned HTTP status: %v, body close error: %v", resp.StatusCode, resp.Body.Close())
+	if resp.StatusCode == http.StatusUnauthorized && task.auth.oauth2 != nil {
+		// token can be revoked before expiration
+		task.auth.oauth2.tokens.invalidate(*task.auth.oauth2)
+	}
+
+	return task.checkResponse(resp)
+}
+
+// SetAlerts sets alerts encoded to JSON as request body and alert fingerprint if body mode is set.
+func (task *task) SetAlerts(alerts executor.Alerts) {
+	if task.bodyMode != "" {
+		labels := make(model.LabelSet, len(alerts.Alert.Labels))
+		for name, value := range alerts.Alert.Labels {
+			labels[model.LabelName(name)] = model.LabelValue(value)
+		}
+		task.alertFingerprint = labels.Fingerprint().String()
+	}
+
+	var v interface{}
+	switch task.bodyMode {
+	case bodyModeAlert:
+		v = alerts.Alert
+	case bodyModeMatched:
+		v = alerts.Matched
+	case bodyModePayload:
+		v = alerts.Payload
+	default:
+		return
 	}
 
-	return resp.Body.Close()
+	b, err := json.Marshal(v)
+	if err != nil {
+		task.bodyErr = fmt.Errorf("body encode error: %v", err)
+		return
+	}
+
+	task.body = string(b)
+}
+
+// Output returns response status code and values extracted from response JSON.
+func (task *task) Output() map[string]interface{} {
+	return task.output
 }
 
 type taskExecutor struct {
-	clientGen func(time.Duration) Doer
+	clients *clients
+	tokens  *tokens
 }
 
 // NewExecutor creates TaskExecutor for HTTP tasks.
-func NewExecutor(clientGen func(time.Duration) Doer) executor.TaskExecutor {
-	return taskExecutor{clientGen: clientGen}
+// Clients are created by clientGen once for each unique settings.
+func NewExecutor(clientGen func(ClientSettings) (Doer, error)) executor.TaskExecutor {
+	return taskExecutor{clients: newClients(clientGen), tokens: newTokens(time.Now)}
 }
 
 func (executor taskExecutor) ValidateParameters(parameters map[string]interface{}) error {
@@ -138,18 +270,95 @@ func (executor taskExecutor) ValidateParameters(parameters map[string]interface{
 	}
 
 	for key, val := range parameters {
-		if !strings.HasPrefix(key, paramHeaderPrefix) {
+		if !strings.HasPrefix(key, paramHeaderPrefix) && !strings.HasPrefix(key, paramBodyJSONPrefix) && !strings.HasPrefix(key, paramOutputPrefix) {
 			continue
 		}
 
-		if _, ok := val.(string); !ok {
+		valStr, ok := val.(string)
+		if !ok {
 			return fmt.Errorf("%v parameter value is not a string", key)
 		}
+
+		var path string
+		switch {
+		case strings.HasPrefix(key, paramBodyJSONPrefix):
+			path = strings.TrimSpace(strings.TrimPrefix(key, paramBodyJSONPrefix))
+		case strings.HasPrefix(key, paramOutputPrefix):
+			path = valStr
+		default:
+			continue
+		}
+
+		err := utils.ValidateJSONPath(path)
+		if err != nil {
+			return fmt.Errorf("%v parameter: %v", key, err)
+		}
+	}
+
+	if mode, ok := parameters[paramBodyMode].(string); ok {
+		switch mode {
+		case bodyModeAlert, bodyModeMatched, bodyModePayload:
+		default:
+			return fmt.Errorf("%v parameter value %v is not one of %v, %v, %v", paramBodyMode, mode, bodyModeAlert, bodyModeMatched, bodyModePayload)
+		}
+
+		if _, ok := parameters[paramBody]; ok {
+			return errBodyWithBodyMode
+		}
+	}
+
+	if status, ok := parameters[paramSuccessHTTPStatus]; ok {
+		_, err := parseStatusRanges(status)
+		if err != nil {
+			return err
+		}
+	}
+
+	if expr, ok := parameters[paramBodyRegexp].(string); ok {
+		_, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("%v parameter: %v", paramBodyRegexp, err)
+		}
+	}
+
+	if skip, ok := parameters[paramTLSInsecureSkipVerify]; ok {
+		if _, ok := skip.(bool); !ok {
+			return fmt.Errorf("%v parameter value is not a boolean", paramTLSInsecureSkipVerify)
+		}
+	}
+
+	err := validateAuth(parameters)
+	if err != nil {
+		return err
+	}
+
+	// client is created on validate, so wrong TLS files are found on config load
+	_, err = executor.clients.get(clientSettings(parameters))
+	if err != nil {
+		return fmt.Errorf("http client: %v", err)
 	}
 
 	return nil
 }
 
+func clientSettings(parameters map[string]interface{}) ClientSettings {
+	settings := ClientSettings{Timeout: defaultTimeout}
+	if timeoutStr, ok := parameters[paramTimeout].(string); ok {
+		tm, err := time.ParseDuration(timeoutStr)
+		if err == nil {
+			settings.Timeout = tm
+		}
+	}
+
+	settings.TLSCAFile, _ = parameters[paramTLSCAFile].(string)
+	settings.TLSCertFile, _ = parameters[paramTLSCertFile].(string)
+	settings.TLSKeyFile, _ = parameters[paramTLSKeyFile].(string)
+	settings.TLSInsecureSkipVerify, _ = parameters[paramTLSInsecureSkipVerify].(bool)
+	settings.ProxyURL, _ = parameters[paramProxyURL].(string)
+
+	return settings
+}
+
 func (executor taskExecutor) NewTask(eventID, rule, alert string, blockTTL time.Duration, preparedParameters map[string]interface{}) executor.Task {
 	method := defaultMethod
 	if m, ok := preparedParameters[paramMethod]; ok {
@@ -164,33 +373,46 @@ func (executor taskExecutor) NewTask(eventID, rule, alert string, blockTTL time.
 	}
 
 	task.body, _ = preparedParameters[paramBody].(string)
+	task.bodyMode, _ = preparedParameters[paramBodyMode].(string)
 
-	headers := make(map[string]string)
+	headers, bodyJSON, outputs := make(map[string]string), make(map[string]string), make(map[string]string)
 	for key, val := range preparedParameters {
 		valStr, ok := val.(string)
 		if !ok {
 			continue
 		}
-		if strings.HasPrefix(key, paramHeaderPrefix) {
+		switch {
+		case strings.HasPrefix(key, paramHeaderPrefix):
 			headers[strings.TrimSpace(strings.Replace(key, paramHeaderPrefix, "", 1))] = valStr
+		case strings.HasPrefix(key, paramBodyJSONPrefix):
+			bodyJSON[strings.TrimSpace(strings.Replace(key, paramBodyJSONPrefix, "", 1))] = valStr
+		case strings.HasPrefix(key, paramOutputPrefix):
+			outputs[strings.TrimSpace(strings.Replace(key, paramOutputPrefix, "", 1))] = valStr
 		}
 	}
-	task.headers = headers
-
-	timeout := defaultTimeout
-	if timeoutStr, ok := preparedParameters[paramTimeout].(string); ok {
-		tm, err := time.ParseDuration(timeoutStr)
-		if err == nil {
-			timeout = tm
+	task.headers, task.bodyJSON, task.outputs = headers, bodyJSON, outputs
+
+	task.successHTTPStatuses = statusRanges{{min: defaultSuccessHTTPStatus, max: defaultSuccessHTTPStatus}}
+	if status, ok := preparedParameters[paramSuccessHTTPStatus]; ok {
+		ranges, err := parseStatusRanges(status)
+		if err != nil {
+			task.checkErr = err
+		} else {
+			task.successHTTPStatuses = ranges
 		}
 	}
 
-	task.successHTTPStatus = defaultSuccessHTTPStatus
-	if status, ok := preparedParameters[paramSuccessHTTPStatus].(int); ok && status > 0 {
-		task.successHTTPStatus = status
+	task.bodyContains, _ = preparedParameters[paramBodyContains].(string)
+	if expr, ok := preparedParameters[paramBodyRegexp].(string); ok {
+		bodyRegexp, err := regexp.Compile(expr)
+		if err != nil {
+			task.checkErr = fmt.Errorf("%v parameter: %v", paramBodyRegexp, err)
+		}
+		task.bodyRegexp = bodyRegexp
 	}
 
-	task.client = executor.clientGen(timeout)
+	task.client, task.clientErr = executor.clients.get(clientSettings(preparedParameters))
+	task.auth = newAuth(preparedParameters, executor.tokens)
 
 	task.SetBase(eventID, rule, alert, blockTTL)
 	return task
@@ -2,14 +2,22 @@ package httpe
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/golang/mock/gomock"
 	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/prometheus/alertmanager/template"
 	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -17,8 +25,8 @@ import (
 func TestHTTPTask_ExecutorInterface(t *testing.T) {
 	t.Parallel()
 
-	executorMock := NewExecutor(func(timeout time.Duration) Doer {
-		return &http.Client{Timeout: timeout}
+	executorMock := NewExecutor(func(settings ClientSettings) (Doer, error) {
+		return &http.Client{Timeout: settings.Timeout}, nil
 	})
 
 	type testTableData struct {
@@ -136,8 +144,8 @@ func TestHTTPTask_ExecutorInterface(t *testing.T) {
 func TestHTTPTaskExecutor_ValidateParameters(t *testing.T) {
 	t.Parallel()
 
-	executorMock := NewExecutor(func(timeout time.Duration) Doer {
-		return &http.Client{Timeout: timeout}
+	executorMock := NewExecutor(func(settings ClientSettings) (Doer, error) {
+		return &http.Client{Timeout: settings.Timeout}, nil
 	})
 
 	type testTableData struct {
@@ -199,18 +207,137 @@ func TestHTTPTaskExecutor_ValidateParameters(t *testing.T) {
 			},
 			expected: errors.New("header Int parameter value is not a string"),
 		},
+		{
+			tcase: "correct response params",
+			params: map[string]interface{}{
+				"url":                 "http://www.test.com/",
+				"success_http_status": "200-299,302",
+				"body_contains":       "done",
+				"body_regexp":         "^ok",
+				"body_json $.status":  "ok",
+				"output build_id":     "$.builds[0].id",
+			},
+			expected: nil,
+		},
+		{
+			tcase: "param success_http_status wrong type",
+			params: map[string]interface{}{
+				"url":                 "http://www.test.com/",
+				"success_http_status": 2.5,
+			},
+			expected: errors.New("success_http_status parameter value is not an integer or a string"),
+		},
+		{
+			tcase: "param success_http_status not status",
+			params: map[string]interface{}{
+				"url":                 "http://www.test.com/",
+				"success_http_status": 20,
+			},
+			expected: errors.New("success_http_status parameter value 20 is not HTTP status"),
+		},
+		{
+			tcase: "param success_http_status wrong range",
+			params: map[string]interface{}{
+				"url":                 "http://www.test.com/",
+				"success_http_status": "200, 299-200",
+			},
+			expected: errors.New("success_http_status parameter value 299-200 is not HTTP status or range"),
+		},
+		{
+			tcase: "param body_regexp wrong regexp",
+			params: map[string]interface{}{
+				"url":         "http://www.test.com/",
+				"body_regexp": "(ok",
+			},
+			expected: errors.New("body_regexp parameter: error parsing regexp: missing closing ): `(ok`"),
+		},
+		{
+			tcase: "param body_json wrong JSONPath",
+			params: map[string]interface{}{
+				"url":              "http://www.test.com/",
+				"body_json status": "ok",
+			},
+			expected: errors.New("body_json status parameter: JSONPath status should start with $"),
+		},
+		{
+			tcase: "param output wrong JSONPath",
+			params: map[string]interface{}{
+				"url":             "http://www.test.com/",
+				"output build_id": "$.builds[",
+			},
+			expected: errors.New("output build_id parameter: JSONPath $.builds[ has unclosed bracket"),
+		},
+		{
+			tcase: "param output wrong type",
+			params: map[string]interface{}{
+				"url":             "http://www.test.com/",
+				"output build_id": 1,
+			},
+			expected: errors.New("output build_id parameter value is not a string"),
+		},
+		{
+			tcase: "correct TLS params",
+			params: map[string]interface{}{
+				"url":                      "https://www.test.com/",
+				"tls_ca_file":              "/etc/ssl/ca.pem",
+				"tls_insecure_skip_verify": true,
+				"proxy_url":                "http://proxy:3128",
+			},
+			expected: nil,
+		},
+		{
+			tcase: "param body_mode unknown",
+			params: map[string]interface{}{
+				"url":       "http://www.test.com/",
+				"body_mode": "alerts",
+			},
+			expected: errors.New("body_mode parameter value alerts is not one of alert, matched, payload"),
+		},
+		{
+			tcase: "params body and body_mode together",
+			params: map[string]interface{}{
+				"url":       "http://www.test.com/",
+				"body":      "some body",
+				"body_mode": "alert",
+			},
+			expected: errBodyWithBodyMode,
+		},
+		{
+			tcase: "param tls_insecure_skip_verify wrong type",
+			params: map[string]interface{}{
+				"url":                      "https://www.test.com/",
+				"tls_insecure_skip_verify": "yes",
+			},
+			expected: errors.New("tls_insecure_skip_verify parameter value is not a boolean"),
+		},
+		{
+			tcase: "param tls_cert_file wrong type",
+			params: map[string]interface{}{
+				"url":           "https://www.test.com/",
+				"tls_cert_file": true,
+			},
+			expected: errors.New("tls_cert_file parameter value is not a string"),
+		},
 	}
 
 	for _, testUnit := range testTable {
 		assert.Equal(t, testUnit.expected, executorMock.ValidateParameters(testUnit.params), testUnit.tcase)
 	}
+
+	executorMock = NewExecutor(func(settings ClientSettings) (Doer, error) {
+		return nil, errors.New("read CA file error")
+	})
+	assert.Equal(t, errors.New("http client: read CA file error"), executorMock.ValidateParameters(map[string]interface{}{
+		"url":         "https://www.test.com/",
+		"tls_ca_file": "/etc/ssl/ca.pem",
+	}))
 }
 
 func TestHTTPTaskExecutor_NewTask(t *testing.T) {
 	t.Parallel()
 
-	executorMock := NewExecutor(func(timeout time.Duration) Doer {
-		return &http.Client{Timeout: timeout}
+	executorMock := NewExecutor(func(settings ClientSettings) (Doer, error) {
+		return &http.Client{Timeout: settings.Timeout}, nil
 	})
 
 	type testTableData struct {
@@ -233,12 +360,14 @@ func TestHTTPTaskExecutor_NewTask(t *testing.T) {
 			},
 			expected: func() executor.Task {
 				task := &task{
-					method:            "GET",
-					url:               "http://www.test.com/",
-					body:              "",
-					headers:           map[string]string{},
-					successHTTPStatus: defaultSuccessHTTPStatus,
-					client:            &http.Client{Timeout: 1 * time.Second},
+					method:              "GET",
+					url:                 "http://www.test.com/",
+					body:                "",
+					headers:             map[string]string{},
+					successHTTPStatuses: statusRanges{{min: defaultSuccessHTTPStatus, max: defaultSuccessHTTPStatus}},
+					bodyJSON:            map[string]string{},
+					outputs:             map[string]string{},
+					client:              &http.Client{Timeout: 1 * time.Second},
 				}
 				task.SetBase("825e", "testrule1", "testalert1", 1*time.Second)
 				return task
@@ -257,16 +386,74 @@ func TestHTTPTaskExecutor_NewTask(t *testing.T) {
 				"header Authorization":     "ba0828c9fac6b0b47d9147963429d091",
 				"header Wrong type header": 123,
 				"timeout":                  "10s",
-				"success_http_status":      201,
+				"success_http_status":      "200-299, 302",
+				"body_contains":            "done",
+				"body_regexp":              "^ok",
+				"body_json $.status":       "ok",
+				"output build_id":          "$.id",
+			},
+			expected: func() executor.Task {
+				task := &task{
+					method:              "POST",
+					url:                 "http://www.test.com/",
+					body:                "some body",
+					headers:             map[string]string{"Authorization": "ba0828c9fac6b0b47d9147963429d091"},
+					successHTTPStatuses: statusRanges{{min: 200, max: 299}, {min: 302, max: 302}},
+					bodyContains:        "done",
+					bodyRegexp:          regexp.MustCompile("^ok"),
+					bodyJSON:            map[string]string{"$.status": "ok"},
+					outputs:             map[string]string{"build_id": "$.id"},
+					client:              &http.Client{Timeout: 10 * time.Second},
+				}
+				task.SetBase("825e", "testrule1", "testalert1", 1*time.Second)
+				return task
+			},
+		},
+		{
+			tcase:    "label value breaks body regexp",
+			eventID:  "825e",
+			rule:     "testrule1",
+			alert:    "testalert1",
+			blockTTL: 1 * time.Second,
+			preparedParameters: map[string]interface{}{
+				"url":         "http://www.test.com/",
+				"body_regexp": "^host (db[1",
+			},
+			expected: func() executor.Task {
+				task := &task{
+					method:              "GET",
+					url:                 "http://www.test.com/",
+					headers:             map[string]string{},
+					successHTTPStatuses: statusRanges{{min: defaultSuccessHTTPStatus, max: defaultSuccessHTTPStatus}},
+					bodyJSON:            map[string]string{},
+					outputs:             map[string]string{},
+					client:              &http.Client{Timeout: 1 * time.Second},
+					checkErr:            errors.New("body_regexp parameter: error parsing regexp: missing closing ]: `[1`"),
+				}
+				task.SetBase("825e", "testrule1", "testalert1", 1*time.Second)
+				return task
+			},
+		},
+		{
+			tcase:    "label value breaks success http status",
+			eventID:  "825e",
+			rule:     "testrule1",
+			alert:    "testalert1",
+			blockTTL: 1 * time.Second,
+			preparedParameters: map[string]interface{}{
+				"url":                 "http://www.test.com/",
+				"success_http_status": "200, unknown",
 			},
 			expected: func() executor.Task {
 				task := &task{
-					method:            "POST",
-					url:               "http://www.test.com/",
-					body:              "some body",
-					headers:           map[string]string{"Authorization": "ba0828c9fac6b0b47d9147963429d091"},
-					successHTTPStatus: 201,
-					client:            &http.Client{Timeout: 10 * time.Second},
+					method:              "GET",
+					url:                 "http://www.test.com/",
+					headers:             map[string]string{},
+					successHTTPStatuses: statusRanges{{min: defaultSuccessHTTPStatus, max: defaultSuccessHTTPStatus}},
+					bodyJSON:            map[string]string{},
+					outputs:             map[string]string{},
+					client:              &http.Client{Timeout: 1 * time.Second},
+					checkErr:            errors.New("success_http_status parameter value unknown is not HTTP status or range"),
 				}
 				task.SetBase("825e", "testrule1", "testalert1", 1*time.Second)
 				return task
@@ -301,12 +488,12 @@ func TestHTTPTask_Exec(t *testing.T) {
 			tcase: "success with minimal parameters",
 			task: func() *task {
 				task := &task{
-					method:            "GET",
-					url:               "http://www.test.com/",
-					body:              "",
-					headers:           map[string]string{},
-					successHTTPStatus: http.StatusOK,
-					client:            doerMock,
+					method:              "GET",
+					url:                 "http://www.test.com/",
+					body:                "",
+					headers:             map[string]string{},
+					successHTTPStatuses: statusRanges{{min: http.StatusOK, max: http.StatusOK}},
+					client:              doerMock,
 				}
 				task.SetBase("id", "rule", "alert", 10*time.Minute)
 				return task
@@ -324,12 +511,12 @@ func TestHTTPTask_Exec(t *testing.T) {
 			tcase: "bad status code",
 			task: func() *task {
 				task := &task{
-					method:            "GET",
-					url:               "http://www.test.com/",
-					body:              "",
-					headers:           map[string]string{},
-					successHTTPStatus: http.StatusOK,
-					client:            doerMock,
+					method:              "GET",
+					url:                 "http://www.test.com/",
+					body:                "",
+					headers:             map[string]string{},
+					successHTTPStatuses: statusRanges{{min: http.StatusOK, max: http.StatusOK}},
+					client:              doerMock,
 				}
 				task.SetBase("id", "rule", "alert", 10*time.Minute)
 				return task
@@ -341,18 +528,18 @@ func TestHTTPTask_Exec(t *testing.T) {
 					Body:       ioutil.NopCloser(bytes.NewBufferString("resp body")),
 				}, nil)
 			},
-			expectedErr: errors.New("returned HTTP status: 504, body close error: <nil>"),
+			expectedErr: errors.New("returned HTTP status: 504, body: resp body"),
 		},
 		{
 			tcase: "request error",
 			task: func() *task {
 				task := &task{
-					method:            "GET",
-					url:               "http://www.test.com/",
-					body:              "",
-					headers:           map[string]string{},
-					successHTTPStatus: http.StatusOK,
-					client:            doerMock,
+					method:              "GET",
+					url:                 "http://www.test.com/",
+					body:                "",
+					headers:             map[string]string{},
+					successHTTPStatuses: statusRanges{{min: http.StatusOK, max: http.StatusOK}},
+					client:              doerMock,
 				}
 				task.SetBase("id", "rule", "alert", 10*time.Minute)
 				return task
@@ -367,12 +554,12 @@ func TestHTTPTask_Exec(t *testing.T) {
 			tcase: "success with header parameter",
 			task: func() *task {
 				task := &task{
-					method:            "GET",
-					url:               "http://www.test.com/",
-					body:              "",
-					headers:           map[string]string{"Authorization": "ba0828c9fac6b0b47d9147963429d091"},
-					successHTTPStatus: http.StatusOK,
-					client:            doerMock,
+					method:              "GET",
+					url:                 "http://www.test.com/",
+					body:                "",
+					headers:             map[string]string{"Authorization": "ba0828c9fac6b0b47d9147963429d091"},
+					successHTTPStatuses: statusRanges{{min: http.StatusOK, max: http.StatusOK}},
+					client:              doerMock,
 				}
 				task.SetBase("id", "rule", "alert", 10*time.Minute)
 				return task
@@ -391,12 +578,12 @@ func TestHTTPTask_Exec(t *testing.T) {
 			tcase: "new request error",
 			task: func() *task {
 				task := &task{
-					method:            "POST",
-					url:               "http://www test com/",
-					body:              "some body",
-					headers:           map[string]string{"Authorization": "ba0828c9fac6b0b47d9147963429d091"},
-					successHTTPStatus: http.StatusOK,
-					client:            doerMock,
+					method:              "POST",
+					url:                 "http://www test com/",
+					body:                "some body",
+					headers:             map[string]string{"Authorization": "ba0828c9fac6b0b47d9147963429d091"},
+					successHTTPStatuses: statusRanges{{min: http.StatusOK, max: http.StatusOK}},
+					client:              doerMock,
 				}
 				task.SetBase("id", "rule", "alert", 10*time.Minute)
 				return task
@@ -404,6 +591,39 @@ func TestHTTPTask_Exec(t *testing.T) {
 			expectFunc:  func(t *MockDoer) {},
 			expectedErr: &url.Error{Op: "parse", URL: "http://www test com/", Err: url.InvalidHostError(" ")},
 		},
+		{
+			tcase: "client error",
+			task: func() *task {
+				task := &task{
+					method:              "GET",
+					url:                 "https://www.test.com/",
+					headers:             map[string]string{},
+					successHTTPStatuses: statusRanges{{min: http.StatusOK, max: http.StatusOK}},
+					clientErr:           errors.New("read CA file error"),
+				}
+				task.SetBase("id", "rule", "alert", 10*time.Minute)
+				return task
+			},
+			expectFunc:  func(t *MockDoer) {},
+			expectedErr: errors.New("read CA file error"),
+		},
+		{
+			tcase: "check error",
+			task: func() *task {
+				task := &task{
+					method:              "GET",
+					url:                 "https://www.test.com/",
+					headers:             map[string]string{},
+					successHTTPStatuses: statusRanges{{min: http.StatusOK, max: http.StatusOK}},
+					client:              doerMock,
+					checkErr:            errors.New("body_regexp parameter: error parsing regexp: missing closing ]: `[1`"),
+				}
+				task.SetBase("id", "rule", "alert", 10*time.Minute)
+				return task
+			},
+			expectFunc:  func(t *MockDoer) {},
+			expectedErr: errors.New("body_regexp parameter: error parsing regexp: missing closing ]: `[1`"),
+		},
 	}
 
 	for _, testUnit := range testTable {
@@ -414,3 +634,239 @@ func TestHTTPTask_Exec(t *testing.T) {
 	// logger is not used
 	assert.Equal(t, 0, len(hook.Entries))
 }
+
+func TestHTTPTask_ExecResponse(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	logger, _ := test.NewNullLogger()
+
+	newTask := func(doer Doer, params map[string]interface{}) executor.Task {
+		params["url"] = "http://www.test.com/"
+		return NewExecutor(func(ClientSettings) (Doer, error) { return doer, nil }).NewTask("id", "rule", "alert", 0, params)
+	}
+
+	type testTableData struct {
+		tcase          string
+		params         map[string]interface{}
+		status         int
+		body           string
+		expectedErr    error
+		expectedOutput map[string]interface{}
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:          "status in range",
+			params:         map[string]interface{}{"success_http_status": "200-299"},
+			status:         http.StatusAccepted,
+			body:           "accepted",
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"status_code": http.StatusAccepted},
+		},
+		{
+			tcase:          "status not in list",
+			params:         map[string]interface{}{"success_http_status": "200, 202"},
+			status:         http.StatusNotFound,
+			body:           "not found",
+			expectedErr:    errors.New("returned HTTP status: 404, body: not found"),
+			expectedOutput: map[string]interface{}{"status_code": http.StatusNotFound},
+		},
+		{
+			tcase:          "long body is cut in error",
+			params:         map[string]interface{}{},
+			status:         http.StatusInternalServerError,
+			body:           strings.Repeat("a", bodySnippetSize+1),
+			expectedErr:    fmt.Errorf("returned HTTP status: 500, body: %v...", strings.Repeat("a", bodySnippetSize)),
+			expectedOutput: map[string]interface{}{"status_code": http.StatusInternalServerError},
+		},
+		{
+			tcase:          "body contains",
+			params:         map[string]interface{}{"body_contains": "done"},
+			status:         http.StatusOK,
+			body:           "job is done",
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"status_code": http.StatusOK},
+		},
+		{
+			tcase:          "body does not contain",
+			params:         map[string]interface{}{"body_contains": "done"},
+			status:         http.StatusOK,
+			body:           "job is failed",
+			expectedErr:    errors.New(`response body does not contain "done", body: job is failed`),
+			expectedOutput: map[string]interface{}{"status_code": http.StatusOK},
+		},
+		{
+			tcase:          "body does not match regexp",
+			params:         map[string]interface{}{"body_regexp": "^ok"},
+			status:         http.StatusOK,
+			body:           "not ok",
+			expectedErr:    errors.New("response body does not match regexp ^ok, body: not ok"),
+			expectedOutput: map[string]interface{}{"status_code": http.StatusOK},
+		},
+		{
+			tcase: "JSON assertions and outputs",
+			params: map[string]interface{}{
+				"body_json $.status":  "ok",
+				"body_json $.count":   "2",
+				"output build_id":     "$.builds[0].id",
+				"output build_result": "$.builds[0].result",
+			},
+			status:      http.StatusOK,
+			body:        `{"status":"ok","count":2,"builds":[{"id":42,"result":"SUCCESS"}]}`,
+			expectedErr: nil,
+			expectedOutput: map[string]interface{}{
+				"status_code":  http.StatusOK,
+				"build_id":     float64(42),
+				"build_result": "SUCCESS",
+			},
+		},
+		{
+			tcase:          "JSON assertion failed",
+			params:         map[string]interface{}{"body_json $.status": "ok"},
+			status:         http.StatusOK,
+			body:           `{"status":"failed"}`,
+			expectedErr:    errors.New(`response body $.status is failed, expected ok, body: {"status":"failed"}`),
+			expectedOutput: map[string]interface{}{"status_code": http.StatusOK},
+		},
+		{
+			tcase:          "JSON assertion path not found",
+			params:         map[string]interface{}{"body_json $.status": "ok"},
+			status:         http.StatusOK,
+			body:           `{}`,
+			expectedErr:    errors.New("response body $.status: key status is not found, body: {}"),
+			expectedOutput: map[string]interface{}{"status_code": http.StatusOK},
+		},
+		{
+			tcase:          "output not found",
+			params:         map[string]interface{}{"output build_id": "$.id"},
+			status:         http.StatusOK,
+			body:           `{}`,
+			expectedErr:    errors.New("output build_id from response body $.id: key id is not found, body: {}"),
+			expectedOutput: map[string]interface{}{"status_code": http.StatusOK},
+		},
+		{
+			tcase:          "body is not JSON",
+			params:         map[string]interface{}{"output build_id": "$.id"},
+			status:         http.StatusOK,
+			body:           "ok",
+			expectedErr:    errors.New("response body is not JSON: invalid character 'o' looking for beginning of value, body: ok"),
+			expectedOutput: map[string]interface{}{"status_code": http.StatusOK},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		doerMock := NewMockDoer(ctrl)
+		doerMock.EXPECT().Do(gomock.Any()).Return(&http.Response{
+			StatusCode: testUnit.status,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(testUnit.body)),
+		}, nil)
+
+		task := newTask(doerMock, testUnit.params)
+		assert.Equal(t, testUnit.expectedErr, task.Exec(logger), testUnit.tcase)
+		assert.Equal(t, testUnit.expectedOutput, executor.Output(task), testUnit.tcase)
+	}
+}
+
+func TestHTTPTask_SetAlerts(t *testing.T) {
+	t.Parallel()
+
+	logger, _ := test.NewNullLogger()
+
+	type request struct {
+		contentType string
+		signature   string
+		body        string
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		requests <- request{contentType: req.Header.Get("Content-Type"), signature: req.Header.Get("X-Signature"), body: string(body)}
+	}))
+	defer server.Close()
+
+	alert := template.Alert{Status: "firing", Labels: template.KV{"alertname": "testalert1"}}
+	alerts := executor.Alerts{
+		Alert:   alert,
+		Matched: template.Data{Receiver: "webhooker", Status: "firing", Alerts: template.Alerts{alert}},
+		Payload: template.Data{Receiver: "webhooker", Status: "firing", Alerts: template.Alerts{alert, {Status: "resolved", Labels: template.KV{"alertname": "testalert2"}}}},
+	}
+
+	type testTableData struct {
+		tcase               string
+		params              map[string]interface{}
+		expectedDetails     map[string]interface{}
+		expectedContentType string
+		expectedBody        string
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:               "alert",
+			params:              map[string]interface{}{"body_mode": "alert"},
+			expectedDetails:     map[string]interface{}{"method": "POST", "url": server.URL, "body_mode": "alert"},
+			expectedContentType: "application/json",
+			expectedBody:        `{"status":"firing","labels":{"alertname":"testalert1"},"annotations":null,"startsAt":"0001-01-01T00:00:00Z","endsAt":"0001-01-01T00:00:00Z","generatorURL":""}`,
+		},
+		{
+			tcase:               "matched alerts",
+			params:              map[string]interface{}{"body_mode": "matched", "header Content-Type": "application/vnd.alerts+json"},
+			expectedDetails:     map[string]interface{}{"method": "POST", "url": server.URL, "body_mode": "matched", "headers": map[string]string{"Content-Type": "application/vnd.alerts+json"}},
+			expectedContentType: "application/vnd.alerts+json",
+			expectedBody:        `{"receiver":"webhooker","status":"firing","alerts":[{"status":"firing","labels":{"alertname":"testalert1"},"annotations":null,"startsAt":"0001-01-01T00:00:00Z","endsAt":"0001-01-01T00:00:00Z","generatorURL":""}],"groupLabels":null,"commonLabels":null,"commonAnnotations":null,"externalURL":""}`,
+		},
+		{
+			tcase:               "payload",
+			params:              map[string]interface{}{"body_mode": "payload"},
+			expectedDetails:     map[string]interface{}{"method": "POST", "url": server.URL, "body_mode": "payload"},
+			expectedContentType: "application/json",
+			expectedBody:        `{"receiver":"webhooker","status":"firing","alerts":[{"status":"firing","labels":{"alertname":"testalert1"},"annotations":null,"startsAt":"0001-01-01T00:00:00Z","endsAt":"0001-01-01T00:00:00Z","generatorURL":""},{"status":"resolved","labels":{"alertname":"testalert2"},"annotations":null,"startsAt":"0001-01-01T00:00:00Z","endsAt":"0001-01-01T00:00:00Z","generatorURL":""}],"groupLabels":null,"commonLabels":null,"commonAnnotations":null,"externalURL":""}`,
+		},
+		{
+			tcase:               "body is not replaced without body mode",
+			params:              map[string]interface{}{"body": "some body"},
+			expectedDetails:     map[string]interface{}{"method": "POST", "url": server.URL, "body": "some body"},
+			expectedContentType: "",
+			expectedBody:        "some body",
+		},
+	}
+
+	for _, testUnit := range testTable {
+		testUnit.params["url"] = server.URL
+		testUnit.params["method"] = "POST"
+		task := NewExecutor(NewClient).NewTask("id", "rule", "alert", 0, testUnit.params)
+		executor.SetAlerts(task, func() executor.Alerts { return alerts })
+
+		assert.Equal(t, testUnit.expectedDetails, task.ExecutorDetails(), testUnit.tcase)
+		assert.Equal(t, nil, task.Exec(logger), testUnit.tcase)
+		req := <-requests
+		assert.Equal(t, testUnit.expectedContentType, req.contentType, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedBody, req.body, testUnit.tcase)
+	}
+
+	// fingerprint is made of body mode and alert labels instead of body
+	fingerprint := func(alert template.Alert, bodyMode string) string {
+		task := NewExecutor(NewClient).NewTask("id", "rule", "alert", 0, map[string]interface{}{"url": server.URL, "method": "POST", "body_mode": bodyMode})
+		executor.SetAlerts(task, func() executor.Alerts {
+			return executor.Alerts{Alert: alert, Matched: template.Data{Alerts: template.Alerts{alert}}}
+		})
+		return task.Fingerprint()
+	}
+	restarted := alert
+	restarted.StartsAt = time.Unix(1535086351, 0)
+	other := template.Alert{Status: "firing", Labels: template.KV{"alertname": "testalert2"}}
+	assert.Equal(t, fingerprint(alert, "alert"), fingerprint(restarted, "alert"))
+	assert.NotEqual(t, fingerprint(alert, "alert"), fingerprint(other, "alert"))
+	assert.NotEqual(t, fingerprint(alert, "alert"), fingerprint(alert, "matched"))
+
+	// signature is made of body actually sent
+	task := NewExecutor(NewClient).NewTask("id", "rule", "alert", 0, map[string]interface{}{"url": server.URL, "method": "POST", "body_mode": "alert", "hmac_secret": "secret"})
+	executor.SetAlerts(task, func() executor.Alerts { return alerts })
+	assert.Equal(t, nil, task.Exec(logger))
+	req := <-requests
+	mac := hmac.New(sha256.New, []byte("secret"))
+	_, _ = mac.Write([]byte(req.body))
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), req.signature)
+}
@@ -0,0 +1,161 @@
+package httpe
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/krpn/prometheus-alert-webhooker/utils"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxBodySize is a maximum size of response body read for assertions and outputs.
+	maxBodySize = 1024 * 1024
+
+	// bodySnippetSize is a maximum size of response body included in errors.
+	bodySnippetSize = 512
+)
+
+// statusRange is an inclusive range of HTTP status codes.
+type statusRange struct {
+	min, max int
+}
+
+type statusRanges []statusRange
+
+func (ranges statusRanges) contains(status int) bool {
+	for _, r := range ranges {
+		if status >= r.min && status <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+// parseStatusRanges parses success_http_status parameter value:
+// integer status or string list of statuses and ranges, e.g. "200-299, 302".
+func parseStatusRanges(value interface{}) (statusRanges, error) {
+	switch v := value.(type) {
+	case int:
+		if v < 100 || v > 599 {
+			return nil, fmt.Errorf("%v parameter value %v is not HTTP status", paramSuccessHTTPStatus, v)
+		}
+		return statusRanges{{min: v, max: v}}, nil
+	case string:
+		var ranges statusRanges
+		for _, part := range strings.Split(v, ",") {
+			bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+			if len(bounds) == 1 {
+				bounds = append(bounds, bounds[0])
+			}
+
+			min, minErr := strconv.Atoi(strings.TrimSpace(bounds[0]))
+			max, maxErr := strconv.Atoi(strings.TrimSpace(bounds[1]))
+			if minErr != nil || maxErr != nil || min < 100 || max > 599 || min > max {
+				return nil, fmt.Errorf("%v parameter value %v is not HTTP status or range", paramSuccessHTTPStatus, strings.TrimSpace(part))
+			}
+
+			ranges = append(ranges, statusRange{min: min, max: max})
+		}
+		return ranges, nil
+	default:
+		return nil, fmt.Errorf("%v parameter value is not an integer or a string", paramSuccessHTTPStatus)
+	}
+}
+
+// readBody reads limited response body and closes it.
+func readBody(body io.ReadCloser) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(body, maxBodySize))
+	closeErr := body.Close()
+	if err != nil {
+		return nil, err
+	}
+	return b, closeErr
+}
+
+// snippet returns beginning of response body for errors.
+func snippet(body []byte) string {
+	if len(body) > bodySnippetSize {
+		return string(body[:bodySnippetSize]) + "..."
+	}
+	return string(body)
+}
+
+// checkResponse checks response status and body assertions, and extracts outputs.
+func (task *task) checkResponse(resp *http.Response) error {
+	task.output = map[string]interface{}{"status_code": resp.StatusCode}
+
+	body, err := readBody(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if !task.successHTTPStatuses.contains(resp.StatusCode) {
+		return fmt.Errorf("returned HTTP status: %v, body: %v", resp.StatusCode, snippet(body))
+	}
+
+	if len(task.bodyContains) > 0 && !strings.Contains(string(body), task.bodyContains) {
+		return fmt.Errorf("response body does not contain %q, body: %v", task.bodyContains, snippet(body))
+	}
+
+	if task.bodyRegexp != nil && !task.bodyRegexp.Match(body) {
+		return fmt.Errorf("response body does not match regexp %v, body: %v", task.bodyRegexp, snippet(body))
+	}
+
+	if len(task.bodyJSON) == 0 && len(task.outputs) == 0 {
+		return nil
+	}
+
+	var data interface{}
+	err = json.Unmarshal(body, &data)
+	if err != nil {
+		return fmt.Errorf("response body is not JSON: %v, body: %v", err, snippet(body))
+	}
+
+	for _, path := range sortedKeys(task.bodyJSON) {
+		expected := task.bodyJSON[path]
+		value, err := utils.JSONPath(data, path)
+		if err != nil {
+			return fmt.Errorf("response body %v: %v, body: %v", path, err, snippet(body))
+		}
+
+		if actual := jsonString(value); actual != expected {
+			return fmt.Errorf("response body %v is %v, expected %v, body: %v", path, actual, expected, snippet(body))
+		}
+	}
+
+	for _, name := range sortedKeys(task.outputs) {
+		path := task.outputs[name]
+		value, err := utils.JSONPath(data, path)
+		if err != nil {
+			return fmt.Errorf("output %v from response body %v: %v, body: %v", name, path, err, snippet(body))
+		}
+
+		task.output[name] = value
+	}
+
+	return nil
+}
+
+// jsonString returns string as is and other JSON values encoded, so they can be compared with parameters.
+func jsonString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,262 @@
+package jenkins
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Build describes Jenkins build.
+type Build struct {
+	Number   int64  `json:"number"`
+	URL      string `json:"url"`
+	Building bool   `json:"building"`
+	Result   string `json:"result"`
+}
+
+// QueueItem describes Jenkins queue item of triggered build.
+type QueueItem struct {
+	ID        int64 `json:"id"`
+	Cancelled bool  `json:"cancelled"`
+
+	// Why is a reason of waiting in queue.
+	Why string `json:"why"`
+
+	// Executable is set when build is started.
+	Executable *struct {
+		Number int64  `json:"number"`
+		URL    string `json:"url"`
+	} `json:"executable"`
+}
+
+// Build results.
+const (
+	resultSuccess  = "SUCCESS"
+	resultUnstable = "UNSTABLE"
+)
+
+const defaultClientTimeout = 30 * time.Second
+
+// client is a client of Jenkins remote access API.
+type client struct {
+	endpoint string
+	login    string
+
+	// password is a password or API token.
+	password string
+
+	// crumb is not requested with API token, Jenkins does not check it for token requests.
+	crumb bool
+
+	httpClient *http.Client
+}
+
+// NewClient creates Jenkins client authorized by login and password or API token.
+// Crumb is requested for each POST request if password is used.
+func NewClient(endpoint, login, password string, apiToken bool) Jenkins {
+	// crumb is bound to the session it is issued in
+	jar, _ := cookiejar.New(nil)
+
+	return &client{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		login:      login,
+		password:   password,
+		crumb:      !apiToken,
+		httpClient: &http.Client{Timeout: defaultClientTimeout, Jar: jar},
+	}
+}
+
+// BuildJob triggers job build and returns ID of queue item.
+func (c *client) BuildJob(job string, parameters map[string]string) (int64, error) {
+	p := jobPath(job) + "/build"
+	form := url.Values{}
+	if len(parameters) > 0 {
+		p = jobPath(job) + "/buildWithParameters"
+		for key, val := range parameters {
+			form.Set(key, val)
+		}
+	}
+
+	resp, err := c.do(http.MethodPost, p, form)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	// Location is .../queue/item/<id>/
+	location := strings.TrimSuffix(resp.Header.Get("Location"), "/")
+	queueID, err := strconv.ParseInt(path.Base(location), 10, 64)
+	if err != nil || !strings.Contains(location, "/queue/item/") {
+		return 0, fmt.Errorf("build job response has no queue item location: %q", location)
+	}
+
+	return queueID, nil
+}
+
+// GetQueueItem returns queue item.
+func (c *client) GetQueueItem(id int64) (QueueItem, error) {
+	var item QueueItem
+	err := c.getJSON(fmt.Sprintf("/queue/item/%v/api/json", id), &item)
+	return item, err
+}
+
+// CancelQueueItem removes item from queue.
+func (c *client) CancelQueueItem(id int64) error {
+	resp, err := c.do(http.MethodPost, fmt.Sprintf("/queue/cancelItem?id=%v", id), nil)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// GetBuild returns job build.
+func (c *client) GetBuild(job string, number int64) (Build, error) {
+	var build Build
+	err := c.getJSON(fmt.Sprintf("%v/%v/api/json", jobPath(job), number), &build)
+	return build, err
+}
+
+// StopBuild aborts job build.
+func (c *client) StopBuild(job string, number int64) error {
+	resp, err := c.do(http.MethodPost, fmt.Sprintf("%v/%v/stop", jobPath(job), number), nil)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// GetConsoleTail returns the last lines of build console output.
+func (c *client) GetConsoleTail(job string, number int64, lines int) (string, error) {
+	resp, err := c.do(http.MethodGet, fmt.Sprintf("%v/%v/consoleText", jobPath(job), number), nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// console output can be large, only the last lines are kept
+	tail := make([]string, 0, lines)
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			if len(tail) == lines {
+				tail = tail[1:]
+			}
+			tail = append(tail, strings.TrimRight(line, "\r\n"))
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.Join(tail, "\n"), nil
+}
+
+// jobPath returns path of job. Job in folder is set as Folder/job/Job.
+func jobPath(job string) string {
+	return "/job/" + strings.Trim(job, "/")
+}
+
+func (c *client) getJSON(p string, v interface{}) error {
+	resp, err := c.do(http.MethodGet, p, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return fmt.Errorf("GET %v response is not JSON: %v", p, err)
+	}
+
+	return nil
+}
+
+// do makes request and returns response with success status code.
+func (c *client) do(method, p string, form url.Values) (*http.Response, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequest(method, c.endpoint+p, body)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.login, c.password)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	if method == http.MethodPost && c.crumb {
+		field, crumb, err := c.getCrumb()
+		if err != nil {
+			return nil, err
+		}
+		if len(field) > 0 {
+			req.Header.Set(field, crumb)
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%v %v returned HTTP status: %v", method, p, resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+// getCrumb returns crumb header. Empty header is returned if CSRF protection is disabled.
+func (c *client) getCrumb() (field, crumb string, err error) {
+	req, err := http.NewRequest(http.MethodGet, c.endpoint+"/crumbIssuer/api/json", nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.SetBasicAuth(c.login, c.password)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return "", "", nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("crumb issuer returned HTTP status: %v", resp.StatusCode)
+	}
+
+	var r struct {
+		Crumb             string `json:"crumb"`
+		CrumbRequestField string `json:"crumbRequestField"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	if err != nil {
+		return "", "", fmt.Errorf("crumb issuer response is not JSON: %v", err)
+	}
+
+	return r.CrumbRequestField, r.Crumb, nil
+}
@@ -0,0 +1,221 @@
+package jenkins
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// jenkinsStub is a Jenkins stand-in checking auth and crumb.
+type jenkinsStub struct {
+	mt *sync.Mutex
+
+	// csrf enables crumb issuer
+	csrf bool
+
+	// queueWaits is a quantity of queue item polls before build is started
+	queueWaits int
+
+	// buildPolls is a quantity of build polls before build is finished
+	buildPolls int
+
+	crumbRequests int
+	forms         []map[string]string
+	stopped       []string
+	cancelled     []string
+}
+
+const (
+	stubLogin    = "admin"
+	stubPassword = "qwerty123"
+	stubToken    = "11e3e5b4ba1d8bb55ac4b5a1ff3d4b2c"
+	stubSession  = "node01abc"
+	stubCrumb    = "4e2b0f3d"
+)
+
+func (stub *jenkinsStub) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	stub.mt.Lock()
+	defer stub.mt.Unlock()
+
+	login, password, _ := req.BasicAuth()
+	if login != stubLogin || (password != stubPassword && password != stubToken) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if req.URL.Path == "/crumbIssuer/api/json" {
+		if !stub.csrf {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		stub.crumbRequests++
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: stubSession, Path: "/"})
+		_, _ = fmt.Fprintf(w, `{"_class":"hudson.security.csrf.DefaultCrumbIssuer","crumb":"%v","crumbRequestField":"Jenkins-Crumb"}`, stubCrumb)
+		return
+	}
+
+	if req.Method == http.MethodPost && stub.csrf && password != stubToken {
+		// crumb is valid only in the session it is issued in
+		cookie, err := req.Cookie("JSESSIONID")
+		if err != nil || cookie.Value != stubSession || req.Header.Get("Jenkins-Crumb") != stubCrumb {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+
+	switch {
+	case req.Method == http.MethodPost && (req.URL.Path == "/job/Folder/job/SomeJob/buildWithParameters" || req.URL.Path == "/job/SomeJob/buildWithParameters" || req.URL.Path == "/job/SomeJob/build"):
+		_ = req.ParseForm()
+		form := make(map[string]string)
+		for key := range req.PostForm {
+			form[key] = req.PostForm.Get(key)
+		}
+		stub.forms = append(stub.forms, form)
+		w.Header().Set("Location", "http://"+req.Host+"/queue/item/12/")
+		w.WriteHeader(http.StatusCreated)
+	case req.Method == http.MethodPost && req.URL.Path == "/job/BrokenJob/build":
+		w.WriteHeader(http.StatusCreated)
+	case req.Method == http.MethodPost && req.URL.Path == "/job/SomeJob/20/stop":
+		stub.stopped = append(stub.stopped, "SomeJob/20")
+		w.WriteHeader(http.StatusOK)
+	case req.Method == http.MethodPost && req.URL.Path == "/queue/cancelItem":
+		stub.cancelled = append(stub.cancelled, req.URL.Query().Get("id"))
+		w.Header().Set("Location", "http://"+req.Host+"/queue/")
+		w.WriteHeader(http.StatusFound)
+	case req.Method == http.MethodGet && req.URL.Path == "/queue/":
+		w.WriteHeader(http.StatusOK)
+	case req.Method == http.MethodGet && req.URL.Path == "/queue/item/12/api/json":
+		if stub.queueWaits > 0 {
+			stub.queueWaits--
+			_, _ = w.Write([]byte(`{"_class":"hudson.model.Queue$BuildableItem","id":12,"why":"Waiting for next available executor"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"_class":"hudson.model.Queue$LeftItem","id":12,"cancelled":false,"executable":{"number":20,"url":"http://jenkins.company.com/job/SomeJob/20/"}}`))
+	case req.Method == http.MethodGet && req.URL.Path == "/queue/item/13/api/json":
+		_, _ = w.Write([]byte(`{"_class":"hudson.model.Queue$LeftItem","id":13,"cancelled":true,"executable":null}`))
+	case req.Method == http.MethodGet && req.URL.Path == "/job/SomeJob/20/api/json":
+		if stub.buildPolls > 0 {
+			stub.buildPolls--
+			_, _ = w.Write([]byte(`{"number":20,"url":"http://jenkins.company.com/job/SomeJob/20/","building":true,"result":null}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"number":20,"url":"http://jenkins.company.com/job/SomeJob/20/","building":false,"result":"SUCCESS"}`))
+	case req.Method == http.MethodGet && req.URL.Path == "/job/SomeJob/20/consoleText":
+		_, _ = w.Write([]byte("Started by user webhooker\r\n+ ./restart.sh\r\nERROR: service is not restarted\r\nFinished: FAILURE"))
+	case req.Method == http.MethodGet && req.URL.Path == "/job/SomeJob/21/api/json":
+		_, _ = w.Write([]byte(`<html>`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	type testTableData struct {
+		tcase                 string
+		csrf                  bool
+		password              string
+		apiToken              bool
+		expectedCrumbRequests int
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:                 "password with crumb",
+			csrf:                  true,
+			password:              stubPassword,
+			expectedCrumbRequests: 3,
+		},
+		{
+			tcase:                 "password without CSRF protection",
+			csrf:                  false,
+			password:              stubPassword,
+			expectedCrumbRequests: 0,
+		},
+		{
+			tcase:                 "api token",
+			csrf:                  true,
+			password:              stubToken,
+			apiToken:              true,
+			expectedCrumbRequests: 0,
+		},
+	}
+
+	for _, testUnit := range testTable {
+		stub := &jenkinsStub{mt: &sync.Mutex{}, csrf: testUnit.csrf}
+		server := httptest.NewServer(stub)
+
+		c := NewClient(server.URL+"/", stubLogin, testUnit.password, testUnit.apiToken)
+
+		queueID, err := c.BuildJob("Folder/job/SomeJob", map[string]string{"cluster": "c1"})
+		assert.Equal(t, nil, err, testUnit.tcase)
+		assert.Equal(t, int64(12), queueID, testUnit.tcase)
+
+		item, err := c.GetQueueItem(12)
+		assert.Equal(t, nil, err, testUnit.tcase)
+		assert.Equal(t, int64(20), item.Executable.Number, testUnit.tcase)
+
+		build, err := c.GetBuild("SomeJob", 20)
+		assert.Equal(t, nil, err, testUnit.tcase)
+		assert.Equal(t, Build{Number: 20, URL: "http://jenkins.company.com/job/SomeJob/20/", Result: "SUCCESS"}, build, testUnit.tcase)
+
+		tail, err := c.GetConsoleTail("SomeJob", 20, 2)
+		assert.Equal(t, nil, err, testUnit.tcase)
+		assert.Equal(t, "ERROR: service is not restarted\nFinished: FAILURE", tail, testUnit.tcase)
+
+		tail, err = c.GetConsoleTail("SomeJob", 20, 10)
+		assert.Equal(t, nil, err, testUnit.tcase)
+		assert.Equal(t, "Started by user webhooker\n+ ./restart.sh\nERROR: service is not restarted\nFinished: FAILURE", tail, testUnit.tcase)
+
+		assert.Equal(t, nil, c.StopBuild("SomeJob", 20), testUnit.tcase)
+		assert.Equal(t, nil, c.CancelQueueItem(12), testUnit.tcase)
+
+		stub.mt.Lock()
+		assert.Equal(t, []map[string]string{{"cluster": "c1"}}, stub.forms, testUnit.tcase)
+		assert.Equal(t, []string{"SomeJob/20"}, stub.stopped, testUnit.tcase)
+		assert.Equal(t, []string{"12"}, stub.cancelled, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedCrumbRequests, stub.crumbRequests, testUnit.tcase)
+		stub.mt.Unlock()
+
+		server.Close()
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(&jenkinsStub{mt: &sync.Mutex{}, csrf: true})
+	defer server.Close()
+
+	c := NewClient(server.URL, stubLogin, stubPassword, false)
+
+	queueID, err := c.BuildJob("SomeJob", nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(12), queueID)
+
+	queueID, err = c.BuildJob("BrokenJob", nil)
+	assert.Equal(t, errors.New(`build job response has no queue item location: ""`), err)
+	assert.Equal(t, int64(0), queueID)
+
+	item, err := c.GetQueueItem(13)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, QueueItem{ID: 13, Cancelled: true}, item)
+
+	_, err = c.GetBuild("SomeJob", 22)
+	assert.Equal(t, errors.New("GET /job/SomeJob/22/api/json returned HTTP status: 404"), err)
+
+	_, err = c.GetBuild("SomeJob", 21)
+	assert.Equal(t, errors.New("GET /job/SomeJob/21/api/json response is not JSON: invalid character '<' looking for beginning of value"), err)
+
+	c = NewClient(server.URL, stubLogin, "wrong", false)
+	_, err = c.BuildJob("SomeJob", nil)
+	assert.Equal(t, errors.New("crumb issuer returned HTTP status: 401"), err)
+
+	c = NewClient(server.URL, stubLogin, "wrong", true)
+	assert.Equal(t, errors.New("POST /job/SomeJob/20/stop returned HTTP status: 401"), c.StopBuild("SomeJob", 20))
+}
@@ -3,7 +3,6 @@ package jenkins
 import (
 	"errors"
 	"fmt"
-	"github.com/bndr/gojenkins"
 	"github.com/krpn/prometheus-alert-webhooker/executor"
 	"github.com/krpn/prometheus-alert-webhooker/utils"
 	"github.com/sirupsen/logrus"
@@ -16,31 +15,53 @@ const (
 	paramEndpoint               = "endpoint"
 	paramLogin                  = "login"
 	paramPassword               = "password"
+	paramAPIToken               = "api_token"
 	paramJob                    = "job"
 	paramParameterPrefix        = "job parameter "
 	paramStateRefreshDelay      = "state_refresh_delay"
 	paramSecureInterationsLimit = "secure_interations_limit"
+	paramBuildTimeout           = "build_timeout"
+	paramConsoleTailLines       = "console_tail_lines"
+	paramUnstableIsSuccess      = "unstable_is_success"
 
 	defaultStateRefreshDelay      = 15 * time.Second
 	defaultSecureBuildDelay       = 1 * time.Second
 	defaultSecureInterationsLimit = 1000
+	defaultConsoleTailLines       = 30
 )
 
+const context = "jenkins"
+
 var requiredStringParameters = []string{
 	paramEndpoint,
 	paramLogin,
-	paramPassword,
 	paramJob,
 }
 
+var stringParameters = []string{
+	paramPassword,
+	paramAPIToken,
+	paramBuildTimeout,
+}
+
+var errPasswordAndAPIToken = errors.New("one of password and api_token parameters is required")
+
+// Reasons of aborting queue item or build.
+const (
+	abortBuildTimeout          = "build timeout"
+	abortSecureIterationsLimit = "secure iterations limit"
+)
+
 //go:generate mockgen -source=jenkins.go -destination=jenkins_mocks.go -package=jenkins doc github.com/golang/mock/gomock
 
 // Jenkins is the interface of Jenkins client.
 type Jenkins interface {
-	Init() (*gojenkins.Jenkins, error)
-	BuildJob(name string, options ...interface{}) (int64, error)
-	GetBuild(jobName string, number int64) (*gojenkins.Build, error)
-	GetAllBuildIds(job string) ([]gojenkins.JobBuild, error)
+	BuildJob(job string, parameters map[string]string) (int64, error)
+	GetQueueItem(id int64) (QueueItem, error)
+	CancelQueueItem(id int64) error
+	GetBuild(job string, number int64) (Build, error)
+	StopBuild(job string, number int64) error
+	GetConsoleTail(job string, number int64, lines int) (string, error)
 }
 
 type task struct {
@@ -49,8 +70,14 @@ type task struct {
 	stateRefreshDelay      time.Duration
 	secureInterationsLimit int
 	secureBuildDelay       time.Duration
+	buildTimeout           time.Duration
+	consoleTailLines       int
+	unstableIsSuccess      bool
 	parameters             map[string]string
 	jenkins                Jenkins
+
+	// output is set after build is found.
+	output map[string]interface{}
 }
 
 func (task *task) ExecutorName() string {
@@ -85,89 +112,154 @@ func (task *task) Fingerprint() string {
 }
 
 func (task *task) Exec(logger *logrus.Logger) error {
-	queueID, err := runJob(task.jenkins, task.job, task.parameters)
+	queueID, err := task.jenkins.BuildJob(task.job, task.parameters)
 	if err != nil {
 		return err
 	}
 
+	started := time.Now()
 	time.Sleep(task.secureBuildDelay)
 
 	var (
 		buildID int64
-		job     *gojenkins.Build
+		build   Build
 		iter    int
 	)
 	for {
-		if iter >= task.secureInterationsLimit {
-			return errors.New("secure iterations limit exceed")
-		}
 		iter++
 
 		time.Sleep(task.stateRefreshDelay)
 
-		buildID, err = getBuildIDEffectively(buildID, task.jenkins, task.job, queueID)
-		if err != nil {
-			return err
+		// abort is set to reason queue item or build is aborted for, hung build is not left running
+		abort := ""
+		switch {
+		case task.buildTimeout > 0 && time.Since(started) > task.buildTimeout:
+			abort = abortBuildTimeout
+		case iter >= task.secureInterationsLimit:
+			abort = abortSecureIterationsLimit
 		}
 
 		if buildID == 0 {
-			continue
+			buildID, err = task.waitQueueItem(queueID, abort)
+			if err != nil {
+				return err
+			}
+
+			if buildID == 0 {
+				continue
+			}
 		}
 
-		job, err = task.jenkins.GetBuild(task.job, buildID)
+		found := task.output == nil
+		build, err = task.jenkins.GetBuild(task.job, buildID)
 		if err != nil {
 			return err
 		}
+		task.setOutput(build)
+		if found {
+			task.logger(logger, build).Info("build is started")
+		}
 
-		if job.Raw.Building {
-			continue
+		if !build.Building {
+			break
 		}
 
-		break
+		if len(abort) > 0 {
+			err = task.jenkins.StopBuild(task.job, buildID)
+			if err != nil {
+				return fmt.Errorf("%v, build %v abort error: %v", task.exceeded(abort), build.URL, err)
+			}
+			task.logger(logger, build).Warnf("%v exceeded, build is aborted", abort)
+			return fmt.Errorf("%v, build %v is aborted", task.exceeded(abort), build.URL)
+		}
 	}
 
-	if job.Raw.Result != gojenkins.STATUS_SUCCESS {
-		return errors.New("build failed")
+	task.logger(logger, build).Info("build is finished")
+
+	if task.success(build.Result) {
+		return nil
 	}
 
-	return nil
+	return task.failed(build)
+}
+
+func (task *task) success(result string) bool {
+	return result == resultSuccess || (task.unstableIsSuccess && result == resultUnstable)
 }
 
-func runJob(j Jenkins, job string, parameters map[string]string) (int64, error) {
-	_, err := j.Init()
+// failed returns error of failed build with the tail of its console output.
+func (task *task) failed(build Build) error {
+	if task.consoleTailLines <= 0 {
+		return fmt.Errorf("build %v failed with result %v", build.URL, build.Result)
+	}
+
+	tail, err := task.jenkins.GetConsoleTail(task.job, build.Number, task.consoleTailLines)
 	if err != nil {
-		return 0, err
+		return fmt.Errorf("build %v failed with result %v, console output error: %v", build.URL, build.Result, err)
 	}
 
-	return j.BuildJob(job, parameters)
+	task.output["console_tail"] = tail
+	return fmt.Errorf("build %v failed with result %v, console output tail:\n%v", build.URL, build.Result, tail)
+}
+
+func (task *task) logger(logger *logrus.Logger, build Build) *logrus.Entry {
+	return logger.WithFields(executor.TaskDetails(task)).WithFields(logrus.Fields{
+		"context":      context,
+		"build_url":    build.URL,
+		"build_result": build.Result,
+	})
 }
 
-func getBuildIDEffectively(currBuildID int64, j Jenkins, job string, queueID int64) (int64, error) {
-	if currBuildID != 0 {
-		return currBuildID, nil
+func (task *task) setOutput(build Build) {
+	task.output = map[string]interface{}{
+		"build_number": build.Number,
+		"build_url":    build.URL,
+	}
+	if len(build.Result) > 0 {
+		task.output["build_result"] = build.Result
 	}
+}
 
-	return getBuildID(j, job, queueID)
+// Output returns number, URL and result of build.
+func (task *task) Output() map[string]interface{} {
+	return task.output
 }
 
-func getBuildID(j Jenkins, job string, queueID int64) (int64, error) {
-	builds, err := j.GetAllBuildIds(job)
+// waitQueueItem returns number of build started from queue item or 0 if item is still waiting in queue.
+// Waiting item is cancelled if abort reason is set.
+func (task *task) waitQueueItem(queueID int64, abort string) (int64, error) {
+	item, err := task.jenkins.GetQueueItem(queueID)
 	if err != nil {
 		return 0, err
 	}
 
-	for _, buildID := range builds {
-		build, err := j.GetBuild(job, buildID.Number)
-		if err != nil {
-			return 0, err
-		}
+	if item.Cancelled {
+		return 0, fmt.Errorf("queue item %v is cancelled", queueID)
+	}
 
-		if build.Raw.QueueID == queueID {
-			return buildID.Number, nil
-		}
+	if item.Executable != nil {
+		return item.Executable.Number, nil
 	}
 
-	return 0, nil
+	if len(abort) == 0 {
+		return 0, nil
+	}
+
+	err = task.jenkins.CancelQueueItem(queueID)
+	if err != nil {
+		return 0, fmt.Errorf("%v, queue item %v cancel error: %v", task.exceeded(abort), queueID, err)
+	}
+
+	return 0, fmt.Errorf("%v, build is not started and queue item %v is cancelled: %v", task.exceeded(abort), queueID, item.Why)
+}
+
+// exceeded describes exceeded limit of abort reason.
+func (task *task) exceeded(abort string) string {
+	if abort == abortSecureIterationsLimit {
+		return fmt.Sprintf("%v %v exceeded", abort, task.secureInterationsLimit)
+	}
+
+	return fmt.Sprintf("%v %v exceeded", abort, task.buildTimeout)
 }
 
 type taskExecutor struct{}
@@ -189,6 +281,39 @@ func (executor taskExecutor) ValidateParameters(parameters map[string]interface{
 		}
 	}
 
+	for _, param := range stringParameters {
+		if _, ok := parameters[param]; ok {
+			if _, ok := parameters[param].(string); !ok {
+				return fmt.Errorf("%v parameter value is not a string", param)
+			}
+		}
+	}
+
+	_, password := parameters[paramPassword]
+	_, apiToken := parameters[paramAPIToken]
+	if password == apiToken {
+		return errPasswordAndAPIToken
+	}
+
+	if lines, ok := parameters[paramConsoleTailLines]; ok {
+		if _, ok := lines.(int); !ok {
+			return fmt.Errorf("%v parameter value is not an integer", paramConsoleTailLines)
+		}
+	}
+
+	if unstable, ok := parameters[paramUnstableIsSuccess]; ok {
+		if _, ok := unstable.(bool); !ok {
+			return fmt.Errorf("%v parameter value is not a boolean", paramUnstableIsSuccess)
+		}
+	}
+
+	if timeout, ok := parameters[paramBuildTimeout].(string); ok {
+		_, err := time.ParseDuration(timeout)
+		if err != nil {
+			return fmt.Errorf("%v parameter: %v", paramBuildTimeout, err)
+		}
+	}
+
 	for key, val := range parameters {
 		if !strings.HasPrefix(key, paramParameterPrefix) {
 			continue
@@ -222,6 +347,20 @@ func (executor taskExecutor) NewTask(eventID, rule, alert string, blockTTL time.
 
 	task.secureBuildDelay = defaultSecureBuildDelay
 
+	task.consoleTailLines = defaultConsoleTailLines
+	if lines, ok := preparedParameters[paramConsoleTailLines].(int); ok {
+		task.consoleTailLines = lines
+	}
+
+	task.unstableIsSuccess, _ = preparedParameters[paramUnstableIsSuccess].(bool)
+
+	if timeoutStr, ok := preparedParameters[paramBuildTimeout].(string); ok {
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err == nil {
+			task.buildTimeout = timeout
+		}
+	}
+
 	parameters := make(map[string]string)
 	for key, val := range preparedParameters {
 		valStr, ok := val.(string)
@@ -234,11 +373,17 @@ func (executor taskExecutor) NewTask(eventID, rule, alert string, blockTTL time.
 	}
 	task.parameters = parameters
 
-	task.jenkins = gojenkins.CreateJenkins(
-		nil,
+	password, _ := preparedParameters[paramPassword].(string)
+	token, apiToken := preparedParameters[paramAPIToken].(string)
+	if apiToken {
+		password = token
+	}
+
+	task.jenkins = NewClient(
 		preparedParameters[paramEndpoint].(string),
-		preparedParameters[paramLogin],
-		preparedParameters[paramPassword],
+		preparedParameters[paramLogin].(string),
+		password,
+		apiToken,
 	)
 	task.SetBase(eventID, rule, alert, blockTTL)
 	return task
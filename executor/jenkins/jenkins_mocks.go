@@ -5,7 +5,6 @@
 package jenkins
 
 import (
-	gojenkins "github.com/bndr/gojenkins"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
 )
@@ -33,59 +32,78 @@ func (m *MockJenkins) EXPECT() *MockJenkinsMockRecorder {
 	return m.recorder
 }
 
-// Init mocks base method
-func (m *MockJenkins) Init() (*gojenkins.Jenkins, error) {
-	ret := m.ctrl.Call(m, "Init")
-	ret0, _ := ret[0].(*gojenkins.Jenkins)
+// BuildJob mocks base method
+func (m *MockJenkins) BuildJob(job string, parameters map[string]string) (int64, error) {
+	ret := m.ctrl.Call(m, "BuildJob", job, parameters)
+	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// Init indicates an expected call of Init
-func (mr *MockJenkinsMockRecorder) Init() *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockJenkins)(nil).Init))
+// BuildJob indicates an expected call of BuildJob
+func (mr *MockJenkinsMockRecorder) BuildJob(job, parameters interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuildJob", reflect.TypeOf((*MockJenkins)(nil).BuildJob), job, parameters)
 }
 
-// BuildJob mocks base method
-func (m *MockJenkins) BuildJob(name string, options ...interface{}) (int64, error) {
-	varargs := []interface{}{name}
-	for _, a := range options {
-		varargs = append(varargs, a)
-	}
-	ret := m.ctrl.Call(m, "BuildJob", varargs...)
-	ret0, _ := ret[0].(int64)
+// GetQueueItem mocks base method
+func (m *MockJenkins) GetQueueItem(id int64) (QueueItem, error) {
+	ret := m.ctrl.Call(m, "GetQueueItem", id)
+	ret0, _ := ret[0].(QueueItem)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// BuildJob indicates an expected call of BuildJob
-func (mr *MockJenkinsMockRecorder) BuildJob(name interface{}, options ...interface{}) *gomock.Call {
-	varargs := append([]interface{}{name}, options...)
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuildJob", reflect.TypeOf((*MockJenkins)(nil).BuildJob), varargs...)
+// GetQueueItem indicates an expected call of GetQueueItem
+func (mr *MockJenkinsMockRecorder) GetQueueItem(id interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQueueItem", reflect.TypeOf((*MockJenkins)(nil).GetQueueItem), id)
+}
+
+// CancelQueueItem mocks base method
+func (m *MockJenkins) CancelQueueItem(id int64) error {
+	ret := m.ctrl.Call(m, "CancelQueueItem", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelQueueItem indicates an expected call of CancelQueueItem
+func (mr *MockJenkinsMockRecorder) CancelQueueItem(id interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelQueueItem", reflect.TypeOf((*MockJenkins)(nil).CancelQueueItem), id)
 }
 
 // GetBuild mocks base method
-func (m *MockJenkins) GetBuild(jobName string, number int64) (*gojenkins.Build, error) {
-	ret := m.ctrl.Call(m, "GetBuild", jobName, number)
-	ret0, _ := ret[0].(*gojenkins.Build)
+func (m *MockJenkins) GetBuild(job string, number int64) (Build, error) {
+	ret := m.ctrl.Call(m, "GetBuild", job, number)
+	ret0, _ := ret[0].(Build)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetBuild indicates an expected call of GetBuild
-func (mr *MockJenkinsMockRecorder) GetBuild(jobName, number interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBuild", reflect.TypeOf((*MockJenkins)(nil).GetBuild), jobName, number)
+func (mr *MockJenkinsMockRecorder) GetBuild(job, number interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBuild", reflect.TypeOf((*MockJenkins)(nil).GetBuild), job, number)
+}
+
+// StopBuild mocks base method
+func (m *MockJenkins) StopBuild(job string, number int64) error {
+	ret := m.ctrl.Call(m, "StopBuild", job, number)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StopBuild indicates an expected call of StopBuild
+func (mr *MockJenkinsMockRecorder) StopBuild(job, number interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StopBuild", reflect.TypeOf((*MockJenkins)(nil).StopBuild), job, number)
 }
 
-// GetAllBuildIds mocks base method
-func (m *MockJenkins) GetAllBuildIds(job string) ([]gojenkins.JobBuild, error) {
-	ret := m.ctrl.Call(m, "GetAllBuildIds", job)
-	ret0, _ := ret[0].([]gojenkins.JobBuild)
+// GetConsoleTail mocks base method
+func (m *MockJenkins) GetConsoleTail(job string, number int64, lines int) (string, error) {
+	ret := m.ctrl.Call(m, "GetConsoleTail", job, number, lines)
+	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAllBuildIds indicates an expected call of GetAllBuildIds
-func (mr *MockJenkinsMockRecorder) GetAllBuildIds(job interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllBuildIds", reflect.TypeOf((*MockJenkins)(nil).GetAllBuildIds), job)
+// GetConsoleTail indicates an expected call of GetConsoleTail
+func (mr *MockJenkinsMockRecorder) GetConsoleTail(job, number, lines interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConsoleTail", reflect.TypeOf((*MockJenkins)(nil).GetConsoleTail), job, number, lines)
 }
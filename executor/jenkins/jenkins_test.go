@@ -2,11 +2,13 @@ package jenkins
 
 import (
 	"errors"
-	"github.com/bndr/gojenkins"
+	"fmt"
 	"github.com/golang/mock/gomock"
 	"github.com/krpn/prometheus-alert-webhooker/executor"
 	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 )
@@ -81,6 +83,8 @@ func TestJenkinsTaskExecutor_ValidateParameters(t *testing.T) {
 
 	executorMock := NewExecutor()
 
+	_, errDuration := time.ParseDuration("30")
+
 	type testTableData struct {
 		tcase    string
 		params   map[string]interface{}
@@ -98,14 +102,78 @@ func TestJenkinsTaskExecutor_ValidateParameters(t *testing.T) {
 			},
 			expected: nil,
 		},
+		{
+			tcase: "correct params with api token",
+			params: map[string]interface{}{
+				"endpoint":      "http://jenkins.company.com/",
+				"job":           "SomeJob",
+				"login":         "admin",
+				"api_token":     "11e3e5b4ba1d8bb55ac4b5a1ff3d4b2c",
+				"build_timeout": "30m",
+			},
+			expected: nil,
+		},
 		{
 			tcase: "param missing",
+			params: map[string]interface{}{
+				"endpoint": "http://jenkins.company.com/",
+				"job":      "SomeJob",
+				"password": "qwerty123",
+			},
+			expected: errors.New("required parameter login is missing"),
+		},
+		{
+			tcase: "password and api token missing",
 			params: map[string]interface{}{
 				"endpoint": "http://jenkins.company.com/",
 				"job":      "SomeJob",
 				"login":    "admin",
 			},
-			expected: errors.New("required parameter password is missing"),
+			expected: errPasswordAndAPIToken,
+		},
+		{
+			tcase: "password and api token together",
+			params: map[string]interface{}{
+				"endpoint":  "http://jenkins.company.com/",
+				"job":       "SomeJob",
+				"login":     "admin",
+				"password":  "qwerty123",
+				"api_token": "11e3e5b4ba1d8bb55ac4b5a1ff3d4b2c",
+			},
+			expected: errPasswordAndAPIToken,
+		},
+		{
+			tcase: "console_tail_lines wrong type",
+			params: map[string]interface{}{
+				"endpoint":           "http://jenkins.company.com/",
+				"job":                "SomeJob",
+				"login":              "admin",
+				"password":           "qwerty123",
+				"console_tail_lines": "50",
+			},
+			expected: errors.New("console_tail_lines parameter value is not an integer"),
+		},
+		{
+			tcase: "unstable_is_success wrong type",
+			params: map[string]interface{}{
+				"endpoint":            "http://jenkins.company.com/",
+				"job":                 "SomeJob",
+				"login":               "admin",
+				"password":            "qwerty123",
+				"unstable_is_success": "yes",
+			},
+			expected: errors.New("unstable_is_success parameter value is not a boolean"),
+		},
+		{
+			tcase: "wrong build timeout",
+			params: map[string]interface{}{
+				"endpoint":      "http://jenkins.company.com/",
+				"job":           "SomeJob",
+				"login":         "admin",
+				"password":      "qwerty123",
+				"build_timeout": "30",
+			},
+			expected: fmt.Errorf("build_timeout parameter: %v", errDuration),
 		},
 		{
 			tcase: "param wrong type",
@@ -159,25 +227,26 @@ func TestJenkinsTaskExecutor_NewTask(t *testing.T) {
 				"endpoint":                              "http://jenkins.company.com/",
 				"job":                                   "SomeJob",
 				"login":                                 "admin",
-				"password":                              "qwerty123",
+				"api_token":                             "token123",
 				"state_refresh_delay":                   "1m",
 				"secure_interations_limit":              666,
+				"build_timeout":                         "30m",
+				"console_tail_lines":                    50,
+				"unstable_is_success":                   true,
 				"job parameter test":                    "test1",
 				"job parameter test job parameter test": "test2",
 			},
 			expected: func() executor.Task {
 				task := &task{
-					jenkins: gojenkins.CreateJenkins(
-						nil,
-						"http://jenkins.company.com/",
-						"admin",
-						"qwerty123",
-					),
+					jenkins: NewClient("http://jenkins.company.com/", "admin", "token123", true),
 				}
 				task.job = "SomeJob"
 				task.stateRefreshDelay = 1 * time.Minute
 				task.secureInterationsLimit = 666
 				task.secureBuildDelay = defaultSecureBuildDelay
+				task.buildTimeout = 30 * time.Minute
+				task.consoleTailLines = 50
+				task.unstableIsSuccess = true
 				task.parameters = map[string]string{
 					"test":                    "test1",
 					"test job parameter test": "test2",
@@ -200,18 +269,14 @@ func TestJenkinsTaskExecutor_NewTask(t *testing.T) {
 			},
 			expected: func() executor.Task {
 				task := &task{
-					jenkins: gojenkins.CreateJenkins(
-						nil,
-						"http://jenkins.company.com/",
-						"admin",
-						"qwerty123",
-					),
+					jenkins: NewClient("http://jenkins.company.com/", "admin", "qwerty123", false),
 				}
 				task.job = "SomeJob"
 				task.stateRefreshDelay = defaultStateRefreshDelay
 				task.secureInterationsLimit = defaultSecureInterationsLimit
 				task.parameters = map[string]string{}
 				task.secureBuildDelay = defaultSecureBuildDelay
+				task.consoleTailLines = defaultConsoleTailLines
 				task.SetBase("825e", "testrule1", "testalert1", 1*time.Second)
 				return task
 			},
@@ -223,7 +288,7 @@ func TestJenkinsTaskExecutor_NewTask(t *testing.T) {
 	}
 }
 
-func TestGetBuildID(t *testing.T) {
+func TestJenkinsTask_Exec(t *testing.T) {
 	t.Parallel()
 
 	ctrl := gomock.NewController(t)
@@ -231,179 +296,321 @@ func TestGetBuildID(t *testing.T) {
 
 	jenkinsMock := NewMockJenkins(ctrl)
 
+	logger, hook := test.NewNullLogger()
+
+	newTask := func(buildTimeout time.Duration, consoleTailLines int, unstableIsSuccess bool) *task {
+		task := &task{
+			job:                    "SomeJob",
+			stateRefreshDelay:      0 * time.Second,
+			secureInterationsLimit: 4,
+			secureBuildDelay:       0 * time.Second,
+			buildTimeout:           buildTimeout,
+			consoleTailLines:       consoleTailLines,
+			unstableIsSuccess:      unstableIsSuccess,
+			parameters:             map[string]string{"test": "test1"},
+			jenkins:                jenkinsMock,
+		}
+		task.SetBase("id", "rule", "alert", 10*time.Minute)
+		return task
+	}
+
+	buildURL := "http://jenkins.company.com/job/SomeJob/20/"
+
+	started := QueueItem{ID: 10}
+	started.Executable = &struct {
+		Number int64  `json:"number"`
+		URL    string `json:"url"`
+	}{Number: 20, URL: buildURL}
+
 	type testTableData struct {
-		tcase           string
-		job             string
-		queueID         int64
-		expectFunc      func(j *MockJenkins, jb string, qID int64)
-		expectedBuildID int64
-		expectedErr     error
+		tcase             string
+		buildTimeout      time.Duration
+		consoleTailLines  int
+		unstableIsSuccess bool
+		expectFunc        func(j *MockJenkins)
+		expectedErr       error
+		expectedOutput    map[string]interface{}
+		expectedLogs      []string
 	}
 
 	testTable := []testTableData{
 		{
-			tcase:   "3 loops",
-			job:     "test",
-			queueID: 10,
-			expectFunc: func(j *MockJenkins, jb string, qID int64) {
-				j.EXPECT().GetAllBuildIds(jb).Return([]gojenkins.JobBuild{{Number: 1}, {Number: 2}, {Number: 3}}, nil)
-				j.EXPECT().GetBuild(jb, int64(1)).Return(&gojenkins.Build{Raw: &gojenkins.BuildResponse{QueueID: 4}}, nil)
-				j.EXPECT().GetBuild(jb, int64(2)).Return(&gojenkins.Build{Raw: &gojenkins.BuildResponse{QueueID: 8}}, nil)
-				j.EXPECT().GetBuild(jb, int64(3)).Return(&gojenkins.Build{Raw: &gojenkins.BuildResponse{QueueID: 10}}, nil)
+			tcase: "3 loops",
+			expectFunc: func(j *MockJenkins) {
+				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(10), nil)
+				j.EXPECT().GetQueueItem(int64(10)).Return(QueueItem{ID: 10, Why: "Waiting for next available executor"}, nil)
+				j.EXPECT().GetQueueItem(int64(10)).Return(started, nil)
+				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(Build{Number: 20, URL: buildURL, Building: true}, nil).Times(2)
+				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(Build{Number: 20, URL: buildURL, Building: false, Result: "SUCCESS"}, nil)
 			},
-			expectedBuildID: 3,
-			expectedErr:     nil,
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"build_number": int64(20), "build_url": buildURL, "build_result": "SUCCESS"},
+			expectedLogs:   []string{"build is started", "build is finished"},
 		},
 		{
-			tcase:   "3 loops + not found",
-			job:     "test",
-			queueID: 10,
-			expectFunc: func(j *MockJenkins, jb string, qID int64) {
-				j.EXPECT().GetAllBuildIds(jb).Return([]gojenkins.JobBuild{{Number: 1}, {Number: 2}, {Number: 3}}, nil)
-				j.EXPECT().GetBuild(jb, int64(1)).Return(&gojenkins.Build{Raw: &gojenkins.BuildResponse{QueueID: 4}}, nil)
-				j.EXPECT().GetBuild(jb, int64(2)).Return(&gojenkins.Build{Raw: &gojenkins.BuildResponse{QueueID: 6}}, nil)
-				j.EXPECT().GetBuild(jb, int64(3)).Return(&gojenkins.Build{Raw: &gojenkins.BuildResponse{QueueID: 8}}, nil)
+			tcase: "build failed",
+			expectFunc: func(j *MockJenkins) {
+				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(10), nil)
+				j.EXPECT().GetQueueItem(int64(10)).Return(started, nil)
+				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(Build{Number: 20, URL: buildURL, Building: false, Result: "FAILURE"}, nil)
 			},
-			expectedBuildID: 0,
-			expectedErr:     nil,
+			expectedErr:    errors.New("build http://jenkins.company.com/job/SomeJob/20/ failed with result FAILURE"),
+			expectedOutput: map[string]interface{}{"build_number": int64(20), "build_url": buildURL, "build_result": "FAILURE"},
+			expectedLogs:   []string{"build is started", "build is finished"},
 		},
 		{
-			tcase:   "get all builds error",
-			job:     "test",
-			queueID: 10,
-			expectFunc: func(j *MockJenkins, jb string, qID int64) {
-				j.EXPECT().GetAllBuildIds(jb).Return(nil, errors.New("get all builds error"))
+			tcase:            "build failed + console tail",
+			consoleTailLines: 2,
+			expectFunc: func(j *MockJenkins) {
+				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(10), nil)
+				j.EXPECT().GetQueueItem(int64(10)).Return(started, nil)
+				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(Build{Number: 20, URL: buildURL, Building: false, Result: "FAILURE"}, nil)
+				j.EXPECT().GetConsoleTail("SomeJob", int64(20), 2).Return("ERROR: service is not restarted\nFinished: FAILURE", nil)
 			},
-			expectedBuildID: 0,
-			expectedErr:     errors.New("get all builds error"),
+			expectedErr:    errors.New("build http://jenkins.company.com/job/SomeJob/20/ failed with result FAILURE, console output tail:\nERROR: service is not restarted\nFinished: FAILURE"),
+			expectedOutput: map[string]interface{}{"build_number": int64(20), "build_url": buildURL, "build_result": "FAILURE", "console_tail": "ERROR: service is not restarted\nFinished: FAILURE"},
+			expectedLogs:   []string{"build is started", "build is finished"},
 		},
 		{
-			tcase:   "get build error",
-			job:     "test",
-			queueID: 10,
-			expectFunc: func(j *MockJenkins, jb string, qID int64) {
-				j.EXPECT().GetAllBuildIds(jb).Return([]gojenkins.JobBuild{{Number: 1}, {Number: 2}, {Number: 3}}, nil)
-				j.EXPECT().GetBuild(jb, int64(1)).Return(&gojenkins.Build{Raw: &gojenkins.BuildResponse{QueueID: 4}}, nil)
-				j.EXPECT().GetBuild(jb, int64(2)).Return(nil, errors.New("get build error"))
+			tcase:            "build failed + console tail error",
+			consoleTailLines: 2,
+			expectFunc: func(j *MockJenkins) {
+				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(10), nil)
+				j.EXPECT().GetQueueItem(int64(10)).Return(started, nil)
+				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(Build{Number: 20, URL: buildURL, Building: false, Result: "FAILURE"}, nil)
+				j.EXPECT().GetConsoleTail("SomeJob", int64(20), 2).Return("", errors.New("GET /job/SomeJob/20/consoleText returned HTTP status: 404"))
 			},
-			expectedBuildID: 0,
-			expectedErr:     errors.New("get build error"),
+			expectedErr:    errors.New("build http://jenkins.company.com/job/SomeJob/20/ failed with result FAILURE, console output error: GET /job/SomeJob/20/consoleText returned HTTP status: 404"),
+			expectedOutput: map[string]interface{}{"build_number": int64(20), "build_url": buildURL, "build_result": "FAILURE"},
+			expectedLogs:   []string{"build is started", "build is finished"},
 		},
-	}
-
-	for _, testUnit := range testTable {
-		testUnit.expectFunc(jenkinsMock, testUnit.job, testUnit.queueID)
-		buildID, err := getBuildID(jenkinsMock, testUnit.job, testUnit.queueID)
-		assert.Equal(t, testUnit.expectedBuildID, buildID, testUnit.tcase)
-		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
-	}
-}
-
-func TestJenkinsTask_Exec(t *testing.T) {
-	t.Parallel()
-
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-
-	jenkinsMock := NewMockJenkins(ctrl)
-
-	logger, hook := test.NewNullLogger()
-
-	task := &task{
-		job:                    "SomeJob",
-		stateRefreshDelay:      0 * time.Second,
-		secureInterationsLimit: 4,
-		secureBuildDelay:       0 * time.Second,
-		parameters:             map[string]string{"test": "test1"},
-		jenkins:                jenkinsMock,
-	}
-	task.SetBase("id", "rule", "alert", 10*time.Minute)
-
-	type testTableData struct {
-		tcase       string
-		expectFunc  func(j *MockJenkins)
-		expectedErr error
-	}
-
-	testTable := []testTableData{
 		{
-			tcase: "3 loops",
+			tcase:             "unstable is success",
+			unstableIsSuccess: true,
 			expectFunc: func(j *MockJenkins) {
-				j.EXPECT().Init().Return(nil, nil)
 				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(10), nil)
-				j.EXPECT().GetAllBuildIds("SomeJob").Return([]gojenkins.JobBuild{{Number: 5}}, nil)
-				j.EXPECT().GetBuild("SomeJob", int64(5)).Return(&gojenkins.Build{Raw: &gojenkins.BuildResponse{QueueID: 1}}, nil)
-				j.EXPECT().GetAllBuildIds("SomeJob").Return([]gojenkins.JobBuild{{Number: 5}, {Number: 20}}, nil)
-				j.EXPECT().GetBuild("SomeJob", int64(5)).Return(&gojenkins.Build{Raw: &gojenkins.BuildResponse{QueueID: 1}}, nil)
-				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(&gojenkins.Build{Raw: &gojenkins.BuildResponse{QueueID: 10}}, nil)
-				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(&gojenkins.Build{Raw: &gojenkins.BuildResponse{Building: true}}, nil).Times(2)
-				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(&gojenkins.Build{Raw: &gojenkins.BuildResponse{Building: false, Result: gojenkins.STATUS_SUCCESS}}, nil)
+				j.EXPECT().GetQueueItem(int64(10)).Return(started, nil)
+				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(Build{Number: 20, URL: buildURL, Building: false, Result: "UNSTABLE"}, nil)
 			},
-			expectedErr: nil,
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"build_number": int64(20), "build_url": buildURL, "build_result": "UNSTABLE"},
+			expectedLogs:   []string{"build is started", "build is finished"},
 		},
 		{
-			tcase: "build failed",
+			tcase: "unstable is failure by default",
+			expectFunc: func(j *MockJenkins) {
+				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(10), nil)
+				j.EXPECT().GetQueueItem(int64(10)).Return(started, nil)
+				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(Build{Number: 20, URL: buildURL, Building: false, Result: "UNSTABLE"}, nil)
+			},
+			expectedErr:    errors.New("build http://jenkins.company.com/job/SomeJob/20/ failed with result UNSTABLE"),
+			expectedOutput: map[string]interface{}{"build_number": int64(20), "build_url": buildURL, "build_result": "UNSTABLE"},
+			expectedLogs:   []string{"build is started", "build is finished"},
+		},
+		{
+			tcase:        "build timeout",
+			buildTimeout: time.Nanosecond,
+			expectFunc: func(j *MockJenkins) {
+				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(10), nil)
+				j.EXPECT().GetQueueItem(int64(10)).Return(started, nil)
+				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(Build{Number: 20, URL: buildURL, Building: true}, nil)
+				j.EXPECT().StopBuild("SomeJob", int64(20)).Return(nil)
+			},
+			expectedErr:    errors.New("build timeout 1ns exceeded, build http://jenkins.company.com/job/SomeJob/20/ is aborted"),
+			expectedOutput: map[string]interface{}{"build_number": int64(20), "build_url": buildURL},
+			expectedLogs:   []string{"build is started", "build timeout exceeded, build is aborted"},
+		},
+		{
+			tcase:        "build timeout + abort error",
+			buildTimeout: time.Nanosecond,
+			expectFunc: func(j *MockJenkins) {
+				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(10), nil)
+				j.EXPECT().GetQueueItem(int64(10)).Return(started, nil)
+				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(Build{Number: 20, URL: buildURL, Building: true}, nil)
+				j.EXPECT().StopBuild("SomeJob", int64(20)).Return(errors.New("POST /job/SomeJob/20/stop returned HTTP status: 403"))
+			},
+			expectedErr:    errors.New("build timeout 1ns exceeded, build http://jenkins.company.com/job/SomeJob/20/ abort error: POST /job/SomeJob/20/stop returned HTTP status: 403"),
+			expectedOutput: map[string]interface{}{"build_number": int64(20), "build_url": buildURL},
+			expectedLogs:   []string{"build is started"},
+		},
+		{
+			tcase:        "build timeout + build is not started",
+			buildTimeout: time.Nanosecond,
+			expectFunc: func(j *MockJenkins) {
+				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(10), nil)
+				j.EXPECT().GetQueueItem(int64(10)).Return(QueueItem{ID: 10, Why: "Waiting for next available executor"}, nil)
+				j.EXPECT().CancelQueueItem(int64(10)).Return(nil)
+			},
+			expectedErr:    errors.New("build timeout 1ns exceeded, build is not started and queue item 10 is cancelled: Waiting for next available executor"),
+			expectedOutput: nil,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase:        "build timeout + queue item cancel error",
+			buildTimeout: time.Nanosecond,
 			expectFunc: func(j *MockJenkins) {
-				j.EXPECT().Init().Return(nil, nil)
 				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(10), nil)
-				j.EXPECT().GetAllBuildIds("SomeJob").Return([]gojenkins.JobBuild{{Number: 20}}, nil)
-				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(&gojenkins.Build{Raw: &gojenkins.BuildResponse{QueueID: 10}}, nil)
-				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(&gojenkins.Build{Raw: &gojenkins.BuildResponse{Building: false, Result: gojenkins.STATUS_FAIL}}, nil)
+				j.EXPECT().GetQueueItem(int64(10)).Return(QueueItem{ID: 10}, nil)
+				j.EXPECT().CancelQueueItem(int64(10)).Return(errors.New("POST /queue/cancelItem?id=10 returned HTTP status: 403"))
 			},
-			expectedErr: errors.New("build failed"),
+			expectedErr:    errors.New("build timeout 1ns exceeded, queue item 10 cancel error: POST /queue/cancelItem?id=10 returned HTTP status: 403"),
+			expectedOutput: nil,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase: "queue item is cancelled",
+			expectFunc: func(j *MockJenkins) {
+				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(10), nil)
+				j.EXPECT().GetQueueItem(int64(10)).Return(QueueItem{ID: 10}, nil)
+				j.EXPECT().GetQueueItem(int64(10)).Return(QueueItem{ID: 10, Cancelled: true}, nil)
+			},
+			expectedErr:    errors.New("queue item 10 is cancelled"),
+			expectedOutput: nil,
+			expectedLogs:   []string{},
 		},
 		{
 			tcase: "get build error",
 			expectFunc: func(j *MockJenkins) {
-				j.EXPECT().Init().Return(nil, nil)
 				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(10), nil)
-				j.EXPECT().GetAllBuildIds("SomeJob").Return([]gojenkins.JobBuild{{Number: 20}}, nil)
-				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(&gojenkins.Build{Raw: &gojenkins.BuildResponse{QueueID: 10}}, nil)
-				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(nil, errors.New("get build error"))
+				j.EXPECT().GetQueueItem(int64(10)).Return(started, nil)
+				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(Build{}, errors.New("get build error"))
 			},
-			expectedErr: errors.New("get build error"),
+			expectedErr:    errors.New("get build error"),
+			expectedOutput: nil,
+			expectedLogs:   []string{},
 		},
 		{
-			tcase: "get build ID error",
+			tcase: "get queue item error",
 			expectFunc: func(j *MockJenkins) {
-				j.EXPECT().Init().Return(nil, nil)
 				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(10), nil)
-				j.EXPECT().GetAllBuildIds("SomeJob").Return([]gojenkins.JobBuild{{Number: 20}}, nil)
-				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(nil, errors.New("get build ID error"))
+				j.EXPECT().GetQueueItem(int64(10)).Return(QueueItem{}, errors.New("get queue item error"))
 			},
-			expectedErr: errors.New("get build ID error"),
+			expectedErr:    errors.New("get queue item error"),
+			expectedOutput: nil,
+			expectedLogs:   []string{},
 		},
 		{
 			tcase: "build job error",
 			expectFunc: func(j *MockJenkins) {
-				j.EXPECT().Init().Return(nil, nil)
-				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(0), errors.New("get build ID error"))
+				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(0), errors.New("build job error"))
 			},
-			expectedErr: errors.New("get build ID error"),
+			expectedErr:    errors.New("build job error"),
+			expectedOutput: nil,
+			expectedLogs:   []string{},
 		},
 		{
-			tcase: "init error",
+			tcase: "secure iterations limit exceed",
 			expectFunc: func(j *MockJenkins) {
-				j.EXPECT().Init().Return(nil, errors.New("init error"))
+				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(10), nil)
+				j.EXPECT().GetQueueItem(int64(10)).Return(QueueItem{ID: 10, Why: "Waiting for next available executor"}, nil).Times(4)
+				j.EXPECT().CancelQueueItem(int64(10)).Return(nil)
 			},
-			expectedErr: errors.New("init error"),
+			expectedErr:    errors.New("secure iterations limit 4 exceeded, build is not started and queue item 10 is cancelled: Waiting for next available executor"),
+			expectedOutput: nil,
+			expectedLogs:   []string{},
 		},
 		{
-			tcase: "secure iterations limit exceed",
+			tcase: "secure iterations limit exceed + build is aborted",
 			expectFunc: func(j *MockJenkins) {
-				j.EXPECT().Init().Return(nil, nil)
 				j.EXPECT().BuildJob("SomeJob", map[string]string{"test": "test1"}).Return(int64(10), nil)
-				j.EXPECT().GetAllBuildIds("SomeJob").Return([]gojenkins.JobBuild{}, nil).Times(4)
+				j.EXPECT().GetQueueItem(int64(10)).Return(started, nil)
+				j.EXPECT().GetBuild("SomeJob", int64(20)).Return(Build{Number: 20, URL: buildURL, Building: true}, nil).Times(4)
+				j.EXPECT().StopBuild("SomeJob", int64(20)).Return(nil)
 			},
-			expectedErr: errors.New("secure iterations limit exceed"),
+			expectedErr:    errors.New("secure iterations limit 4 exceeded, build http://jenkins.company.com/job/SomeJob/20/ is aborted"),
+			expectedOutput: map[string]interface{}{"build_number": int64(20), "build_url": buildURL},
+			expectedLogs:   []string{"build is started", "secure iterations limit exceeded, build is aborted"},
 		},
 	}
 
 	for _, testUnit := range testTable {
+		hook.Reset()
 		testUnit.expectFunc(jenkinsMock)
+		task := newTask(testUnit.buildTimeout, testUnit.consoleTailLines, testUnit.unstableIsSuccess)
 		assert.Equal(t, testUnit.expectedErr, task.Exec(logger), testUnit.tcase)
+		assert.Equal(t, testUnit.expectedOutput, executor.Output(task), testUnit.tcase)
+
+		logs := make([]string, 0)
+		for _, entry := range hook.AllEntries() {
+			assert.Equal(t, "jenkins", entry.Data["context"], testUnit.tcase)
+			assert.Equal(t, "rule", entry.Data["rule"], testUnit.tcase)
+			assert.Equal(t, buildURL, entry.Data["build_url"], testUnit.tcase)
+			logs = append(logs, entry.Message)
+		}
+		assert.Equal(t, testUnit.expectedLogs, logs, testUnit.tcase)
+	}
+}
+
+func TestJenkinsTask_ExecStub(t *testing.T) {
+	t.Parallel()
+
+	logger, _ := test.NewNullLogger()
+
+	type testTableData struct {
+		tcase          string
+		buildTimeout   string
+		queueWaits     int
+		buildPolls     int
+		expectedErr    error
+		expectedOutput map[string]interface{}
+		expectedStop   []string
+		expectedCancel []string
 	}
 
-	// logger is not used
-	assert.Equal(t, 0, len(hook.Entries))
+	testTable := []testTableData{
+		{
+			tcase:          "build is queued and finished",
+			queueWaits:     2,
+			buildPolls:     2,
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"build_number": int64(20), "build_url": "http://jenkins.company.com/job/SomeJob/20/", "build_result": "SUCCESS"},
+		},
+		{
+			tcase:          "build is not started within timeout",
+			buildTimeout:   "1ns",
+			queueWaits:     2,
+			expectedErr:    errors.New("build timeout 1ns exceeded, build is not started and queue item 12 is cancelled: Waiting for next available executor"),
+			expectedOutput: nil,
+			expectedCancel: []string{"12"},
+		},
+		{
+			tcase:          "build is aborted on timeout",
+			buildTimeout:   "20ms",
+			buildPolls:     1000,
+			expectedErr:    errors.New("build timeout 20ms exceeded, build http://jenkins.company.com/job/SomeJob/20/ is aborted"),
+			expectedOutput: map[string]interface{}{"build_number": int64(20), "build_url": "http://jenkins.company.com/job/SomeJob/20/"},
+			expectedStop:   []string{"SomeJob/20"},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		stub := &jenkinsStub{mt: &sync.Mutex{}, csrf: true, queueWaits: testUnit.queueWaits, buildPolls: testUnit.buildPolls}
+		server := httptest.NewServer(stub)
+
+		params := map[string]interface{}{
+			"endpoint":                 server.URL,
+			"job":                      "SomeJob",
+			"login":                    stubLogin,
+			"password":                 stubPassword,
+			"state_refresh_delay":      "1ms",
+			"job parameter cluster":    "c1",
+			"secure_interations_limit": 1000,
+		}
+		if len(testUnit.buildTimeout) > 0 {
+			params["build_timeout"] = testUnit.buildTimeout
+		}
+
+		task := NewExecutor().NewTask("id", "rule", "alert", 0, params).(*task)
+		task.secureBuildDelay = 0
+
+		assert.Equal(t, testUnit.expectedErr, task.Exec(logger), testUnit.tcase)
+		assert.Equal(t, testUnit.expectedOutput, executor.Output(task), testUnit.tcase)
+
+		stub.mt.Lock()
+		assert.Equal(t, testUnit.expectedStop, stub.stopped, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedCancel, stub.cancelled, testUnit.tcase)
+		stub.mt.Unlock()
+
+		server.Close()
+	}
 }
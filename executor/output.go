@@ -0,0 +1,16 @@
+package executor
+
+// outputTask is the interface implemented by tasks providing output of execute.
+type outputTask interface {
+	Output() map[string]interface{}
+}
+
+// Output returns output of executed task, for example stdout of command.
+// If task does not provide output, returns nil.
+func Output(task Task) map[string]interface{} {
+	if output, ok := task.(outputTask); ok {
+		return output.Output()
+	}
+
+	return nil
+}
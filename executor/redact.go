@@ -0,0 +1,87 @@
+package executor
+
+import (
+	"net/url"
+	"regexp"
+)
+
+// Redacted replaces secret values in redacted details.
+const Redacted = "redacted"
+
+// secretKey matches names of parameters, headers and query parameters carrying secrets.
+var secretKey = regexp.MustCompile(`(?i)(authorization|cookie|token|secret|passw|credential|api[-_]?key|private[-_]?key)`)
+
+// Redact returns copy of task details or output with secret values replaced,
+// it is used before details are logged, stored to blocks or execution history.
+// Values of keys looking like secrets (e.g. Authorization header or password parameter) are replaced,
+// passwords and secret query parameters are removed from URLs.
+func Redact(details interface{}) interface{} {
+	switch d := details.(type) {
+	case map[string]interface{}:
+		if d == nil {
+			return d
+		}
+		redacted := make(map[string]interface{}, len(d))
+		for key, value := range d {
+			redacted[key] = redactValue(key, value)
+		}
+		return redacted
+	case map[string]string:
+		if d == nil {
+			return d
+		}
+		redacted := make(map[string]string, len(d))
+		for key, value := range d {
+			redacted[key] = redactValue(key, value).(string)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(d))
+		for i, value := range d {
+			redacted[i] = Redact(value)
+		}
+		return redacted
+	case string:
+		return redactURL(d)
+	default:
+		return details
+	}
+}
+
+func redactValue(key string, value interface{}) interface{} {
+	if _, ok := value.(string); ok && secretKey.MatchString(key) {
+		return Redacted
+	}
+
+	return Redact(value)
+}
+
+// redactURL removes password and secret query parameters from URL.
+// Values which are not absolute URLs are returned as is.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || !u.IsAbs() || len(u.Host) == 0 {
+		return s
+	}
+
+	redacted := false
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), Redacted)
+		redacted = true
+	}
+
+	query := u.Query()
+	for key := range query {
+		if secretKey.MatchString(key) {
+			query.Set(key, Redacted)
+			redacted = true
+		}
+	}
+
+	if !redacted {
+		return s
+	}
+
+	u.RawQuery = query.Encode()
+	return u.String()
+}
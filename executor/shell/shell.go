@@ -19,6 +19,7 @@ type task struct {
 	execFunc func(name string, arg ...string) *exec.Cmd
 	command  string
 	args     []string
+	stdout   string
 }
 
 func (task *task) ExecutorName() string {
@@ -35,10 +36,19 @@ func (task *task) Fingerprint() string {
 
 func (task *task) Exec(logger *logrus.Logger) error {
 	cmd := task.execFunc(task.command, task.args...)
-	_, err := cmd.Output()
+	stdout, err := cmd.Output()
+	task.stdout = string(stdout)
 	return err
 }
 
+func (task *task) Output() map[string]interface{} {
+	if len(task.stdout) == 0 {
+		return nil
+	}
+
+	return map[string]interface{}{"stdout": task.stdout}
+}
+
 type taskExecutor struct {
 	execFunc func(name string, arg ...string) *exec.Cmd
 }
@@ -59,6 +59,13 @@ func TestShellTask_ExecutorInterface(t *testing.T) {
 			},
 			expected: errors.New("exec: Stdout already set"),
 		},
+		{
+			tcase: "Output func",
+			taskFunc: func(t executor.Task) interface{} {
+				return executor.Output(t)
+			},
+			expected: map[string]interface{}(nil),
+		},
 	}
 
 	for _, testUnit := range testTable {
@@ -69,6 +76,16 @@ func TestShellTask_ExecutorInterface(t *testing.T) {
 	assert.Equal(t, 0, len(hook.Entries))
 }
 
+func TestShellTask_Output(t *testing.T) {
+	t.Parallel()
+
+	logger, _ := test.NewNullLogger()
+
+	task := NewExecutor(exec.Command).NewTask("825e", "testrule1", "testalert1", 0, map[string]interface{}{"command": "echo", "args": []interface{}{"done"}})
+	assert.Equal(t, nil, task.Exec(logger))
+	assert.Equal(t, map[string]interface{}{"stdout": "done\n"}, executor.Output(task))
+}
+
 func TestShellTaskExecutor_NewTask(t *testing.T) {
 	t.Parallel()
 
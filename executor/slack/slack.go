@@ -0,0 +1,306 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/krpn/prometheus-alert-webhooker/utils"
+	"github.com/prometheus/common/model"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	paramWebhookURL    = "webhook_url"
+	paramBotToken      = "bot_token"
+	paramChannel       = "channel"
+	paramUsername      = "username"
+	paramText          = "text"
+	paramColorByStatus = "color_by_status"
+	paramThread        = "thread"
+
+	// colors of message attachment by alert status
+	colorFiring   = "#d63232"
+	colorResolved = "#36a64f"
+
+	// DefaultAPIURL is an URL of Slack Web API used with bot token.
+	DefaultAPIURL = "https://slack.com/api"
+)
+
+var stringParameters = []string{
+	paramWebhookURL,
+	paramBotToken,
+	paramChannel,
+	paramUsername,
+	paramText,
+}
+
+var boolParameters = []string{
+	paramColorByStatus,
+	paramThread,
+}
+
+var (
+	errWebhookURLAndBotToken  = errors.New("exactly one of webhook_url and bot_token parameters should be set")
+	errBotTokenWithoutChannel = errors.New("channel parameter is required with bot_token")
+	errThreadWithoutBotToken  = errors.New("thread parameter requires bot_token, webhooks do not return messages")
+)
+
+// message is a message of incoming webhook or chat.postMessage method.
+// Mattermost incoming webhooks accept the same format.
+type message struct {
+	Channel     string       `json:"channel,omitempty"`
+	Username    string       `json:"username,omitempty"`
+	Text        string       `json:"text,omitempty"`
+	Attachments []attachment `json:"attachments,omitempty"`
+	ThreadTS    string       `json:"thread_ts,omitempty"`
+}
+
+type attachment struct {
+	Fallback string `json:"fallback"`
+	Color    string `json:"color"`
+	Text     string `json:"text"`
+}
+
+type task struct {
+	executor.TaskBase
+	webhookURL    string
+	botToken      string
+	apiURL        string
+	channel       string
+	username      string
+	text          string
+	colorByStatus bool
+
+	// thread enables replies in thread of the first message sent for the same alert.
+	thread  bool
+	threads *threads
+
+	alertStatus      string
+	alertFingerprint string
+
+	output map[string]interface{}
+	client *http.Client
+}
+
+func (task *task) ExecutorName() string {
+	return "slack"
+}
+
+func (task *task) ExecutorDetails() interface{} {
+	return map[string]interface{}{"channel": task.channel, "text": task.text}
+}
+
+func (task *task) Fingerprint() string {
+	base := fmt.Sprintf("%v|%v|%v", task.webhookURL, task.channel, task.text)
+	return utils.MD5Hash(base)
+}
+
+// SetAlerts takes alert status for attachment color and alert fingerprint to find the thread.
+func (task *task) SetAlerts(alerts executor.Alerts) {
+	task.alertStatus = alerts.Alert.Status
+
+	if task.thread && len(alerts.Alert.Labels) > 0 {
+		labels := make(model.LabelSet, len(alerts.Alert.Labels))
+		for name, value := range alerts.Alert.Labels {
+			labels[model.LabelName(name)] = model.LabelValue(value)
+		}
+		task.alertFingerprint = labels.Fingerprint().String()
+	}
+}
+
+func (task *task) Exec(logger *logrus.Logger) error {
+	msg := message{
+		Channel:  task.channel,
+		Username: task.username,
+		Text:     task.text,
+	}
+
+	if task.colorByStatus {
+		color := colorFiring
+		if task.alertStatus == string(model.AlertResolved) {
+			color = colorResolved
+		}
+		msg.Text = ""
+		msg.Attachments = []attachment{{Fallback: task.text, Color: color, Text: task.text}}
+	}
+
+	if len(task.webhookURL) > 0 {
+		return task.postWebhook(msg)
+	}
+
+	key := threadKey(task.channel, task.alertFingerprint)
+	threaded := task.thread && len(task.alertFingerprint) > 0
+	if threaded {
+		msg.ThreadTS = task.threads.get(key)
+	}
+
+	channel, ts, err := task.postMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	task.output = map[string]interface{}{"channel": channel, "ts": ts}
+
+	switch {
+	case !threaded:
+	case len(msg.ThreadTS) == 0:
+		task.threads.set(key, ts)
+	case task.alertStatus == string(model.AlertResolved):
+		// the next firing of alert starts new thread
+		task.threads.delete(key)
+	}
+
+	if len(msg.ThreadTS) > 0 {
+		task.output["thread_ts"] = msg.ThreadTS
+	}
+
+	return nil
+}
+
+func (task *task) Output() map[string]interface{} {
+	return task.output
+}
+
+// postWebhook sends message to incoming webhook.
+func (task *task) postWebhook(msg message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	resp, err := task.client.Post(task.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("webhook returned HTTP status %v: %v", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+
+	return nil
+}
+
+// postMessage sends message by chat.postMessage method and returns channel ID and timestamp of the message.
+func (task *task) postMessage(msg message) (channel, ts string, err error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return "", "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, task.apiURL+"/chat.postMessage", bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+task.botToken)
+
+	resp, err := task.client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("chat.postMessage returned HTTP status %v", resp.StatusCode)
+	}
+
+	var r struct {
+		OK      bool   `json:"ok"`
+		Error   string `json:"error"`
+		Channel string `json:"channel"`
+		TS      string `json:"ts"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	if err != nil {
+		return "", "", fmt.Errorf("chat.postMessage response is not JSON: %v", err)
+	}
+
+	if !r.OK {
+		return "", "", fmt.Errorf("chat.postMessage error: %v", r.Error)
+	}
+
+	return r.Channel, r.TS, nil
+}
+
+type taskExecutor struct {
+	client  *http.Client
+	apiURL  string
+	threads *threads
+}
+
+// NewExecutor creates TaskExecutor for Slack tasks.
+// Messages are sent to incoming webhooks (Slack or Mattermost) or by Slack Web API at apiURL with bot token.
+func NewExecutor(client *http.Client, apiURL string) executor.TaskExecutor {
+	return taskExecutor{client: client, apiURL: strings.TrimSuffix(apiURL, "/"), threads: newThreads()}
+}
+
+func (executor taskExecutor) ValidateParameters(parameters map[string]interface{}) error {
+	if _, ok := parameters[paramText]; !ok {
+		return fmt.Errorf("required parameter %v is missing", paramText)
+	}
+
+	for _, stringParam := range stringParameters {
+		param, ok := parameters[stringParam]
+		if !ok {
+			continue
+		}
+
+		if _, ok := param.(string); !ok {
+			return fmt.Errorf("%v parameter value is not a string", stringParam)
+		}
+	}
+
+	for _, boolParam := range boolParameters {
+		param, ok := parameters[boolParam]
+		if !ok {
+			continue
+		}
+
+		if _, ok := param.(bool); !ok {
+			return fmt.Errorf("%v parameter value is not a boolean", boolParam)
+		}
+	}
+
+	_, webhook := parameters[paramWebhookURL]
+	_, bot := parameters[paramBotToken]
+	if webhook == bot {
+		return errWebhookURLAndBotToken
+	}
+
+	if _, ok := parameters[paramChannel]; bot && !ok {
+		return errBotTokenWithoutChannel
+	}
+
+	if thread, _ := parameters[paramThread].(bool); thread && !bot {
+		return errThreadWithoutBotToken
+	}
+
+	return nil
+}
+
+func (executor taskExecutor) NewTask(eventID, rule, alert string, blockTTL time.Duration, preparedParameters map[string]interface{}) executor.Task {
+	task := &task{
+		text:    preparedParameters[paramText].(string),
+		apiURL:  executor.apiURL,
+		threads: executor.threads,
+		client:  executor.client,
+	}
+
+	task.webhookURL, _ = preparedParameters[paramWebhookURL].(string)
+	task.botToken, _ = preparedParameters[paramBotToken].(string)
+	task.channel, _ = preparedParameters[paramChannel].(string)
+	task.username, _ = preparedParameters[paramUsername].(string)
+	task.colorByStatus, _ = preparedParameters[paramColorByStatus].(bool)
+	task.thread, _ = preparedParameters[paramThread].(bool)
+
+	task.SetBase(eventID, rule, alert, blockTTL)
+	return task
+}
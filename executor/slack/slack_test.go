@@ -0,0 +1,351 @@
+package slack
+
+import (
+	"errors"
+	"fmt"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slackStub is an incoming webhook and Web API stand-in.
+type slackStub struct {
+	mt       *sync.Mutex
+	requests []string
+	messages int
+}
+
+func (stub *slackStub) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	stub.mt.Lock()
+	defer stub.mt.Unlock()
+
+	body, _ := ioutil.ReadAll(req.Body)
+	stub.requests = append(stub.requests, req.URL.Path+" "+string(body))
+
+	switch req.URL.Path {
+	case "/hooks/T000/B000/XXX":
+		_, _ = w.Write([]byte("ok"))
+	case "/hooks/broken":
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("invalid_payload\n"))
+	case "/api/chat.postMessage":
+		switch req.Header.Get("Authorization") {
+		case "Bearer xoxb-valid":
+			stub.messages++
+			_, _ = fmt.Fprintf(w, `{"ok":true,"channel":"C024BE91L","ts":"1503435956.00000%v"}`, stub.messages)
+		case "Bearer xoxb-html":
+			_, _ = w.Write([]byte(`<html>`))
+		default:
+			_, _ = w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (stub *slackStub) popRequests() []string {
+	stub.mt.Lock()
+	defer stub.mt.Unlock()
+
+	requests := stub.requests
+	stub.requests = nil
+	return requests
+}
+
+func TestSlackTask_Exec(t *testing.T) {
+	t.Parallel()
+
+	stub := &slackStub{mt: &sync.Mutex{}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	logger, hook := test.NewNullLogger()
+	slackExecutor := NewExecutor(&http.Client{}, server.URL+"/api/")
+
+	newTask := func(parameters map[string]interface{}, status string) executor.Task {
+		task := slackExecutor.NewTask("825e", "testrule1", "testalert1", 0, parameters)
+		executor.SetAlerts(task, func() executor.Alerts {
+			return executor.Alerts{Alert: template.Alert{Status: status, Labels: template.KV{"alertname": "ServiceDown", "instance": "server1"}}}
+		})
+		return task
+	}
+
+	type testTableData struct {
+		tcase            string
+		task             executor.Task
+		expectedErr      error
+		expectedOutput   map[string]interface{}
+		expectedRequests []string
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "webhook",
+			task: newTask(map[string]interface{}{
+				"webhook_url": server.URL + "/hooks/T000/B000/XXX",
+				"channel":     "#alerts",
+				"username":    "webhooker",
+				"text":        "Restarted \"server1\"",
+			}, "firing"),
+			expectedErr:      nil,
+			expectedOutput:   nil,
+			expectedRequests: []string{`/hooks/T000/B000/XXX {"channel":"#alerts","username":"webhooker","text":"Restarted \"server1\""}`},
+		},
+		{
+			tcase: "webhook color by status",
+			task: newTask(map[string]interface{}{
+				"webhook_url":     server.URL + "/hooks/T000/B000/XXX",
+				"text":            "ServiceDown is resolved",
+				"color_by_status": true,
+			}, "resolved"),
+			expectedErr:      nil,
+			expectedOutput:   nil,
+			expectedRequests: []string{`/hooks/T000/B000/XXX {"attachments":[{"fallback":"ServiceDown is resolved","color":"#36a64f","text":"ServiceDown is resolved"}]}`},
+		},
+		{
+			tcase: "webhook error",
+			task: newTask(map[string]interface{}{
+				"webhook_url": server.URL + "/hooks/broken",
+				"text":        "test",
+			}, "firing"),
+			expectedErr:      errors.New("webhook returned HTTP status 400: invalid_payload"),
+			expectedOutput:   nil,
+			expectedRequests: []string{`/hooks/broken {"text":"test"}`},
+		},
+		{
+			tcase: "thread is started",
+			task: newTask(map[string]interface{}{
+				"bot_token":       "xoxb-valid",
+				"channel":         "#alerts",
+				"text":            "ServiceDown is firing",
+				"color_by_status": true,
+				"thread":          true,
+			}, "firing"),
+			expectedErr:      nil,
+			expectedOutput:   map[string]interface{}{"channel": "C024BE91L", "ts": "1503435956.000001"},
+			expectedRequests: []string{`/api/chat.postMessage {"channel":"#alerts","attachments":[{"fallback":"ServiceDown is firing","color":"#d63232","text":"ServiceDown is firing"}]}`},
+		},
+		{
+			tcase: "remediation follow-up in thread",
+			task: newTask(map[string]interface{}{
+				"bot_token": "xoxb-valid",
+				"channel":   "#alerts",
+				"text":      "server1 is restarted",
+				"thread":    true,
+			}, "firing"),
+			expectedErr:      nil,
+			expectedOutput:   map[string]interface{}{"channel": "C024BE91L", "ts": "1503435956.000002", "thread_ts": "1503435956.000001"},
+			expectedRequests: []string{`/api/chat.postMessage {"channel":"#alerts","text":"server1 is restarted","thread_ts":"1503435956.000001"}`},
+		},
+		{
+			tcase: "resolution in thread",
+			task: newTask(map[string]interface{}{
+				"bot_token":       "xoxb-valid",
+				"channel":         "#alerts",
+				"text":            "ServiceDown is resolved",
+				"color_by_status": true,
+				"thread":          true,
+			}, "resolved"),
+			expectedErr:      nil,
+			expectedOutput:   map[string]interface{}{"channel": "C024BE91L", "ts": "1503435956.000003", "thread_ts": "1503435956.000001"},
+			expectedRequests: []string{`/api/chat.postMessage {"channel":"#alerts","attachments":[{"fallback":"ServiceDown is resolved","color":"#36a64f","text":"ServiceDown is resolved"}],"thread_ts":"1503435956.000001"}`},
+		},
+		{
+			tcase: "new thread after resolution",
+			task: newTask(map[string]interface{}{
+				"bot_token": "xoxb-valid",
+				"channel":   "#alerts",
+				"text":      "ServiceDown is firing",
+				"thread":    true,
+			}, "firing"),
+			expectedErr:      nil,
+			expectedOutput:   map[string]interface{}{"channel": "C024BE91L", "ts": "1503435956.000004"},
+			expectedRequests: []string{`/api/chat.postMessage {"channel":"#alerts","text":"ServiceDown is firing"}`},
+		},
+		{
+			tcase: "api error",
+			task: newTask(map[string]interface{}{
+				"bot_token": "xoxb-revoked",
+				"channel":   "#alerts",
+				"text":      "test",
+			}, "firing"),
+			expectedErr:      errors.New("chat.postMessage error: invalid_auth"),
+			expectedOutput:   nil,
+			expectedRequests: []string{`/api/chat.postMessage {"channel":"#alerts","text":"test"}`},
+		},
+		{
+			tcase: "api wrong response",
+			task: newTask(map[string]interface{}{
+				"bot_token": "xoxb-html",
+				"channel":   "#alerts",
+				"text":      "test",
+			}, "firing"),
+			expectedErr:      errors.New("chat.postMessage response is not JSON: invalid character '<' looking for beginning of value"),
+			expectedOutput:   nil,
+			expectedRequests: []string{`/api/chat.postMessage {"channel":"#alerts","text":"test"}`},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		assert.Equal(t, testUnit.expectedErr, testUnit.task.Exec(logger), testUnit.tcase)
+		assert.Equal(t, testUnit.expectedOutput, executor.Output(testUnit.task), testUnit.tcase)
+		assert.Equal(t, testUnit.expectedRequests, stub.popRequests(), testUnit.tcase)
+	}
+
+	// logger is not used
+	assert.Equal(t, 0, len(hook.Entries))
+}
+
+func TestSlackTask_ExecutorInterface(t *testing.T) {
+	t.Parallel()
+
+	task := NewExecutor(&http.Client{}, DefaultAPIURL).NewTask("825e", "testrule1", "testalert1", 1*time.Second,
+		map[string]interface{}{
+			"webhook_url": "https://hooks.slack.com/services/T000/B000/XXX",
+			"channel":     "#alerts",
+			"text":        "test",
+		},
+	)
+
+	assert.Equal(t, "slack", task.ExecutorName())
+	assert.Equal(t, map[string]interface{}{"channel": "#alerts", "text": "test"}, task.ExecutorDetails())
+	assert.Equal(t, "930548c4b19bf40461cfd4ae8584cffb", task.Fingerprint())
+}
+
+func TestSlackTaskExecutor_NewTask(t *testing.T) {
+	t.Parallel()
+
+	client := &http.Client{}
+	slackExecutor := NewExecutor(client, "https://slack.example.com/api/")
+	threads := slackExecutor.(taskExecutor).threads
+
+	slackTask := slackExecutor.NewTask("825e", "testrule1", "testalert1", 1*time.Second, map[string]interface{}{
+		"bot_token":       "xoxb-valid",
+		"channel":         "#alerts",
+		"username":        "webhooker",
+		"text":            "test",
+		"color_by_status": true,
+		"thread":          true,
+	})
+
+	expected := &task{
+		botToken:      "xoxb-valid",
+		apiURL:        "https://slack.example.com/api",
+		channel:       "#alerts",
+		username:      "webhooker",
+		text:          "test",
+		colorByStatus: true,
+		thread:        true,
+		threads:       threads,
+		client:        client,
+	}
+	expected.SetBase("825e", "testrule1", "testalert1", 1*time.Second)
+
+	assert.Equal(t, expected, slackTask)
+}
+
+func TestSlackTaskExecutor_ValidateParameters(t *testing.T) {
+	t.Parallel()
+
+	slackExecutor := NewExecutor(&http.Client{}, DefaultAPIURL)
+
+	type testTableData struct {
+		tcase    string
+		params   map[string]interface{}
+		expected error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "webhook",
+			params: map[string]interface{}{
+				"webhook_url":     "https://mattermost.example.com/hooks/xxx",
+				"channel":         "town-square",
+				"username":        "webhooker",
+				"text":            "test",
+				"color_by_status": true,
+			},
+			expected: nil,
+		},
+		{
+			tcase: "bot token",
+			params: map[string]interface{}{
+				"bot_token": "xoxb-valid",
+				"channel":   "#alerts",
+				"text":      "test",
+				"thread":    true,
+			},
+			expected: nil,
+		},
+		{
+			tcase: "text missing",
+			params: map[string]interface{}{
+				"webhook_url": "https://hooks.slack.com/services/T000/B000/XXX",
+			},
+			expected: errors.New("required parameter text is missing"),
+		},
+		{
+			tcase: "text wrong type",
+			params: map[string]interface{}{
+				"webhook_url": "https://hooks.slack.com/services/T000/B000/XXX",
+				"text":        123,
+			},
+			expected: errors.New("text parameter value is not a string"),
+		},
+		{
+			tcase: "thread wrong type",
+			params: map[string]interface{}{
+				"bot_token": "xoxb-valid",
+				"channel":   "#alerts",
+				"text":      "test",
+				"thread":    "yes",
+			},
+			expected: errors.New("thread parameter value is not a boolean"),
+		},
+		{
+			tcase: "no webhook_url and bot_token",
+			params: map[string]interface{}{
+				"text": "test",
+			},
+			expected: errWebhookURLAndBotToken,
+		},
+		{
+			tcase: "both webhook_url and bot_token",
+			params: map[string]interface{}{
+				"webhook_url": "https://hooks.slack.com/services/T000/B000/XXX",
+				"bot_token":   "xoxb-valid",
+				"channel":     "#alerts",
+				"text":        "test",
+			},
+			expected: errWebhookURLAndBotToken,
+		},
+		{
+			tcase: "bot_token without channel",
+			params: map[string]interface{}{
+				"bot_token": "xoxb-valid",
+				"text":      "test",
+			},
+			expected: errBotTokenWithoutChannel,
+		},
+		{
+			tcase: "thread with webhook",
+			params: map[string]interface{}{
+				"webhook_url": "https://hooks.slack.com/services/T000/B000/XXX",
+				"text":        "test",
+				"thread":      true,
+			},
+			expected: errThreadWithoutBotToken,
+		},
+	}
+
+	for _, testUnit := range testTable {
+		assert.Equal(t, testUnit.expected, slackExecutor.ValidateParameters(testUnit.params), testUnit.tcase)
+	}
+}
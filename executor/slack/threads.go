@@ -0,0 +1,70 @@
+package slack
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// threadTTL is how long thread of the first alert message is remembered.
+const threadTTL = 7 * 24 * time.Hour
+
+// threads keeps timestamps of the first messages by channel and alert fingerprint to reply in their threads.
+type threads struct {
+	mt      *sync.Mutex
+	threads map[string]thread
+	nowFunc func() time.Time
+}
+
+type thread struct {
+	ts       string
+	storedAt time.Time
+}
+
+func newThreads() *threads {
+	return &threads{
+		mt:      &sync.Mutex{},
+		threads: make(map[string]thread),
+		nowFunc: time.Now,
+	}
+}
+
+func threadKey(channel, alertFingerprint string) string {
+	return fmt.Sprintf("%v|%v", channel, alertFingerprint)
+}
+
+// get returns timestamp of the thread message for the key or empty string if it is unknown or expired.
+func (t *threads) get(key string) string {
+	t.mt.Lock()
+	defer t.mt.Unlock()
+
+	th, ok := t.threads[key]
+	if !ok || t.nowFunc().Sub(th.storedAt) > threadTTL {
+		return ""
+	}
+
+	return th.ts
+}
+
+// set remembers timestamp of the thread message for the key. Expired threads are removed.
+func (t *threads) set(key, ts string) {
+	t.mt.Lock()
+	defer t.mt.Unlock()
+
+	now := t.nowFunc()
+	for k, th := range t.threads {
+		if now.Sub(th.storedAt) > threadTTL {
+			delete(t.threads, k)
+		}
+	}
+
+	t.threads[key] = thread{ts: ts, storedAt: now}
+}
+
+// delete forgets the thread, so the next message for the key starts new thread.
+func (t *threads) delete(key string) {
+	t.mt.Lock()
+	defer t.mt.Unlock()
+
+	delete(t.threads, key)
+}
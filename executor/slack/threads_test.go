@@ -0,0 +1,30 @@
+package slack
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestThreads(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	th := newThreads()
+	th.nowFunc = func() time.Time { return now }
+
+	key1, key2 := threadKey("#alerts", "b8a5c0a1e4a5d8c7"), threadKey("#ops", "b8a5c0a1e4a5d8c7")
+
+	th.set(key1, "1503435956.000001")
+	assert.Equal(t, "1503435956.000001", th.get(key1))
+	assert.Equal(t, "", th.get(key2))
+
+	// expired thread is not returned and removed on the next set
+	now = now.Add(threadTTL + time.Minute)
+	assert.Equal(t, "", th.get(key1))
+	th.set(key2, "1503435956.000002")
+	assert.Equal(t, map[string]thread{key2: {ts: "1503435956.000002", storedAt: now}}, th.threads)
+
+	th.delete(key2)
+	assert.Equal(t, "", th.get(key2))
+}
@@ -0,0 +1,494 @@
+package telegram
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	paramApprovers     = "approvers"
+	paramTimeout       = "timeout"
+	paramCallback      = "callback"
+	paramWebhookSecret = "webhook_secret"
+
+	callbackPolling = "polling"
+	callbackWebhook = "webhook"
+
+	defaultApprovalTimeout = 30 * time.Minute
+
+	callbackApprove = "approve"
+	callbackReject  = "reject"
+
+	// CallbackPath is a path of webhook endpoint receiving Telegram updates with approval callbacks.
+	CallbackPath = "/telegram/callback"
+
+	// secretTokenHeader is a header with secret token set by setWebhook.
+	secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+	pollTimeout    = 30 * time.Second
+	pollRetryDelay = 5 * time.Second
+
+	approvalContext = "telegram_approval"
+)
+
+var (
+	errApproversNotStrings  = errors.New("approvers parameter value is not a list of strings")
+	errWebhookWithoutSecret = errors.New("webhook_secret parameter is required with webhook callback")
+)
+
+// Approvals keeps pending approvals and resolves them by callbacks of Approve/Reject buttons.
+// Callbacks are received by long polling of bot updates or by webhook endpoint.
+type Approvals struct {
+	mt      *sync.Mutex
+	pending map[string]*pendingApproval
+
+	// polled are bot tokens polled for updates, offset of the next update is kept between polls.
+	polled  map[string]bool
+	offsets map[string]int
+
+	idFunc         func() string
+	pollRetryDelay time.Duration
+}
+
+type pendingApproval struct {
+	token         string
+	callback      string
+	webhookSecret string
+	approvers     []string
+	telegram      Telegram
+	decision      chan decision
+}
+
+type decision struct {
+	approved bool
+	by       string
+}
+
+// NewApprovals creates Approvals.
+func NewApprovals() *Approvals {
+	return &Approvals{
+		mt:             &sync.Mutex{},
+		pending:        make(map[string]*pendingApproval),
+		polled:         make(map[string]bool),
+		offsets:        make(map[string]int),
+		idFunc:         randomID,
+		pollRetryDelay: pollRetryDelay,
+	}
+}
+
+func randomID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// add adds pending approval and returns its ID. Decision is sent to pending approval channel.
+func (a *Approvals) add(p *pendingApproval) (id string) {
+	a.mt.Lock()
+	defer a.mt.Unlock()
+
+	id = a.idFunc()
+	p.decision = make(chan decision, 1)
+	a.pending[id] = p
+	return id
+}
+
+func (a *Approvals) remove(id string) {
+	a.mt.Lock()
+	defer a.mt.Unlock()
+
+	delete(a.pending, id)
+}
+
+// resolve passes decision of callback query to pending approval accepted by accept func.
+// Returns answer shown to the user pressed the button.
+func (a *Approvals) resolve(query *tgbotapi.CallbackQuery, accept func(p *pendingApproval) bool) (p *pendingApproval, answer string) {
+	parts := strings.SplitN(query.Data, ":", 2)
+	if len(parts) != 2 || (parts[0] != callbackApprove && parts[0] != callbackReject) {
+		return nil, ""
+	}
+
+	a.mt.Lock()
+	defer a.mt.Unlock()
+
+	p, ok := a.pending[parts[1]]
+	if !ok || !accept(p) {
+		return nil, "Approval is not pending"
+	}
+
+	if !p.allowed(query.From) {
+		return p, "You are not allowed to approve"
+	}
+
+	delete(a.pending, parts[1])
+	p.decision <- decision{approved: parts[0] == callbackApprove, by: userName(query.From)}
+
+	if parts[0] == callbackApprove {
+		return p, "Approved"
+	}
+
+	return p, "Rejected"
+}
+
+// allowed checks user is in approvers list by username or ID. Anyone is allowed if list is empty.
+func (p *pendingApproval) allowed(user *tgbotapi.User) bool {
+	if len(p.approvers) == 0 {
+		return true
+	}
+
+	if user == nil {
+		return false
+	}
+
+	for _, approver := range p.approvers {
+		approver = strings.TrimPrefix(approver, "@")
+		if (len(user.UserName) > 0 && approver == user.UserName) || approver == strconv.Itoa(user.ID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func userName(user *tgbotapi.User) string {
+	if user == nil {
+		return "unknown"
+	}
+
+	if len(user.UserName) > 0 {
+		return fmt.Sprintf("@%v (%v)", user.UserName, user.ID)
+	}
+
+	return fmt.Sprintf("%v (%v)", strings.TrimSpace(user.FirstName+" "+user.LastName), user.ID)
+}
+
+// answer shows answer to the user pressed the button.
+func answer(telegram Telegram, query *tgbotapi.CallbackQuery, text string) error {
+	_, err := telegram.MakeRequest("answerCallbackQuery", url.Values{
+		"callback_query_id": {query.ID},
+		"text":              {text},
+	})
+	return err
+}
+
+// startPolling starts polling of bot updates if the bot is not polled yet.
+// Polling is stopped when bot has no pending approvals.
+func (a *Approvals) startPolling(token string, telegram Telegram, logger *logrus.Logger) {
+	a.mt.Lock()
+	defer a.mt.Unlock()
+
+	if a.polled[token] {
+		return
+	}
+
+	a.polled[token] = true
+	go a.poll(token, telegram, logger)
+}
+
+func (a *Approvals) poll(token string, telegram Telegram, logger *logrus.Logger) {
+	ctxLogger := logger.WithField("context", approvalContext)
+	accept := func(p *pendingApproval) bool {
+		return p.callback == callbackPolling && p.token == token
+	}
+
+	for {
+		offset, ok := a.pollOffset(token)
+		if !ok {
+			return
+		}
+
+		resp, err := telegram.MakeRequest("getUpdates", url.Values{
+			"offset":          {strconv.Itoa(offset)},
+			"timeout":         {strconv.Itoa(int(pollTimeout.Seconds()))},
+			"allowed_updates": {`["callback_query"]`},
+		})
+		if err != nil {
+			ctxLogger.Errorf("get updates error: %v", err)
+			time.Sleep(a.pollRetryDelay)
+			continue
+		}
+
+		var updates []tgbotapi.Update
+		err = json.Unmarshal(resp.Result, &updates)
+		if err != nil {
+			ctxLogger.Errorf("updates decode error: %v", err)
+			time.Sleep(a.pollRetryDelay)
+			continue
+		}
+
+		for _, update := range updates {
+			a.setOffset(token, update.UpdateID+1)
+			if update.CallbackQuery == nil {
+				continue
+			}
+
+			_, text := a.resolve(update.CallbackQuery, accept)
+			if len(text) == 0 {
+				continue
+			}
+
+			err = answer(telegram, update.CallbackQuery, text)
+			if err != nil {
+				ctxLogger.Errorf("answer callback query error: %v", err)
+			}
+		}
+	}
+}
+
+// pollOffset returns offset of the next update or false if polling should be stopped.
+func (a *Approvals) pollOffset(token string) (int, bool) {
+	a.mt.Lock()
+	defer a.mt.Unlock()
+
+	for _, p := range a.pending {
+		if p.callback == callbackPolling && p.token == token {
+			return a.offsets[token], true
+		}
+	}
+
+	delete(a.polled, token)
+	return 0, false
+}
+
+func (a *Approvals) setOffset(token string, offset int) {
+	a.mt.Lock()
+	defer a.mt.Unlock()
+
+	a.offsets[token] = offset
+}
+
+// Callback handles Telegram update sent to webhook endpoint.
+// Update is accepted by approval with webhook callback if secret token matches its webhook secret.
+func (a *Approvals) Callback(w http.ResponseWriter, r *http.Request, logger *logrus.Logger) {
+	ctxLogger := logger.WithField("context", approvalContext)
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var update tgbotapi.Update
+	err := json.NewDecoder(r.Body).Decode(&update)
+	if err != nil {
+		ctxLogger.Errorf("update decode error: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Telegram retries update until it gets successful response
+	w.WriteHeader(http.StatusOK)
+
+	if update.CallbackQuery == nil {
+		return
+	}
+
+	secret := r.Header.Get(secretTokenHeader)
+	p, text := a.resolve(update.CallbackQuery, func(p *pendingApproval) bool {
+		// without secret anyone could forge callback with approver in it
+		return p.callback == callbackWebhook && len(p.webhookSecret) > 0 &&
+			subtle.ConstantTimeCompare([]byte(p.webhookSecret), []byte(secret)) == 1
+	})
+	if p == nil {
+		ctxLogger.Warnf("callback query %q does not match pending approval", update.CallbackQuery.Data)
+		return
+	}
+
+	err = answer(p.telegram, update.CallbackQuery, text)
+	if err != nil {
+		ctxLogger.Errorf("answer callback query error: %v", err)
+	}
+}
+
+type approvalTask struct {
+	*task
+	token         string
+	approvers     []string
+	timeout       time.Duration
+	callback      string
+	webhookSecret string
+	approvals     *Approvals
+}
+
+func (task *approvalTask) ExecutorName() string {
+	return "telegram_approval"
+}
+
+func (task *approvalTask) ExecutorDetails() interface{} {
+	return map[string]interface{}{"chatID": task.chatID, "message": task.message, "approvers": task.approvers, "timeout": task.timeout.String()}
+}
+
+// Exec sends message with Approve/Reject buttons and waits for decision.
+// Error is returned if approval is rejected or timeout is exceeded, so the next tasks of group are cancelled.
+func (task *approvalTask) Exec(logger *logrus.Logger) error {
+	pending := &pendingApproval{
+		token:         task.token,
+		callback:      task.callback,
+		webhookSecret: task.webhookSecret,
+		approvers:     task.approvers,
+		telegram:      task.telegram,
+	}
+	id := task.approvals.add(pending)
+	defer task.approvals.remove(id)
+
+	params, err := task.params(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Approve", callbackApprove+":"+id),
+		tgbotapi.NewInlineKeyboardButtonData("Reject", callbackReject+":"+id),
+	))
+	if err != nil {
+		return err
+	}
+
+	resp, err := task.telegram.MakeRequest("sendMessage", params)
+	if err != nil {
+		return err
+	}
+
+	var sent tgbotapi.Message
+	err = json.Unmarshal(resp.Result, &sent)
+	if err != nil {
+		return fmt.Errorf("sent message decode error: %v", err)
+	}
+	task.output = map[string]interface{}{"message_id": sent.MessageID}
+
+	if task.callback == callbackPolling {
+		task.approvals.startPolling(task.token, task.telegram, logger)
+	}
+
+	ctxLogger := logger.WithFields(executor.TaskDetails(task)).WithField("context", approvalContext)
+	ctxLogger.Info("approval is requested")
+
+	timer := time.NewTimer(task.timeout)
+	defer timer.Stop()
+
+	var result string
+	select {
+	case d := <-pending.decision:
+		if d.approved {
+			task.output["approved_by"] = d.by
+			result, err = "Approved by "+d.by, nil
+		} else {
+			task.output["rejected_by"] = d.by
+			result, err = "Rejected by "+d.by, fmt.Errorf("approval is rejected by %v", d.by)
+		}
+	case <-timer.C:
+		result, err = fmt.Sprintf("Approval timeout %v exceeded", task.timeout), fmt.Errorf("approval timeout %v exceeded", task.timeout)
+	}
+
+	ctxLogger.WithField("result", result).Info("approval is finished")
+
+	// buttons are removed with edit
+	params.Del("reply_markup")
+	params.Set("text", task.message+"\n\n"+result)
+	editErr := task.edit(sent.MessageID, params)
+	if editErr != nil {
+		ctxLogger.Warnf("approval message edit error: %v", editErr)
+	}
+
+	return err
+}
+
+type approvalExecutor struct {
+	client    *http.Client
+	approvals *Approvals
+}
+
+// NewApprovalExecutor creates TaskExecutor for Telegram approval tasks.
+func NewApprovalExecutor(client *http.Client, approvals *Approvals) executor.TaskExecutor {
+	return approvalExecutor{client: client, approvals: approvals}
+}
+
+func (e approvalExecutor) ValidateParameters(parameters map[string]interface{}) error {
+	err := taskExecutor{}.ValidateParameters(parameters)
+	if err != nil {
+		return err
+	}
+
+	if param, ok := parameters[paramApprovers]; ok {
+		approvers, ok := param.([]interface{})
+		if !ok {
+			return errApproversNotStrings
+		}
+
+		for _, approver := range approvers {
+			if _, ok := approver.(string); !ok {
+				return errApproversNotStrings
+			}
+		}
+	}
+
+	if param, ok := parameters[paramTimeout]; ok {
+		timeout, ok := param.(string)
+		if !ok {
+			return fmt.Errorf("%v parameter value is not a string", paramTimeout)
+		}
+
+		_, err = time.ParseDuration(timeout)
+		if err != nil {
+			return fmt.Errorf("%v parameter: %v", paramTimeout, err)
+		}
+	}
+
+	if param, ok := parameters[paramCallback]; ok {
+		switch param {
+		case callbackPolling, callbackWebhook:
+		default:
+			return fmt.Errorf("%v parameter value %v is not one of %v, %v", paramCallback, param, callbackPolling, callbackWebhook)
+		}
+	}
+
+	secret, ok := parameters[paramWebhookSecret]
+	if ok {
+		if _, ok := secret.(string); !ok {
+			return fmt.Errorf("%v parameter value is not a string", paramWebhookSecret)
+		}
+	}
+
+	if secret, _ := secret.(string); parameters[paramCallback] == callbackWebhook && len(secret) == 0 {
+		return errWebhookWithoutSecret
+	}
+
+	return nil
+}
+
+func (e approvalExecutor) NewTask(eventID, rule, alert string, blockTTL time.Duration, preparedParameters map[string]interface{}) executor.Task {
+	t := taskExecutor{client: e.client}.NewTask(eventID, rule, alert, blockTTL, preparedParameters).(*task)
+
+	task := &approvalTask{
+		task:      t,
+		token:     preparedParameters[paramToken].(string),
+		timeout:   defaultApprovalTimeout,
+		callback:  callbackPolling,
+		approvals: e.approvals,
+	}
+
+	if approvers, ok := preparedParameters[paramApprovers].([]interface{}); ok {
+		for _, approver := range approvers {
+			task.approvers = append(task.approvers, approver.(string))
+		}
+	}
+
+	if timeout, ok := preparedParameters[paramTimeout].(string); ok {
+		task.timeout, _ = time.ParseDuration(timeout)
+	}
+
+	if callback, ok := preparedParameters[paramCallback].(string); ok {
+		task.callback = callback
+	}
+
+	task.webhookSecret, _ = preparedParameters[paramWebhookSecret].(string)
+
+	return task
+}
@@ -0,0 +1,441 @@
+package telegram
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTelegram records requests and returns queued updates on polling.
+type fakeTelegram struct {
+	mt       *sync.Mutex
+	requests []string
+	updates  chan []tgbotapi.Update
+}
+
+func newFakeTelegram() *fakeTelegram {
+	return &fakeTelegram{mt: &sync.Mutex{}, updates: make(chan []tgbotapi.Update, 1)}
+}
+
+func (f *fakeTelegram) MakeRequest(endpoint string, params url.Values) (tgbotapi.APIResponse, error) {
+	f.mt.Lock()
+	f.requests = append(f.requests, endpoint+" "+params.Encode())
+	f.mt.Unlock()
+
+	switch endpoint {
+	case "sendMessage":
+		return tgbotapi.APIResponse{Ok: true, Result: []byte(`{"message_id":15}`)}, nil
+	case "getUpdates":
+		select {
+		case updates := <-f.updates:
+			b, _ := json.Marshal(updates)
+			return tgbotapi.APIResponse{Ok: true, Result: b}, nil
+		case <-time.After(10 * time.Millisecond):
+			return tgbotapi.APIResponse{Ok: true, Result: []byte(`[]`)}, nil
+		}
+	default:
+		return tgbotapi.APIResponse{Ok: true, Result: []byte(`true`)}, nil
+	}
+}
+
+// calls returns requests except getUpdates.
+func (f *fakeTelegram) calls() []string {
+	f.mt.Lock()
+	defer f.mt.Unlock()
+
+	var calls []string
+	for _, r := range f.requests {
+		if !strings.HasPrefix(r, "getUpdates") {
+			calls = append(calls, r)
+		}
+	}
+	return calls
+}
+
+// waitSent waits for approval message is sent.
+func (f *fakeTelegram) waitSent() {
+	for len(f.calls()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func callbackQuery(data, userName string, userID int) *tgbotapi.CallbackQuery {
+	return &tgbotapi.CallbackQuery{ID: "cq1", Data: data, From: &tgbotapi.User{ID: userID, UserName: userName, FirstName: "John"}}
+}
+
+func postCallback(approvals *Approvals, secret string, query *tgbotapi.CallbackQuery) int {
+	b, _ := json.Marshal(tgbotapi.Update{UpdateID: 7, CallbackQuery: query})
+	req := httptest.NewRequest(http.MethodPost, CallbackPath, strings.NewReader(string(b)))
+	req.Header.Set(secretTokenHeader, secret)
+	w := httptest.NewRecorder()
+	logger, _ := test.NewNullLogger()
+	approvals.Callback(w, req, logger)
+	return w.Code
+}
+
+func TestApprovalTask_Exec(t *testing.T) {
+	t.Parallel()
+
+	const keyboard = `{"inline_keyboard":[[{"text":"Approve","callback_data":"approve:a1b2"},{"text":"Reject","callback_data":"reject:a1b2"}]]}`
+	sendMessage := "sendMessage " + url.Values{"chat_id": {"12345678"}, "text": {"Failover c1?"}, "reply_markup": {keyboard}}.Encode()
+	editMessage := func(result string) string {
+		return "editMessageText " + url.Values{"chat_id": {"12345678"}, "message_id": {"15"}, "text": {"Failover c1?\n\n" + result}}.Encode()
+	}
+	answerCallback := func(text string) string {
+		return "answerCallbackQuery " + url.Values{"callback_query_id": {"cq1"}, "text": {text}}.Encode()
+	}
+
+	type testTableData struct {
+		tcase          string
+		callback       string
+		webhookSecret  string
+		callbackFunc   func(approvals *Approvals, telegram *fakeTelegram)
+		expectedErr    error
+		expectedOutput map[string]interface{}
+		expectedCalls  []string
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:         "webhook approve",
+			callback:      callbackWebhook,
+			webhookSecret: "s3cr3t",
+			callbackFunc: func(approvals *Approvals, telegram *fakeTelegram) {
+				postCallback(approvals, "s3cr3t", callbackQuery("approve:a1b2", "mallory", 1002))
+				postCallback(approvals, "s3cr3t", callbackQuery("approve:a1b2", "jdoe", 1001))
+			},
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"message_id": 15, "approved_by": "@jdoe (1001)"},
+			expectedCalls: []string{
+				sendMessage,
+				answerCallback("You are not allowed to approve"),
+				answerCallback("Approved"),
+				editMessage("Approved by @jdoe (1001)"),
+			},
+		},
+		{
+			tcase:         "webhook reject by ID",
+			callback:      callbackWebhook,
+			webhookSecret: "s3cr3t",
+			callbackFunc: func(approvals *Approvals, telegram *fakeTelegram) {
+				postCallback(approvals, "s3cr3t", callbackQuery("reject:a1b2", "", 1003))
+			},
+			expectedErr:    errors.New("approval is rejected by John (1003)"),
+			expectedOutput: map[string]interface{}{"message_id": 15, "rejected_by": "John (1003)"},
+			expectedCalls: []string{
+				sendMessage,
+				answerCallback("Rejected"),
+				editMessage("Rejected by John (1003)"),
+			},
+		},
+		{
+			tcase:         "webhook wrong secret",
+			callback:      callbackWebhook,
+			webhookSecret: "s3cr3t",
+			callbackFunc: func(approvals *Approvals, telegram *fakeTelegram) {
+				postCallback(approvals, "wrong", callbackQuery("approve:a1b2", "jdoe", 1001))
+			},
+			expectedErr:    errors.New("approval timeout 50ms exceeded"),
+			expectedOutput: map[string]interface{}{"message_id": 15},
+			expectedCalls: []string{
+				sendMessage,
+				editMessage("Approval timeout 50ms exceeded"),
+			},
+		},
+		{
+			tcase:         "webhook without secret",
+			callback:      callbackWebhook,
+			webhookSecret: "",
+			callbackFunc: func(approvals *Approvals, telegram *fakeTelegram) {
+				postCallback(approvals, "", callbackQuery("approve:a1b2", "jdoe", 1001))
+			},
+			expectedErr:    errors.New("approval timeout 50ms exceeded"),
+			expectedOutput: map[string]interface{}{"message_id": 15},
+			expectedCalls: []string{
+				sendMessage,
+				editMessage("Approval timeout 50ms exceeded"),
+			},
+		},
+		{
+			tcase:         "polling approve",
+			callback:      callbackPolling,
+			webhookSecret: "s3cr3t",
+			callbackFunc: func(approvals *Approvals, telegram *fakeTelegram) {
+				telegram.updates <- []tgbotapi.Update{
+					{UpdateID: 5, Message: &tgbotapi.Message{MessageID: 3}},
+					{UpdateID: 6, CallbackQuery: callbackQuery("approve:a1b2", "jdoe", 1001)},
+				}
+			},
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"message_id": 15, "approved_by": "@jdoe (1001)"},
+			expectedCalls: []string{
+				sendMessage,
+				answerCallback("Approved"),
+				editMessage("Approved by @jdoe (1001)"),
+			},
+		},
+		{
+			tcase:         "polling timeout",
+			callback:      callbackPolling,
+			webhookSecret: "s3cr3t",
+			callbackFunc: func(approvals *Approvals, telegram *fakeTelegram) {
+				// button of another approval is pressed
+				telegram.updates <- []tgbotapi.Update{{UpdateID: 6, CallbackQuery: callbackQuery("approve:c3d4", "jdoe", 1001)}}
+			},
+			expectedErr:    errors.New("approval timeout 50ms exceeded"),
+			expectedOutput: map[string]interface{}{"message_id": 15},
+			expectedCalls: []string{
+				sendMessage,
+				answerCallback("Approval is not pending"),
+				editMessage("Approval timeout 50ms exceeded"),
+			},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		logger, _ := test.NewNullLogger()
+		telegram := newFakeTelegram()
+		approvals := NewApprovals()
+		approvals.idFunc = func() string { return "a1b2" }
+
+		task := &approvalTask{
+			task: &task{
+				chatID:   12345678,
+				message:  "Failover c1?",
+				telegram: telegram,
+			},
+			token:         "123456:ABC",
+			approvers:     []string{"@jdoe", "1003"},
+			timeout:       50 * time.Millisecond,
+			callback:      testUnit.callback,
+			webhookSecret: testUnit.webhookSecret,
+			approvals:     approvals,
+		}
+		task.SetBase("id", "rule", "alert", 0)
+
+		go func() {
+			telegram.waitSent()
+			testUnit.callbackFunc(approvals, telegram)
+		}()
+
+		assert.Equal(t, testUnit.expectedErr, task.Exec(logger), testUnit.tcase)
+		assert.Equal(t, testUnit.expectedOutput, task.Output(), testUnit.tcase)
+		assert.Equal(t, testUnit.expectedCalls, telegram.calls(), testUnit.tcase)
+	}
+}
+
+func TestApprovals_Callback(t *testing.T) {
+	t.Parallel()
+
+	approvals := NewApprovals()
+
+	req := httptest.NewRequest(http.MethodGet, CallbackPath, nil)
+	w := httptest.NewRecorder()
+	logger, hook := test.NewNullLogger()
+	approvals.Callback(w, req, logger)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, CallbackPath, strings.NewReader("{"))
+	w = httptest.NewRecorder()
+	approvals.Callback(w, req, logger)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "update decode error: unexpected EOF", hook.LastEntry().Message)
+
+	assert.Equal(t, http.StatusOK, postCallback(approvals, "", callbackQuery("approve:a1b2", "jdoe", 1001)))
+}
+
+func TestApprovalExecutor_ValidateParameters(t *testing.T) {
+	t.Parallel()
+
+	executorMock := NewApprovalExecutor(&http.Client{}, NewApprovals())
+
+	_, errDuration := time.ParseDuration("30")
+
+	type testTableData struct {
+		tcase    string
+		params   map[string]interface{}
+		expected error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "correct params",
+			params: map[string]interface{}{
+				"bot_token":      "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":        12345678,
+				"message":        "Failover?",
+				"approvers":      []interface{}{"@jdoe", "1003"},
+				"timeout":        "15m",
+				"callback":       "webhook",
+				"webhook_secret": "s3cr3t",
+			},
+			expected: nil,
+		},
+		{
+			tcase: "telegram params error",
+			params: map[string]interface{}{
+				"bot_token": "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"message":   "Failover?",
+			},
+			expected: errors.New("required parameter chat_id is missing"),
+		},
+		{
+			tcase: "approvers wrong type",
+			params: map[string]interface{}{
+				"bot_token": "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":   12345678,
+				"message":   "Failover?",
+				"approvers": "@jdoe",
+			},
+			expected: errApproversNotStrings,
+		},
+		{
+			tcase: "approvers wrong item type",
+			params: map[string]interface{}{
+				"bot_token": "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":   12345678,
+				"message":   "Failover?",
+				"approvers": []interface{}{"@jdoe", 1003},
+			},
+			expected: errApproversNotStrings,
+		},
+		{
+			tcase: "timeout wrong value",
+			params: map[string]interface{}{
+				"bot_token": "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":   12345678,
+				"message":   "Failover?",
+				"timeout":   "30",
+			},
+			expected: errors.New("timeout parameter: " + errDuration.Error()),
+		},
+		{
+			tcase: "callback wrong value",
+			params: map[string]interface{}{
+				"bot_token": "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":   12345678,
+				"message":   "Failover?",
+				"callback":  "push",
+			},
+			expected: errors.New("callback parameter value push is not one of polling, webhook"),
+		},
+		{
+			tcase: "webhook_secret wrong type",
+			params: map[string]interface{}{
+				"bot_token":      "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":        12345678,
+				"message":        "Failover?",
+				"webhook_secret": 123,
+			},
+			expected: errors.New("webhook_secret parameter value is not a string"),
+		},
+		{
+			tcase: "webhook without webhook_secret",
+			params: map[string]interface{}{
+				"bot_token": "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":   12345678,
+				"message":   "Failover?",
+				"callback":  "webhook",
+			},
+			expected: errWebhookWithoutSecret,
+		},
+		{
+			tcase: "webhook with empty webhook_secret",
+			params: map[string]interface{}{
+				"bot_token":      "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":        12345678,
+				"message":        "Failover?",
+				"callback":       "webhook",
+				"webhook_secret": "",
+			},
+			expected: errWebhookWithoutSecret,
+		},
+	}
+
+	for _, testUnit := range testTable {
+		assert.Equal(t, testUnit.expected, executorMock.ValidateParameters(testUnit.params), testUnit.tcase)
+	}
+}
+
+func TestApprovalExecutor_NewTask(t *testing.T) {
+	t.Parallel()
+
+	approvals := NewApprovals()
+	executorMock := NewApprovalExecutor(&http.Client{}, approvals)
+
+	newTask := func() *approvalTask {
+		task := &approvalTask{
+			task: &task{
+				chatID:  12345678,
+				message: "Failover?",
+				telegram: &tgbotapi.BotAPI{
+					Token:  "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+					Buffer: defaultBuffer,
+					Client: &http.Client{},
+				},
+			},
+			token:     "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+			timeout:   defaultApprovalTimeout,
+			callback:  callbackPolling,
+			approvals: approvals,
+		}
+		task.SetBase("825e", "testrule1", "testalert1", 0)
+		return task
+	}
+
+	type testTableData struct {
+		tcase              string
+		preparedParameters map[string]interface{}
+		expected           func() executor.Task
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "default params",
+			preparedParameters: map[string]interface{}{
+				"bot_token": "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":   12345678,
+				"message":   "Failover?",
+			},
+			expected: func() executor.Task {
+				return newTask()
+			},
+		},
+		{
+			tcase: "all params",
+			preparedParameters: map[string]interface{}{
+				"bot_token":      "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":        12345678,
+				"message":        "Failover?",
+				"approvers":      []interface{}{"@jdoe", "1003"},
+				"timeout":        "15m",
+				"callback":       "webhook",
+				"webhook_secret": "s3cr3t",
+			},
+			expected: func() executor.Task {
+				task := newTask()
+				task.approvers = []string{"@jdoe", "1003"}
+				task.timeout = 15 * time.Minute
+				task.callback = callbackWebhook
+				task.webhookSecret = "s3cr3t"
+				return task
+			},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		task := executorMock.NewTask("825e", "testrule1", "testalert1", 0, testUnit.preparedParameters)
+		assert.Equal(t, testUnit.expected(), task, testUnit.tcase)
+		assert.Equal(t, "telegram_approval", task.ExecutorName(), testUnit.tcase)
+	}
+}
@@ -0,0 +1,63 @@
+package telegram
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// messageTTL is how long sent message is remembered to be edited.
+const messageTTL = 48 * time.Hour
+
+// messages keeps IDs of sent messages by rule, chat and alert fingerprint to edit them later.
+type messages struct {
+	mt       *sync.Mutex
+	messages map[string]message
+	nowFunc  func() time.Time
+}
+
+type message struct {
+	id       int
+	storedAt time.Time
+}
+
+func newMessages() *messages {
+	return &messages{
+		mt:       &sync.Mutex{},
+		messages: make(map[string]message),
+		nowFunc:  time.Now,
+	}
+}
+
+// messageKey includes rule, so actions of different rules sending to the same chat do not edit messages of each other.
+func messageKey(rule string, chatID, messageThreadID int64, alertFingerprint string) string {
+	return fmt.Sprintf("%v|%v|%v|%v", rule, chatID, messageThreadID, alertFingerprint)
+}
+
+// get returns ID of the message sent for the key or 0 if it is unknown or expired.
+func (m *messages) get(key string) int {
+	m.mt.Lock()
+	defer m.mt.Unlock()
+
+	msg, ok := m.messages[key]
+	if !ok || m.nowFunc().Sub(msg.storedAt) > messageTTL {
+		return 0
+	}
+
+	return msg.id
+}
+
+// set remembers ID of the message sent for the key. Expired messages are removed.
+func (m *messages) set(key string, id int) {
+	m.mt.Lock()
+	defer m.mt.Unlock()
+
+	now := m.nowFunc()
+	for k, msg := range m.messages {
+		if now.Sub(msg.storedAt) > messageTTL {
+			delete(m.messages, k)
+		}
+	}
+
+	m.messages[key] = message{id: id, storedAt: now}
+}
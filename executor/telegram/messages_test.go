@@ -0,0 +1,38 @@
+package telegram
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMessages(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := newMessages()
+	m.nowFunc = func() time.Time { return now }
+
+	key1 := messageKey("rule", 12345678, 0, "b8a5c0a1e4a5d8c7")
+	key2 := messageKey("rule", 12345678, 42, "b8a5c0a1e4a5d8c7")
+	assert.NotEqual(t, key1, messageKey("rule2", 12345678, 0, "b8a5c0a1e4a5d8c7"))
+
+	assert.Equal(t, 0, m.get(key1))
+
+	m.set(key1, 15)
+	assert.Equal(t, 15, m.get(key1))
+	assert.Equal(t, 0, m.get(key2))
+
+	now = now.Add(messageTTL - time.Minute)
+	m.set(key2, 16)
+	assert.Equal(t, 15, m.get(key1))
+	assert.Equal(t, 16, m.get(key2))
+
+	// expired message is not returned and removed on the next set
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, 0, m.get(key1))
+	assert.Equal(t, 16, m.get(key2))
+
+	m.set(key2, 17)
+	assert.Equal(t, map[string]message{key2: {id: 17, storedAt: now}}, m.messages)
+}
@@ -1,22 +1,37 @@
 package telegram
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/krpn/prometheus-alert-webhooker/executor"
 	"github.com/krpn/prometheus-alert-webhooker/utils"
+	"github.com/prometheus/common/model"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	paramToken   = "bot_token"
-	paramChatID  = "chat_id"
-	paramMessage = "message"
+	paramToken               = "bot_token"
+	paramChatID              = "chat_id"
+	paramMessage             = "message"
+	paramParseMode           = "parse_mode"
+	paramDisableNotification = "disable_notification"
+	paramMessageThreadID     = "message_thread_id"
+	paramGeneratorURLButton  = "generator_url_button"
+	paramEditMessage         = "edit_message"
+
+	parseModeMarkdown   = "Markdown"
+	parseModeMarkdownV2 = "MarkdownV2"
+	parseModeHTML       = "HTML"
 
 	defaultBuffer = 100
+
+	context = "telegram"
 )
 
 var requiredStringParameters = []string{
@@ -24,17 +39,36 @@ var requiredStringParameters = []string{
 	paramMessage,
 }
 
+var boolParameters = []string{
+	paramDisableNotification,
+	paramEditMessage,
+}
+
 //go:generate mockgen -source=telegram.go -destination=telegram_mocks.go -package=telegram doc github.com/golang/mock/gomock
 
 // Telegram is the interface of Telegram client.
 type Telegram interface {
-	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	MakeRequest(endpoint string, params url.Values) (tgbotapi.APIResponse, error)
 }
 
 type task struct {
 	executor.TaskBase
-	chatID   int64
-	message  string
+	chatID              int64
+	message             string
+	parseMode           string
+	disableNotification bool
+	messageThreadID     int64
+
+	// generatorURLButton is a text of buttons linking to generator URLs of alerts.
+	generatorURLButton string
+	generatorURLs      []string
+
+	// editMessage enables editing of the message sent for the same alert before.
+	editMessage      bool
+	alertFingerprint string
+	messages         *messages
+
+	output   map[string]interface{}
 	telegram Telegram
 }
 
@@ -48,22 +82,142 @@ func (task *task) ExecutorDetails() interface{} {
 
 func (task *task) Fingerprint() string {
 	base := fmt.Sprintf("%v|%v", strconv.FormatInt(task.chatID, 10), task.message)
+	if task.messageThreadID != 0 {
+		base = fmt.Sprintf("%v|%v|%v", strconv.FormatInt(task.chatID, 10), task.messageThreadID, task.message)
+	}
 	return utils.MD5Hash(base)
 }
 
+// SetAlerts takes generator URLs for buttons and alert fingerprint to find the message to edit.
+func (task *task) SetAlerts(alerts executor.Alerts) {
+	if len(task.generatorURLButton) > 0 {
+		sources := alerts.Matched.Alerts
+		if len(sources) == 0 {
+			sources = append(sources, alerts.Alert)
+		}
+
+		for _, a := range sources {
+			if len(a.GeneratorURL) > 0 && !utils.StringSliceContains(task.generatorURLs, a.GeneratorURL) {
+				task.generatorURLs = append(task.generatorURLs, a.GeneratorURL)
+			}
+		}
+	}
+
+	if task.editMessage && len(alerts.Alert.Labels) > 0 {
+		labels := make(model.LabelSet, len(alerts.Alert.Labels))
+		for name, value := range alerts.Alert.Labels {
+			labels[model.LabelName(name)] = model.LabelValue(value)
+		}
+		task.alertFingerprint = labels.Fingerprint().String()
+	}
+}
+
 func (task *task) Exec(logger *logrus.Logger) error {
-	message := tgbotapi.NewMessage(task.chatID, task.message)
-	_, err := task.telegram.Send(message)
+	params, err := task.params()
+	if err != nil {
+		return err
+	}
+
+	key := messageKey(task.Rule(), task.chatID, task.messageThreadID, task.alertFingerprint)
+	if task.editMessage && len(task.alertFingerprint) > 0 {
+		if messageID := task.messages.get(key); messageID > 0 {
+			err = task.edit(messageID, params)
+			if err == nil {
+				task.output = map[string]interface{}{"message_id": messageID, "edited": true}
+				return nil
+			}
+
+			logger.WithFields(executor.TaskDetails(task)).WithFields(logrus.Fields{
+				"context":    context,
+				"message_id": messageID,
+				"error":      err,
+			}).Warn("message edit error, new message is sent")
+		}
+	}
+
+	resp, err := task.telegram.MakeRequest("sendMessage", params)
+	if err != nil {
+		return err
+	}
+
+	var sent tgbotapi.Message
+	err = json.Unmarshal(resp.Result, &sent)
+	if err != nil {
+		return fmt.Errorf("sent message decode error: %v", err)
+	}
+
+	if task.editMessage && len(task.alertFingerprint) > 0 {
+		task.messages.set(key, sent.MessageID)
+	}
+	task.output = map[string]interface{}{"message_id": sent.MessageID}
+
+	return nil
+}
+
+func (task *task) Output() map[string]interface{} {
+	return task.output
+}
+
+// edit replaces text of sent message. Message with the same text is considered edited.
+func (task *task) edit(messageID int, params url.Values) error {
+	edit := url.Values{}
+	for _, key := range []string{"chat_id", "text", "parse_mode", "reply_markup"} {
+		if val := params.Get(key); len(val) > 0 {
+			edit.Set(key, val)
+		}
+	}
+	edit.Set("message_id", strconv.Itoa(messageID))
+
+	_, err := task.telegram.MakeRequest("editMessageText", edit)
+	if err != nil && strings.Contains(err.Error(), "message is not modified") {
+		return nil
+	}
+
 	return err
 }
 
+// params returns parameters of sendMessage request.
+// Keyboard rows are added before buttons linking to generator URLs.
+func (task *task) params(rows ...[]tgbotapi.InlineKeyboardButton) (url.Values, error) {
+	params := url.Values{}
+	params.Set("chat_id", strconv.FormatInt(task.chatID, 10))
+	params.Set("text", task.message)
+	if len(task.parseMode) > 0 {
+		params.Set("parse_mode", task.parseMode)
+	}
+	if task.disableNotification {
+		params.Set("disable_notification", "true")
+	}
+	if task.messageThreadID != 0 {
+		params.Set("message_thread_id", strconv.FormatInt(task.messageThreadID, 10))
+	}
+
+	for _, generatorURL := range task.generatorURLs {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL(task.generatorURLButton, generatorURL),
+		))
+	}
+
+	if len(rows) > 0 {
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+		markup, err := json.Marshal(keyboard)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("reply_markup", string(markup))
+	}
+
+	return params, nil
+}
+
 type taskExecutor struct {
-	client *http.Client
+	client   *http.Client
+	messages *messages
 }
 
 // NewExecutor creates TaskExecutor for Telegram tasks.
 func NewExecutor(client *http.Client) executor.TaskExecutor {
-	return taskExecutor{client: client}
+	return taskExecutor{client: client, messages: newMessages()}
 }
 
 func (executor taskExecutor) ValidateParameters(parameters map[string]interface{}) error {
@@ -83,9 +237,41 @@ func (executor taskExecutor) ValidateParameters(parameters map[string]interface{
 		return fmt.Errorf("required parameter %v is missing", paramChatID)
 	}
 
-	if _, ok := chatIDStr.(int); !ok {
-		if _, ok := chatIDStr.(float64); !ok {
-			return fmt.Errorf("%v parameter value is not a number", paramChatID)
+	if !isNumber(chatIDStr) {
+		return fmt.Errorf("%v parameter value is not a number", paramChatID)
+	}
+
+	if threadID, ok := parameters[paramMessageThreadID]; ok && !isNumber(threadID) {
+		return fmt.Errorf("%v parameter value is not a number", paramMessageThreadID)
+	}
+
+	if param, ok := parameters[paramParseMode]; ok {
+		mode, ok := param.(string)
+		if !ok {
+			return fmt.Errorf("%v parameter value is not a string", paramParseMode)
+		}
+
+		switch mode {
+		case parseModeMarkdown, parseModeMarkdownV2, parseModeHTML:
+		default:
+			return fmt.Errorf("%v parameter value %v is not one of %v, %v, %v", paramParseMode, mode, parseModeMarkdown, parseModeMarkdownV2, parseModeHTML)
+		}
+	}
+
+	if param, ok := parameters[paramGeneratorURLButton]; ok {
+		if _, ok := param.(string); !ok {
+			return fmt.Errorf("%v parameter value is not a string", paramGeneratorURLButton)
+		}
+	}
+
+	for _, boolParam := range boolParameters {
+		param, ok := parameters[boolParam]
+		if !ok {
+			continue
+		}
+
+		if _, ok := param.(bool); !ok {
+			return fmt.Errorf("%v parameter value is not a boolean", boolParam)
 		}
 	}
 
@@ -93,14 +279,10 @@ func (executor taskExecutor) ValidateParameters(parameters map[string]interface{
 }
 
 func (executor taskExecutor) NewTask(eventID, rule, alert string, blockTTL time.Duration, preparedParameters map[string]interface{}) executor.Task {
-	chatID, ok := preparedParameters[paramChatID].(int)
-	if !ok {
-		chatID = int(preparedParameters[paramChatID].(float64))
-	}
-
 	task := &task{
-		chatID:  int64(chatID),
-		message: preparedParameters[paramMessage].(string),
+		chatID:   toInt64(preparedParameters[paramChatID]),
+		message:  preparedParameters[paramMessage].(string),
+		messages: executor.messages,
 		telegram: &tgbotapi.BotAPI{
 			Token:  preparedParameters[paramToken].(string),
 			Buffer: defaultBuffer,
@@ -108,6 +290,32 @@ func (executor taskExecutor) NewTask(eventID, rule, alert string, blockTTL time.
 		},
 	}
 
+	task.parseMode, _ = preparedParameters[paramParseMode].(string)
+	task.disableNotification, _ = preparedParameters[paramDisableNotification].(bool)
+	task.generatorURLButton, _ = preparedParameters[paramGeneratorURLButton].(string)
+	task.editMessage, _ = preparedParameters[paramEditMessage].(bool)
+	if threadID, ok := preparedParameters[paramMessageThreadID]; ok {
+		task.messageThreadID = toInt64(threadID)
+	}
+
 	task.SetBase(eventID, rule, alert, blockTTL)
 	return task
 }
+
+// isNumber checks value is a number, config numbers are parsed as int or float64.
+func isNumber(val interface{}) bool {
+	switch val.(type) {
+	case int, float64:
+		return true
+	default:
+		return false
+	}
+}
+
+func toInt64(val interface{}) int64 {
+	if i, ok := val.(int); ok {
+		return int64(i)
+	}
+
+	return int64(val.(float64))
+}
@@ -7,6 +7,7 @@ package telegram
 import (
 	telegram_bot_api "github.com/go-telegram-bot-api/telegram-bot-api"
 	gomock "github.com/golang/mock/gomock"
+	url "net/url"
 	reflect "reflect"
 )
 
@@ -33,15 +34,15 @@ func (m *MockTelegram) EXPECT() *MockTelegramMockRecorder {
 	return m.recorder
 }
 
-// Send mocks base method
-func (m *MockTelegram) Send(c telegram_bot_api.Chattable) (telegram_bot_api.Message, error) {
-	ret := m.ctrl.Call(m, "Send", c)
-	ret0, _ := ret[0].(telegram_bot_api.Message)
+// MakeRequest mocks base method
+func (m *MockTelegram) MakeRequest(endpoint string, params url.Values) (telegram_bot_api.APIResponse, error) {
+	ret := m.ctrl.Call(m, "MakeRequest", endpoint, params)
+	ret0, _ := ret[0].(telegram_bot_api.APIResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// Send indicates an expected call of Send
-func (mr *MockTelegramMockRecorder) Send(c interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockTelegram)(nil).Send), c)
+// MakeRequest indicates an expected call of MakeRequest
+func (mr *MockTelegramMockRecorder) MakeRequest(endpoint, params interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeRequest", reflect.TypeOf((*MockTelegram)(nil).MakeRequest), endpoint, params)
 }
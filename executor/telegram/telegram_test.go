@@ -5,9 +5,12 @@ import (
 	"github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/golang/mock/gomock"
 	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"net/http"
+	"net/url"
 	"testing"
 	"time"
 )
@@ -63,6 +66,7 @@ func TestTelegramTaskExecutor_NewTask(t *testing.T) {
 	t.Parallel()
 
 	executorMock := NewExecutor(&http.Client{})
+	messages := executorMock.(taskExecutor).messages
 
 	type testTableData struct {
 		tcase              string
@@ -80,8 +84,9 @@ func TestTelegramTaskExecutor_NewTask(t *testing.T) {
 			},
 			expected: func() executor.Task {
 				task := &task{
-					chatID:  12345678,
-					message: "test",
+					chatID:   12345678,
+					message:  "test",
+					messages: messages,
 					telegram: &tgbotapi.BotAPI{
 						Token:  "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
 						Buffer: defaultBuffer,
@@ -101,8 +106,41 @@ func TestTelegramTaskExecutor_NewTask(t *testing.T) {
 			},
 			expected: func() executor.Task {
 				task := &task{
-					chatID:  12345678,
-					message: "test",
+					chatID:   12345678,
+					message:  "test",
+					messages: messages,
+					telegram: &tgbotapi.BotAPI{
+						Token:  "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+						Buffer: defaultBuffer,
+						Client: &http.Client{},
+					},
+				}
+				task.SetBase("825e", "testrule1", "testalert1", 1*time.Second)
+				return task
+			},
+		},
+		{
+			tcase: "all params",
+			preparedParameters: map[string]interface{}{
+				"bot_token":            "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":              -1001103941234,
+				"message":              "<b>test</b>",
+				"parse_mode":           "HTML",
+				"disable_notification": true,
+				"message_thread_id":    float64(42),
+				"generator_url_button": "Graph",
+				"edit_message":         true,
+			},
+			expected: func() executor.Task {
+				task := &task{
+					chatID:              -1001103941234,
+					message:             "<b>test</b>",
+					parseMode:           "HTML",
+					disableNotification: true,
+					messageThreadID:     42,
+					generatorURLButton:  "Graph",
+					editMessage:         true,
+					messages:            messages,
 					telegram: &tgbotapi.BotAPI{
 						Token:  "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
 						Buffer: defaultBuffer,
@@ -186,6 +224,70 @@ func TestTelegramTaskExecutor_ValidateParameters(t *testing.T) {
 			},
 			expected: errors.New("bot_token parameter value is not a string"),
 		},
+		{
+			tcase: "all params",
+			params: map[string]interface{}{
+				"bot_token":            "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":              -1001103941234,
+				"message":              "*test*",
+				"parse_mode":           "MarkdownV2",
+				"disable_notification": true,
+				"message_thread_id":    42,
+				"generator_url_button": "Graph",
+				"edit_message":         true,
+			},
+			expected: nil,
+		},
+		{
+			tcase: "param parse_mode wrong value",
+			params: map[string]interface{}{
+				"bot_token":  "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":    12345678,
+				"message":    "test",
+				"parse_mode": "markdown",
+			},
+			expected: errors.New("parse_mode parameter value markdown is not one of Markdown, MarkdownV2, HTML"),
+		},
+		{
+			tcase: "param parse_mode wrong type",
+			params: map[string]interface{}{
+				"bot_token":  "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":    12345678,
+				"message":    "test",
+				"parse_mode": 1,
+			},
+			expected: errors.New("parse_mode parameter value is not a string"),
+		},
+		{
+			tcase: "param message_thread_id wrong type",
+			params: map[string]interface{}{
+				"bot_token":         "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":           12345678,
+				"message":           "test",
+				"message_thread_id": "42",
+			},
+			expected: errors.New("message_thread_id parameter value is not a number"),
+		},
+		{
+			tcase: "param generator_url_button wrong type",
+			params: map[string]interface{}{
+				"bot_token":            "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":              12345678,
+				"message":              "test",
+				"generator_url_button": true,
+			},
+			expected: errors.New("generator_url_button parameter value is not a string"),
+		},
+		{
+			tcase: "param edit_message wrong type",
+			params: map[string]interface{}{
+				"bot_token":    "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11",
+				"chat_id":      12345678,
+				"message":      "test",
+				"edit_message": "true",
+			},
+			expected: errors.New("edit_message parameter value is not a boolean"),
+		},
 	}
 
 	for _, testUnit := range testTable {
@@ -210,34 +312,218 @@ func TestTelegramTask_Exec(t *testing.T) {
 	}
 	task.SetBase("id", "rule", "alert", 10*time.Minute)
 
+	params := url.Values{"chat_id": {"12345678"}, "text": {"test"}}
+
 	type testTableData struct {
-		tcase       string
-		expectFunc  func(t *MockTelegram)
-		expectedErr error
+		tcase          string
+		expectFunc     func(t *MockTelegram)
+		expectedErr    error
+		expectedOutput map[string]interface{}
 	}
 
 	testTable := []testTableData{
 		{
 			tcase: "success",
 			expectFunc: func(t *MockTelegram) {
-				t.EXPECT().Send(tgbotapi.NewMessage(12345678, "test")).Return(tgbotapi.Message{}, nil)
+				t.EXPECT().MakeRequest("sendMessage", params).Return(tgbotapi.APIResponse{Ok: true, Result: []byte(`{"message_id":15}`)}, nil)
 			},
-			expectedErr: nil,
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"message_id": 15},
 		},
 		{
 			tcase: "error",
 			expectFunc: func(t *MockTelegram) {
-				t.EXPECT().Send(tgbotapi.NewMessage(12345678, "test")).Return(tgbotapi.Message{}, errors.New("error"))
+				t.EXPECT().MakeRequest("sendMessage", params).Return(tgbotapi.APIResponse{}, errors.New("error"))
 			},
-			expectedErr: errors.New("error"),
+			expectedErr:    errors.New("error"),
+			expectedOutput: nil,
+		},
+		{
+			tcase: "wrong response",
+			expectFunc: func(t *MockTelegram) {
+				t.EXPECT().MakeRequest("sendMessage", params).Return(tgbotapi.APIResponse{Ok: true, Result: []byte(`true`)}, nil)
+			},
+			expectedErr:    errors.New("sent message decode error: json: cannot unmarshal bool into Go value of type tgbotapi.Message"),
+			expectedOutput: nil,
 		},
 	}
 
 	for _, testUnit := range testTable {
+		task.output = nil
 		testUnit.expectFunc(telegramMock)
 		assert.Equal(t, testUnit.expectedErr, task.Exec(logger), testUnit.tcase)
+		assert.Equal(t, testUnit.expectedOutput, task.Output(), testUnit.tcase)
 	}
 
 	// logger is not used
 	assert.Equal(t, 0, len(hook.Entries))
 }
+
+func TestTelegramTask_ExecOptions(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	telegramMock := NewMockTelegram(ctrl)
+
+	logger, _ := test.NewNullLogger()
+
+	task := &task{
+		chatID:              -1001103941234,
+		message:             "<b>test</b>",
+		parseMode:           "HTML",
+		disableNotification: true,
+		messageThreadID:     42,
+		generatorURLButton:  "Graph",
+		telegram:            telegramMock,
+	}
+	task.SetBase("id", "rule", "alert", 10*time.Minute)
+
+	executor.SetAlerts(task, func() executor.Alerts {
+		return executor.Alerts{
+			Matched: template.Data{Alerts: template.Alerts{
+				{Labels: template.KV{"instance": "server1"}, GeneratorURL: "http://prometheus.example.com/graph?g0.expr=up"},
+				{Labels: template.KV{"instance": "server2"}, GeneratorURL: "http://prometheus.example.com/graph?g0.expr=up"},
+				{Labels: template.KV{"instance": "server3"}, GeneratorURL: "http://prometheus2.example.com/graph?g0.expr=up"},
+			}},
+		}
+	})
+
+	telegramMock.EXPECT().MakeRequest("sendMessage", url.Values{
+		"chat_id":              {"-1001103941234"},
+		"text":                 {"<b>test</b>"},
+		"parse_mode":           {"HTML"},
+		"disable_notification": {"true"},
+		"message_thread_id":    {"42"},
+		"reply_markup":         {`{"inline_keyboard":[[{"text":"Graph","url":"http://prometheus.example.com/graph?g0.expr=up"}],[{"text":"Graph","url":"http://prometheus2.example.com/graph?g0.expr=up"}]]}`},
+	}).Return(tgbotapi.APIResponse{Ok: true, Result: []byte(`{"message_id":15}`)}, nil)
+
+	assert.Equal(t, nil, task.Exec(logger))
+}
+
+func TestTelegramTask_ExecEdit(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	telegramMock := NewMockTelegram(ctrl)
+
+	logger, hook := test.NewNullLogger()
+
+	messages := newMessages()
+
+	newTask := func(rule, message, status string) *task {
+		task := &task{
+			chatID:      12345678,
+			message:     message,
+			editMessage: true,
+			messages:    messages,
+			telegram:    telegramMock,
+		}
+		task.SetBase("id", rule, "alert", 10*time.Minute)
+		executor.SetAlerts(task, func() executor.Alerts {
+			return executor.Alerts{Alert: template.Alert{Status: status, Labels: template.KV{"alertname": "ServiceDown", "instance": "server1"}}}
+		})
+		return task
+	}
+
+	type testTableData struct {
+		tcase          string
+		task           *task
+		expectFunc     func(t *MockTelegram)
+		expectedErr    error
+		expectedOutput map[string]interface{}
+		expectedLogs   int
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "first message is sent",
+			task:  newTask("rule", "ServiceDown is firing", "firing"),
+			expectFunc: func(t *MockTelegram) {
+				t.EXPECT().MakeRequest("sendMessage", url.Values{"chat_id": {"12345678"}, "text": {"ServiceDown is firing"}}).Return(tgbotapi.APIResponse{Ok: true, Result: []byte(`{"message_id":15}`)}, nil)
+			},
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"message_id": 15},
+		},
+		{
+			tcase: "message is edited",
+			task:  newTask("rule", "ServiceDown is resolved", "resolved"),
+			expectFunc: func(t *MockTelegram) {
+				t.EXPECT().MakeRequest("editMessageText", url.Values{"chat_id": {"12345678"}, "message_id": {"15"}, "text": {"ServiceDown is resolved"}}).Return(tgbotapi.APIResponse{Ok: true}, nil)
+			},
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"message_id": 15, "edited": true},
+		},
+		{
+			tcase: "message is not modified",
+			task:  newTask("rule", "ServiceDown is resolved", "resolved"),
+			expectFunc: func(t *MockTelegram) {
+				t.EXPECT().MakeRequest("editMessageText", url.Values{"chat_id": {"12345678"}, "message_id": {"15"}, "text": {"ServiceDown is resolved"}}).Return(tgbotapi.APIResponse{}, tgbotapi.Error{Message: "Bad Request: message is not modified"})
+			},
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"message_id": 15, "edited": true},
+		},
+		{
+			tcase: "edit error, new message is sent",
+			task:  newTask("rule", "ServiceDown is firing", "firing"),
+			expectFunc: func(t *MockTelegram) {
+				t.EXPECT().MakeRequest("editMessageText", url.Values{"chat_id": {"12345678"}, "message_id": {"15"}, "text": {"ServiceDown is firing"}}).Return(tgbotapi.APIResponse{}, tgbotapi.Error{Message: "Bad Request: message to edit not found"})
+				t.EXPECT().MakeRequest("sendMessage", url.Values{"chat_id": {"12345678"}, "text": {"ServiceDown is firing"}}).Return(tgbotapi.APIResponse{Ok: true, Result: []byte(`{"message_id":16}`)}, nil)
+			},
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"message_id": 16},
+			expectedLogs:   1,
+		},
+		{
+			tcase: "new message is edited",
+			task:  newTask("rule", "ServiceDown is resolved", "resolved"),
+			expectFunc: func(t *MockTelegram) {
+				t.EXPECT().MakeRequest("editMessageText", url.Values{"chat_id": {"12345678"}, "message_id": {"16"}, "text": {"ServiceDown is resolved"}}).Return(tgbotapi.APIResponse{Ok: true}, nil)
+			},
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"message_id": 16, "edited": true},
+		},
+		{
+			tcase: "message of other rule for the same alert is sent",
+			task:  newTask("rule2", "Restarting server1", "firing"),
+			expectFunc: func(t *MockTelegram) {
+				t.EXPECT().MakeRequest("sendMessage", url.Values{"chat_id": {"12345678"}, "text": {"Restarting server1"}}).Return(tgbotapi.APIResponse{Ok: true, Result: []byte(`{"message_id":17}`)}, nil)
+			},
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"message_id": 17},
+		},
+		{
+			tcase: "message of other rule is edited",
+			task:  newTask("rule2", "server1 is restarted", "firing"),
+			expectFunc: func(t *MockTelegram) {
+				t.EXPECT().MakeRequest("editMessageText", url.Values{"chat_id": {"12345678"}, "message_id": {"17"}, "text": {"server1 is restarted"}}).Return(tgbotapi.APIResponse{Ok: true}, nil)
+			},
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"message_id": 17, "edited": true},
+		},
+		{
+			tcase: "message of the first rule is kept",
+			task:  newTask("rule", "ServiceDown is resolved", "resolved"),
+			expectFunc: func(t *MockTelegram) {
+				t.EXPECT().MakeRequest("editMessageText", url.Values{"chat_id": {"12345678"}, "message_id": {"16"}, "text": {"ServiceDown is resolved"}}).Return(tgbotapi.APIResponse{Ok: true}, nil)
+			},
+			expectedErr:    nil,
+			expectedOutput: map[string]interface{}{"message_id": 16, "edited": true},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		hook.Reset()
+		testUnit.expectFunc(telegramMock)
+		assert.Equal(t, testUnit.expectedErr, testUnit.task.Exec(logger), testUnit.tcase)
+		assert.Equal(t, testUnit.expectedOutput, testUnit.task.Output(), testUnit.tcase)
+		assert.Equal(t, testUnit.expectedLogs, len(hook.Entries), testUnit.tcase)
+		if testUnit.expectedLogs > 0 {
+			assert.Equal(t, logrus.WarnLevel, hook.LastEntry().Level, testUnit.tcase)
+			assert.Equal(t, "message edit error, new message is sent", hook.LastEntry().Message, testUnit.tcase)
+		}
+	}
+}
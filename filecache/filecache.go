@@ -0,0 +1,319 @@
+package filecache
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Cache is a key-value cache with TTL persisted to append-only file.
+// All entries are kept in memory, file is used to restore entries on startup.
+type Cache struct {
+	path    string
+	logger  *logrus.Logger
+	nowFunc func() time.Time
+	rename  func(oldpath, newpath string) error
+
+	mt      *sync.Mutex
+	entries map[string]entry
+	file    *os.File
+	writer  *bufio.Writer
+
+	// records is a quantity of records in file, used to decide when to compact
+	records int
+}
+
+type entry struct {
+	value    []byte
+	expireAt time.Time
+}
+
+// record is a line of append-only file.
+type record struct {
+	Op       string `json:"op"`
+	Key      []byte `json:"key"`
+	Value    []byte `json:"value,omitempty"`
+	ExpireAt int64  `json:"expire_at,omitempty"`
+}
+
+const (
+	opSet = "set"
+	opDel = "del"
+
+	context = "filecache"
+
+	// file is compacted when it has more records than live entries multiplied by compactRatio
+	compactRatio      = 2
+	compactMinRecords = 1000
+)
+
+// ErrNotFound is returned by Get if entry is not found or expired.
+// Text is the same as freecache error has, blocker relies on it.
+var ErrNotFound = errors.New("Entry not found")
+
+// Get returns value by key.
+func (c *Cache) Get(key []byte) (value []byte, err error) {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+
+	e, ok := c.entries[string(key)]
+	if !ok || c.expired(e) {
+		return nil, ErrNotFound
+	}
+
+	return e.value, nil
+}
+
+// Set sets value by key, entry expires after expireSeconds.
+// Entry does not expire if expireSeconds is not positive.
+func (c *Cache) Set(key, value []byte, expireSeconds int) (err error) {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+
+	e := entry{value: value}
+	if expireSeconds > 0 {
+		e.expireAt = c.nowFunc().Add(time.Duration(expireSeconds) * time.Second)
+	}
+
+	err = c.write(record{Op: opSet, Key: key, Value: value, ExpireAt: unix(e.expireAt)})
+	if err != nil {
+		return
+	}
+
+	c.entries[string(key)] = e
+	c.compactIfNeeded()
+
+	return
+}
+
+// Del deletes entry by key.
+func (c *Cache) Del(key []byte) (affected bool) {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+
+	_, affected = c.entries[string(key)]
+	delete(c.entries, string(key))
+
+	if c.write(record{Op: opDel, Key: key}) != nil {
+		return
+	}
+
+	c.compactIfNeeded()
+
+	return
+}
+
+// Values returns all not expired values.
+func (c *Cache) Values() (values [][]byte) {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+
+	for _, e := range c.entries {
+		if c.expired(e) {
+			continue
+		}
+		values = append(values, e.value)
+	}
+
+	return
+}
+
+// Close flushes and closes file.
+func (c *Cache) Close() error {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+
+	err := c.writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	return c.file.Close()
+}
+
+func (c *Cache) expired(e entry) bool {
+	return !e.expireAt.IsZero() && !c.nowFunc().Before(e.expireAt)
+}
+
+func (c *Cache) write(r record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.writer.Write(append(b, '\n'))
+	if err != nil {
+		return err
+	}
+
+	c.records++
+
+	// entries should be on disk before task is executed
+	return c.writer.Flush()
+}
+
+// compactIfNeeded compacts file if it has too many records.
+// Compaction error is logged only: entry is already written, file is compacted on next write.
+func (c *Cache) compactIfNeeded() {
+	if c.records < compactMinRecords || c.records < len(c.entries)*compactRatio {
+		return
+	}
+
+	err := c.compact()
+	if err != nil {
+		c.logger.WithField("context", context).Errorf("compact file error: %v", err)
+	}
+}
+
+// compact rewrites file with live entries only.
+func (c *Cache) compact() error {
+	tmpPath := c.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(tmp)
+	records := 0
+	for key, e := range c.entries {
+		if c.expired(e) {
+			delete(c.entries, key)
+			continue
+		}
+
+		var b []byte
+		b, err = json.Marshal(record{Op: opSet, Key: []byte(key), Value: e.value, ExpireAt: unix(e.expireAt)})
+		if err != nil {
+			_ = tmp.Close()
+			return err
+		}
+
+		_, err = writer.Write(append(b, '\n'))
+		if err != nil {
+			_ = tmp.Close()
+			return err
+		}
+		records++
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	_ = c.file.Close()
+
+	err = c.rename(tmpPath, c.path)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+
+		// records are appended to original file
+		openErr := c.open(c.records)
+		if openErr != nil {
+			return openErr
+		}
+		return err
+	}
+
+	return c.open(records)
+}
+
+func (c *Cache) open(records int) (err error) {
+	c.file, err = os.OpenFile(c.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+
+	c.writer = bufio.NewWriter(c.file)
+	c.records = records
+	return
+}
+
+// restore reads entries from file.
+// Entries without expiration are not restored: they are blocks of tasks which were in progress while stopping.
+func (c *Cache) restore() error {
+	f, err := os.Open(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r record
+		if json.Unmarshal(scanner.Bytes(), &r) != nil {
+			// skip partially written record
+			continue
+		}
+
+		switch r.Op {
+		case opSet:
+			if r.ExpireAt == 0 {
+				delete(c.entries, string(r.Key))
+				continue
+			}
+			c.entries[string(r.Key)] = entry{value: r.Value, expireAt: time.Unix(0, r.ExpireAt)}
+		case opDel:
+			delete(c.entries, string(r.Key))
+		}
+	}
+
+	return scanner.Err()
+}
+
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// New creates Cache instance, restores entries from file and compacts it.
+// Errors of compaction while writing are logged by logger.
+func New(path string, logger *logrus.Logger, nowFunc func() time.Time) (*Cache, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cache{
+		path:    path,
+		logger:  logger,
+		nowFunc: nowFunc,
+		rename:  os.Rename,
+		mt:      &sync.Mutex{},
+		entries: make(map[string]entry),
+	}
+
+	err = c.restore()
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.open(0)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.compact()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,198 @@
+package filecache
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tempPath(t *testing.T) (path string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "filecache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "blocks", "blocks.log"), func() { _ = os.RemoveAll(dir) }
+}
+
+func fileLines(t *testing.T, path string) []string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	now := time.Unix(1535086351, 0)
+	nowFunc := func() time.Time {
+		return now
+	}
+
+	c, err := New(path, logrus.New(), nowFunc)
+	assert.Equal(t, nil, err)
+
+	_, err = c.Get([]byte("key1"))
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.Equal(t, nil, c.Set([]byte("key1"), []byte("l"), 60))
+	assert.Equal(t, nil, c.Set([]byte("key2"), []byte("l"), 0))
+	assert.Equal(t, nil, c.Set([]byte("key3"), []byte("l"), 10))
+
+	value, err := c.Get([]byte("key1"))
+	assert.Equal(t, []byte("l"), value)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, true, c.Del([]byte("key3")))
+	assert.Equal(t, false, c.Del([]byte("key3")))
+	assert.Equal(t, [][]byte{[]byte("l"), []byte("l")}, c.Values())
+
+	now = now.Add(time.Minute)
+	_, err = c.Get([]byte("key1"))
+	assert.Equal(t, ErrNotFound, err)
+
+	value, err = c.Get([]byte("key2"))
+	assert.Equal(t, []byte("l"), value)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, nil, c.Close())
+}
+
+func TestNew_Restore(t *testing.T) {
+	t.Parallel()
+
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	now := time.Unix(1535086351, 0)
+	nowFunc := func() time.Time {
+		return now
+	}
+
+	c, err := New(path, logrus.New(), nowFunc)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, nil, c.Set([]byte("ttl"), []byte("l"), 600))
+	assert.Equal(t, nil, c.Set([]byte("expired"), []byte("l"), 10))
+	assert.Equal(t, nil, c.Set([]byte("in_progress"), []byte("l"), 0))
+	assert.Equal(t, nil, c.Set([]byte("deleted"), []byte("l"), 600))
+	c.Del([]byte("deleted"))
+	assert.Equal(t, nil, c.Close())
+
+	// partially written record
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	assert.Equal(t, nil, err)
+	_, err = f.WriteString(`{"op":"set","ke`)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, f.Close())
+
+	now = now.Add(time.Minute)
+	c, err = New(path, logrus.New(), nowFunc)
+	assert.Equal(t, nil, err)
+	defer c.Close()
+
+	value, err := c.Get([]byte("ttl"))
+	assert.Equal(t, []byte("l"), value)
+	assert.Equal(t, nil, err)
+
+	for _, key := range []string{"expired", "in_progress", "deleted"} {
+		_, err = c.Get([]byte(key))
+		assert.Equal(t, ErrNotFound, err, key)
+	}
+
+	// file is compacted on startup
+	assert.Equal(t, 1, len(fileLines(t, path)))
+
+	// TTL is restored, not renewed
+	now = now.Add(9 * time.Minute)
+	_, err = c.Get([]byte("ttl"))
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestCache_compactIfNeeded(t *testing.T) {
+	t.Parallel()
+
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	c, err := New(path, logrus.New(), time.Now)
+	assert.Equal(t, nil, err)
+	defer c.Close()
+
+	for i := 0; i < compactMinRecords; i++ {
+		assert.Equal(t, nil, c.Set([]byte("key1"), []byte("l"), 600))
+	}
+
+	assert.Equal(t, 1, c.records)
+	assert.Equal(t, 1, len(fileLines(t, path)))
+}
+
+func TestCache_compactIfNeeded_Error(t *testing.T) {
+	t.Parallel()
+
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	logger, hook := test.NewNullLogger()
+	c, err := New(path, logger, time.Now)
+	assert.Equal(t, nil, err)
+	defer c.Close()
+
+	// temporary file of compaction can not be created
+	assert.Equal(t, nil, os.MkdirAll(path+".tmp", 0700))
+
+	for i := 0; i < compactMinRecords; i++ {
+		assert.Equal(t, nil, c.Set([]byte("key1"), []byte("l"), 600))
+	}
+
+	assert.Equal(t, compactMinRecords, c.records)
+	assert.Equal(t, logrus.ErrorLevel, hook.LastEntry().Level)
+	assert.Equal(t, "filecache", hook.LastEntry().Data["context"])
+}
+
+func TestCache_compact_RenameError(t *testing.T) {
+	t.Parallel()
+
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	c, err := New(path, logrus.New(), time.Now)
+	assert.Equal(t, nil, err)
+	defer c.Close()
+
+	assert.Equal(t, nil, c.Set([]byte("key1"), []byte("value1"), 600))
+
+	c.rename = func(oldpath, newpath string) error { return errors.New("rename error") }
+	assert.Equal(t, errors.New("rename error"), c.compact())
+
+	// original file is reopened and written
+	assert.Equal(t, nil, c.Set([]byte("key2"), []byte("value2"), 600))
+	assert.Equal(t, 2, len(fileLines(t, path)))
+
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestNew_Error(t *testing.T) {
+	t.Parallel()
+
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	// path is a directory
+	assert.Equal(t, nil, os.MkdirAll(path, 0700))
+
+	_, err := New(path, logrus.New(), time.Now)
+	assert.NotEqual(t, nil, err)
+}
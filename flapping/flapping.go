@@ -0,0 +1,116 @@
+package flapping
+
+import (
+	"github.com/krpn/prometheus-alert-webhooker/model"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Detector detects flapping alerts by their status transitions.
+type Detector struct {
+	// settings keeps *model.Flapping swapped on config refresh
+	settings *atomic.Value
+	mt       *sync.Mutex
+	alerts   map[string]*alertState
+}
+
+type alertState struct {
+	status   string
+	lastSeen time.Time
+
+	// transitions keeps last settings.Transitions status transitions
+	transitions []time.Time
+	flapping    bool
+}
+
+// Observe registers alert status and returns true if alert is flapping.
+// Detector is disabled if settings are not set.
+func (d *Detector) Observe(fingerprint, status string, now time.Time) (flapping bool) {
+	settings := d.settings.Load().(*model.Flapping)
+	if settings == nil {
+		return false
+	}
+
+	d.mt.Lock()
+	defer d.mt.Unlock()
+
+	state, ok := d.alerts[fingerprint]
+	if !ok {
+		// first observation is not a transition
+		d.alerts[fingerprint] = &alertState{status: status, lastSeen: now}
+		return false
+	}
+
+	state.lastSeen = now
+
+	if state.status != status {
+		state.status = status
+		state.transitions = append(state.transitions, now)
+		if len(state.transitions) > settings.Transitions {
+			state.transitions = state.transitions[len(state.transitions)-settings.Transitions:]
+		}
+
+		if len(state.transitions) == settings.Transitions && now.Sub(state.transitions[0]) <= settings.Window {
+			state.flapping = true
+		}
+	}
+
+	return isFlapping(state, settings, now)
+}
+
+// FlappingQty returns quantity of currently flapping alerts.
+// It also forgets alerts which were not observed for a long time.
+func (d *Detector) FlappingQty(now time.Time) (qty int) {
+	settings := d.settings.Load().(*model.Flapping)
+	if settings == nil {
+		return 0
+	}
+
+	d.mt.Lock()
+	defer d.mt.Unlock()
+
+	for fingerprint, state := range d.alerts {
+		if isFlapping(state, settings, now) {
+			qty++
+			continue
+		}
+
+		if now.Sub(state.lastSeen) > settings.Window+settings.Stable {
+			delete(d.alerts, fingerprint)
+		}
+	}
+
+	return
+}
+
+// Update replaces settings, it is called on config refresh.
+// Observed alerts are kept, detector is disabled if settings are not set.
+func (d *Detector) Update(settings *model.Flapping) {
+	d.settings.Store(settings)
+}
+
+func isFlapping(state *alertState, settings *model.Flapping, now time.Time) bool {
+	if !state.flapping {
+		return false
+	}
+
+	// alert is stable
+	if now.Sub(state.transitions[len(state.transitions)-1]) >= settings.Stable {
+		state.flapping = false
+	}
+
+	return state.flapping
+}
+
+// New creates Detector instance.
+func New(settings *model.Flapping) *Detector {
+	d := &Detector{
+		settings: &atomic.Value{},
+		mt:       &sync.Mutex{},
+		alerts:   make(map[string]*alertState),
+	}
+	d.Update(settings)
+
+	return d
+}
@@ -0,0 +1,126 @@
+package flapping
+
+import (
+	"github.com/krpn/prometheus-alert-webhooker/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDetector_Observe(t *testing.T) {
+	t.Parallel()
+
+	settings := &model.Flapping{
+		Transitions: 3,
+		Window:      time.Hour,
+		Stable:      30 * time.Minute,
+	}
+
+	type step struct {
+		after            time.Duration
+		fingerprint      string
+		status           string
+		expectedFlapping bool
+	}
+
+	type testTableData struct {
+		tcase    string
+		settings *model.Flapping
+		steps    []step
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:    "flapping until stable",
+			settings: settings,
+			steps: []step{
+				{fingerprint: "fp1", status: "firing"},
+				{after: 10 * time.Minute, fingerprint: "fp1", status: "resolved"},
+				{after: 10 * time.Minute, fingerprint: "fp1", status: "firing"},
+				{after: 10 * time.Minute, fingerprint: "fp2", status: "firing"},
+				{after: 10 * time.Minute, fingerprint: "fp1", status: "resolved", expectedFlapping: true},
+				{after: 10 * time.Minute, fingerprint: "fp1", status: "resolved", expectedFlapping: true},
+				{after: 10 * time.Minute, fingerprint: "fp2", status: "firing"},
+				{after: 10 * time.Minute, fingerprint: "fp1", status: "resolved"},
+			},
+		},
+		{
+			tcase:    "slow transitions",
+			settings: settings,
+			steps: []step{
+				{fingerprint: "fp1", status: "firing"},
+				{after: 35 * time.Minute, fingerprint: "fp1", status: "resolved"},
+				{after: 35 * time.Minute, fingerprint: "fp1", status: "firing"},
+				{after: 35 * time.Minute, fingerprint: "fp1", status: "resolved"},
+				{after: 35 * time.Minute, fingerprint: "fp1", status: "firing"},
+			},
+		},
+		{
+			tcase:    "disabled",
+			settings: nil,
+			steps: []step{
+				{fingerprint: "fp1", status: "firing"},
+				{fingerprint: "fp1", status: "resolved"},
+				{fingerprint: "fp1", status: "firing"},
+				{fingerprint: "fp1", status: "resolved"},
+			},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		now := time.Unix(1535086351, 0)
+		detector := New(testUnit.settings)
+		for i, s := range testUnit.steps {
+			now = now.Add(s.after)
+			assert.Equal(t, s.expectedFlapping, detector.Observe(s.fingerprint, s.status, now), "%v: step #%v", testUnit.tcase, i+1)
+		}
+	}
+}
+
+func TestDetector_FlappingQty(t *testing.T) {
+	t.Parallel()
+
+	detector := New(&model.Flapping{
+		Transitions: 2,
+		Window:      time.Hour,
+		Stable:      time.Hour,
+	})
+
+	now := time.Unix(1535086351, 0)
+
+	detector.Observe("fp1", "firing", now)
+	detector.Observe("fp1", "resolved", now)
+	detector.Observe("fp1", "firing", now)
+	detector.Observe("fp2", "firing", now)
+	assert.Equal(t, 1, detector.FlappingQty(now))
+	assert.Equal(t, 2, len(detector.alerts))
+
+	// fp1 is stable, fp2 is forgotten
+	now = now.Add(3 * time.Hour)
+	assert.Equal(t, 0, detector.FlappingQty(now))
+	assert.Equal(t, 0, len(detector.alerts))
+
+	assert.Equal(t, 0, New(nil).FlappingQty(now))
+}
+
+func TestDetector_Update(t *testing.T) {
+	t.Parallel()
+
+	detector := New(nil)
+	now := time.Unix(1535086351, 0)
+
+	assert.Equal(t, false, detector.Observe("fp1", "firing", now))
+
+	detector.Update(&model.Flapping{
+		Transitions: 2,
+		Window:      time.Hour,
+		Stable:      time.Hour,
+	})
+	assert.Equal(t, false, detector.Observe("fp1", "firing", now))
+	assert.Equal(t, false, detector.Observe("fp1", "resolved", now))
+	assert.Equal(t, true, detector.Observe("fp1", "firing", now))
+
+	detector.Update(nil)
+	assert.Equal(t, false, detector.Observe("fp1", "resolved", now))
+	assert.Equal(t, 0, detector.FlappingQty(now))
+}
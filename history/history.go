@@ -0,0 +1,66 @@
+package history
+
+import "time"
+
+// Execution is a record of executed tasks group.
+type Execution struct {
+	ID      int64  `json:"id"`
+	EventID string `json:"event_id"`
+	Rule    string `json:"rule"`
+	Alert   string `json:"alert"`
+
+	// Result is a result of the last executed task of the group.
+	Result string `json:"result"`
+
+	// Error is an error of the last executed task of the group.
+	Error string `json:"error,omitempty"`
+
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+
+	// Tasks are executed tasks of the group, tasks skipped after unsuccessful result are not included.
+	Tasks []TaskExecution `json:"tasks"`
+}
+
+// TaskExecution is a record of executed task.
+type TaskExecution struct {
+	Executor   string                 `json:"executor"`
+	Details    interface{}            `json:"details"`
+	Result     string                 `json:"result"`
+	Error      string                 `json:"error,omitempty"`
+	Output     map[string]interface{} `json:"output,omitempty"`
+	StartedAt  time.Time              `json:"started_at"`
+	FinishedAt time.Time              `json:"finished_at"`
+}
+
+// Filter describes executions to return. Empty fields are not filtered.
+type Filter struct {
+	Rule   string
+	Alert  string
+	Result string
+
+	// From and To limit execution start time.
+	From time.Time
+	To   time.Time
+
+	// Limit is a maximum quantity of returned executions.
+	Limit int
+}
+
+// Match returns true if execution satisfies filter.
+func (f Filter) Match(execution Execution) bool {
+	switch {
+	case len(f.Rule) > 0 && execution.Rule != f.Rule:
+		return false
+	case len(f.Alert) > 0 && execution.Alert != f.Alert:
+		return false
+	case len(f.Result) > 0 && execution.Result != f.Result:
+		return false
+	case !f.From.IsZero() && execution.StartedAt.Before(f.From):
+		return false
+	case !f.To.IsZero() && execution.StartedAt.After(f.To):
+		return false
+	default:
+		return true
+	}
+}
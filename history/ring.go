@@ -0,0 +1,68 @@
+package history
+
+import "sync"
+
+// Ring keeps the latest executions in memory.
+// The oldest execution is overwritten when ring is full.
+type Ring struct {
+	mt         *sync.RWMutex
+	executions []Execution
+	next       int
+	full       bool
+	seq        int64
+}
+
+// Record adds execution to ring.
+func (r *Ring) Record(execution Execution) error {
+	r.mt.Lock()
+	defer r.mt.Unlock()
+
+	r.seq++
+	execution.ID = r.seq
+	r.executions[r.next] = execution
+
+	r.next++
+	if r.next == len(r.executions) {
+		r.next = 0
+		r.full = true
+	}
+
+	return nil
+}
+
+// Executions returns executions matching filter, newest first.
+func (r *Ring) Executions(filter Filter) ([]Execution, error) {
+	r.mt.RLock()
+	defer r.mt.RUnlock()
+
+	qty := r.next
+	if r.full {
+		qty = len(r.executions)
+	}
+
+	executions := make([]Execution, 0)
+	for i := 1; i <= qty; i++ {
+		if filter.Limit > 0 && len(executions) >= filter.Limit {
+			break
+		}
+
+		execution := r.executions[(r.next-i+len(r.executions))%len(r.executions)]
+		if filter.Match(execution) {
+			executions = append(executions, execution)
+		}
+	}
+
+	return executions, nil
+}
+
+// NewRing creates Ring instance keeping size executions.
+func NewRing(size int) *Ring {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Ring{
+		mt:         &sync.RWMutex{},
+		executions: make([]Execution, size),
+	}
+}
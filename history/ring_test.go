@@ -0,0 +1,124 @@
+package history
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func testExecutions(now time.Time) []Execution {
+	return []Execution{
+		{EventID: "825e", Rule: "testrule1", Alert: "testalert1", Result: "success", StartedAt: now, FinishedAt: now.Add(time.Second)},
+		{EventID: "825e", Rule: "testrule2", Alert: "testalert1", Result: "exec_error", Error: "exit status 1", StartedAt: now.Add(time.Minute), FinishedAt: now.Add(time.Minute)},
+		{EventID: "998e", Rule: "testrule1", Alert: "testalert2", Result: "in_block", StartedAt: now.Add(2 * time.Minute), FinishedAt: now.Add(2 * time.Minute)},
+		{
+			EventID:    "998e",
+			Rule:       "testrule1",
+			Alert:      "testalert1",
+			Result:     "success",
+			StartedAt:  now.Add(3 * time.Minute),
+			FinishedAt: now.Add(3 * time.Minute),
+			Tasks: []TaskExecution{
+				{
+					Executor:   "shell",
+					Details:    map[string]interface{}{"command": "echo"},
+					Result:     "success",
+					Output:     map[string]interface{}{"stdout": "done"},
+					StartedAt:  now.Add(3 * time.Minute),
+					FinishedAt: now.Add(3 * time.Minute),
+				},
+			},
+		},
+	}
+}
+
+func withIDs(executions []Execution, ids ...int64) []Execution {
+	r := make([]Execution, len(ids))
+	for i, id := range ids {
+		r[i] = executions[id-1]
+		r[i].ID = id
+	}
+	return r
+}
+
+func testFilters(now time.Time, executions []Execution) []struct {
+	tcase    string
+	filter   Filter
+	expected []Execution
+} {
+	return []struct {
+		tcase    string
+		filter   Filter
+		expected []Execution
+	}{
+		{
+			tcase:    "all",
+			filter:   Filter{},
+			expected: withIDs(executions, 4, 3, 2, 1),
+		},
+		{
+			tcase:    "rule",
+			filter:   Filter{Rule: "testrule1"},
+			expected: withIDs(executions, 4, 3, 1),
+		},
+		{
+			tcase:    "alert and result",
+			filter:   Filter{Alert: "testalert1", Result: "success"},
+			expected: withIDs(executions, 4, 1),
+		},
+		{
+			tcase:    "time range",
+			filter:   Filter{From: now.Add(time.Minute), To: now.Add(2 * time.Minute)},
+			expected: withIDs(executions, 3, 2),
+		},
+		{
+			tcase:    "limit",
+			filter:   Filter{Rule: "testrule1", Limit: 2},
+			expected: withIDs(executions, 4, 3),
+		},
+		{
+			tcase:    "not found",
+			filter:   Filter{Rule: "testrule3"},
+			expected: []Execution{},
+		},
+	}
+}
+
+func TestRing(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1535086351, 0).UTC()
+	executions := testExecutions(now)
+
+	ring := NewRing(10)
+	for _, execution := range executions {
+		assert.Equal(t, nil, ring.Record(execution))
+	}
+
+	for _, testUnit := range testFilters(now, executions) {
+		actual, err := ring.Executions(testUnit.filter)
+		assert.Equal(t, nil, err, testUnit.tcase)
+		assert.Equal(t, testUnit.expected, actual, testUnit.tcase)
+	}
+}
+
+func TestRing_Overwrite(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1535086351, 0).UTC()
+	executions := testExecutions(now)
+
+	ring := NewRing(3)
+	actual, err := ring.Executions(Filter{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []Execution{}, actual)
+
+	for _, execution := range executions {
+		assert.Equal(t, nil, ring.Record(execution))
+	}
+
+	// the oldest execution is overwritten
+	actual, err = ring.Executions(Filter{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, withIDs(executions, 4, 3, 2), actual)
+}
@@ -0,0 +1,158 @@
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	_ "github.com/mattn/go-sqlite3" // registers sqlite3 driver
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SQLite keeps the latest executions in SQLite database, so history survives restarts.
+// Executions exceeding size are deleted after each record.
+type SQLite struct {
+	db   *sql.DB
+	size int
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS executions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_id TEXT NOT NULL,
+	rule TEXT NOT NULL,
+	alert TEXT NOT NULL,
+	result TEXT NOT NULL,
+	started_at INTEGER NOT NULL,
+	data TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS executions_started_at ON executions (started_at);
+`
+
+// Record inserts execution and deletes executions exceeding size.
+func (s *SQLite) Record(execution Execution) error {
+	data, err := json.Marshal(execution)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.Exec(
+		"INSERT INTO executions (event_id, rule, alert, result, started_at, data) VALUES (?, ?, ?, ?, ?, ?)",
+		execution.EventID, execution.Rule, execution.Alert, execution.Result, execution.StartedAt.UnixNano(), string(data),
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("DELETE FROM executions WHERE id <= ?", id-int64(s.size))
+	return err
+}
+
+// Executions returns executions matching filter, newest first.
+func (s *SQLite) Executions(filter Filter) ([]Execution, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	for _, field := range []struct {
+		column, value string
+	}{
+		{"rule", filter.Rule},
+		{"alert", filter.Alert},
+		{"result", filter.Result},
+	} {
+		if len(field.value) > 0 {
+			conditions = append(conditions, field.column+" = ?")
+			args = append(args, field.value)
+		}
+	}
+
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "started_at >= ?")
+		args = append(args, filter.From.UnixNano())
+	}
+
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "started_at <= ?")
+		args = append(args, filter.To.UnixNano())
+	}
+
+	query := "SELECT id, data FROM executions"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	executions := make([]Execution, 0)
+	for rows.Next() {
+		var (
+			id   int64
+			data string
+		)
+
+		err = rows.Scan(&id, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		var execution Execution
+		err = json.Unmarshal([]byte(data), &execution)
+		if err != nil {
+			return nil, fmt.Errorf("execution %v: %v", id, err)
+		}
+		execution.ID = id
+
+		executions = append(executions, execution)
+	}
+
+	return executions, rows.Err()
+}
+
+// Close closes database.
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// NewSQLite opens or creates SQLite database at path keeping size executions.
+func NewSQLite(path string, size int) (*SQLite, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// sqlite does not support concurrent writes
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	if size < 1 {
+		size = 1
+	}
+
+	return &SQLite{db: db, size: size}, nil
+}
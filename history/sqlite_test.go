@@ -0,0 +1,69 @@
+package history
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func tempPath(t *testing.T) (path string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "history", "history.db"), func() { _ = os.RemoveAll(dir) }
+}
+
+func TestSQLite(t *testing.T) {
+	t.Parallel()
+
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	now := time.Unix(1535086351, 0).UTC()
+	executions := testExecutions(now)
+
+	store, err := NewSQLite(path, 10)
+	assert.Equal(t, nil, err)
+	for _, execution := range executions {
+		assert.Equal(t, nil, store.Record(execution))
+	}
+	assert.Equal(t, nil, store.Close())
+
+	// history is kept after reopen
+	store, err = NewSQLite(path, 10)
+	assert.Equal(t, nil, err)
+	defer store.Close()
+
+	for _, testUnit := range testFilters(now, executions) {
+		actual, err := store.Executions(testUnit.filter)
+		assert.Equal(t, nil, err, testUnit.tcase)
+		assert.Equal(t, testUnit.expected, actual, testUnit.tcase)
+	}
+}
+
+func TestSQLite_Trim(t *testing.T) {
+	t.Parallel()
+
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	now := time.Unix(1535086351, 0).UTC()
+	executions := testExecutions(now)
+
+	store, err := NewSQLite(path, 3)
+	assert.Equal(t, nil, err)
+	defer store.Close()
+
+	for _, execution := range executions {
+		assert.Equal(t, nil, store.Record(execution))
+	}
+
+	// the oldest execution is deleted
+	actual, err := store.Executions(Filter{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, withIDs(executions, 4, 3, 2), actual)
+}
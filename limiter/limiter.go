@@ -0,0 +1,160 @@
+package limiter
+
+import (
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/krpn/prometheus-alert-webhooker/model"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// LimitRule is a limit for tasks exceeded rule rate limit.
+	LimitRule = "rule_rate_limited"
+
+	// LimitExecutor is a limit for tasks exceeded executor rate limit.
+	LimitExecutor = "executor_rate_limited"
+
+	// LimitCircuitBreaker is a limit for tasks while circuit breaker is tripped.
+	LimitCircuitBreaker = "circuit_breaker_open"
+)
+
+// Limiter limits tasks executions by rule and executor rate limits and global circuit breaker.
+type Limiter struct {
+	// settings keeps *settings swapped on config refresh
+	settings *atomic.Value
+	nowFunc  func() time.Time
+
+	mt                 *sync.Mutex
+	ruleExecutions     map[string][]time.Time
+	executorExecutions map[string][]time.Time
+	executions         []time.Time
+	haltedUntil        time.Time
+}
+
+// settings are limits of rules and executors and circuit breaker.
+type settings struct {
+	rules          model.Rules
+	executorLimits map[string]model.RateLimit
+	breaker        *model.CircuitBreaker
+}
+
+// Allow checks limits for task and counts its execution if task is allowed.
+// Returns empty limit if task is allowed.
+// Returns tripped true if circuit breaker is tripped by this task.
+func (l *Limiter) Allow(task executor.Task) (limit string, tripped bool) {
+	l.mt.Lock()
+	defer l.mt.Unlock()
+
+	now := l.nowFunc()
+	if now.Before(l.haltedUntil) {
+		return LimitCircuitBreaker, false
+	}
+
+	s := l.settings.Load().(*settings)
+	rule, executorName := task.Rule(), strings.ToLower(task.ExecutorName())
+
+	ruleLimit := s.ruleLimit(rule)
+	if ruleLimit != nil {
+		l.ruleExecutions[rule] = trim(l.ruleExecutions[rule], now, ruleLimit.Window)
+		if len(l.ruleExecutions[rule]) >= ruleLimit.Max {
+			return LimitRule, false
+		}
+	}
+
+	executorLimit, ok := s.executorLimits[executorName]
+	if ok {
+		l.executorExecutions[executorName] = trim(l.executorExecutions[executorName], now, executorLimit.Window)
+		if len(l.executorExecutions[executorName]) >= executorLimit.Max {
+			return LimitExecutor, false
+		}
+	}
+
+	if s.breaker != nil {
+		l.executions = trim(l.executions, now, s.breaker.Window)
+		if len(l.executions) >= s.breaker.MaxActions {
+			l.haltedUntil = now.Add(s.breaker.Halt)
+			l.executions = nil
+			return LimitCircuitBreaker, true
+		}
+		l.executions = append(l.executions, now)
+	}
+
+	if ruleLimit != nil {
+		l.ruleExecutions[rule] = append(l.ruleExecutions[rule], now)
+	}
+
+	if ok {
+		l.executorExecutions[executorName] = append(l.executorExecutions[executorName], now)
+	}
+
+	return "", false
+}
+
+// TripTasks returns tasks to notify about circuit breaker trip.
+func (l *Limiter) TripTasks(eventID string) model.Tasks {
+	l.mt.Lock()
+	defer l.mt.Unlock()
+
+	breaker := l.settings.Load().(*settings).breaker
+	if breaker == nil {
+		return model.Tasks{}
+	}
+
+	return breaker.NewTasks(eventID, l.haltedUntil)
+}
+
+// Update replaces limits and circuit breaker, it is called on config refresh.
+// Counted executions are kept.
+func (l *Limiter) Update(rules model.Rules, executorLimits map[string]model.RateLimit, breaker *model.CircuitBreaker) {
+	l.settings.Store(newSettings(rules, executorLimits, breaker))
+}
+
+// ruleLimit returns rate limit of the rule.
+func (s *settings) ruleLimit(name string) *model.RateLimit {
+	for _, rule := range s.rules {
+		if rule.Name == name {
+			return rule.RateLimit
+		}
+	}
+	return nil
+}
+
+// trim removes executions older than window.
+func trim(executions []time.Time, now time.Time, window time.Duration) []time.Time {
+	from := now.Add(-window)
+	for i, t := range executions {
+		if t.After(from) {
+			return executions[i:]
+		}
+	}
+	return executions[:0]
+}
+
+func newSettings(rules model.Rules, executorLimits map[string]model.RateLimit, breaker *model.CircuitBreaker) *settings {
+	limits := make(map[string]model.RateLimit, len(executorLimits))
+	for executorName, limit := range executorLimits {
+		limits[strings.ToLower(executorName)] = limit
+	}
+
+	return &settings{
+		rules:          rules,
+		executorLimits: limits,
+		breaker:        breaker,
+	}
+}
+
+// New creates Limiter instance.
+func New(rules model.Rules, executorLimits map[string]model.RateLimit, breaker *model.CircuitBreaker, nowFunc func() time.Time) *Limiter {
+	l := &Limiter{
+		settings:           &atomic.Value{},
+		nowFunc:            nowFunc,
+		mt:                 &sync.Mutex{},
+		ruleExecutions:     make(map[string][]time.Time),
+		executorExecutions: make(map[string][]time.Time),
+	}
+	l.Update(rules, executorLimits, breaker)
+
+	return l
+}
@@ -0,0 +1,176 @@
+package limiter
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/krpn/prometheus-alert-webhooker/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newTask := func(rule, executorName string) *executor.MockTask {
+		task := executor.NewMockTask(ctrl)
+		task.EXPECT().Rule().Return(rule).AnyTimes()
+		task.EXPECT().ExecutorName().Return(executorName).AnyTimes()
+		return task
+	}
+
+	type step struct {
+		after           time.Duration
+		task            *executor.MockTask
+		expectedLimit   string
+		expectedTripped bool
+	}
+
+	type testTableData struct {
+		tcase          string
+		rules          model.Rules
+		executorLimits map[string]model.RateLimit
+		breaker        *model.CircuitBreaker
+		steps          []step
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "rule rate limit",
+			rules: model.Rules{
+				{Name: "testrule1", RateLimit: &model.RateLimit{Max: 2, Window: time.Minute}},
+				{Name: "testrule2"},
+			},
+			steps: []step{
+				{task: newTask("testrule1", "shell")},
+				{task: newTask("testrule1", "shell")},
+				{task: newTask("testrule1", "shell"), expectedLimit: LimitRule},
+				{task: newTask("testrule2", "shell")},
+				{after: 30 * time.Second, task: newTask("testrule1", "shell"), expectedLimit: LimitRule},
+				{after: 31 * time.Second, task: newTask("testrule1", "shell")},
+			},
+		},
+		{
+			tcase:          "executor rate limit",
+			rules:          model.Rules{},
+			executorLimits: map[string]model.RateLimit{"Shell": {Max: 1, Window: time.Minute}},
+			steps: []step{
+				{task: newTask("testrule1", "shell")},
+				{task: newTask("testrule2", "shell"), expectedLimit: LimitExecutor},
+				{task: newTask("testrule2", "jenkins")},
+				{after: time.Minute, task: newTask("testrule2", "shell")},
+			},
+		},
+		{
+			tcase: "limited tasks are not counted",
+			rules: model.Rules{
+				{Name: "testrule1", RateLimit: &model.RateLimit{Max: 1, Window: time.Minute}},
+			},
+			executorLimits: map[string]model.RateLimit{"shell": {Max: 2, Window: time.Minute}},
+			steps: []step{
+				{task: newTask("testrule1", "shell")},
+				{task: newTask("testrule1", "shell"), expectedLimit: LimitRule},
+				{task: newTask("testrule2", "shell")},
+			},
+		},
+		{
+			tcase: "circuit breaker",
+			rules: model.Rules{},
+			breaker: &model.CircuitBreaker{
+				MaxActions: 2,
+				Window:     time.Minute,
+				Halt:       10 * time.Minute,
+			},
+			steps: []step{
+				{task: newTask("testrule1", "shell")},
+				{task: newTask("testrule2", "jenkins")},
+				{task: newTask("testrule3", "http"), expectedLimit: LimitCircuitBreaker, expectedTripped: true},
+				{after: 9 * time.Minute, task: newTask("testrule3", "http"), expectedLimit: LimitCircuitBreaker},
+				{after: time.Minute, task: newTask("testrule3", "http")},
+			},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		now := time.Unix(1535086351, 0)
+		nowFunc := func() time.Time {
+			return now
+		}
+
+		limiter := New(testUnit.rules, testUnit.executorLimits, testUnit.breaker, nowFunc)
+		for i, s := range testUnit.steps {
+			now = now.Add(s.after)
+			limit, tripped := limiter.Allow(s.task)
+			assert.Equal(t, s.expectedLimit, limit, "%v: step #%v", testUnit.tcase, i+1)
+			assert.Equal(t, s.expectedTripped, tripped, "%v: step #%v", testUnit.tcase, i+1)
+		}
+	}
+}
+
+func TestLimiter_TripTasks(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executorMock := executor.NewMockTaskExecutor(ctrl)
+	task := executor.NewMockTask(ctrl)
+
+	nowFunc := func() time.Time {
+		return time.Unix(1535086351, 0)
+	}
+
+	breaker := &model.CircuitBreaker{
+		MaxActions: 1,
+		Window:     time.Minute,
+		Halt:       time.Hour,
+		Actions: model.Actions{
+			{
+				Executor:     "telegram",
+				Parameters:   map[string]interface{}{"message": "halted until ${ANNOTATION_HALTED_UNTIL}"},
+				TaskExecutor: executorMock,
+			},
+		},
+	}
+
+	assert.Equal(t, model.Tasks{}, New(model.Rules{}, nil, nil, nowFunc).TripTasks("4a72"))
+
+	executorMock.EXPECT().NewTask("4a72", model.CircuitBreakerRuleName, "WebhookerCircuitBreakerTripped", time.Duration(0), map[string]interface{}{
+		"message": "halted until 2018-08-24T05:52:31Z",
+	}).Return(task)
+
+	limiter := New(model.Rules{}, nil, breaker, nowFunc)
+	limiter.haltedUntil = nowFunc().Add(breaker.Halt).UTC()
+	assert.Equal(t, model.Tasks{task}, limiter.TripTasks("4a72"))
+}
+
+func TestLimiter_Update(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	task := executor.NewMockTask(ctrl)
+	task.EXPECT().Rule().Return("testrule1").AnyTimes()
+	task.EXPECT().ExecutorName().Return("shell").AnyTimes()
+
+	limiter := New(model.Rules{{Name: "testrule1"}}, nil, nil, time.Now)
+
+	limit, _ := limiter.Allow(task)
+	assert.Equal(t, "", limit)
+
+	limiter.Update(model.Rules{{Name: "testrule1"}}, map[string]model.RateLimit{"Shell": {Max: 1, Window: time.Minute}}, nil)
+	limit, _ = limiter.Allow(task)
+	assert.Equal(t, "", limit)
+	limit, _ = limiter.Allow(task)
+	assert.Equal(t, LimitExecutor, limit)
+
+	limiter.Update(model.Rules{{Name: "testrule1", RateLimit: &model.RateLimit{Max: 1, Window: time.Minute}}}, nil, nil)
+	limit, _ = limiter.Allow(task)
+	assert.Equal(t, "", limit)
+	limit, _ = limiter.Allow(task)
+	assert.Equal(t, LimitRule, limit)
+}
@@ -7,12 +7,16 @@ import (
 
 // PrometheusMetrics describes Prometheus metric collector.
 type PrometheusMetrics struct {
-	incomeTasks  incomeTasks
-	excutedTasks excutedTasks
+	incomeTasks    incomeTasks
+	excutedTasks   excutedTasks
+	skippedRules   skippedRules
+	flappingAlerts flappingAlerts
+	expiredLeases  expiredLeases
 }
 
 // New creates PrometheusMetrics.
-func New() *PrometheusMetrics {
+// Failure cooldowns gauge is computed by failureCooldowns on each scrape.
+func New(failureCooldowns func() int) *PrometheusMetrics {
 	incomeTasks := pr.NewCounterVec(
 		pr.CounterOpts{
 			Namespace: "prometheus",
@@ -33,12 +37,58 @@ func New() *PrometheusMetrics {
 		[]string{"rule", "alert", "executor", "result", "error"},
 	)
 
+	skippedRules := pr.NewCounterVec(
+		pr.CounterOpts{
+			Namespace: "prometheus",
+			Subsystem: "alert_webhooker",
+			Name:      "skipped_rules",
+			Help:      "Matched rules with skipped actions counter.",
+		},
+		[]string{"rule", "alert", "reason"},
+	)
+
+	flappingAlerts := pr.NewGauge(
+		pr.GaugeOpts{
+			Namespace: "prometheus",
+			Subsystem: "alert_webhooker",
+			Name:      "flapping_alerts",
+			Help:      "Currently flapping alerts.",
+		},
+	)
+
+	expiredLeases := pr.NewCounterVec(
+		pr.CounterOpts{
+			Namespace: "prometheus",
+			Subsystem: "alert_webhooker",
+			Name:      "expired_block_leases",
+			Help:      "In-progress block leases expired while task is executing counter.",
+		},
+		[]string{"rule", "alert", "executor"},
+	)
+
+	cooldowns := pr.NewGaugeFunc(
+		pr.GaugeOpts{
+			Namespace: "prometheus",
+			Subsystem: "alert_webhooker",
+			Name:      "failure_cooldowns",
+			Help:      "Currently blocked actions after consecutive failures.",
+		},
+		func() float64 { return float64(failureCooldowns()) },
+	)
+
 	pr.MustRegister(incomeTasks)
 	pr.MustRegister(excutedTasks)
+	pr.MustRegister(skippedRules)
+	pr.MustRegister(flappingAlerts)
+	pr.MustRegister(expiredLeases)
+	pr.MustRegister(cooldowns)
 
 	p := &PrometheusMetrics{
-		incomeTasks:  incomeTasks,
-		excutedTasks: excutedTasks,
+		incomeTasks:    incomeTasks,
+		excutedTasks:   excutedTasks,
+		skippedRules:   skippedRules,
+		flappingAlerts: flappingAlerts,
+		expiredLeases:  expiredLeases,
 	}
 
 	return p
@@ -54,6 +104,21 @@ func (p *PrometheusMetrics) ExecutedTaskObserve(rule, alert, executor, result st
 	p.excutedTasks.WithLabelValues(rule, alert, executor, result, errTextOrEmpty(err)).Observe(duration.Seconds())
 }
 
+// SkippedRuleInc increments skipped rules counter with given parameters.
+func (p *PrometheusMetrics) SkippedRuleInc(rule, alert, reason string) {
+	p.skippedRules.WithLabelValues(rule, alert, reason).Inc()
+}
+
+// FlappingAlertsSet sets currently flapping alerts gauge.
+func (p *PrometheusMetrics) FlappingAlertsSet(qty int) {
+	p.flappingAlerts.Set(float64(qty))
+}
+
+// BlockLeaseExpiredInc increments expired block leases counter with given parameters.
+func (p *PrometheusMetrics) BlockLeaseExpiredInc(rule, alert, executor string) {
+	p.expiredLeases.WithLabelValues(rule, alert, executor).Inc()
+}
+
 func errTextOrEmpty(err error) string {
 	if err == nil {
 		return ""
@@ -70,3 +135,15 @@ type incomeTasks interface {
 type excutedTasks interface {
 	WithLabelValues(lvs ...string) pr.Observer
 }
+
+type skippedRules interface {
+	WithLabelValues(lvs ...string) pr.Counter
+}
+
+type flappingAlerts interface {
+	Set(float64)
+}
+
+type expiredLeases interface {
+	WithLabelValues(lvs ...string) pr.Counter
+}
@@ -87,3 +87,114 @@ func (m *MockexcutedTasks) WithLabelValues(lvs ...string) prometheus.Observer {
 func (mr *MockexcutedTasksMockRecorder) WithLabelValues(lvs ...interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithLabelValues", reflect.TypeOf((*MockexcutedTasks)(nil).WithLabelValues), lvs...)
 }
+
+// MockskippedRules is a mock of skippedRules interface
+type MockskippedRules struct {
+	ctrl     *gomock.Controller
+	recorder *MockskippedRulesMockRecorder
+}
+
+// MockskippedRulesMockRecorder is the mock recorder for MockskippedRules
+type MockskippedRulesMockRecorder struct {
+	mock *MockskippedRules
+}
+
+// NewMockskippedRules creates a new mock instance
+func NewMockskippedRules(ctrl *gomock.Controller) *MockskippedRules {
+	mock := &MockskippedRules{ctrl: ctrl}
+	mock.recorder = &MockskippedRulesMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockskippedRules) EXPECT() *MockskippedRulesMockRecorder {
+	return m.recorder
+}
+
+// WithLabelValues mocks base method
+func (m *MockskippedRules) WithLabelValues(lvs ...string) prometheus.Counter {
+	varargs := []interface{}{}
+	for _, a := range lvs {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "WithLabelValues", varargs...)
+	ret0, _ := ret[0].(prometheus.Counter)
+	return ret0
+}
+
+// WithLabelValues indicates an expected call of WithLabelValues
+func (mr *MockskippedRulesMockRecorder) WithLabelValues(lvs ...interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithLabelValues", reflect.TypeOf((*MockskippedRules)(nil).WithLabelValues), lvs...)
+}
+
+// MockflappingAlerts is a mock of flappingAlerts interface
+type MockflappingAlerts struct {
+	ctrl     *gomock.Controller
+	recorder *MockflappingAlertsMockRecorder
+}
+
+// MockflappingAlertsMockRecorder is the mock recorder for MockflappingAlerts
+type MockflappingAlertsMockRecorder struct {
+	mock *MockflappingAlerts
+}
+
+// NewMockflappingAlerts creates a new mock instance
+func NewMockflappingAlerts(ctrl *gomock.Controller) *MockflappingAlerts {
+	mock := &MockflappingAlerts{ctrl: ctrl}
+	mock.recorder = &MockflappingAlertsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockflappingAlerts) EXPECT() *MockflappingAlertsMockRecorder {
+	return m.recorder
+}
+
+// Set mocks base method
+func (m *MockflappingAlerts) Set(arg0 float64) {
+	m.ctrl.Call(m, "Set", arg0)
+}
+
+// Set indicates an expected call of Set
+func (mr *MockflappingAlertsMockRecorder) Set(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockflappingAlerts)(nil).Set), arg0)
+}
+
+// MockexpiredLeases is a mock of expiredLeases interface
+type MockexpiredLeases struct {
+	ctrl     *gomock.Controller
+	recorder *MockexpiredLeasesMockRecorder
+}
+
+// MockexpiredLeasesMockRecorder is the mock recorder for MockexpiredLeases
+type MockexpiredLeasesMockRecorder struct {
+	mock *MockexpiredLeases
+}
+
+// NewMockexpiredLeases creates a new mock instance
+func NewMockexpiredLeases(ctrl *gomock.Controller) *MockexpiredLeases {
+	mock := &MockexpiredLeases{ctrl: ctrl}
+	mock.recorder = &MockexpiredLeasesMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockexpiredLeases) EXPECT() *MockexpiredLeasesMockRecorder {
+	return m.recorder
+}
+
+// WithLabelValues mocks base method
+func (m *MockexpiredLeases) WithLabelValues(lvs ...string) prometheus.Counter {
+	varargs := []interface{}{}
+	for _, a := range lvs {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "WithLabelValues", varargs...)
+	ret0, _ := ret[0].(prometheus.Counter)
+	return ret0
+}
+
+// WithLabelValues indicates an expected call of WithLabelValues
+func (mr *MockexpiredLeasesMockRecorder) WithLabelValues(lvs ...interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithLabelValues", reflect.TypeOf((*MockexpiredLeases)(nil).WithLabelValues), lvs...)
+}
@@ -18,10 +18,26 @@ func TestPrometheus_ConsistentLabelCardinality(t *testing.T) {
 		}
 	}()
 
-	p := New()
+	p := New(func() int { return 1 })
 
 	p.IncomeTaskInc("testrule1", "testalert1", "testexecutor1")
 	p.ExecutedTaskObserve("testrule1", "testalert1", "testexecutor1", "success", nil, time.Second)
+	p.SkippedRuleInc("testrule1", "testalert1", "muted")
+	p.FlappingAlertsSet(2)
+	p.BlockLeaseExpiredInc("testrule1", "testalert1", "testexecutor1")
+
+	families, err := pr.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cooldowns float64
+	for _, family := range families {
+		if family.GetName() == "prometheus_alert_webhooker_failure_cooldowns" {
+			cooldowns = family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	assert.Equal(t, float64(1), cooldowns)
 }
 
 func TestPrometheusm_IncomeTaskInc(t *testing.T) {
@@ -111,6 +127,45 @@ func TestPrometheusm_ExecutedTaskObserve(t *testing.T) {
 	}
 }
 
+func TestPrometheusm_SkippedRuleInc(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	skippedRules := NewMockskippedRules(ctrl)
+	prometheus := &PrometheusMetrics{skippedRules: skippedRules}
+
+	skippedRules.EXPECT().WithLabelValues("testrule1", "testalert1", "muted").Return(pr.NewCounter(pr.CounterOpts{}))
+	prometheus.SkippedRuleInc("testrule1", "testalert1", "muted")
+}
+
+func TestPrometheusm_FlappingAlertsSet(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flappingAlerts := NewMockflappingAlerts(ctrl)
+	prometheus := &PrometheusMetrics{flappingAlerts: flappingAlerts}
+
+	flappingAlerts.EXPECT().Set(float64(2))
+	prometheus.FlappingAlertsSet(2)
+}
+
+func TestPrometheusm_BlockLeaseExpiredInc(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	expiredLeases := NewMockexpiredLeases(ctrl)
+	prometheus := &PrometheusMetrics{expiredLeases: expiredLeases}
+
+	expiredLeases.EXPECT().WithLabelValues("testrule1", "testalert1", "testexecutor1").Return(pr.NewCounter(pr.CounterOpts{}))
+	prometheus.BlockLeaseExpiredInc("testrule1", "testalert1", "testexecutor1")
+}
+
 func TestErrTextOrEmpty(t *testing.T) {
 	t.Parallel()
 
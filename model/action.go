@@ -1,10 +1,15 @@
 package model
 
 import (
+	"errors"
 	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"regexp"
 	"time"
 )
 
+// unresolvedPlaceholder matches placeholder left in block key when alert has no such label or annotation.
+var unresolvedPlaceholder = regexp.MustCompile(`\$\{[A-Z0-9_]+\}`)
+
 // Action describes direct action as an reaction of alert.
 type Action struct {
 	// Executor of action: shell, jenkins, etc.
@@ -19,9 +24,104 @@ type Action struct {
 	// Block time after action success execute.
 	Block time.Duration `mapstructure:"block"`
 
+	// BlockOnFailure is a base block time after action failed execute (optional).
+	// It is doubled for each consecutive failure.
+	BlockOnFailure time.Duration `mapstructure:"block_on_failure"`
+
+	// BlockKey overrides executor fingerprint the task is blocked by (optional).
+	// Alert placeholders are supported, e.g. ${LABEL_CLUSTER}.
+	BlockKey string `mapstructure:"block_key"`
+
+	// BlockScope is a scope of block: action (by default), rule or global.
+	// Action scope blocks tasks of the same executor, rule scope blocks tasks of all rule actions,
+	// global scope blocks tasks of all rules actions.
+	BlockScope string `mapstructure:"block_scope"`
+
 	// TaskExecutor for this action.
 	TaskExecutor executor.TaskExecutor `mapstructure:"-"`
 }
 
 // Actions is a slice of Action.
 type Actions []Action
+
+// Block scopes of action.
+const (
+	BlockScopeAction = "action"
+	BlockScopeRule   = "rule"
+	BlockScopeGlobal = "global"
+)
+
+var errActionValidateBlockScope = errors.New("invalid block scope: should be action, rule or global")
+
+func (action Action) validateBlockScope() error {
+	switch action.BlockScope {
+	case "", BlockScopeAction, BlockScopeRule, BlockScopeGlobal:
+		return nil
+	default:
+		return errActionValidateBlockScope
+	}
+}
+
+// newTask creates task of action for rule-alert pair.
+// Task is wrapped to be blocked by action block key, scope and block on failure if they are set.
+func (action Action) newTask(rule Rule, alert alert, eventID string) executor.Task {
+	task := action.TaskExecutor.NewTask(eventID, rule.Name, alert.Name(), action.Block, prepareParams(action.Parameters, alert))
+	executor.SetAlerts(task, alert.executorAlerts)
+
+	if len(action.BlockKey) == 0 && (action.BlockScope == "" || action.BlockScope == BlockScopeAction) && action.BlockOnFailure <= 0 {
+		return task
+	}
+
+	blocked := &blockTask{Task: task, blockOnFailure: action.BlockOnFailure}
+
+	// block key with unresolved placeholders would be shared by all alerts missing the label,
+	// executor fingerprint is used instead
+	if key := prepareParam(alert, action.BlockKey); !unresolvedPlaceholder.MatchString(key) {
+		blocked.fingerprint = key
+	}
+
+	switch action.BlockScope {
+	case BlockScopeRule:
+		blocked.scope = BlockScopeRule + ":" + rule.Name
+	case BlockScopeGlobal:
+		blocked.scope = BlockScopeGlobal
+	}
+
+	return blocked
+}
+
+// blockTask is a task blocked by action block settings.
+type blockTask struct {
+	executor.Task
+	fingerprint    string
+	scope          string
+	blockOnFailure time.Duration
+}
+
+// Fingerprint returns prepared block key or executor fingerprint if block key is not set.
+func (task *blockTask) Fingerprint() string {
+	if len(task.fingerprint) == 0 {
+		return task.Task.Fingerprint()
+	}
+
+	return task.fingerprint
+}
+
+// BlockScope returns rule or global scope or executor name for action scope.
+func (task *blockTask) BlockScope() string {
+	if len(task.scope) == 0 {
+		return task.Task.ExecutorName()
+	}
+
+	return task.scope
+}
+
+// BlockOnFailure returns base block time after failed execute.
+func (task *blockTask) BlockOnFailure() time.Duration {
+	return task.blockOnFailure
+}
+
+// Output returns output of wrapped task.
+func (task *blockTask) Output() map[string]interface{} {
+	return executor.Output(task.Task)
+}
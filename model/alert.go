@@ -1,15 +1,25 @@
 package model
 
 import (
+	"github.com/krpn/prometheus-alert-webhooker/executor"
 	"github.com/krpn/prometheus-alert-webhooker/utils"
+	"github.com/prometheus/alertmanager/template"
 	"github.com/prometheus/common/model"
 	"regexp"
+	"time"
 )
 
 type alert struct {
 	Status      string
 	Labels      map[string]string
 	Annotations map[string]string
+	Flapping    bool
+
+	// Sources are alerts of payload the alert is made of: one alert or all alerts of the group.
+	Sources template.Alerts `json:",omitempty"`
+
+	// payload is the payload alert is received in. It is persisted by queue separately from alerts.
+	payload *Payload
 }
 
 func (a alert) match(conditions Conditions) bool {
@@ -51,38 +61,126 @@ func mapMatchConditions(m map[string]string, conditions map[string]string, condi
 	return true
 }
 
-func (a alert) toTasksGroups(rules Rules, eventID string) (tasksGroups TasksGroups) {
-	tasksGroups = make(TasksGroups, 0)
-
-	for _, rule := range rules {
-		if !a.match(rule.Conditions) {
-			continue
-		}
+func (a alert) Name() string {
+	return a.Labels[model.AlertNameLabel]
+}
 
-		tasksGroups = append(tasksGroups, NewTasks(rule, a, eventID))
+// executorAlerts returns alerts for tasks created for the alert.
+func (a alert) executorAlerts() executor.Alerts {
+	alerts := executor.Alerts{}
+	if a.payload != nil {
+		alerts.Payload = template.Data(*a.payload)
 	}
 
-	return
-}
+	alerts.Matched = alerts.Payload
+	alerts.Matched.Alerts = a.Sources
 
-func (a alert) Name() string {
-	return a.Labels[model.AlertNameLabel]
+	if len(a.Sources) == 1 {
+		alerts.Alert = a.Sources[0]
+	} else {
+		alerts.Alert = template.Alert{Status: a.Status, Labels: a.Labels, Annotations: a.Annotations}
+	}
+
+	return alerts
 }
 
 // Alerts  is a slice of Alert.
 type Alerts []alert
 
+// Payload returns payload alerts are received in or nil if it is unknown.
+func (alerts Alerts) Payload() *Payload {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	return alerts[0].payload
+}
+
+// SetPayload sets payload alerts are received in, e.g. when alerts are restored from queue.
+func (alerts Alerts) SetPayload(payload *Payload) {
+	for i := range alerts {
+		alerts[i].payload = payload
+	}
+}
+
 // ToTasksGroups converts alerts to tasks.
-func (alerts Alerts) ToTasksGroups(rules Rules, eventID string) (tasksGroups TasksGroups) {
+// Rules are matched in priority order, per alert and group rules alike: after rule which does not continue
+// matched alert (or alerts of group), next rules are not matched for it.
+// Rules matched alerts but skipped at the moment are returned as skipped rules.
+func (alerts Alerts) ToTasksGroups(rules Rules, eventID string, now time.Time) (tasksGroups TasksGroups, skippedRules SkippedRules) {
 	tasksGroups = make(TasksGroups, 0)
+	skippedRules = make(SkippedRules, 0)
+
+	stopped := make([]bool, len(alerts))
+	for _, rule := range rules {
+		var matched [][]int
+		if rule.Conditions.Group != nil {
+			matched = alerts.matchGroups(rule, stopped)
+		} else {
+			for i, a := range alerts {
+				if !stopped[i] && a.match(rule.Conditions) {
+					matched = append(matched, []int{i})
+				}
+			}
+		}
+
+		for _, indexes := range matched {
+			a := alerts[indexes[0]]
+			if rule.Conditions.Group != nil {
+				a = alerts.pick(indexes).common()
+			}
+
+			tasks, reason := rule.newTasks(a, eventID, now)
+			if len(reason) > 0 {
+				skippedRules = append(skippedRules, SkippedRule{Rule: rule.Name, Alert: a.Name(), Reason: reason})
+			} else {
+				tasksGroups = append(tasksGroups, tasks)
+			}
+
+			if rule.continues() {
+				continue
+			}
 
-	for _, alert := range alerts {
-		tasksGroups = append(tasksGroups, alert.toTasksGroups(rules, eventID)...)
+			for _, i := range indexes {
+				stopped[i] = true
+			}
+		}
 	}
 
 	return
 }
 
+// newTasks creates tasks for matched rule and alert.
+// Returns skip reason if rule actions should not be executed.
+func (rule Rule) newTasks(a alert, eventID string, now time.Time) (tasks Tasks, skipReason string) {
+	if rule.muted(now) {
+		return nil, SkipReasonMuted
+	}
+
+	if a.Flapping {
+		if len(rule.FlappingActions) == 0 {
+			return nil, SkipReasonFlapping
+		}
+
+		rule.Actions = rule.FlappingActions
+	}
+
+	return NewTasks(rule, a, eventID), ""
+}
+
+// SkipReasonMuted is a reason of skipping rule which is muted by its time intervals.
+const SkipReasonMuted = "muted"
+
+// SkippedRule describes rule which matched alert but its actions were skipped.
+type SkippedRule struct {
+	Rule   string `json:"rule"`
+	Alert  string `json:"alert"`
+	Reason string `json:"reason"`
+}
+
+// SkippedRules is a slice of SkippedRule.
+type SkippedRules []SkippedRule
+
 func prepareParams(params map[string]interface{}, alert alert) map[string]interface{} {
 	preparedParams := make(map[string]interface{}, len(params))
 
@@ -3,6 +3,7 @@ package model
 import (
 	"github.com/golang/mock/gomock"
 	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/prometheus/alertmanager/template"
 	"github.com/stretchr/testify/assert"
 	"regexp"
 	"testing"
@@ -273,7 +274,128 @@ func TestAlerts_ToTasksGroups(t *testing.T) {
 
 	for _, testUnit := range testTable {
 		testUnit.expectFunc(executorMock)
-		assert.Equal(t, testUnit.expectedTasksQty, len(testUnit.alerts.ToTasksGroups(testUnit.rules, testUnit.eventID)), testUnit.tcase)
+		tasksGroups, _ := testUnit.alerts.ToTasksGroups(testUnit.rules, testUnit.eventID, time.Now())
+		assert.Equal(t, testUnit.expectedTasksQty, len(tasksGroups), testUnit.tcase)
+	}
+}
+
+func TestAlerts_ToTasksGroups_Continue(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executorMock := executor.NewMockTaskExecutor(ctrl)
+	task := executor.NewMockTask(ctrl)
+
+	stop := false
+
+	alerts := Alerts{
+		{
+			Status: "firing",
+			Labels: map[string]string{
+				"alertname": "testalert1",
+				"job":       "db",
+			},
+		},
+		{
+			Status: "firing",
+			Labels: map[string]string{
+				"alertname": "testalert2",
+				"job":       "web",
+			},
+		},
+	}
+
+	rules := Rules{
+		{
+			Name:     "specific",
+			Continue: &stop,
+			Conditions: Conditions{
+				AlertStatus: "firing",
+				AlertLabels: map[string]string{"job": "db"},
+			},
+			Actions: Actions{
+				{
+					Executor:     "shell",
+					Parameters:   map[string]interface{}{"command": "specific"},
+					TaskExecutor: executorMock,
+				},
+			},
+		},
+		{
+			Name: "generic",
+			Conditions: Conditions{
+				AlertStatus: "firing",
+			},
+			Actions: Actions{
+				{
+					Executor:     "shell",
+					Parameters:   map[string]interface{}{"command": "generic"},
+					TaskExecutor: executorMock,
+				},
+			},
+		},
+	}
+
+	executorMock.EXPECT().NewTask("998e", "specific", "testalert1", time.Duration(0), map[string]interface{}{"command": "specific"}).Return(task)
+	executorMock.EXPECT().NewTask("998e", "generic", "testalert2", time.Duration(0), map[string]interface{}{"command": "generic"}).Return(task)
+
+	tasksGroups, _ := alerts.ToTasksGroups(rules, "998e", time.Now())
+	assert.Equal(t, 2, len(tasksGroups))
+}
+
+func TestAlert_executorAlerts(t *testing.T) {
+	t.Parallel()
+
+	payload := Payload(template.Data{
+		Receiver: "webhooker",
+		Status:   "firing",
+		Alerts: template.Alerts{
+			{Status: "firing", Labels: template.KV{"alertname": "NodeDown", "cluster": "c1", "node": "n1"}},
+			{Status: "firing", Labels: template.KV{"alertname": "NodeDown", "cluster": "c1", "node": "n2"}},
+			{Status: "firing", Labels: template.KV{"alertname": "DiskFull", "cluster": "c1", "node": "n1"}},
+		},
+		CommonLabels: template.KV{"cluster": "c1"},
+	})
+	alerts := payload.ToAlerts()
+
+	type testTableData struct {
+		tcase    string
+		alert    alert
+		expected executor.Alerts
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "alert",
+			alert: alerts[1],
+			expected: executor.Alerts{
+				Alert:   payload.Alerts[1],
+				Matched: template.Data{Receiver: "webhooker", Status: "firing", Alerts: template.Alerts{payload.Alerts[1]}, CommonLabels: template.KV{"cluster": "c1"}},
+				Payload: template.Data(payload),
+			},
+		},
+		{
+			tcase: "group",
+			alert: alerts[:2].common(),
+			expected: executor.Alerts{
+				Alert:   template.Alert{Status: "firing", Labels: map[string]string{"alertname": "NodeDown", "cluster": "c1"}, Annotations: map[string]string{}},
+				Matched: template.Data{Receiver: "webhooker", Status: "firing", Alerts: payload.Alerts[:2], CommonLabels: template.KV{"cluster": "c1"}},
+				Payload: template.Data(payload),
+			},
+		},
+		{
+			tcase: "alert without payload",
+			alert: alert{Status: "firing", Labels: map[string]string{"alertname": "WebhookerRateLimit"}},
+			expected: executor.Alerts{
+				Alert: template.Alert{Status: "firing", Labels: map[string]string{"alertname": "WebhookerRateLimit"}},
+			},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		assert.Equal(t, testUnit.expected, testUnit.alert.executorAlerts(), testUnit.tcase)
 	}
 }
 
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"errors"
+	"github.com/prometheus/common/model"
+	"time"
+)
+
+// Flapping describes settings of flapping alerts detection.
+// Alert is flapping if it changed status Transitions times within Window.
+// Alert stays flapping until it has not changed status for Stable period.
+type Flapping struct {
+	// Transitions is a quantity of status transitions to detect flapping.
+	Transitions int `mapstructure:"transitions"`
+
+	// Window is a time window for counting transitions.
+	Window time.Duration `mapstructure:"window"`
+
+	// Stable is a period without transitions after which alert is not flapping. By default set to Window.
+	Stable time.Duration `mapstructure:"stable"`
+}
+
+// SkipReasonFlapping is a reason of skipping rule for flapping alert without flapping actions.
+const SkipReasonFlapping = "flapping"
+
+var (
+	errFlappingValidateTransitions = errors.New("flapping transitions should be greater than 1")
+	errFlappingValidateWindow      = errors.New("flapping window should be positive")
+	errFlappingValidateStable      = errors.New("flapping stable should not be negative")
+)
+
+// Prepare validates flapping settings and sets defaults.
+func (flapping *Flapping) Prepare() error {
+	if flapping.Transitions < 2 {
+		return errFlappingValidateTransitions
+	}
+
+	if flapping.Window <= 0 {
+		return errFlappingValidateWindow
+	}
+
+	if flapping.Stable < 0 {
+		return errFlappingValidateStable
+	}
+
+	if flapping.Stable == 0 {
+		flapping.Stable = flapping.Window
+	}
+
+	return nil
+}
+
+// DetectFlapping observes alerts status by flapper and marks flapping alerts.
+func (alerts Alerts) DetectFlapping(flapper flapper, now time.Time) {
+	for i, a := range alerts {
+		alerts[i].Flapping = flapper.Observe(a.fingerprint(), a.Status, now)
+	}
+}
+
+// fingerprint returns alert fingerprint calculated by labels the same way as Alertmanager does.
+func (a alert) fingerprint() string {
+	labels := make(model.LabelSet, len(a.Labels))
+	for name, value := range a.Labels {
+		labels[model.LabelName(name)] = model.LabelValue(value)
+	}
+	return labels.Fingerprint().String()
+}
+
+//go:generate mockgen -source=flapping.go -destination=flapping_mocks.go -package=model doc github.com/golang/mock/gomock
+
+type flapper interface {
+	Observe(fingerprint, status string, now time.Time) (flapping bool)
+}
@@ -0,0 +1,147 @@
+package model
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFlapping_Prepare(t *testing.T) {
+	t.Parallel()
+
+	type testTableData struct {
+		tcase            string
+		flapping         Flapping
+		expectedFlapping Flapping
+		expectedErr      error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:            "default stable",
+			flapping:         Flapping{Transitions: 4, Window: time.Hour},
+			expectedFlapping: Flapping{Transitions: 4, Window: time.Hour, Stable: time.Hour},
+			expectedErr:      nil,
+		},
+		{
+			tcase:            "stable",
+			flapping:         Flapping{Transitions: 4, Window: time.Hour, Stable: time.Minute},
+			expectedFlapping: Flapping{Transitions: 4, Window: time.Hour, Stable: time.Minute},
+			expectedErr:      nil,
+		},
+		{
+			tcase:       "one transition",
+			flapping:    Flapping{Transitions: 1, Window: time.Hour},
+			expectedErr: errFlappingValidateTransitions,
+		},
+		{
+			tcase:       "zero window",
+			flapping:    Flapping{Transitions: 4},
+			expectedErr: errFlappingValidateWindow,
+		},
+		{
+			tcase:       "negative stable",
+			flapping:    Flapping{Transitions: 4, Window: time.Hour, Stable: -time.Minute},
+			expectedErr: errFlappingValidateStable,
+		},
+	}
+
+	for _, testUnit := range testTable {
+		err := testUnit.flapping.Prepare()
+		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
+		if err == nil {
+			assert.Equal(t, testUnit.expectedFlapping, testUnit.flapping, testUnit.tcase)
+		}
+	}
+}
+
+func TestAlerts_DetectFlapping(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flapper := NewMockflapper(ctrl)
+	now := time.Unix(1535086351, 0)
+
+	alerts := Alerts{
+		{Status: "firing", Labels: map[string]string{"alertname": "testalert1"}},
+		{Status: "resolved", Labels: map[string]string{"alertname": "testalert2"}},
+	}
+
+	flapper.EXPECT().Observe(alerts[0].fingerprint(), "firing", now).Return(true)
+	flapper.EXPECT().Observe(alerts[1].fingerprint(), "resolved", now).Return(false)
+
+	alerts.DetectFlapping(flapper, now)
+
+	assert.Equal(t, true, alerts[0].Flapping)
+	assert.Equal(t, false, alerts[1].Flapping)
+}
+
+func TestAlert_fingerprint(t *testing.T) {
+	t.Parallel()
+
+	a := alert{Labels: map[string]string{"alertname": "testalert1", "instance": "testinstance1"}}
+	b := alert{Labels: map[string]string{"instance": "testinstance1", "alertname": "testalert1"}, Status: "resolved"}
+	c := alert{Labels: map[string]string{"alertname": "testalert1", "instance": "testinstance2"}}
+
+	assert.Equal(t, a.fingerprint(), b.fingerprint())
+	assert.NotEqual(t, a.fingerprint(), c.fingerprint())
+}
+
+func TestAlerts_ToTasksGroups_Flapping(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executorMock := executor.NewMockTaskExecutor(ctrl)
+	task := executor.NewMockTask(ctrl)
+
+	alerts := Alerts{
+		{Status: "firing", Labels: map[string]string{"alertname": "testalert1", "cluster": "c1"}, Flapping: true},
+		{Status: "firing", Labels: map[string]string{"alertname": "testalert2", "cluster": "c1"}},
+	}
+
+	rules := Rules{
+		{
+			Name:       "suppressed",
+			Conditions: Conditions{AlertStatus: "firing", AlertLabels: map[string]string{"alertname": "testalert1"}},
+			Actions: Actions{
+				{Executor: "shell", Parameters: map[string]interface{}{"command": "restart"}, TaskExecutor: executorMock},
+			},
+		},
+		{
+			Name:       "routed",
+			Conditions: Conditions{AlertStatus: "firing"},
+			Actions: Actions{
+				{Executor: "shell", Parameters: map[string]interface{}{"command": "restart"}, TaskExecutor: executorMock},
+			},
+			FlappingActions: Actions{
+				{Executor: "telegram", Parameters: map[string]interface{}{"message": "${LABEL_ALERTNAME} is flapping"}, TaskExecutor: executorMock},
+			},
+		},
+		{
+			Name: "group",
+			Conditions: Conditions{
+				AlertStatus: "firing",
+				Group:       &GroupConditions{ByLabels: []string{"cluster"}, MinAlerts: 2},
+			},
+			Actions: Actions{
+				{Executor: "shell", Parameters: map[string]interface{}{"command": "failover"}, TaskExecutor: executorMock},
+			},
+		},
+	}
+
+	executorMock.EXPECT().NewTask("4a72", "routed", "testalert1", time.Duration(0), map[string]interface{}{"message": "testalert1 is flapping"}).Return(task)
+	executorMock.EXPECT().NewTask("4a72", "routed", "testalert2", time.Duration(0), map[string]interface{}{"command": "restart"}).Return(task)
+
+	tasksGroups, skippedRules := alerts.ToTasksGroups(rules, "4a72", time.Now())
+	assert.Equal(t, 2, len(tasksGroups))
+	assert.Equal(t, SkippedRules{
+		{Rule: "suppressed", Alert: "testalert1", Reason: SkipReasonFlapping},
+		{Rule: "group", Alert: "", Reason: SkipReasonFlapping},
+	}, skippedRules)
+}
@@ -0,0 +1,196 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"github.com/prometheus/alertmanager/template"
+	"strings"
+)
+
+// GroupConditions describes conditions over all alerts of the payload.
+// Rule with group conditions produces one tasks group per alerts group instead of one per alert.
+type GroupConditions struct {
+	// ByLabels splits alerts into groups by values of these labels.
+	// All alerts of the payload are in one group if empty.
+	ByLabels []string `mapstructure:"by_labels"`
+
+	// MinAlerts is a minimum quantity of alerts in group matched rule conditions. By default set to 1.
+	MinAlerts int `mapstructure:"min_alerts"`
+
+	// Alerts is a list of conditions, each of them must be matched by at least one alert in group.
+	Alerts []Conditions `mapstructure:"alerts"`
+}
+
+const (
+	defaultGroupMinAlerts = 1
+	groupKeySeparator     = "\xff"
+)
+
+var (
+	errGroupValidateNegativeMinAlerts = errors.New("group min alerts should not be negative")
+	errGroupValidateEmptyByLabel      = errors.New("group by label is empty")
+	errGroupValidateNestedGroup       = errors.New("group alerts conditions can not contain group")
+)
+
+func (group *GroupConditions) validateUncompiled() error {
+	if group.MinAlerts < 0 {
+		return errGroupValidateNegativeMinAlerts
+	}
+
+	for _, label := range group.ByLabels {
+		if len(label) == 0 {
+			return errGroupValidateEmptyByLabel
+		}
+	}
+
+	for i, conditions := range group.Alerts {
+		if conditions.Group != nil {
+			return errGroupValidateNestedGroup
+		}
+
+		err := conditions.validateUncompiled()
+		if err != nil {
+			return fmt.Errorf("group alerts conditions #%v: %v", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (group *GroupConditions) setDefaults(alertStatus string) {
+	if group.MinAlerts == 0 {
+		group.MinAlerts = defaultGroupMinAlerts
+	}
+
+	for i, conditions := range group.Alerts {
+		if len(conditions.AlertStatus) == 0 {
+			conditions.AlertStatus = alertStatus
+			group.Alerts[i] = conditions
+		}
+	}
+}
+
+func (group *GroupConditions) compile() {
+	for i, conditions := range group.Alerts {
+		conditions.compile()
+		group.Alerts[i] = conditions
+	}
+}
+
+func (a alert) groupKey(byLabels []string) string {
+	values := make([]string, len(byLabels))
+	for i, label := range byLabels {
+		values[i] = a.Labels[label]
+	}
+	return strings.Join(values, groupKeySeparator)
+}
+
+// matchGroups returns groups of alerts matched group rule as indexes of alerts.
+// Stopped alerts are not grouped, but they are still checked by group alerts conditions.
+func (alerts Alerts) matchGroups(rule Rule, stopped []bool) (groups [][]int) {
+	group := rule.Conditions.Group
+
+	// order of groups is the order of first matched alerts
+	keys := make([]string, 0)
+	matched := make(map[string][]int)
+	for i, a := range alerts {
+		if stopped[i] || !a.match(rule.Conditions) {
+			continue
+		}
+
+		key := a.groupKey(group.ByLabels)
+		if _, ok := matched[key]; !ok {
+			keys = append(keys, key)
+		}
+		matched[key] = append(matched[key], i)
+	}
+
+	for _, key := range keys {
+		indexes := matched[key]
+		if len(indexes) < group.MinAlerts {
+			continue
+		}
+
+		if !alerts.matchGroupAlerts(group, key) {
+			continue
+		}
+
+		groups = append(groups, indexes)
+	}
+
+	return
+}
+
+// pick returns alerts by indexes.
+func (alerts Alerts) pick(indexes []int) Alerts {
+	picked := make(Alerts, len(indexes))
+	for i, index := range indexes {
+		picked[i] = alerts[index]
+	}
+	return picked
+}
+
+func (alerts Alerts) matchGroupAlerts(group *GroupConditions, key string) bool {
+	for _, conditions := range group.Alerts {
+		found := false
+		for _, a := range alerts {
+			if a.groupKey(group.ByLabels) != key {
+				continue
+			}
+
+			if a.match(conditions) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// common returns alert with labels and annotations which are equal for all alerts.
+// It used for placeholders in group tasks.
+func (alerts Alerts) common() alert {
+	if len(alerts) == 0 {
+		return alert{}
+	}
+
+	// group is flapping if any of its alerts is flapping
+	flapping := false
+	var sources template.Alerts
+	for _, a := range alerts {
+		flapping = flapping || a.Flapping
+		sources = append(sources, a.Sources...)
+	}
+
+	return alert{
+		Status:      alerts[0].Status,
+		Labels:      commonMap(alerts, func(a alert) map[string]string { return a.Labels }),
+		Annotations: commonMap(alerts, func(a alert) map[string]string { return a.Annotations }),
+		Flapping:    flapping,
+		Sources:     sources,
+		payload:     alerts[0].payload,
+	}
+}
+
+func commonMap(alerts Alerts, mapFunc func(a alert) map[string]string) map[string]string {
+	common := make(map[string]string)
+	for key, value := range mapFunc(alerts[0]) {
+		common[key] = value
+	}
+
+	for _, a := range alerts[1:] {
+		m := mapFunc(a)
+		for key, value := range common {
+			if avalue, ok := m[key]; !ok || avalue != value {
+				delete(common, key)
+			}
+		}
+	}
+
+	return common
+}
@@ -0,0 +1,362 @@
+package model
+
+import (
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestGroupConditions_validateUncompiled(t *testing.T) {
+	t.Parallel()
+
+	type testTableData struct {
+		tcase    string
+		group    GroupConditions
+		expected error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "valid",
+			group: GroupConditions{
+				ByLabels:  []string{"cluster"},
+				MinAlerts: 3,
+				Alerts: []Conditions{
+					{
+						AlertLabels: map[string]string{"alertname": "LogsHigh"},
+					},
+				},
+			},
+			expected: nil,
+		},
+		{
+			tcase:    "negative min alerts",
+			group:    GroupConditions{MinAlerts: -1},
+			expected: errGroupValidateNegativeMinAlerts,
+		},
+		{
+			tcase:    "empty by label",
+			group:    GroupConditions{ByLabels: []string{"cluster", ""}},
+			expected: errGroupValidateEmptyByLabel,
+		},
+		{
+			tcase: "nested group",
+			group: GroupConditions{
+				Alerts: []Conditions{
+					{
+						Group: &GroupConditions{},
+					},
+				},
+			},
+			expected: errGroupValidateNestedGroup,
+		},
+		{
+			tcase: "invalid alerts conditions",
+			group: GroupConditions{
+				Alerts: []Conditions{
+					{
+						AlertLabels: map[string]string{"alertname": ""},
+					},
+				},
+			},
+			expected: errors.New("group alerts conditions #1: alert label validation error: value for key alertname is empty"),
+		},
+	}
+
+	for _, testUnit := range testTable {
+		assert.Equal(t, testUnit.expected, testUnit.group.validateUncompiled(), testUnit.tcase)
+	}
+}
+
+func TestGroupConditions_setDefaults(t *testing.T) {
+	t.Parallel()
+
+	group := &GroupConditions{
+		Alerts: []Conditions{
+			{AlertStatus: ""},
+			{AlertStatus: "resolved"},
+		},
+	}
+
+	group.setDefaults("firing")
+
+	assert.Equal(t, &GroupConditions{
+		MinAlerts: 1,
+		Alerts: []Conditions{
+			{AlertStatus: "firing"},
+			{AlertStatus: "resolved"},
+		},
+	}, group)
+}
+
+func TestAlerts_ToTasksGroups_Group(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executorMock := executor.NewMockTaskExecutor(ctrl)
+	task := executor.NewMockTask(ctrl)
+
+	actions := Actions{
+		{
+			Executor: "shell",
+			Parameters: map[string]interface{}{
+				"command": "failover ${LABEL_CLUSTER}",
+			},
+			Block:        1 * time.Minute,
+			TaskExecutor: executorMock,
+		},
+	}
+
+	alerts := Alerts{
+		{Status: "firing", Labels: map[string]string{"alertname": "NodeDown", "cluster": "c1", "instance": "n1"}},
+		{Status: "firing", Labels: map[string]string{"alertname": "NodeDown", "cluster": "c1", "instance": "n2"}},
+		{Status: "firing", Labels: map[string]string{"alertname": "NodeDown", "cluster": "c1", "instance": "n3"}},
+		{Status: "firing", Labels: map[string]string{"alertname": "NodeDown", "cluster": "c2", "instance": "n4"}},
+		{Status: "firing", Labels: map[string]string{"alertname": "DiskFull", "cluster": "c2", "instance": "n4"}},
+		{Status: "firing", Labels: map[string]string{"alertname": "LogsHigh", "cluster": "c2", "instance": "n5"}},
+		{Status: "firing", Labels: map[string]string{"alertname": "DiskFull", "cluster": "c3", "instance": "n6"}},
+	}
+
+	type testTableData struct {
+		tcase            string
+		rules            Rules
+		expectFunc       func(e *executor.MockTaskExecutor)
+		expectedTasksQty int
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "min alerts by cluster",
+			rules: Rules{
+				{
+					Name: "testrule1",
+					Conditions: Conditions{
+						AlertStatus: "firing",
+						AlertLabels: map[string]string{"alertname": "NodeDown"},
+						Group: &GroupConditions{
+							ByLabels:  []string{"cluster"},
+							MinAlerts: 3,
+						},
+					},
+					Actions: actions,
+				},
+			},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().NewTask("4a72", "testrule1", "NodeDown", 1*time.Minute, map[string]interface{}{"command": "failover c1"}).Return(task)
+			},
+			expectedTasksQty: 1,
+		},
+		{
+			tcase: "min alerts whole payload",
+			rules: Rules{
+				{
+					Name: "testrule1",
+					Conditions: Conditions{
+						AlertStatus: "firing",
+						AlertLabels: map[string]string{"alertname": "NodeDown"},
+						Group: &GroupConditions{
+							MinAlerts: 4,
+						},
+					},
+					Actions: actions,
+				},
+			},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().NewTask("4a72", "testrule1", "NodeDown", 1*time.Minute, map[string]interface{}{"command": "failover ${LABEL_CLUSTER}"}).Return(task)
+			},
+			expectedTasksQty: 1,
+		},
+		{
+			tcase: "same cluster has both alerts",
+			rules: Rules{
+				{
+					Name: "testrule1",
+					Conditions: Conditions{
+						AlertStatus: "firing",
+						AlertLabels: map[string]string{"alertname": "DiskFull"},
+						Group: &GroupConditions{
+							ByLabels:  []string{"cluster"},
+							MinAlerts: 1,
+							Alerts: []Conditions{
+								{
+									AlertStatus: "firing",
+									AlertLabelsRegexp: map[string]*regexp.Regexp{
+										"alertname": regexp.MustCompile("^(LogsHigh)$"),
+									},
+								},
+							},
+						},
+					},
+					Actions: actions,
+				},
+			},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().NewTask("4a72", "testrule1", "DiskFull", 1*time.Minute, map[string]interface{}{"command": "failover c2"}).Return(task)
+			},
+			expectedTasksQty: 1,
+		},
+		{
+			tcase: "not enough alerts",
+			rules: Rules{
+				{
+					Name: "testrule1",
+					Conditions: Conditions{
+						AlertStatus: "firing",
+						AlertLabels: map[string]string{"alertname": "NodeDown"},
+						Group: &GroupConditions{
+							MinAlerts: 5,
+						},
+					},
+					Actions: actions,
+				},
+			},
+			expectFunc:       func(e *executor.MockTaskExecutor) {},
+			expectedTasksQty: 0,
+		},
+	}
+
+	for _, testUnit := range testTable {
+		testUnit.expectFunc(executorMock)
+		tasksGroups, _ := alerts.ToTasksGroups(testUnit.rules, "4a72", time.Now())
+		assert.Equal(t, testUnit.expectedTasksQty, len(tasksGroups), testUnit.tcase)
+	}
+}
+
+func TestAlerts_ToTasksGroups_GroupPriority(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executorMock := executor.NewMockTaskExecutor(ctrl)
+	task := executor.NewMockTask(ctrl)
+
+	stop := false
+	actions := func(command string) Actions {
+		return Actions{{Executor: "shell", Parameters: map[string]interface{}{"command": command}, TaskExecutor: executorMock}}
+	}
+
+	alerts := Alerts{
+		{Status: "firing", Labels: map[string]string{"alertname": "NodeDown", "cluster": "c1", "instance": "n1"}},
+		{Status: "firing", Labels: map[string]string{"alertname": "NodeDown", "cluster": "c1", "instance": "n2"}},
+		{Status: "firing", Labels: map[string]string{"alertname": "NodeDown", "cluster": "c2", "instance": "n3"}},
+	}
+
+	restart := Rule{
+		Name:       "restart",
+		Conditions: Conditions{AlertStatus: "firing", AlertLabels: map[string]string{"alertname": "NodeDown"}},
+		Actions:    actions("restart ${LABEL_INSTANCE}"),
+	}
+
+	failover := Rule{
+		Name:     "failover",
+		Continue: &stop,
+		Conditions: Conditions{
+			AlertStatus: "firing",
+			AlertLabels: map[string]string{"alertname": "NodeDown"},
+			Group:       &GroupConditions{ByLabels: []string{"cluster"}, MinAlerts: 2},
+		},
+		Actions: actions("failover ${LABEL_CLUSTER}"),
+	}
+
+	type testTableData struct {
+		tcase            string
+		rules            func() Rules
+		expectFunc       func(e *executor.MockTaskExecutor)
+		expectedTasksQty int
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "group rule stops lower priority rules for its alerts",
+			rules: func() Rules {
+				return Rules{failover, restart}
+			},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().NewTask("4a72", "failover", "NodeDown", time.Duration(0), map[string]interface{}{"command": "failover c1"}).Return(task)
+				e.EXPECT().NewTask("4a72", "restart", "NodeDown", time.Duration(0), map[string]interface{}{"command": "restart n3"}).Return(task)
+			},
+			expectedTasksQty: 2,
+		},
+		{
+			tcase: "alert rule stops lower priority group rules for its alert",
+			rules: func() Rules {
+				rule := restart
+				rule.Continue = &stop
+				rule.Conditions.AlertLabels = map[string]string{"instance": "n1"}
+				return Rules{rule, failover}
+			},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().NewTask("4a72", "restart", "NodeDown", time.Duration(0), map[string]interface{}{"command": "restart n1"}).Return(task)
+			},
+			expectedTasksQty: 1,
+		},
+		{
+			tcase: "alert rule with continue does not stop group rules",
+			rules: func() Rules {
+				return Rules{restart, failover}
+			},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().NewTask("4a72", "restart", "NodeDown", time.Duration(0), map[string]interface{}{"command": "restart n1"}).Return(task)
+				e.EXPECT().NewTask("4a72", "restart", "NodeDown", time.Duration(0), map[string]interface{}{"command": "restart n2"}).Return(task)
+				e.EXPECT().NewTask("4a72", "restart", "NodeDown", time.Duration(0), map[string]interface{}{"command": "restart n3"}).Return(task)
+				e.EXPECT().NewTask("4a72", "failover", "NodeDown", time.Duration(0), map[string]interface{}{"command": "failover c1"}).Return(task)
+			},
+			expectedTasksQty: 4,
+		},
+	}
+
+	for _, testUnit := range testTable {
+		testUnit.expectFunc(executorMock)
+		tasksGroups, _ := alerts.ToTasksGroups(testUnit.rules(), "4a72", time.Now())
+		assert.Equal(t, testUnit.expectedTasksQty, len(tasksGroups), testUnit.tcase)
+	}
+}
+
+func TestAlerts_common(t *testing.T) {
+	t.Parallel()
+
+	type testTableData struct {
+		tcase    string
+		alerts   Alerts
+		expected alert
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "common labels and annotations",
+			alerts: Alerts{
+				{
+					Status:      "firing",
+					Labels:      map[string]string{"alertname": "NodeDown", "cluster": "c1", "instance": "n1"},
+					Annotations: map[string]string{"summary": "node down", "runbook": "r1"},
+				},
+				{
+					Status:      "firing",
+					Labels:      map[string]string{"alertname": "NodeDown", "cluster": "c1", "instance": "n2"},
+					Annotations: map[string]string{"summary": "node down"},
+				},
+			},
+			expected: alert{
+				Status:      "firing",
+				Labels:      map[string]string{"alertname": "NodeDown", "cluster": "c1"},
+				Annotations: map[string]string{"summary": "node down"},
+			},
+		},
+		{
+			tcase:    "empty",
+			alerts:   Alerts{},
+			expected: alert{},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		assert.Equal(t, testUnit.expected, testUnit.alerts.common(), testUnit.tcase)
+	}
+}
@@ -0,0 +1,30 @@
+package model
+
+import "errors"
+
+// ExecutionHistory describes settings of executions history.
+// The latest executions are kept in memory, or in SQLite database if SQLitePath is set.
+type ExecutionHistory struct {
+	// Size is a maximum quantity of kept executions. By default set to 1000.
+	Size int `mapstructure:"size"`
+
+	// SQLitePath is a path to SQLite database, history is kept in memory if empty.
+	SQLitePath string `mapstructure:"sqlite_path"`
+}
+
+const defaultExecutionHistorySize = 1000
+
+var errExecutionHistoryValidateSize = errors.New("execution history size should not be negative")
+
+// Prepare validates execution history settings and sets defaults.
+func (history *ExecutionHistory) Prepare() error {
+	if history.Size < 0 {
+		return errExecutionHistoryValidateSize
+	}
+
+	if history.Size == 0 {
+		history.Size = defaultExecutionHistorySize
+	}
+
+	return nil
+}
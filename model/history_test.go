@@ -0,0 +1,44 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExecutionHistory_Prepare(t *testing.T) {
+	t.Parallel()
+
+	type testTableData struct {
+		tcase           string
+		history         ExecutionHistory
+		expectedHistory ExecutionHistory
+		expectedErr     error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:           "defaults",
+			history:         ExecutionHistory{},
+			expectedHistory: ExecutionHistory{Size: defaultExecutionHistorySize},
+			expectedErr:     nil,
+		},
+		{
+			tcase:           "set",
+			history:         ExecutionHistory{Size: 50, SQLitePath: "/tmp/history.db"},
+			expectedHistory: ExecutionHistory{Size: 50, SQLitePath: "/tmp/history.db"},
+			expectedErr:     nil,
+		},
+		{
+			tcase:           "negative size",
+			history:         ExecutionHistory{Size: -1},
+			expectedHistory: ExecutionHistory{Size: -1},
+			expectedErr:     errExecutionHistoryValidateSize,
+		},
+	}
+
+	for _, testUnit := range testTable {
+		err := testUnit.history.Prepare()
+		assert.Equal(t, testUnit.expectedHistory, testUnit.history, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
+	}
+}
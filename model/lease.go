@@ -0,0 +1,51 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// Lease describes settings of in-progress blocks.
+// In-progress block expires after TTL unless it is renewed while task is executing.
+// Block is renewed no longer than MaxDuration, so hung task does not block forever.
+type Lease struct {
+	// TTL is a time in-progress block expires after if not renewed.
+	TTL time.Duration `mapstructure:"ttl"`
+
+	// MaxDuration is a maximum time in-progress block is renewed for.
+	MaxDuration time.Duration `mapstructure:"max_duration"`
+}
+
+const (
+	defaultLeaseTTL         = time.Minute
+	defaultLeaseMaxDuration = time.Hour
+
+	// minLeaseTTL keeps renew interval of in-progress block positive.
+	minLeaseTTL = time.Second
+)
+
+var (
+	errLeaseValidateTTL         = errors.New("block lease ttl should be at least 1s")
+	errLeaseValidateMaxDuration = errors.New("block lease max duration should not be less than ttl")
+)
+
+// Prepare validates lease settings and sets defaults.
+func (lease *Lease) Prepare() error {
+	if lease.TTL == 0 {
+		lease.TTL = defaultLeaseTTL
+	}
+
+	if lease.TTL < minLeaseTTL {
+		return errLeaseValidateTTL
+	}
+
+	if lease.MaxDuration == 0 {
+		lease.MaxDuration = defaultLeaseMaxDuration
+	}
+
+	if lease.MaxDuration < lease.TTL {
+		return errLeaseValidateMaxDuration
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLease_Prepare(t *testing.T) {
+	t.Parallel()
+
+	type testTableData struct {
+		tcase         string
+		lease         Lease
+		expectedLease Lease
+		expectedErr   error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:         "defaults",
+			lease:         Lease{},
+			expectedLease: Lease{TTL: defaultLeaseTTL, MaxDuration: defaultLeaseMaxDuration},
+			expectedErr:   nil,
+		},
+		{
+			tcase:         "set",
+			lease:         Lease{TTL: 10 * time.Second, MaxDuration: 10 * time.Minute},
+			expectedLease: Lease{TTL: 10 * time.Second, MaxDuration: 10 * time.Minute},
+			expectedErr:   nil,
+		},
+		{
+			tcase:         "negative ttl",
+			lease:         Lease{TTL: -time.Second},
+			expectedLease: Lease{TTL: -time.Second},
+			expectedErr:   errLeaseValidateTTL,
+		},
+		{
+			tcase:         "ttl less than second",
+			lease:         Lease{TTL: time.Millisecond},
+			expectedLease: Lease{TTL: time.Millisecond},
+			expectedErr:   errLeaseValidateTTL,
+		},
+		{
+			tcase:         "max duration equal to ttl",
+			lease:         Lease{TTL: time.Second, MaxDuration: time.Second},
+			expectedLease: Lease{TTL: time.Second, MaxDuration: time.Second},
+			expectedErr:   nil,
+		},
+		{
+			tcase:         "max duration less than ttl",
+			lease:         Lease{TTL: 10 * time.Minute, MaxDuration: time.Minute},
+			expectedLease: Lease{TTL: 10 * time.Minute, MaxDuration: time.Minute},
+			expectedErr:   errLeaseValidateMaxDuration,
+		},
+	}
+
+	for _, testUnit := range testTable {
+		err := testUnit.lease.Prepare()
+		assert.Equal(t, testUnit.expectedLease, testUnit.lease, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
+	}
+}
@@ -31,6 +31,8 @@ func (payload Payload) ToAlerts() (alerts Alerts) {
 			Status:      payload.Status,
 			Labels:      labels,
 			Annotations: annotations,
+			Sources:     template.Alerts{a},
+			payload:     &payload,
 		}
 	}
 
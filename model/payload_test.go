@@ -9,6 +9,31 @@ import (
 func TestPayload_ToAlerts(t *testing.T) {
 	t.Parallel()
 
+	payload := Payload(template.Data{
+		Alerts: []template.Alert{
+			{
+				Labels: map[string]string{
+					"label1": "value1",
+				},
+				Annotations: map[string]string{
+					"annotation1": "avalue1",
+				},
+			},
+			{
+				Labels: map[string]string{
+					"label2": "value2",
+				},
+			},
+		},
+		Status: "firing",
+		CommonLabels: map[string]string{
+			"clabel1": "cvalue1",
+		},
+		CommonAnnotations: map[string]string{
+			"cannotation1": "cavalue1",
+		},
+	})
+
 	type testTableData struct {
 		tcase    string
 		payload  Payload
@@ -17,30 +42,7 @@ func TestPayload_ToAlerts(t *testing.T) {
 
 	testTable := []testTableData{
 		{
-			payload: Payload(template.Data{
-				Alerts: []template.Alert{
-					{
-						Labels: map[string]string{
-							"label1": "value1",
-						},
-						Annotations: map[string]string{
-							"annotation1": "avalue1",
-						},
-					},
-					{
-						Labels: map[string]string{
-							"label2": "value2",
-						},
-					},
-				},
-				Status: "firing",
-				CommonLabels: map[string]string{
-					"clabel1": "cvalue1",
-				},
-				CommonAnnotations: map[string]string{
-					"cannotation1": "cavalue1",
-				},
-			}),
+			payload: payload,
 			expected: []alert{
 				{
 					Status: "firing",
@@ -52,6 +54,8 @@ func TestPayload_ToAlerts(t *testing.T) {
 						"cannotation1": "cavalue1",
 						"annotation1":  "avalue1",
 					},
+					Sources: template.Alerts{payload.Alerts[0]},
+					payload: &payload,
 				},
 				{
 					Status: "firing",
@@ -62,6 +66,8 @@ func TestPayload_ToAlerts(t *testing.T) {
 					Annotations: map[string]string{
 						"cannotation1": "cavalue1",
 					},
+					Sources: template.Alerts{payload.Alerts[1]},
+					payload: &payload,
 				},
 			},
 		},
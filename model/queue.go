@@ -0,0 +1,52 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// TaskQueue describes settings of tasks queue persisted to disk.
+// Tasks groups are recovered from queue on startup unless they are older than Expire.
+type TaskQueue struct {
+	// Path to queue file.
+	Path string `mapstructure:"path"`
+
+	// Expire is an age of queued tasks after which they are dropped instead of recovering. By default set to 1h.
+	Expire time.Duration `mapstructure:"expire"`
+}
+
+// QueuedTasks is a tasks group sent to runners.
+type QueuedTasks struct {
+	// Tasks of the group executed sequentially.
+	Tasks Tasks
+
+	// QueueID is an ID of tasks queue record, empty if queue is disabled.
+	QueueID string
+
+	// Group is an index of tasks group in tasks queue record.
+	Group int
+}
+
+const defaultTaskQueueExpire = time.Hour
+
+var (
+	errTaskQueueValidatePath   = errors.New("empty task queue path")
+	errTaskQueueValidateExpire = errors.New("task queue expire should not be negative")
+)
+
+// Prepare validates task queue settings and sets defaults.
+func (queue *TaskQueue) Prepare() error {
+	if len(queue.Path) == 0 {
+		return errTaskQueueValidatePath
+	}
+
+	if queue.Expire < 0 {
+		return errTaskQueueValidateExpire
+	}
+
+	if queue.Expire == 0 {
+		queue.Expire = defaultTaskQueueExpire
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTaskQueue_Prepare(t *testing.T) {
+	t.Parallel()
+
+	type testTableData struct {
+		tcase         string
+		queue         TaskQueue
+		expectedQueue TaskQueue
+		expectedErr   error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:         "defaults",
+			queue:         TaskQueue{Path: "/tmp/queue.log"},
+			expectedQueue: TaskQueue{Path: "/tmp/queue.log", Expire: defaultTaskQueueExpire},
+			expectedErr:   nil,
+		},
+		{
+			tcase:         "set",
+			queue:         TaskQueue{Path: "/tmp/queue.log", Expire: 30 * time.Minute},
+			expectedQueue: TaskQueue{Path: "/tmp/queue.log", Expire: 30 * time.Minute},
+			expectedErr:   nil,
+		},
+		{
+			tcase:         "empty path",
+			queue:         TaskQueue{},
+			expectedQueue: TaskQueue{},
+			expectedErr:   errTaskQueueValidatePath,
+		},
+		{
+			tcase:         "negative expire",
+			queue:         TaskQueue{Path: "/tmp/queue.log", Expire: -time.Minute},
+			expectedQueue: TaskQueue{Path: "/tmp/queue.log", Expire: -time.Minute},
+			expectedErr:   errTaskQueueValidateExpire,
+		},
+	}
+
+	for _, testUnit := range testTable {
+		err := testUnit.queue.Prepare()
+		assert.Equal(t, testUnit.expectedQueue, testUnit.queue, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
+	}
+}
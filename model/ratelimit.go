@@ -0,0 +1,121 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"time"
+)
+
+// RateLimit describes maximum quantity of executions within time window.
+type RateLimit struct {
+	// Max is a maximum quantity of executions within window.
+	Max int `mapstructure:"max"`
+
+	// Window is a sliding time window for counting executions.
+	Window time.Duration `mapstructure:"window"`
+}
+
+// CircuitBreaker describes global limit of executions.
+// All executions are halted when limit is exceeded.
+type CircuitBreaker struct {
+	// MaxActions is a maximum quantity of executed actions within window.
+	MaxActions int `mapstructure:"max_actions"`
+
+	// Window is a sliding time window for counting actions.
+	Window time.Duration `mapstructure:"window"`
+
+	// Halt is a time all executions are halted for after breaker tripped. By default set to Window.
+	Halt time.Duration `mapstructure:"halt"`
+
+	// Actions to notify about breaker trip.
+	Actions Actions `mapstructure:"actions"`
+}
+
+const (
+	// CircuitBreakerRuleName is a rule name for circuit breaker notification tasks.
+	CircuitBreakerRuleName = "circuit_breaker"
+
+	circuitBreakerAlertName = "WebhookerCircuitBreakerTripped"
+)
+
+var (
+	errRateLimitValidateMax             = errors.New("rate limit max should be positive")
+	errRateLimitValidateWindow          = errors.New("rate limit window should be positive")
+	errCircuitBreakerValidateMaxActions = errors.New("circuit breaker max actions should be positive")
+	errCircuitBreakerValidateWindow     = errors.New("circuit breaker window should be positive")
+	errCircuitBreakerValidateHalt       = errors.New("circuit breaker halt should not be negative")
+)
+
+// Validate validates rate limit.
+func (limit RateLimit) Validate() error {
+	if limit.Max <= 0 {
+		return errRateLimitValidateMax
+	}
+
+	if limit.Window <= 0 {
+		return errRateLimitValidateWindow
+	}
+
+	return nil
+}
+
+// Prepare prepares circuit breaker after config init.
+func (breaker *CircuitBreaker) Prepare(commonParams map[string]map[string]interface{}, taskExecutors map[string]executor.TaskExecutor) error {
+	if breaker.MaxActions <= 0 {
+		return errCircuitBreakerValidateMaxActions
+	}
+
+	if breaker.Window <= 0 {
+		return errCircuitBreakerValidateWindow
+	}
+
+	if breaker.Halt < 0 {
+		return errCircuitBreakerValidateHalt
+	}
+
+	if breaker.Halt == 0 {
+		breaker.Halt = breaker.Window
+	}
+
+	if len(breaker.Actions) == 0 {
+		return nil
+	}
+
+	// notification actions are prepared the same way as rule actions
+	rule := breaker.rule()
+	rule.mergeCommonParameters(commonParams)
+
+	err := rule.prepareTaskExecutors(taskExecutors)
+	if err != nil {
+		return fmt.Errorf("circuit breaker actions: %v", err)
+	}
+
+	breaker.Actions = rule.Actions
+	return nil
+}
+
+// NewTasks creates tasks to notify about breaker trip.
+// Alert annotations max_actions, window and halted_until can be used in placeholders.
+func (breaker CircuitBreaker) NewTasks(eventID string, haltedUntil time.Time) Tasks {
+	a := alert{
+		Status: "firing",
+		Labels: map[string]string{
+			"alertname": circuitBreakerAlertName,
+		},
+		Annotations: map[string]string{
+			"max_actions":  fmt.Sprint(breaker.MaxActions),
+			"window":       breaker.Window.String(),
+			"halted_until": haltedUntil.Format(time.RFC3339),
+		},
+	}
+
+	return NewTasks(breaker.rule(), a, eventID)
+}
+
+func (breaker CircuitBreaker) rule() Rule {
+	return Rule{
+		Name:    CircuitBreakerRuleName,
+		Actions: breaker.Actions,
+	}
+}
@@ -0,0 +1,168 @@
+package model
+
+import (
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRateLimit_Validate(t *testing.T) {
+	t.Parallel()
+
+	type testTableData struct {
+		tcase    string
+		limit    RateLimit
+		expected error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:    "valid",
+			limit:    RateLimit{Max: 10, Window: time.Minute},
+			expected: nil,
+		},
+		{
+			tcase:    "zero max",
+			limit:    RateLimit{Window: time.Minute},
+			expected: errRateLimitValidateMax,
+		},
+		{
+			tcase:    "zero window",
+			limit:    RateLimit{Max: 10},
+			expected: errRateLimitValidateWindow,
+		},
+	}
+
+	for _, testUnit := range testTable {
+		assert.Equal(t, testUnit.expected, testUnit.limit.Validate(), testUnit.tcase)
+	}
+}
+
+func TestCircuitBreaker_Prepare(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executorMock := executor.NewMockTaskExecutor(ctrl)
+	taskExecutors := map[string]executor.TaskExecutor{"telegram": executorMock}
+	commonParams := map[string]map[string]interface{}{"bot": {"bot_token": "token"}}
+
+	type testTableData struct {
+		tcase           string
+		breaker         CircuitBreaker
+		expectFunc      func(e *executor.MockTaskExecutor)
+		expectedBreaker CircuitBreaker
+		expectedErr     error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:           "default halt",
+			breaker:         CircuitBreaker{MaxActions: 50, Window: 5 * time.Minute},
+			expectFunc:      func(e *executor.MockTaskExecutor) {},
+			expectedBreaker: CircuitBreaker{MaxActions: 50, Window: 5 * time.Minute, Halt: 5 * time.Minute},
+			expectedErr:     nil,
+		},
+		{
+			tcase: "actions",
+			breaker: CircuitBreaker{
+				MaxActions: 50,
+				Window:     5 * time.Minute,
+				Halt:       time.Hour,
+				Actions: Actions{
+					{
+						Executor:         "telegram",
+						CommonParameters: "bot",
+						Parameters:       map[string]interface{}{"chat_id": 1},
+					},
+				},
+			},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().ValidateParameters(map[string]interface{}{"chat_id": 1, "bot_token": "token"}).Return(nil)
+			},
+			expectedBreaker: CircuitBreaker{
+				MaxActions: 50,
+				Window:     5 * time.Minute,
+				Halt:       time.Hour,
+				Actions: Actions{
+					{
+						Executor:         "telegram",
+						CommonParameters: "bot",
+						Parameters:       map[string]interface{}{"chat_id": 1, "bot_token": "token"},
+						TaskExecutor:     executorMock,
+					},
+				},
+			},
+			expectedErr: nil,
+		},
+		{
+			tcase:       "zero max actions",
+			breaker:     CircuitBreaker{Window: 5 * time.Minute},
+			expectFunc:  func(e *executor.MockTaskExecutor) {},
+			expectedErr: errCircuitBreakerValidateMaxActions,
+		},
+		{
+			tcase:       "zero window",
+			breaker:     CircuitBreaker{MaxActions: 50},
+			expectFunc:  func(e *executor.MockTaskExecutor) {},
+			expectedErr: errCircuitBreakerValidateWindow,
+		},
+		{
+			tcase:       "negative halt",
+			breaker:     CircuitBreaker{MaxActions: 50, Window: 5 * time.Minute, Halt: -time.Minute},
+			expectFunc:  func(e *executor.MockTaskExecutor) {},
+			expectedErr: errCircuitBreakerValidateHalt,
+		},
+		{
+			tcase: "unknown executor",
+			breaker: CircuitBreaker{
+				MaxActions: 50,
+				Window:     5 * time.Minute,
+				Actions:    Actions{{Executor: "slack"}},
+			},
+			expectFunc:  func(e *executor.MockTaskExecutor) {},
+			expectedErr: errors.New("circuit breaker actions: executor slack not found"),
+		},
+	}
+
+	for _, testUnit := range testTable {
+		testUnit.expectFunc(executorMock)
+		err := testUnit.breaker.Prepare(commonParams, taskExecutors)
+		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
+		if err == nil {
+			assert.Equal(t, testUnit.expectedBreaker, testUnit.breaker, testUnit.tcase)
+		}
+	}
+}
+
+func TestCircuitBreaker_NewTasks(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executorMock := executor.NewMockTaskExecutor(ctrl)
+	task := executor.NewMockTask(ctrl)
+
+	breaker := CircuitBreaker{
+		MaxActions: 50,
+		Window:     5 * time.Minute,
+		Actions: Actions{
+			{
+				Executor:     "telegram",
+				Parameters:   map[string]interface{}{"message": "${LABEL_ALERTNAME}: more than ${ANNOTATION_MAX_ACTIONS} actions in ${ANNOTATION_WINDOW}, halted until ${ANNOTATION_HALTED_UNTIL}"},
+				TaskExecutor: executorMock,
+			},
+		},
+	}
+
+	executorMock.EXPECT().NewTask("4a72", CircuitBreakerRuleName, circuitBreakerAlertName, time.Duration(0), map[string]interface{}{
+		"message": "WebhookerCircuitBreakerTripped: more than 50 actions in 5m0s, halted until 2018-08-24T05:00:00Z",
+	}).Return(task)
+
+	assert.Equal(t, Tasks{task}, breaker.NewTasks("4a72", time.Date(2018, 8, 24, 5, 0, 0, 0, time.UTC)))
+}
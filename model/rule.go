@@ -7,7 +7,9 @@ import (
 	"github.com/krpn/prometheus-alert-webhooker/utils"
 	"github.com/prometheus/common/model"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 )
 
 // Rule describes rule for alerts.
@@ -15,11 +17,39 @@ type Rule struct {
 	// Name of the rule, used for metrics, logger.
 	Name string `mapstructure:"name"`
 
+	// Priority of the rule. Rules with higher priority are matched first, equal priority keeps config order.
+	Priority int `mapstructure:"priority"`
+
+	// Continue matching next rules after this rule matched. By default set to true.
+	// It mirrors Alertmanager route continue option.
+	Continue *bool `mapstructure:"continue"`
+
+	// ActiveTimeIntervals is a list of time intervals when the rule is active. Rule is always active if empty.
+	ActiveTimeIntervals TimeIntervals `mapstructure:"active_time_intervals"`
+
+	// MuteTimeIntervals is a list of time intervals when the rule is muted.
+	// Muted rule is still matched (and stops matching if Continue is false) but its actions are skipped.
+	MuteTimeIntervals TimeIntervals `mapstructure:"mute_time_intervals"`
+
+	// RateLimit is a maximum quantity of the rule actions executions within time window (optional).
+	RateLimit *RateLimit `mapstructure:"rate_limit"`
+
+	// MaxConcurrency is a maximum quantity of the rule actions executing at the same time. Not limited if 0.
+	MaxConcurrency int `mapstructure:"max_concurrency"`
+
 	// Conditions for rule match.
 	Conditions Conditions `mapstructure:"conditions"`
 
 	// Actions is a slice of action.
 	Actions Actions `mapstructure:"actions"`
+
+	// FlappingActions is a slice of action executed instead of Actions for flapping alerts.
+	// Rule actions are skipped for flapping alerts if empty.
+	FlappingActions Actions `mapstructure:"flapping_actions"`
+
+	// Approval is an action executed before rule actions to ask for approval (optional).
+	// Rule actions are cancelled if approval task fails, e.g. approval is rejected or timed out.
+	Approval *Action `mapstructure:"approval"`
 }
 
 // Conditions describes
@@ -38,6 +68,9 @@ type Conditions struct {
 
 	// AlertAnnotationsRegexp is a compiled AlertAnnotations.
 	AlertAnnotationsRegexp map[string]*regexp.Regexp `mapstructure:"-"`
+
+	// Group is a conditions over all alerts of the payload (optional).
+	Group *GroupConditions `mapstructure:"group"`
 }
 
 // Rules is a slice of Rule.
@@ -51,6 +84,7 @@ var (
 	errRuleValidateEmptyExecutor      = errors.New("empty executor")
 	errRuleValidateEmptyActions       = errors.New("empty actions")
 	errRuleValidateAlreadyCompiled    = errors.New("rules already compiled")
+	errRuleValidateMaxConcurrency     = errors.New("max concurrency should not be negative")
 )
 
 func (rule Rule) validateUncompiled() error {
@@ -62,21 +96,45 @@ func (rule Rule) validateUncompiled() error {
 		return errRuleValidateEmptyName
 	}
 
-	err := validateAlertStatus(rule.Conditions.AlertStatus)
+	err := rule.Conditions.validateUncompiled()
+	if err != nil {
+		return err
+	}
+
+	if rule.RateLimit != nil {
+		err = rule.RateLimit.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if rule.MaxConcurrency < 0 {
+		return errRuleValidateMaxConcurrency
+	}
+
+	if rule.Conditions.Group != nil {
+		return rule.Conditions.Group.validateUncompiled()
+	}
+
+	return nil
+}
+
+func (conditions Conditions) validateUncompiled() error {
+	err := validateAlertStatus(conditions.AlertStatus)
 	if err != nil {
 		return err
 	}
 
-	if len(rule.Conditions.AlertLabelsRegexp) > 0 || len(rule.Conditions.AlertAnnotationsRegexp) > 0 {
+	if len(conditions.AlertLabelsRegexp) > 0 || len(conditions.AlertAnnotationsRegexp) > 0 {
 		return errRuleValidateAlreadyCompiled
 	}
 
-	err = utils.CheckMapIsNotEmpty(rule.Conditions.AlertLabels)
+	err = utils.CheckMapIsNotEmpty(conditions.AlertLabels)
 	if err != nil {
 		return fmt.Errorf("alert label validation error: %v", err)
 	}
 
-	err = utils.CheckMapIsNotEmpty(rule.Conditions.AlertAnnotations)
+	err = utils.CheckMapIsNotEmpty(conditions.AlertAnnotations)
 	if err != nil {
 		return fmt.Errorf("alert annotation validation error: %v", err)
 	}
@@ -100,18 +158,65 @@ func validateAlertStatus(status string) error {
 	return errRuleValidateInvalidAlertStatus
 }
 
+func (rule Rule) continues() bool {
+	return rule.Continue == nil || *rule.Continue
+}
+
+// isCatchAll returns true if rule stops matching and matches any alert with its alert status.
+func (rule Rule) isCatchAll() bool {
+	if rule.continues() || rule.Conditions.Group != nil {
+		return false
+	}
+
+	return len(rule.Conditions.AlertLabels) == 0 &&
+		len(rule.Conditions.AlertLabelsRegexp) == 0 &&
+		len(rule.Conditions.AlertAnnotations) == 0 &&
+		len(rule.Conditions.AlertAnnotationsRegexp) == 0
+}
+
 func (rule *Rule) setDefaultAlertStatus() {
 	rule.Conditions.AlertStatus = string(model.AlertFiring)
 }
 
 func (rule *Rule) compile() {
-	l, rl := compileMap(rule.Conditions.AlertLabels)
-	rule.Conditions.AlertLabels = l
-	rule.Conditions.AlertLabelsRegexp = rl
+	rule.Conditions.compile()
+
+	if rule.Conditions.Group != nil {
+		rule.Conditions.Group.compile()
+	}
+}
+
+func (rule *Rule) compileTimeIntervals() (err error) {
+	rule.ActiveTimeIntervals, err = rule.ActiveTimeIntervals.compile()
+	if err != nil {
+		return fmt.Errorf("active time intervals validation error: %v", err)
+	}
 
-	l, rl = compileMap(rule.Conditions.AlertAnnotations)
-	rule.Conditions.AlertAnnotations = l
-	rule.Conditions.AlertAnnotationsRegexp = rl
+	rule.MuteTimeIntervals, err = rule.MuteTimeIntervals.compile()
+	if err != nil {
+		return fmt.Errorf("mute time intervals validation error: %v", err)
+	}
+
+	return nil
+}
+
+// muted returns true if time is out of rule active time intervals or in rule mute time intervals.
+func (rule Rule) muted(now time.Time) bool {
+	if len(rule.ActiveTimeIntervals) > 0 && !rule.ActiveTimeIntervals.contains(now) {
+		return true
+	}
+
+	return rule.MuteTimeIntervals.contains(now)
+}
+
+func (conditions *Conditions) compile() {
+	l, rl := compileMap(conditions.AlertLabels)
+	conditions.AlertLabels = l
+	conditions.AlertLabelsRegexp = rl
+
+	l, rl = compileMap(conditions.AlertAnnotations)
+	conditions.AlertAnnotations = l
+	conditions.AlertAnnotationsRegexp = rl
 }
 
 func compileMap(m map[string]string) (map[string]string, map[string]*regexp.Regexp) {
@@ -153,6 +258,10 @@ func (rules Rules) Prepare(commonParams map[string]map[string]interface{}, taskE
 			rule.setDefaultAlertStatus()
 		}
 
+		if rule.Conditions.Group != nil {
+			rule.Conditions.Group.setDefaults(rule.Conditions.AlertStatus)
+		}
+
 		rule.mergeCommonParameters(commonParams)
 
 		err = rule.prepareTaskExecutors(taskExecutors)
@@ -163,25 +272,66 @@ func (rules Rules) Prepare(commonParams map[string]map[string]interface{}, taskE
 		// compile regexp
 		rule.compile()
 
+		err = rule.compileTimeIntervals()
+		if err != nil {
+			return err
+		}
+
 		rules[i] = rule
 	}
 
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority > rules[j].Priority
+	})
+
 	return nil
 }
 
+// Unreachable returns map of unreachable rule index to name of higher priority catch-all rule shadowing it.
+// Rules are keyed by index because names are not unique. Rules must be prepared.
+func (rules Rules) Unreachable() map[int]string {
+	unreachable := make(map[int]string)
+
+	// alert status -> catch-all rule name
+	catchAll := make(map[string]string)
+	for i, rule := range rules {
+		if shadowing, ok := catchAll[rule.Conditions.AlertStatus]; ok {
+			unreachable[i] = shadowing
+			continue
+		}
+
+		if rule.isCatchAll() {
+			catchAll[rule.Conditions.AlertStatus] = rule.Name
+		}
+	}
+
+	return unreachable
+}
+
 func (rule *Rule) mergeCommonParameters(commonParams map[string]map[string]interface{}) {
+	rule.Actions.mergeCommonParameters(commonParams)
+	rule.FlappingActions.mergeCommonParameters(commonParams)
+
+	if rule.Approval != nil {
+		approval := Actions{*rule.Approval}
+		approval.mergeCommonParameters(commonParams)
+		rule.Approval = &approval[0]
+	}
+}
+
+func (actions Actions) mergeCommonParameters(commonParams map[string]map[string]interface{}) {
 	if len(commonParams) == 0 {
 		return
 	}
 
-	for i, action := range rule.Actions {
+	for i, action := range actions {
 		if action.CommonParameters == "" {
 			continue
 		}
 
 		if action.Parameters == nil {
 			action.Parameters = make(map[string]interface{})
-			rule.Actions[i] = action
+			actions[i] = action
 		}
 
 		common, ok := commonParams[action.CommonParameters]
@@ -204,7 +354,30 @@ func (rule *Rule) prepareTaskExecutors(taskExecutors map[string]executor.TaskExe
 		return errRuleValidateEmptyExecutors
 	}
 
-	for i, action := range rule.Actions {
+	err := rule.Actions.prepareTaskExecutors(taskExecutors)
+	if err != nil {
+		return err
+	}
+
+	err = rule.FlappingActions.prepareTaskExecutors(taskExecutors)
+	if err != nil {
+		return fmt.Errorf("flapping actions: %v", err)
+	}
+
+	if rule.Approval != nil {
+		approval := Actions{*rule.Approval}
+		err = approval.prepareTaskExecutors(taskExecutors)
+		if err != nil {
+			return fmt.Errorf("approval: %v", err)
+		}
+		rule.Approval = &approval[0]
+	}
+
+	return nil
+}
+
+func (actions Actions) prepareTaskExecutors(taskExecutors map[string]executor.TaskExecutor) error {
+	for i, action := range actions {
 		if len(action.Executor) == 0 {
 			return errRuleValidateEmptyExecutor
 		}
@@ -219,8 +392,13 @@ func (rule *Rule) prepareTaskExecutors(taskExecutors map[string]executor.TaskExe
 			return err
 		}
 
+		err = action.validateBlockScope()
+		if err != nil {
+			return err
+		}
+
 		action.TaskExecutor = TaskExecutor
-		rule.Actions[i] = action
+		actions[i] = action
 	}
 
 	return nil
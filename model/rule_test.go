@@ -36,6 +36,15 @@ func TestRule_validateUncompiled(t *testing.T) {
 			},
 			expected: errRuleValidateEmptyName,
 		},
+		{
+			tcase: "negative max concurrency",
+			rule: func() Rule {
+				rule := *getTestRuleUncompiled(1)
+				rule.MaxConcurrency = -1
+				return rule
+			},
+			expected: errRuleValidateMaxConcurrency,
+		},
 		{
 			tcase: "already compiled labels",
 			rule: func() Rule {
@@ -132,6 +141,17 @@ func TestRule_validateUncompiled(t *testing.T) {
 			},
 			expected: errors.New("alert annotation validation error: value for key a is empty"),
 		},
+		{
+			tcase: "invalid group",
+			rule: func() Rule {
+				rule := *getTestRuleUncompiled(1)
+				rule.Conditions.Group = &GroupConditions{
+					MinAlerts: -1,
+				}
+				return rule
+			},
+			expected: errGroupValidateNegativeMinAlerts,
+		},
 	}
 
 	for _, testUnit := range testTable {
@@ -520,6 +540,33 @@ func TestRule_mergeCommonParameters(t *testing.T) {
 	}
 
 	testTable := []testTableData{
+		{
+			tcase: "approval merged",
+			rule: func() *Rule {
+				rule := getTestRuleUncompiled(1)
+				rule.Approval = &Action{
+					Executor:         "telegram_approval",
+					CommonParameters: "telegram",
+					Parameters:       map[string]interface{}{"message": "Restart?"},
+				}
+				return rule
+			},
+			commonParameters: map[string]map[string]interface{}{
+				"telegram": {
+					"bot_token": "123456:ABC",
+					"message":   "Fixed",
+				},
+			},
+			expected: func() *Rule {
+				rule := getTestRuleUncompiled(1)
+				rule.Approval = &Action{
+					Executor:         "telegram_approval",
+					CommonParameters: "telegram",
+					Parameters:       map[string]interface{}{"message": "Restart?", "bot_token": "123456:ABC"},
+				}
+				return rule
+			},
+		},
 		{
 			tcase: "merged",
 			rule: func() *Rule {
@@ -755,6 +802,69 @@ func TestRule_prepareTaskExecutors(t *testing.T) {
 			expected:    func() *Rule { return getTestRuleUncompiled(1) },
 			expectedErr: errors.New("validate params error"),
 		},
+		{
+			tcase: "invalid block scope",
+			rule: func() *Rule {
+				rule := getTestRuleUncompiled(1)
+				rule.Actions[0].BlockScope = "cluster"
+				return rule
+			},
+			taskExecutors: map[string]executor.TaskExecutor{"shell": executorMock},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().ValidateParameters(map[string]interface{}{
+					"command": "${LABEL_BLOCK} | ${URLENCODE_LABEL_ERROR} | ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE} | ${ANNOTATION_TITLE}",
+				}).Return(nil)
+			},
+			expected: func() *Rule {
+				rule := getTestRuleUncompiled(1)
+				rule.Actions[0].BlockScope = "cluster"
+				return rule
+			},
+			expectedErr: errActionValidateBlockScope,
+		},
+		{
+			tcase: "approval",
+			rule: func() *Rule {
+				rule := getTestRuleUncompiled(1)
+				rule.Approval = &Action{Executor: "telegram_approval", Parameters: map[string]interface{}{"message": "Restart?"}}
+				return rule
+			},
+			taskExecutors: map[string]executor.TaskExecutor{"shell": executorMock, "telegram_approval": executorMock},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().ValidateParameters(map[string]interface{}{
+					"command": "${LABEL_BLOCK} | ${URLENCODE_LABEL_ERROR} | ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE} | ${ANNOTATION_TITLE}",
+				}).Return(nil)
+				e.EXPECT().ValidateParameters(map[string]interface{}{"message": "Restart?"}).Return(nil)
+			},
+			expected: func() *Rule {
+				rule := getTestRuleUncompiled(1)
+				rule.Actions[0].TaskExecutor = executorMock
+				rule.Approval = &Action{Executor: "telegram_approval", Parameters: map[string]interface{}{"message": "Restart?"}, TaskExecutor: executorMock}
+				return rule
+			},
+			expectedErr: nil,
+		},
+		{
+			tcase: "approval executor not found",
+			rule: func() *Rule {
+				rule := getTestRuleUncompiled(1)
+				rule.Approval = &Action{Executor: "telegram_approval", Parameters: map[string]interface{}{"message": "Restart?"}}
+				return rule
+			},
+			taskExecutors: map[string]executor.TaskExecutor{"shell": executorMock},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().ValidateParameters(map[string]interface{}{
+					"command": "${LABEL_BLOCK} | ${URLENCODE_LABEL_ERROR} | ${CUT_AFTER_LAST_COLON_LABEL_INSTANCE} | ${ANNOTATION_TITLE}",
+				}).Return(nil)
+			},
+			expected: func() *Rule {
+				rule := getTestRuleUncompiled(1)
+				rule.Actions[0].TaskExecutor = executorMock
+				rule.Approval = &Action{Executor: "telegram_approval", Parameters: map[string]interface{}{"message": "Restart?"}}
+				return rule
+			},
+			expectedErr: errors.New("approval: executor telegram_approval not found"),
+		},
 		{
 			tcase:         "empty executors",
 			rule:          func() *Rule { return getTestRuleUncompiled(1) },
@@ -887,3 +997,107 @@ func getTestRuleCompiled(num int) *Rule {
 		},
 	}
 }
+
+func TestRules_Prepare_Priority(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executorMock := executor.NewMockTaskExecutor(ctrl)
+	executorMock.EXPECT().ValidateParameters(gomock.Any()).Return(nil).Times(4)
+
+	rules := make(Rules, 4)
+	for i, priority := range []int{0, 10, 0, 5} {
+		rule := *getTestRuleUncompiled(i + 1)
+		rule.Priority = priority
+		rules[i] = rule
+	}
+
+	err := rules.Prepare(nil, map[string]executor.TaskExecutor{"shell": executorMock})
+	assert.Equal(t, nil, err)
+
+	names := make([]string, len(rules))
+	for i, rule := range rules {
+		names[i] = rule.Name
+	}
+	assert.Equal(t, []string{"testrule2", "testrule4", "testrule1", "testrule3"}, names)
+}
+
+func TestRules_Unreachable(t *testing.T) {
+	t.Parallel()
+
+	stop := false
+
+	catchAll := func(num int) Rule {
+		rule := *getTestRuleCompiled(num)
+		rule.Continue = &stop
+		rule.Conditions.AlertLabels = map[string]string{}
+		rule.Conditions.AlertAnnotationsRegexp = map[string]*regexp.Regexp{}
+		return rule
+	}
+
+	type testTableData struct {
+		tcase    string
+		rules    func() Rules
+		expected map[int]string
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "no catch-all",
+			rules: func() Rules {
+				return Rules{*getTestRuleCompiled(1), *getTestRuleCompiled(2)}
+			},
+			expected: map[int]string{},
+		},
+		{
+			tcase: "catch-all shadows next rules",
+			rules: func() Rules {
+				return Rules{*getTestRuleCompiled(1), catchAll(2), *getTestRuleCompiled(3), catchAll(4)}
+			},
+			expected: map[int]string{
+				2: "testrule2",
+				3: "testrule2",
+			},
+		},
+		{
+			tcase: "catch-all with continue",
+			rules: func() Rules {
+				rule := catchAll(1)
+				rule.Continue = nil
+				return Rules{rule, *getTestRuleCompiled(2)}
+			},
+			expected: map[int]string{},
+		},
+		{
+			tcase: "catch-all for another alert status",
+			rules: func() Rules {
+				rule := catchAll(1)
+				rule.Conditions.AlertStatus = "resolved"
+				return Rules{rule, *getTestRuleCompiled(2)}
+			},
+			expected: map[int]string{},
+		},
+		{
+			tcase: "group rules are shadowed",
+			rules: func() Rules {
+				rule := *getTestRuleCompiled(2)
+				rule.Conditions.Group = &GroupConditions{MinAlerts: 1}
+				return Rules{catchAll(1), rule}
+			},
+			expected: map[int]string{1: "testrule1"},
+		},
+		{
+			tcase: "rules with the same name",
+			rules: func() Rules {
+				return Rules{*getTestRuleCompiled(1), catchAll(2), *getTestRuleCompiled(1)}
+			},
+			expected: map[int]string{2: "testrule2"},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		assert.Equal(t, testUnit.expected, testUnit.rules().Unreachable(), testUnit.tcase)
+	}
+}
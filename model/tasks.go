@@ -17,12 +17,16 @@ func (tasks Tasks) Details() []map[string]interface{} {
 }
 
 // NewTasks creates for rule-alert pairs.
+// Approval task is the first task if rule has approval.
 func NewTasks(rule Rule, alert alert, eventID string) Tasks {
 	tasks := make(Tasks, 0)
 
+	if rule.Approval != nil {
+		tasks = append(tasks, rule.Approval.newTask(rule, alert, eventID))
+	}
+
 	for _, action := range rule.Actions {
-		preparedParams := prepareParams(action.Parameters, alert)
-		tasks = append(tasks, action.TaskExecutor.NewTask(eventID, rule.Name, alert.Name(), action.Block, preparedParams))
+		tasks = append(tasks, action.newTask(rule, alert, eventID))
 	}
 
 	return tasks
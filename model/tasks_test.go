@@ -16,7 +16,9 @@ func TestNewTasks(t *testing.T) {
 	defer ctrl.Finish()
 
 	executorMock := executor.NewMockTaskExecutor(ctrl)
+	approvalExecutorMock := executor.NewMockTaskExecutor(ctrl)
 	task := executor.NewMockTask(ctrl)
+	approvalTask := executor.NewMockTask(ctrl)
 
 	type testTableData struct {
 		tcase      string
@@ -68,6 +70,195 @@ func TestNewTasks(t *testing.T) {
 				task,
 			},
 		},
+		{
+			tcase:   "block key",
+			eventID: "4a72",
+			rule: func() Rule {
+				rule := *getTestRuleCompiled(1)
+				rule.Actions = Actions{
+					{
+						Executor:     "shell",
+						Parameters:   map[string]interface{}{"command": "restart ${LABEL_INSTANCE}"},
+						BlockKey:     "${LABEL_CLUSTER}",
+						TaskExecutor: executorMock,
+					},
+				}
+				return rule
+			},
+			alert: alert{
+				Status: "firing",
+				Labels: map[string]string{"alertname": "testalert1", "cluster": "c1", "instance": "host1"},
+			},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().NewTask("4a72", "testrule1", "testalert1", time.Duration(0), map[string]interface{}{"command": "restart host1"}).Return(task)
+			},
+			expected: Tasks{
+				&blockTask{Task: task, fingerprint: "c1"},
+			},
+		},
+		{
+			tcase:   "block key with missing label",
+			eventID: "4a72",
+			rule: func() Rule {
+				rule := *getTestRuleCompiled(1)
+				rule.Actions = Actions{
+					{
+						Executor:     "shell",
+						Parameters:   map[string]interface{}{"command": "restart ${LABEL_INSTANCE}"},
+						BlockKey:     "${LABEL_CLUSTER}/${LABEL_INSTANCE}",
+						TaskExecutor: executorMock,
+					},
+				}
+				return rule
+			},
+			alert: alert{
+				Status: "firing",
+				Labels: map[string]string{"alertname": "testalert1", "instance": "host1"},
+			},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().NewTask("4a72", "testrule1", "testalert1", time.Duration(0), map[string]interface{}{"command": "restart host1"}).Return(task)
+			},
+			expected: Tasks{
+				&blockTask{Task: task},
+			},
+		},
+		{
+			tcase:   "block key in rule scope",
+			eventID: "4a72",
+			rule: func() Rule {
+				rule := *getTestRuleCompiled(1)
+				rule.Actions = Actions{
+					{
+						Executor:     "shell",
+						Parameters:   map[string]interface{}{"command": "restart"},
+						BlockKey:     "${LABEL_CLUSTER}",
+						BlockScope:   BlockScopeRule,
+						TaskExecutor: executorMock,
+					},
+				}
+				return rule
+			},
+			alert: alert{
+				Status: "firing",
+				Labels: map[string]string{"alertname": "testalert1", "cluster": "c1"},
+			},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().NewTask("4a72", "testrule1", "testalert1", time.Duration(0), map[string]interface{}{"command": "restart"}).Return(task)
+			},
+			expected: Tasks{
+				&blockTask{Task: task, fingerprint: "c1", scope: "rule:testrule1"},
+			},
+		},
+		{
+			tcase:   "global scope",
+			eventID: "4a72",
+			rule: func() Rule {
+				rule := *getTestRuleCompiled(1)
+				rule.Actions = Actions{
+					{
+						Executor:     "shell",
+						Parameters:   map[string]interface{}{"command": "restart"},
+						BlockScope:   BlockScopeGlobal,
+						TaskExecutor: executorMock,
+					},
+				}
+				return rule
+			},
+			alert: alert{
+				Status: "firing",
+				Labels: map[string]string{"alertname": "testalert1"},
+			},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().NewTask("4a72", "testrule1", "testalert1", time.Duration(0), map[string]interface{}{"command": "restart"}).Return(task)
+			},
+			expected: Tasks{
+				&blockTask{Task: task, scope: "global"},
+			},
+		},
+		{
+			tcase:   "block on failure",
+			eventID: "4a72",
+			rule: func() Rule {
+				rule := *getTestRuleCompiled(1)
+				rule.Actions = Actions{
+					{
+						Executor:       "shell",
+						Parameters:     map[string]interface{}{"command": "restart"},
+						BlockOnFailure: 5 * time.Minute,
+						TaskExecutor:   executorMock,
+					},
+				}
+				return rule
+			},
+			alert: alert{
+				Status: "firing",
+				Labels: map[string]string{"alertname": "testalert1"},
+			},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().NewTask("4a72", "testrule1", "testalert1", time.Duration(0), map[string]interface{}{"command": "restart"}).Return(task)
+			},
+			expected: Tasks{
+				&blockTask{Task: task, blockOnFailure: 5 * time.Minute},
+			},
+		},
+		{
+			tcase:   "action scope",
+			eventID: "4a72",
+			rule: func() Rule {
+				rule := *getTestRuleCompiled(1)
+				rule.Actions = Actions{
+					{
+						Executor:     "shell",
+						Parameters:   map[string]interface{}{"command": "restart"},
+						BlockScope:   BlockScopeAction,
+						TaskExecutor: executorMock,
+					},
+				}
+				return rule
+			},
+			alert: alert{
+				Status: "firing",
+				Labels: map[string]string{"alertname": "testalert1"},
+			},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				e.EXPECT().NewTask("4a72", "testrule1", "testalert1", time.Duration(0), map[string]interface{}{"command": "restart"}).Return(task)
+			},
+			expected: Tasks{
+				task,
+			},
+		},
+		{
+			tcase:   "approval",
+			eventID: "4a72",
+			rule: func() Rule {
+				rule := *getTestRuleCompiled(1)
+				rule.Approval = &Action{
+					Executor:     "telegram_approval",
+					Parameters:   map[string]interface{}{"message": "Restart ${LABEL_INSTANCE}?"},
+					TaskExecutor: approvalExecutorMock,
+				}
+				rule.Actions = Actions{
+					{
+						Executor:     "shell",
+						Parameters:   map[string]interface{}{"command": "restart ${LABEL_INSTANCE}"},
+						TaskExecutor: executorMock,
+					},
+				}
+				return rule
+			},
+			alert: alert{
+				Status: "firing",
+				Labels: map[string]string{"alertname": "testalert1", "instance": "host1"},
+			},
+			expectFunc: func(e *executor.MockTaskExecutor) {
+				approvalExecutorMock.EXPECT().NewTask("4a72", "testrule1", "testalert1", time.Duration(0), map[string]interface{}{"message": "Restart host1?"}).Return(approvalTask)
+				e.EXPECT().NewTask("4a72", "testrule1", "testalert1", time.Duration(0), map[string]interface{}{"command": "restart host1"}).Return(task)
+			},
+			expected: Tasks{
+				approvalTask,
+				task,
+			},
+		},
 	}
 
 	for _, testUnit := range testTable {
@@ -203,3 +394,26 @@ func TestTasksGroups_Details(t *testing.T) {
 		assert.Equal(t, testUnit.expected, tasksGroups.Details())
 	}
 }
+
+func TestBlockTask(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	task := executor.NewMockTask(ctrl)
+	task.EXPECT().Fingerprint().Return("e3b0c442")
+	task.EXPECT().ExecutorName().Return("shell")
+
+	blocked := &blockTask{Task: task}
+	assert.Equal(t, "e3b0c442", blocked.Fingerprint())
+	assert.Equal(t, "shell", executor.BlockScope(blocked))
+
+	assert.Equal(t, time.Duration(0), executor.BlockOnFailure(blocked))
+	assert.Equal(t, map[string]interface{}(nil), executor.Output(blocked))
+
+	blocked = &blockTask{Task: task, fingerprint: "c1", scope: "global", blockOnFailure: time.Minute}
+	assert.Equal(t, "c1", blocked.Fingerprint())
+	assert.Equal(t, "global", executor.BlockScope(blocked))
+	assert.Equal(t, time.Minute, executor.BlockOnFailure(blocked))
+}
@@ -0,0 +1,349 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeInterval describes time interval in Alertmanager time_interval syntax.
+// Time is matched if all set fields are matched, empty field matches any time.
+type TimeInterval struct {
+	// Times is a list of time ranges within a day.
+	Times []TimeRange `mapstructure:"times"`
+
+	// Weekdays is a list of weekdays or weekday ranges, e.g. monday:friday.
+	Weekdays []string `mapstructure:"weekdays"`
+
+	// DaysOfMonth is a list of days or day ranges, negative values count from the end of month, e.g. -3:-1.
+	DaysOfMonth []string `mapstructure:"days_of_month"`
+
+	// Months is a list of months or month ranges by name or number, e.g. january:march or 1:3.
+	Months []string `mapstructure:"months"`
+
+	// Years is a list of years or year ranges, e.g. 2019:2020.
+	Years []string `mapstructure:"years"`
+
+	// Location is a time zone name from the IANA database. By default set to UTC.
+	Location string `mapstructure:"location"`
+
+	// Compiled is a parsed time interval.
+	Compiled *compiledTimeInterval `mapstructure:"-"`
+}
+
+// TimeRange describes time range within a day.
+type TimeRange struct {
+	// StartTime is a start of range in HH:MM format, inclusive.
+	StartTime string `mapstructure:"start_time"`
+
+	// EndTime is an end of range in HH:MM format, exclusive.
+	EndTime string `mapstructure:"end_time"`
+}
+
+// TimeIntervals is a slice of TimeInterval.
+type TimeIntervals []TimeInterval
+
+type compiledTimeInterval struct {
+	times       []intRange
+	weekdays    []intRange
+	daysOfMonth []intRange
+	months      []intRange
+	years       []intRange
+	location    *time.Location
+}
+
+// intRange is an inclusive range of integers.
+type intRange struct {
+	begin, end int
+}
+
+const minutesInDay = 24 * 60
+
+var (
+	weekdays = map[string]int{
+		"sunday":    0,
+		"monday":    1,
+		"tuesday":   2,
+		"wednesday": 3,
+		"thursday":  4,
+		"friday":    5,
+		"saturday":  6,
+	}
+
+	months = map[string]int{
+		"january":   1,
+		"february":  2,
+		"march":     3,
+		"april":     4,
+		"may":       5,
+		"june":      6,
+		"july":      7,
+		"august":    8,
+		"september": 9,
+		"october":   10,
+		"november":  11,
+		"december":  12,
+	}
+
+	errTimeIntervalValidateInvalidTime      = errors.New("invalid time: should be in HH:MM format between 00:00 and 24:00")
+	errTimeIntervalValidateInvalidTimeRange = errors.New("invalid time range: start time should be before end time")
+	errTimeIntervalValidateInvalidRange     = errors.New("invalid range: begin should not be greater than end")
+	errTimeIntervalValidateZeroDayOfMonth   = errors.New("invalid day of month: should not be zero")
+)
+
+func (intervals TimeIntervals) compile() (TimeIntervals, error) {
+	if len(intervals) == 0 {
+		return intervals, nil
+	}
+
+	compiled := make(TimeIntervals, len(intervals))
+	for i, interval := range intervals {
+		c, err := interval.compile()
+		if err != nil {
+			return nil, fmt.Errorf("time interval #%v: %v", i+1, err)
+		}
+
+		interval.Compiled = c
+		compiled[i] = interval
+	}
+
+	return compiled, nil
+}
+
+func (interval TimeInterval) compile() (c *compiledTimeInterval, err error) {
+	c = &compiledTimeInterval{location: time.UTC}
+
+	for _, r := range interval.Times {
+		var tr intRange
+		tr, err = r.parse()
+		if err != nil {
+			return nil, err
+		}
+		c.times = append(c.times, tr)
+	}
+
+	c.weekdays, err = parseRanges(interval.Weekdays, func(s string) (int, error) {
+		return parseName(s, weekdays)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("weekdays: %v", err)
+	}
+
+	c.daysOfMonth, err = parseRanges(interval.DaysOfMonth, parseDayOfMonth)
+	if err != nil {
+		return nil, fmt.Errorf("days of month: %v", err)
+	}
+
+	c.months, err = parseRanges(interval.Months, func(s string) (int, error) {
+		month, err := parseName(s, months)
+		if err == nil {
+			return month, nil
+		}
+		return parseNumber(s, 1, 12)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("months: %v", err)
+	}
+
+	c.years, err = parseRanges(interval.Years, func(s string) (int, error) {
+		return parseNumber(s, 1, 9999)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("years: %v", err)
+	}
+
+	if len(interval.Location) > 0 {
+		c.location, err = time.LoadLocation(interval.Location)
+		if err != nil {
+			return nil, fmt.Errorf("location: %v", err)
+		}
+	}
+
+	return c, nil
+}
+
+func (r TimeRange) parse() (intRange, error) {
+	start, err := parseTime(r.StartTime)
+	if err != nil {
+		return intRange{}, err
+	}
+
+	end, err := parseTime(r.EndTime)
+	if err != nil {
+		return intRange{}, err
+	}
+
+	if start >= end {
+		return intRange{}, errTimeIntervalValidateInvalidTimeRange
+	}
+
+	// end time is exclusive
+	return intRange{begin: start, end: end - 1}, nil
+}
+
+// parseTime returns minutes from the start of day.
+func parseTime(s string) (int, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 || len(parts[0]) != 2 || len(parts[1]) != 2 {
+		return 0, errTimeIntervalValidateInvalidTime
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, errTimeIntervalValidateInvalidTime
+	}
+
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 {
+		return 0, errTimeIntervalValidateInvalidTime
+	}
+
+	t := hours*60 + minutes
+	if hours < 0 || t > minutesInDay {
+		return 0, errTimeIntervalValidateInvalidTime
+	}
+
+	return t, nil
+}
+
+func parseRanges(values []string, parseFunc func(string) (int, error)) ([]intRange, error) {
+	var ranges []intRange
+	for _, value := range values {
+		parts := strings.Split(value, ":")
+		if len(parts) > 2 {
+			return nil, fmt.Errorf("invalid range %v", value)
+		}
+
+		begin, err := parseFunc(parts[0])
+		if err != nil {
+			return nil, err
+		}
+
+		end := begin
+		if len(parts) == 2 {
+			end, err = parseFunc(parts[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// ranges with days of month counting from different ends are validated at match time
+		if begin > end && (begin < 0) == (end < 0) {
+			return nil, errTimeIntervalValidateInvalidRange
+		}
+
+		ranges = append(ranges, intRange{begin: begin, end: end})
+	}
+	return ranges, nil
+}
+
+func parseName(s string, names map[string]int) (int, error) {
+	value, ok := names[strings.ToLower(strings.TrimSpace(s))]
+	if !ok {
+		return 0, fmt.Errorf("unknown name %v", s)
+	}
+	return value, nil
+}
+
+func parseNumber(s string, min, max int) (int, error) {
+	value, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %v", s)
+	}
+
+	if value < min || value > max {
+		return 0, fmt.Errorf("number %v is out of range %v:%v", value, min, max)
+	}
+
+	return value, nil
+}
+
+func parseDayOfMonth(s string) (int, error) {
+	day, err := parseNumber(s, -31, 31)
+	if err != nil {
+		return 0, err
+	}
+
+	if day == 0 {
+		return 0, errTimeIntervalValidateZeroDayOfMonth
+	}
+
+	return day, nil
+}
+
+// contains returns true if time is matched by any of intervals.
+func (intervals TimeIntervals) contains(t time.Time) bool {
+	for _, interval := range intervals {
+		if interval.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (interval TimeInterval) contains(t time.Time) bool {
+	c := interval.Compiled
+	if c == nil {
+		return false
+	}
+
+	t = t.In(c.location)
+
+	if !rangesContain(c.times, t.Hour()*60+t.Minute()) {
+		return false
+	}
+
+	if !rangesContain(c.weekdays, int(t.Weekday())) {
+		return false
+	}
+
+	if !daysOfMonthContain(c.daysOfMonth, t) {
+		return false
+	}
+
+	if !rangesContain(c.months, int(t.Month())) {
+		return false
+	}
+
+	return rangesContain(c.years, t.Year())
+}
+
+// rangesContain returns true if value is in any of ranges or ranges are empty.
+func rangesContain(ranges []intRange, value int) bool {
+	if len(ranges) == 0 {
+		return true
+	}
+
+	for _, r := range ranges {
+		if value >= r.begin && value <= r.end {
+			return true
+		}
+	}
+
+	return false
+}
+
+func daysOfMonthContain(ranges []intRange, t time.Time) bool {
+	if len(ranges) == 0 {
+		return true
+	}
+
+	// day before the first day of next month is the last day of this month
+	daysInMonth := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+
+	absDay := func(day int) int {
+		if day < 0 {
+			return daysInMonth + day + 1
+		}
+		return day
+	}
+
+	absRanges := make([]intRange, len(ranges))
+	for i, r := range ranges {
+		absRanges[i] = intRange{begin: absDay(r.begin), end: absDay(r.end)}
+	}
+
+	return rangesContain(absRanges, t.Day())
+}
@@ -0,0 +1,228 @@
+package model
+
+import (
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTimeIntervals_compile(t *testing.T) {
+	t.Parallel()
+
+	type testTableData struct {
+		tcase       string
+		intervals   TimeIntervals
+		expectedErr error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "valid",
+			intervals: TimeIntervals{
+				{
+					Times:       []TimeRange{{StartTime: "09:00", EndTime: "24:00"}},
+					Weekdays:    []string{"monday:friday", "Sunday"},
+					DaysOfMonth: []string{"1:5", "-3:-1", "10:-1"},
+					Months:      []string{"january:march", "11:12"},
+					Years:       []string{"2018:2020"},
+					Location:    "UTC",
+				},
+			},
+			expectedErr: nil,
+		},
+		{
+			tcase:       "empty",
+			intervals:   nil,
+			expectedErr: nil,
+		},
+		{
+			tcase:       "invalid time",
+			intervals:   TimeIntervals{{Times: []TimeRange{{StartTime: "9:00", EndTime: "10:00"}}}},
+			expectedErr: errors.New("time interval #1: invalid time: should be in HH:MM format between 00:00 and 24:00"),
+		},
+		{
+			tcase:       "time after midnight",
+			intervals:   TimeIntervals{{Times: []TimeRange{{StartTime: "09:00", EndTime: "24:01"}}}},
+			expectedErr: errors.New("time interval #1: invalid time: should be in HH:MM format between 00:00 and 24:00"),
+		},
+		{
+			tcase:       "invalid time range",
+			intervals:   TimeIntervals{{Times: []TimeRange{{StartTime: "10:00", EndTime: "09:00"}}}},
+			expectedErr: errors.New("time interval #1: invalid time range: start time should be before end time"),
+		},
+		{
+			tcase:       "unknown weekday",
+			intervals:   TimeIntervals{{Weekdays: []string{"monday:fryday"}}},
+			expectedErr: errors.New("time interval #1: weekdays: unknown name fryday"),
+		},
+		{
+			tcase:       "invalid weekdays range",
+			intervals:   TimeIntervals{{Weekdays: []string{"saturday:sunday"}}},
+			expectedErr: errors.New("time interval #1: weekdays: invalid range: begin should not be greater than end"),
+		},
+		{
+			tcase:       "zero day of month",
+			intervals:   TimeIntervals{{DaysOfMonth: []string{"0:5"}}},
+			expectedErr: errors.New("time interval #1: days of month: invalid day of month: should not be zero"),
+		},
+		{
+			tcase:       "month out of range",
+			intervals:   TimeIntervals{{Months: []string{"13"}}},
+			expectedErr: errors.New("time interval #1: months: number 13 is out of range 1:12"),
+		},
+		{
+			tcase:       "invalid years range",
+			intervals:   TimeIntervals{{Years: []string{"2018:2019:2020"}}},
+			expectedErr: errors.New("time interval #1: years: invalid range 2018:2019:2020"),
+		},
+		{
+			tcase:       "unknown location",
+			intervals:   TimeIntervals{{}, {Location: "Nowhere/City"}},
+			expectedErr: errors.New("time interval #2: location: unknown time zone Nowhere/City"),
+		},
+	}
+
+	for _, testUnit := range testTable {
+		_, err := testUnit.intervals.compile()
+		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
+	}
+}
+
+func TestTimeIntervals_contains(t *testing.T) {
+	t.Parallel()
+
+	// Friday, 2018-08-31 15:30 UTC
+	now := time.Date(2018, 8, 31, 15, 30, 0, 0, time.UTC)
+
+	type testTableData struct {
+		tcase     string
+		intervals TimeIntervals
+		expected  bool
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:     "empty interval matches any time",
+			intervals: TimeIntervals{{}},
+			expected:  true,
+		},
+		{
+			tcase:     "no intervals",
+			intervals: TimeIntervals{},
+			expected:  false,
+		},
+		{
+			tcase:     "business hours",
+			intervals: TimeIntervals{{Times: []TimeRange{{StartTime: "09:00", EndTime: "18:00"}}, Weekdays: []string{"monday:friday"}}},
+			expected:  true,
+		},
+		{
+			tcase:     "end time is exclusive",
+			intervals: TimeIntervals{{Times: []TimeRange{{StartTime: "09:00", EndTime: "15:30"}}}},
+			expected:  false,
+		},
+		{
+			tcase:     "weekend",
+			intervals: TimeIntervals{{Weekdays: []string{"saturday", "sunday"}}},
+			expected:  false,
+		},
+		{
+			tcase:     "last day of month",
+			intervals: TimeIntervals{{DaysOfMonth: []string{"-1"}}},
+			expected:  true,
+		},
+		{
+			tcase:     "days from the start to the end of month",
+			intervals: TimeIntervals{{DaysOfMonth: []string{"25:-5"}}},
+			expected:  false,
+		},
+		{
+			tcase:     "months by name and number",
+			intervals: TimeIntervals{{Months: []string{"january:march"}}, {Months: []string{"8"}}},
+			expected:  true,
+		},
+		{
+			tcase:     "years",
+			intervals: TimeIntervals{{Years: []string{"2019:2020"}}},
+			expected:  false,
+		},
+		{
+			tcase:     "location",
+			intervals: TimeIntervals{{Times: []TimeRange{{StartTime: "18:00", EndTime: "19:00"}}, Location: "Europe/Moscow"}},
+			expected:  true,
+		},
+	}
+
+	for _, testUnit := range testTable {
+		intervals, err := testUnit.intervals.compile()
+		assert.Equal(t, nil, err, testUnit.tcase)
+		assert.Equal(t, testUnit.expected, intervals.contains(now), testUnit.tcase)
+	}
+}
+
+func TestAlerts_ToTasksGroups_Muted(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executorMock := executor.NewMockTaskExecutor(ctrl)
+	task := executor.NewMockTask(ctrl)
+
+	// Friday, 2018-08-31 15:30 UTC
+	now := time.Date(2018, 8, 31, 15, 30, 0, 0, time.UTC)
+
+	compile := func(intervals TimeIntervals) TimeIntervals {
+		compiled, _ := intervals.compile()
+		return compiled
+	}
+
+	actions := Actions{
+		{
+			Executor:     "shell",
+			Parameters:   map[string]interface{}{"command": "restart"},
+			TaskExecutor: executorMock,
+		},
+	}
+
+	alerts := Alerts{
+		{Status: "firing", Labels: map[string]string{"alertname": "testalert1", "cluster": "c1"}},
+		{Status: "firing", Labels: map[string]string{"alertname": "testalert1", "cluster": "c1"}},
+	}
+
+	rules := Rules{
+		{
+			Name:              "business_hours",
+			MuteTimeIntervals: compile(TimeIntervals{{Times: []TimeRange{{StartTime: "09:00", EndTime: "18:00"}}}}),
+			Conditions:        Conditions{AlertStatus: "firing"},
+			Actions:           actions,
+		},
+		{
+			Name:                "weekends_only",
+			ActiveTimeIntervals: compile(TimeIntervals{{Weekdays: []string{"saturday", "sunday"}}}),
+			Conditions: Conditions{
+				AlertStatus: "firing",
+				Group:       &GroupConditions{ByLabels: []string{"cluster"}, MinAlerts: 2},
+			},
+			Actions: actions,
+		},
+		{
+			Name:       "always",
+			Conditions: Conditions{AlertStatus: "firing", AlertLabels: map[string]string{"cluster": "c1"}},
+			Actions:    actions,
+		},
+	}
+
+	executorMock.EXPECT().NewTask("4a72", "always", "testalert1", time.Duration(0), map[string]interface{}{"command": "restart"}).Return(task).Times(2)
+
+	tasksGroups, skippedRules := alerts.ToTasksGroups(rules, "4a72", now)
+	assert.Equal(t, 2, len(tasksGroups))
+	assert.Equal(t, SkippedRules{
+		{Rule: "business_hours", Alert: "testalert1", Reason: SkipReasonMuted},
+		{Rule: "business_hours", Alert: "testalert1", Reason: SkipReasonMuted},
+		{Rule: "weekends_only", Alert: "testalert1", Reason: SkipReasonMuted},
+	}, skippedRules)
+}
@@ -0,0 +1,339 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/krpn/prometheus-alert-webhooker/model"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Queue is a write-ahead log of tasks groups persisted to append-only file.
+// Record is appended before tasks groups are sent to runners and is removed when all its tasks groups are done.
+// All pending records are kept in memory, file is used to recover them on startup.
+type Queue struct {
+	path string
+
+	mt      *sync.Mutex
+	records map[string]*Record
+	seq     int64
+	file    *os.File
+	writer  *bufio.Writer
+
+	// lines is a quantity of lines in file, used to decide when to compact
+	lines int
+}
+
+// Record describes alerts of payload tasks groups are created from.
+type Record struct {
+	ID         string       `json:"id"`
+	EventID    string       `json:"event_id"`
+	ReceivedAt time.Time    `json:"received_at"`
+	Alerts     model.Alerts `json:"alerts"`
+
+	// Payload is the payload alerts are received in, it is set to alerts on restore.
+	Payload *model.Payload `json:"payload,omitempty"`
+
+	// Groups is a rule name of each tasks group.
+	Groups []string `json:"groups"`
+
+	// Done is a list of indexes of done tasks groups.
+	Done []int `json:"done,omitempty"`
+}
+
+// IsDone returns true if tasks group with index is done.
+func (r Record) IsDone(group int) bool {
+	for _, done := range r.Done {
+		if done == group {
+			return true
+		}
+	}
+	return false
+}
+
+// line is a line of append-only file.
+type line struct {
+	Op     string  `json:"op"`
+	Record *Record `json:"record,omitempty"`
+	ID     string  `json:"id,omitempty"`
+	Group  int     `json:"group,omitempty"`
+}
+
+const (
+	opAppend = "append"
+	opDone   = "done"
+	opDrop   = "drop"
+
+	// file is compacted when it has more lines than pending records multiplied by compactRatio
+	compactRatio    = 2
+	compactMinLines = 1000
+)
+
+// Append persists record of tasks groups and returns its ID.
+// Record is synced to disk before returning.
+func (q *Queue) Append(eventID string, receivedAt time.Time, alerts model.Alerts, groups []string) (id string, err error) {
+	q.mt.Lock()
+	defer q.mt.Unlock()
+
+	q.seq++
+	r := &Record{
+		ID:         fmt.Sprintf("%x-%x", receivedAt.UnixNano(), q.seq),
+		EventID:    eventID,
+		ReceivedAt: receivedAt,
+		Alerts:     alerts,
+		Payload:    alerts.Payload(),
+		Groups:     groups,
+	}
+
+	err = q.write(line{Op: opAppend, Record: r})
+	if err != nil {
+		return "", err
+	}
+
+	// record should be on disk before payload is acknowledged
+	err = q.file.Sync()
+	if err != nil {
+		return "", err
+	}
+
+	q.records[r.ID] = r
+
+	return r.ID, nil
+}
+
+// Done marks tasks group of record as done. Record is removed when all its tasks groups are done.
+func (q *Queue) Done(id string, group int) error {
+	q.mt.Lock()
+	defer q.mt.Unlock()
+
+	r, ok := q.records[id]
+	if !ok || r.IsDone(group) {
+		return nil
+	}
+
+	err := q.write(line{Op: opDone, ID: id, Group: group})
+	if err != nil {
+		return err
+	}
+
+	q.done(r, group)
+
+	return q.compactIfNeeded()
+}
+
+// Drop removes record with all its tasks groups.
+func (q *Queue) Drop(id string) error {
+	q.mt.Lock()
+	defer q.mt.Unlock()
+
+	if _, ok := q.records[id]; !ok {
+		return nil
+	}
+
+	err := q.write(line{Op: opDrop, ID: id})
+	if err != nil {
+		return err
+	}
+
+	delete(q.records, id)
+
+	return q.compactIfNeeded()
+}
+
+// Pending returns copies of pending records sorted by receive time.
+func (q *Queue) Pending() []Record {
+	q.mt.Lock()
+	defer q.mt.Unlock()
+
+	records := make([]Record, 0, len(q.records))
+	for _, r := range q.records {
+		record := *r
+		record.Done = append([]int(nil), r.Done...)
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].ReceivedAt.Equal(records[j].ReceivedAt) {
+			return records[i].ReceivedAt.Before(records[j].ReceivedAt)
+		}
+		return records[i].ID < records[j].ID
+	})
+
+	return records
+}
+
+// Close flushes and closes file.
+func (q *Queue) Close() error {
+	q.mt.Lock()
+	defer q.mt.Unlock()
+
+	err := q.writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	return q.file.Close()
+}
+
+func (q *Queue) done(r *Record, group int) {
+	r.Done = append(r.Done, group)
+	if len(r.Done) >= len(r.Groups) {
+		delete(q.records, r.ID)
+	}
+}
+
+func (q *Queue) write(l line) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.writer.Write(append(b, '\n'))
+	if err != nil {
+		return err
+	}
+
+	q.lines++
+
+	return q.writer.Flush()
+}
+
+func (q *Queue) compactIfNeeded() error {
+	if q.lines < compactMinLines || q.lines < len(q.records)*compactRatio {
+		return nil
+	}
+
+	return q.compact()
+}
+
+// compact rewrites file with pending records only.
+func (q *Queue) compact() error {
+	tmpPath := q.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(tmp)
+	for _, r := range q.records {
+		var b []byte
+		b, err = json.Marshal(line{Op: opAppend, Record: r})
+		if err != nil {
+			_ = tmp.Close()
+			return err
+		}
+
+		_, err = writer.Write(append(b, '\n'))
+		if err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	if q.file != nil {
+		_ = q.file.Close()
+	}
+
+	err = os.Rename(tmpPath, q.path)
+	if err != nil {
+		return err
+	}
+
+	return q.open(len(q.records))
+}
+
+func (q *Queue) open(lines int) (err error) {
+	q.file, err = os.OpenFile(q.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+
+	q.writer = bufio.NewWriter(q.file)
+	q.lines = lines
+	return
+}
+
+// restore reads pending records from file.
+func (q *Queue) restore() error {
+	f, err := os.Open(q.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var l line
+		if json.Unmarshal(scanner.Bytes(), &l) != nil {
+			// skip partially written line
+			continue
+		}
+
+		switch l.Op {
+		case opAppend:
+			if l.Record != nil {
+				l.Record.Alerts.SetPayload(l.Record.Payload)
+				q.records[l.Record.ID] = l.Record
+			}
+		case opDone:
+			if r, ok := q.records[l.ID]; ok && !r.IsDone(l.Group) {
+				q.done(r, l.Group)
+			}
+		case opDrop:
+			delete(q.records, l.ID)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// New creates Queue instance, restores pending records from file and compacts it.
+func New(path string) (*Queue, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Queue{
+		path:    path,
+		mt:      &sync.Mutex{},
+		records: make(map[string]*Record),
+	}
+
+	err = q.restore()
+	if err != nil {
+		return nil, err
+	}
+
+	err = q.compact()
+	if err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
@@ -0,0 +1,181 @@
+package queue
+
+import (
+	"github.com/krpn/prometheus-alert-webhooker/model"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tempPath(t *testing.T) (path string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "queue", "queue.log"), func() { _ = os.RemoveAll(dir) }
+}
+
+func fileLines(t *testing.T, path string) []string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) == 0 {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+func testAlerts(name string) model.Alerts {
+	return model.Payload{
+		Status: "firing",
+		Alerts: template.Alerts{{Labels: template.KV{"alertname": name}}},
+	}.ToAlerts()
+}
+
+func TestQueue(t *testing.T) {
+	t.Parallel()
+
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	now := time.Unix(1535086351, 0).UTC()
+
+	q, err := New(path)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []Record{}, q.Pending())
+
+	id1, err := q.Append("4a72", now, testAlerts("testalert1"), []string{"testrule1", "testrule2"})
+	assert.Equal(t, nil, err)
+	id2, err := q.Append("4a72", now, testAlerts("testalert2"), []string{"testrule1"})
+	assert.Equal(t, nil, err)
+	id3, err := q.Append("998e", now.Add(time.Second), testAlerts("testalert3"), []string{"testrule3"})
+	assert.Equal(t, nil, err)
+	assert.NotEqual(t, id1, id2)
+
+	assert.Equal(t, nil, q.Done(id1, 1))
+	assert.Equal(t, nil, q.Done(id1, 1))
+	assert.Equal(t, nil, q.Done(id2, 0))
+	assert.Equal(t, nil, q.Done("unknown", 0))
+	assert.Equal(t, nil, q.Drop(id3))
+	assert.Equal(t, nil, q.Drop(id3))
+
+	assert.Equal(t, []Record{
+		{
+			ID:         id1,
+			EventID:    "4a72",
+			ReceivedAt: now,
+			Alerts:     testAlerts("testalert1"),
+			Payload:    testAlerts("testalert1").Payload(),
+			Groups:     []string{"testrule1", "testrule2"},
+			Done:       []int{1},
+		},
+	}, q.Pending())
+
+	assert.Equal(t, true, q.Pending()[0].IsDone(1))
+	assert.Equal(t, false, q.Pending()[0].IsDone(0))
+
+	assert.Equal(t, nil, q.Done(id1, 0))
+	assert.Equal(t, []Record{}, q.Pending())
+	assert.Equal(t, nil, q.Close())
+}
+
+func TestNew_Recover(t *testing.T) {
+	t.Parallel()
+
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	now := time.Unix(1535086351, 0).UTC()
+
+	q, err := New(path)
+	assert.Equal(t, nil, err)
+
+	id1, err := q.Append("4a72", now.Add(time.Second), testAlerts("testalert1"), []string{"testrule1", "testrule2"})
+	assert.Equal(t, nil, err)
+	id2, err := q.Append("4a72", now, testAlerts("testalert2"), []string{"testrule1"})
+	assert.Equal(t, nil, err)
+	id3, err := q.Append("998e", now, testAlerts("testalert3"), []string{"testrule3"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, q.Done(id1, 0))
+	assert.Equal(t, nil, q.Done(id3, 0))
+	assert.Equal(t, nil, q.Close())
+
+	// partially written line
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	assert.Equal(t, nil, err)
+	_, err = f.WriteString(`{"op":"append","rec`)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, f.Close())
+
+	q, err = New(path)
+	assert.Equal(t, nil, err)
+	defer q.Close()
+
+	assert.Equal(t, []Record{
+		{
+			ID:         id2,
+			EventID:    "4a72",
+			ReceivedAt: now,
+			Alerts:     testAlerts("testalert2"),
+			Payload:    testAlerts("testalert2").Payload(),
+			Groups:     []string{"testrule1"},
+		},
+		{
+			ID:         id1,
+			EventID:    "4a72",
+			ReceivedAt: now.Add(time.Second),
+			Alerts:     testAlerts("testalert1"),
+			Payload:    testAlerts("testalert1").Payload(),
+			Groups:     []string{"testrule1", "testrule2"},
+			Done:       []int{0},
+		},
+	}, q.Pending())
+
+	// file is compacted on startup
+	assert.Equal(t, 2, len(fileLines(t, path)))
+
+	// new record does not reuse ID of recovered record
+	id4, err := q.Append("4a72", now, testAlerts("testalert4"), []string{"testrule1"})
+	assert.Equal(t, nil, err)
+	assert.NotEqual(t, id2, id4)
+}
+
+func TestQueue_compactIfNeeded(t *testing.T) {
+	t.Parallel()
+
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	q, err := New(path)
+	assert.Equal(t, nil, err)
+	defer q.Close()
+
+	now := time.Unix(1535086351, 0)
+	for i := 0; i < compactMinLines/2; i++ {
+		id, err := q.Append("4a72", now, testAlerts("testalert1"), []string{"testrule1"})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, nil, q.Done(id, 0))
+	}
+
+	assert.Equal(t, 0, q.lines)
+	assert.Equal(t, 0, len(fileLines(t, path)))
+}
+
+func TestNew_Error(t *testing.T) {
+	t.Parallel()
+
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	// path is a directory
+	assert.Equal(t, nil, os.MkdirAll(path, 0700))
+
+	_, err := New(path)
+	assert.NotEqual(t, nil, err)
+}
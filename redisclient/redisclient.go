@@ -0,0 +1,205 @@
+package redisclient
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Client is a minimal Redis protocol client.
+// It keeps one connection and reconnects on network errors.
+type Client struct {
+	address  string
+	password string
+	db       int
+	timeout  time.Duration
+	dialFunc func(network, address string, timeout time.Duration) (net.Conn, error)
+
+	mt     *sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Error is an error reply of Redis server.
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+var errUnexpectedReply = errors.New("unexpected redis reply")
+
+// Do sends command to Redis and returns reply.
+// Reply is string for simple and bulk strings, int64 for integers, []interface{} for arrays,
+// nil for null bulk strings and arrays. Error reply is returned as Error.
+func (c *Client) Do(args ...string) (reply interface{}, err error) {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+
+	if c.conn == nil {
+		err = c.connect()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err = c.do(args...)
+	if _, ok := err.(Error); err != nil && !ok {
+		// connection state is unknown after network error
+		c.close()
+	}
+
+	return
+}
+
+// Close closes connection.
+func (c *Client) Close() {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+
+	c.close()
+}
+
+func (c *Client) connect() (err error) {
+	c.conn, err = c.dialFunc("tcp", c.address, c.timeout)
+	if err != nil {
+		c.conn = nil
+		return
+	}
+	c.reader = bufio.NewReader(c.conn)
+
+	if len(c.password) > 0 {
+		_, err = c.do("AUTH", c.password)
+		if err != nil {
+			c.close()
+			return
+		}
+	}
+
+	if c.db != 0 {
+		_, err = c.do("SELECT", strconv.Itoa(c.db))
+		if err != nil {
+			c.close()
+			return
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) close() {
+	if c.conn == nil {
+		return
+	}
+
+	_ = c.conn.Close()
+	c.conn = nil
+	c.reader = nil
+}
+
+func (c *Client) do(args ...string) (interface{}, error) {
+	if c.timeout > 0 {
+		_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+
+	_, err := c.conn.Write(EncodeCommand(args...))
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadReply(c.reader)
+}
+
+// EncodeCommand encodes command as an array of bulk strings.
+func EncodeCommand(args ...string) []byte {
+	b := []byte(fmt.Sprintf("*%v\r\n", len(args)))
+	for _, arg := range args {
+		b = append(b, fmt.Sprintf("$%v\r\n%v\r\n", len(arg), arg)...)
+	}
+	return b
+}
+
+// ReadReply reads one reply.
+func ReadReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 {
+		return nil, errUnexpectedReply
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errUnexpectedReply
+		}
+		if size < 0 {
+			return nil, nil
+		}
+
+		b := make([]byte, size+2)
+		_, err = io.ReadFull(r, b)
+		if err != nil {
+			return nil, err
+		}
+		return string(b[:size]), nil
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errUnexpectedReply
+		}
+		if size < 0 {
+			return nil, nil
+		}
+
+		array := make([]interface{}, size)
+		for i := range array {
+			array[i], err = ReadReply(r)
+			if _, ok := err.(Error); err != nil && !ok {
+				return nil, err
+			}
+		}
+		return array, nil
+	default:
+		return nil, errUnexpectedReply
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errUnexpectedReply
+	}
+
+	return line[:len(line)-2], nil
+}
+
+// New creates Client instance. Connection is established on first command.
+func New(address, password string, db int, timeout time.Duration) *Client {
+	return &Client{
+		address:  address,
+		password: password,
+		db:       db,
+		timeout:  timeout,
+		dialFunc: net.DialTimeout,
+		mt:       &sync.Mutex{},
+	}
+}
@@ -0,0 +1,98 @@
+package redisclient
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEncodeCommand(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$0\r\n\r\n", string(EncodeCommand("SET", "key", "")))
+}
+
+func TestReadReply(t *testing.T) {
+	t.Parallel()
+
+	type testTableData struct {
+		tcase         string
+		raw           string
+		expectedReply interface{}
+		expectedErr   error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:         "simple string",
+			raw:           "+OK\r\n",
+			expectedReply: "OK",
+			expectedErr:   nil,
+		},
+		{
+			tcase:         "error",
+			raw:           "-ERR syntax error\r\n",
+			expectedReply: nil,
+			expectedErr:   Error("ERR syntax error"),
+		},
+		{
+			tcase:         "integer",
+			raw:           ":-2\r\n",
+			expectedReply: int64(-2),
+			expectedErr:   nil,
+		},
+		{
+			tcase:         "bulk string",
+			raw:           "$5\r\nab\r\nc\r\n",
+			expectedReply: "ab\r\nc",
+			expectedErr:   nil,
+		},
+		{
+			tcase:         "null bulk string",
+			raw:           "$-1\r\n",
+			expectedReply: nil,
+			expectedErr:   nil,
+		},
+		{
+			tcase:         "array",
+			raw:           "*2\r\n$1\r\na\r\n:1\r\n",
+			expectedReply: []interface{}{"a", int64(1)},
+			expectedErr:   nil,
+		},
+		{
+			tcase:         "unknown type",
+			raw:           "?1\r\n",
+			expectedReply: nil,
+			expectedErr:   errUnexpectedReply,
+		},
+		{
+			tcase:         "no CR",
+			raw:           "+OK\n",
+			expectedReply: nil,
+			expectedErr:   errUnexpectedReply,
+		},
+	}
+
+	for _, testUnit := range testTable {
+		reply, err := ReadReply(bufio.NewReader(bytes.NewBufferString(testUnit.raw)))
+		assert.Equal(t, testUnit.expectedReply, reply, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
+	}
+}
+
+func TestClient_Do_DialError(t *testing.T) {
+	t.Parallel()
+
+	client := New("127.0.0.1:6379", "", 0, time.Second)
+	client.dialFunc = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+
+	_, err := client.Do("PING")
+	assert.Equal(t, errors.New("connection refused"), err)
+	assert.Equal(t, true, client.conn == nil)
+}
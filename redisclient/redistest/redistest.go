@@ -0,0 +1,404 @@
+// Package redistest provides in-process Redis protocol server for tests.
+// It supports commands used by webhooker only, scripts are emulated by registered Go functions.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/krpn/prometheus-alert-webhooker/redisclient"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is an in-process Redis protocol server.
+type Server struct {
+	listener net.Listener
+	password string
+	nowFunc  func() time.Time
+
+	mt      *sync.Mutex
+	entries map[string]entry
+	scripts map[string]ScriptFunc
+}
+
+// ScriptFunc emulates Lua script executed by EVAL, Lua is not interpreted by Server.
+// It gets data of Server, keys and args of EVAL and returns integer reply.
+type ScriptFunc func(data Data, keys, args []string) int64
+
+// Data is data of Server available to scripts.
+type Data interface {
+	// Get returns value of not expired key.
+	Get(key string) (value string, ok bool)
+
+	// Set sets value of key, key does not expire if ttl is not positive.
+	Set(key, value string, ttl time.Duration)
+
+	// PExpire sets TTL of existing key and reports whether key exists.
+	PExpire(key string, ttl time.Duration) bool
+
+	// Del deletes key and reports whether key existed.
+	Del(key string) bool
+}
+
+type entry struct {
+	value    string
+	expireAt time.Time
+}
+
+// Addr returns server address.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops server.
+func (s *Server) Close() {
+	_ = s.listener.Close()
+}
+
+// Script registers emulation of script executed by EVAL.
+func (s *Server) Script(script string, fn ScriptFunc) {
+	s.mt.Lock()
+	defer s.mt.Unlock()
+
+	s.scripts[script] = fn
+}
+
+// Keys returns all not expired keys sorted.
+func (s *Server) Keys() []string {
+	s.mt.Lock()
+	defer s.mt.Unlock()
+
+	keys := make([]string, 0, len(s.entries))
+	for key, e := range s.entries {
+		if s.expired(e) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	authorized := len(s.password) == 0
+	for {
+		reply, err := redisclient.ReadReply(r)
+		if err != nil {
+			return
+		}
+
+		array, ok := reply.([]interface{})
+		if !ok || len(array) == 0 {
+			return
+		}
+
+		args := make([]string, len(array))
+		for i, arg := range array {
+			args[i], _ = arg.(string)
+		}
+
+		cmd := strings.ToUpper(args[0])
+		var resp string
+		switch {
+		case cmd == "AUTH":
+			authorized = len(args) == 2 && args[1] == s.password
+			resp = "+OK\r\n"
+			if !authorized {
+				resp = "-ERR invalid password\r\n"
+			}
+		case !authorized:
+			resp = "-NOAUTH Authentication required.\r\n"
+		default:
+			resp = s.exec(cmd, args[1:])
+		}
+
+		_, err = conn.Write([]byte(resp))
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) exec(cmd string, args []string) string {
+	s.mt.Lock()
+	defer s.mt.Unlock()
+
+	switch cmd {
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		if len(args) != 1 {
+			return errWrongArgs(cmd)
+		}
+		e, ok := s.get(args[0])
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(e.value)
+	case "SET":
+		return s.set(args)
+	case "DEL":
+		deleted := 0
+		for _, key := range args {
+			if _, ok := s.get(key); ok {
+				deleted++
+			}
+			delete(s.entries, key)
+		}
+		return fmt.Sprintf(":%v\r\n", deleted)
+	case "PEXPIRE":
+		if len(args) != 2 {
+			return errWrongArgs(cmd)
+		}
+		ms, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		e, ok := s.get(args[0])
+		if !ok {
+			return ":0\r\n"
+		}
+		e.expireAt = s.nowFunc().Add(time.Duration(ms) * time.Millisecond)
+		s.entries[args[0]] = e
+		return ":1\r\n"
+	case "PTTL":
+		if len(args) != 1 {
+			return errWrongArgs(cmd)
+		}
+		e, ok := s.get(args[0])
+		if !ok {
+			return ":-2\r\n"
+		}
+		if e.expireAt.IsZero() {
+			return ":-1\r\n"
+		}
+		return fmt.Sprintf(":%v\r\n", int64(e.expireAt.Sub(s.nowFunc())/time.Millisecond))
+	case "KEYS":
+		if len(args) != 1 {
+			return errWrongArgs(cmd)
+		}
+		return bulkArray(s.keys(args[0]))
+	case "SCAN":
+		// all keys are returned in one iteration
+		pattern := "*"
+		for i := 1; i+1 < len(args); i += 2 {
+			if strings.ToUpper(args[i]) == "MATCH" {
+				pattern = args[i+1]
+			}
+		}
+		return "*2\r\n" + bulk("0") + bulkArray(s.keys(pattern))
+	case "EVAL":
+		return s.eval(args)
+	default:
+		return fmt.Sprintf("-ERR unknown command '%v'\r\n", cmd)
+	}
+}
+
+func (s *Server) set(args []string) string {
+	if len(args) < 2 {
+		return errWrongArgs("SET")
+	}
+
+	key, e := args[0], entry{value: args[1]}
+	nx := false
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "PX", "EX":
+			if i+1 >= len(args) {
+				return "-ERR syntax error\r\n"
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				return "-ERR invalid expire time in set\r\n"
+			}
+			unit := time.Millisecond
+			if strings.ToUpper(args[i]) == "EX" {
+				unit = time.Second
+			}
+			e.expireAt = s.nowFunc().Add(time.Duration(n) * unit)
+			i++
+		default:
+			return "-ERR syntax error\r\n"
+		}
+	}
+
+	if _, ok := s.get(key); ok && nx {
+		return "$-1\r\n"
+	}
+
+	s.entries[key] = e
+	return "+OK\r\n"
+}
+
+func (s *Server) eval(args []string) string {
+	if len(args) < 2 {
+		return errWrongArgs("EVAL")
+	}
+
+	fn, ok := s.scripts[args[0]]
+	if !ok {
+		return "-NOSCRIPT No matching script.\r\n"
+	}
+
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil || numKeys < 0 || numKeys > len(args)-2 {
+		return "-ERR Number of keys can't be greater than number of args\r\n"
+	}
+
+	return fmt.Sprintf(":%v\r\n", fn(data{s}, args[2:2+numKeys], args[2+numKeys:]))
+}
+
+// data implements Data for scripts, lock of Server is held while script is executed.
+type data struct {
+	s *Server
+}
+
+func (d data) Get(key string) (string, bool) {
+	e, ok := d.s.get(key)
+	return e.value, ok
+}
+
+func (d data) Set(key, value string, ttl time.Duration) {
+	e := entry{value: value}
+	if ttl > 0 {
+		e.expireAt = d.s.nowFunc().Add(ttl)
+	}
+	d.s.entries[key] = e
+}
+
+func (d data) PExpire(key string, ttl time.Duration) bool {
+	e, ok := d.s.get(key)
+	if !ok {
+		return false
+	}
+	e.expireAt = d.s.nowFunc().Add(ttl)
+	d.s.entries[key] = e
+	return true
+}
+
+func (d data) Del(key string) bool {
+	_, ok := d.s.get(key)
+	delete(d.s.entries, key)
+	return ok
+}
+
+func (s *Server) get(key string) (entry, bool) {
+	e, ok := s.entries[key]
+	if !ok {
+		return entry{}, false
+	}
+
+	if s.expired(e) {
+		delete(s.entries, key)
+		return entry{}, false
+	}
+
+	return e, true
+}
+
+func (s *Server) expired(e entry) bool {
+	return !e.expireAt.IsZero() && !s.nowFunc().Before(e.expireAt)
+}
+
+func (s *Server) keys(pattern string) []string {
+	keys := make([]string, 0)
+	for key, e := range s.entries {
+		if s.expired(e) {
+			continue
+		}
+		if match(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// match reports whether s matches glob-style pattern with *, ? and \ escaping.
+func match(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if match(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+func bulkArray(values []string) string {
+	resp := fmt.Sprintf("*%v\r\n", len(values))
+	for _, value := range values {
+		resp += bulk(value)
+	}
+	return resp
+}
+
+func bulk(value string) string {
+	return fmt.Sprintf("$%v\r\n%v\r\n", len(value), value)
+}
+
+func errWrongArgs(cmd string) string {
+	return fmt.Sprintf("-ERR wrong number of arguments for '%v' command\r\n", strings.ToLower(cmd))
+}
+
+// NewServer starts Server on random local port.
+// Password is required if not empty. Time of expiration is got by nowFunc.
+func NewServer(password string, nowFunc func() time.Time) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener: listener,
+		password: password,
+		nowFunc:  nowFunc,
+		mt:       &sync.Mutex{},
+		entries:  make(map[string]entry),
+		scripts:  make(map[string]ScriptFunc),
+	}
+
+	go s.serve()
+
+	return s, nil
+}
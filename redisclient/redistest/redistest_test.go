@@ -0,0 +1,69 @@
+package redistest
+
+import (
+	"github.com/krpn/prometheus-alert-webhooker/redisclient"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1535086351, 0)
+	server, err := NewServer("secret", func() time.Time { return now })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	noauth := redisclient.New(server.Addr(), "", 0, time.Second)
+	defer noauth.Close()
+	_, err = noauth.Do("PING")
+	assert.Equal(t, redisclient.Error("NOAUTH Authentication required."), err)
+
+	server.Script("return redis.call('DEL', KEYS[1])", func(data Data, keys, args []string) int64 {
+		if data.Del(keys[0]) {
+			return 1
+		}
+		return 0
+	})
+
+	client := redisclient.New(server.Addr(), "secret", 1, time.Second)
+	defer client.Close()
+
+	type step struct {
+		after         time.Duration
+		args          []string
+		expectedReply interface{}
+		expectedErr   error
+	}
+
+	steps := []step{
+		{args: []string{"PING"}, expectedReply: "PONG"},
+		{args: []string{"SET", "k", "v", "NX", "PX", "1000"}, expectedReply: "OK"},
+		{args: []string{"SET", "k", "v", "NX"}, expectedReply: nil},
+		{args: []string{"GET", "k"}, expectedReply: "v"},
+		{args: []string{"PTTL", "k"}, expectedReply: int64(1000)},
+		{args: []string{"PEXPIRE", "k", "5000"}, expectedReply: int64(1)},
+		{after: 2 * time.Second, args: []string{"KEYS", "*"}, expectedReply: []interface{}{"k"}},
+		{after: 3 * time.Second, args: []string{"GET", "k"}, expectedReply: nil},
+		{args: []string{"PTTL", "k"}, expectedReply: int64(-2)},
+		{args: []string{"SET", "k", "v"}, expectedReply: "OK"},
+		{args: []string{"PTTL", "k"}, expectedReply: int64(-1)},
+		{args: []string{"DEL", "k", "other"}, expectedReply: int64(1)},
+		{args: []string{"SET", "k", "v"}, expectedReply: "OK"},
+		{args: []string{"EVAL", "return redis.call('DEL', KEYS[1])", "1", "k"}, expectedReply: int64(1)},
+		{args: []string{"EVAL", "return redis.call('DEL', KEYS[1])", "1", "k"}, expectedReply: int64(0)},
+		{args: []string{"EVAL", "return 1", "0"}, expectedErr: redisclient.Error("NOSCRIPT No matching script.")},
+		{args: []string{"SET", "k"}, expectedErr: redisclient.Error("ERR wrong number of arguments for 'set' command")},
+		{args: []string{"FLUSHALL"}, expectedErr: redisclient.Error("ERR unknown command 'FLUSHALL'")},
+	}
+
+	for i, s := range steps {
+		now = now.Add(s.after)
+		reply, err := client.Do(s.args...)
+		assert.Equal(t, s.expectedReply, reply, "step #%v", i+1)
+		assert.Equal(t, s.expectedErr, err, "step #%v", i+1)
+	}
+}
@@ -2,7 +2,9 @@ package runner
 
 import (
 	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/krpn/prometheus-alert-webhooker/model"
 	"github.com/sirupsen/logrus"
+	"time"
 )
 
 type execResult string
@@ -15,6 +17,9 @@ const (
 	execResultExecErrorWithoutBlock execResult = "exec_error_without_block"
 	execResultSuccess               execResult = "success"
 	execResultSuccessWithoutBlock   execResult = "success_without_block"
+	execResultRuleRateLimited       execResult = "rule_rate_limited"
+	execResultExecutorRateLimited   execResult = "executor_rate_limited"
+	execResultCircuitBreakerOpen    execResult = "circuit_breaker_open"
 )
 
 var successfulResults = []string{
@@ -26,8 +31,14 @@ func (r execResult) String() string {
 	return string(r)
 }
 
-func exec(task executor.Task, blocker blocker, logger *logrus.Logger) (execResult, error) {
-	if task.BlockTTL().Seconds() <= 0 {
+func exec(task executor.Task, blocker blocker, limiter limiter, cooldowns cooldowns, inProgress *inProgress, lease model.Lease, metric metricser, logger *logrus.Logger, nowFunc func() time.Time) (execResult, error) {
+	blockTTL, blockOnFailure := task.BlockTTL(), executor.BlockOnFailure(task)
+	if blockTTL.Seconds() <= 0 && blockOnFailure <= 0 {
+		result, limited := limit(task, limiter, logger)
+		if limited {
+			return result, nil
+		}
+
 		err := task.Exec(logger)
 		if err != nil {
 			return execResultExecErrorWithoutBlock, err
@@ -36,25 +47,98 @@ func exec(task executor.Task, blocker blocker, logger *logrus.Logger) (execResul
 		return execResultSuccessWithoutBlock, nil
 	}
 
-	success, err := blocker.BlockInProgress(task.ExecutorName(), task.Fingerprint())
+	// owner is passed to blocker, so block taken by another execution after lease expired is not touched
+	owner, err := blocker.BlockInProgress(task, lease.TTL)
 	if err != nil {
 		return execResultBlockError, err
 	}
 
-	if !success {
+	if len(owner) == 0 {
 		return execResultInBlock, nil
 	}
 
+	// limits are checked after blocking so blocked duplicates are not counted
+	result, limited := limit(task, limiter, logger)
+	if limited {
+		blocker.Unblock(executor.BlockScope(task), task.Fingerprint(), owner)
+		return result, nil
+	}
+
+	done := inProgress.add(task, owner)
+	release := keepLease(task, owner, blocker, lease, metric, logger, nowFunc)
 	err = task.Exec(logger)
+	release()
+	done()
 	if err != nil {
-		blocker.Unblock(task.ExecutorName(), task.Fingerprint())
+		if blockOnFailure > 0 {
+			blockForCooldown(task, owner, blocker, cooldowns, blockOnFailure, logger, nowFunc)
+			return execResultExecError, err
+		}
+
+		blocker.Unblock(executor.BlockScope(task), task.Fingerprint(), owner)
 		return execResultExecError, err
 	}
 
-	err = blocker.BlockForTTL(task.ExecutorName(), task.Fingerprint(), task.BlockTTL())
+	if blockOnFailure > 0 {
+		cooldowns.Succeed(cooldownKey(task))
+	}
+
+	if blockTTL.Seconds() <= 0 {
+		blocker.Unblock(executor.BlockScope(task), task.Fingerprint(), owner)
+		return execResultSuccessWithoutBlock, nil
+	}
+
+	err = blocker.BlockForTTL(task, owner, blockTTL)
 	if err != nil {
 		return execResultCanNotBlock, err
 	}
 
 	return execResultSuccess, nil
 }
+
+// blockForCooldown blocks failed task for cooldown growing with consecutive failures.
+// Task is unblocked if block fails, so it is retried on next alert.
+func blockForCooldown(task executor.Task, owner string, blocker blocker, cooldowns cooldowns, blockOnFailure time.Duration, logger *logrus.Logger, nowFunc func() time.Time) {
+	cooldown, failures := cooldowns.Fail(cooldownKey(task), blockOnFailure, nowFunc())
+
+	taskLogger := logger.WithFields(executor.TaskDetails(task)).WithField("context", context)
+
+	err := blocker.BlockForTTL(task, owner, cooldown)
+	if err != nil {
+		taskLogger.Errorf("failure cooldown block error: %v", err)
+		blocker.Unblock(executor.BlockScope(task), task.Fingerprint(), owner)
+		return
+	}
+
+	taskLogger.Warnf("task is blocked for %v after %v consecutive failures", cooldown, failures)
+}
+
+func cooldownKey(task executor.Task) string {
+	return executor.BlockScope(task) + "_" + task.Fingerprint()
+}
+
+func limit(task executor.Task, limiter limiter, logger *logrus.Logger) (result execResult, limited bool) {
+	l, tripped := limiter.Allow(task)
+	if tripped {
+		notifyTrip(task.EventID(), limiter, logger)
+	}
+
+	if len(l) == 0 {
+		return "", false
+	}
+
+	return execResult(l), true
+}
+
+// notifyTrip executes circuit breaker notification tasks without blocking and limits.
+func notifyTrip(eventID string, limiter limiter, logger *logrus.Logger) {
+	ctxLogger := logger.WithFields(logrus.Fields{"context": context, "event_id": eventID})
+	ctxLogger.Error("circuit breaker is tripped, all tasks are halted")
+
+	for _, task := range limiter.TripTasks(eventID) {
+		err := task.Exec(logger)
+		if err != nil {
+			ctxLogger.WithFields(executor.TaskDetails(task)).Errorf("circuit breaker notification error: %v", err)
+		}
+	}
+}
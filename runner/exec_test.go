@@ -2,8 +2,11 @@ package runner
 
 import (
 	"errors"
+	"github.com/coocood/freecache"
 	"github.com/golang/mock/gomock"
+	blc "github.com/krpn/prometheus-alert-webhooker/blocker"
 	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/krpn/prometheus-alert-webhooker/model"
 	"github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
@@ -18,6 +21,11 @@ func Test_exec(t *testing.T) {
 	defer ctrl.Finish()
 
 	blocker := NewMockblocker(ctrl)
+	limiter := NewMocklimiter(ctrl)
+	cooldowns := NewMockcooldowns(ctrl)
+	limiter.EXPECT().Allow(gomock.Any()).Return("", false).AnyTimes()
+	metric := NewMockmetricser(ctrl)
+	lease := model.Lease{TTL: time.Minute, MaxDuration: time.Hour}
 	logger, hook := test.NewNullLogger()
 
 	type testTableData struct {
@@ -33,12 +41,10 @@ func Test_exec(t *testing.T) {
 			tcase: execResultSuccess,
 			task:  executor.NewMockTask(ctrl),
 			expectFunc: func(t *executor.MockTask, b *Mockblocker, l *logrus.Logger) {
-				t.EXPECT().BlockTTL().Return(10 * time.Minute).Times(2)
-				t.EXPECT().Fingerprint().Return("testfp1").Times(2)
-				t.EXPECT().ExecutorName().Return("shell").Times(2)
-				b.EXPECT().BlockInProgress("shell", "testfp1").Return(true, nil)
+				t.EXPECT().BlockTTL().Return(10 * time.Minute)
+				b.EXPECT().BlockInProgress(t, time.Minute).Return("owner1", nil)
 				t.EXPECT().Exec(l).Return(nil)
-				b.EXPECT().BlockForTTL("shell", "testfp1", 10*time.Minute).Return(nil)
+				b.EXPECT().BlockForTTL(t, "owner1", 10*time.Minute).Return(nil)
 			},
 			expectedResult: execResultSuccess,
 			expectedErr:    nil,
@@ -48,9 +54,7 @@ func Test_exec(t *testing.T) {
 			task:  executor.NewMockTask(ctrl),
 			expectFunc: func(t *executor.MockTask, b *Mockblocker, l *logrus.Logger) {
 				t.EXPECT().BlockTTL().Return(10 * time.Minute)
-				t.EXPECT().Fingerprint().Return("testfp1")
-				t.EXPECT().ExecutorName().Return("shell")
-				b.EXPECT().BlockInProgress("shell", "testfp1").Return(false, nil)
+				b.EXPECT().BlockInProgress(t, time.Minute).Return("", nil)
 			},
 			expectedResult: execResultInBlock,
 			expectedErr:    nil,
@@ -60,9 +64,7 @@ func Test_exec(t *testing.T) {
 			task:  executor.NewMockTask(ctrl),
 			expectFunc: func(t *executor.MockTask, b *Mockblocker, l *logrus.Logger) {
 				t.EXPECT().BlockTTL().Return(10 * time.Minute)
-				t.EXPECT().Fingerprint().Return("testfp1")
-				t.EXPECT().ExecutorName().Return("shell")
-				b.EXPECT().BlockInProgress("shell", "testfp1").Return(false, errors.New("block error"))
+				b.EXPECT().BlockInProgress(t, time.Minute).Return("", errors.New("block error"))
 			},
 			expectedResult: execResultBlockError,
 			expectedErr:    errors.New("block error"),
@@ -72,11 +74,11 @@ func Test_exec(t *testing.T) {
 			task:  executor.NewMockTask(ctrl),
 			expectFunc: func(t *executor.MockTask, b *Mockblocker, l *logrus.Logger) {
 				t.EXPECT().BlockTTL().Return(10 * time.Minute)
-				t.EXPECT().Fingerprint().Return("testfp1").Times(2)
-				t.EXPECT().ExecutorName().Return("shell").Times(2)
-				b.EXPECT().BlockInProgress("shell", "testfp1").Return(true, nil)
+				t.EXPECT().Fingerprint().Return("testfp1")
+				t.EXPECT().ExecutorName().Return("shell")
+				b.EXPECT().BlockInProgress(t, time.Minute).Return("owner1", nil)
 				t.EXPECT().Exec(l).Return(errors.New("exec error"))
-				b.EXPECT().Unblock("shell", "testfp1")
+				b.EXPECT().Unblock("shell", "testfp1", "owner1")
 			},
 			expectedResult: execResultExecError,
 			expectedErr:    errors.New("exec error"),
@@ -105,12 +107,10 @@ func Test_exec(t *testing.T) {
 			tcase: execResultCanNotBlock,
 			task:  executor.NewMockTask(ctrl),
 			expectFunc: func(t *executor.MockTask, b *Mockblocker, l *logrus.Logger) {
-				t.EXPECT().BlockTTL().Return(10 * time.Minute).Times(2)
-				t.EXPECT().Fingerprint().Return("testfp1").Times(2)
-				t.EXPECT().ExecutorName().Return("shell").Times(2)
-				b.EXPECT().BlockInProgress("shell", "testfp1").Return(true, nil)
+				t.EXPECT().BlockTTL().Return(10 * time.Minute)
+				b.EXPECT().BlockInProgress(t, time.Minute).Return("owner1", nil)
 				t.EXPECT().Exec(l).Return(nil)
-				b.EXPECT().BlockForTTL("shell", "testfp1", 10*time.Minute).Return(errors.New("some block error"))
+				b.EXPECT().BlockForTTL(t, "owner1", 10*time.Minute).Return(errors.New("some block error"))
 			},
 			expectedResult: execResultCanNotBlock,
 			expectedErr:    errors.New("some block error"),
@@ -119,7 +119,7 @@ func Test_exec(t *testing.T) {
 
 	for _, testUnit := range testTable {
 		testUnit.expectFunc(testUnit.task, blocker, logger)
-		result, err := exec(testUnit.task, blocker, logger)
+		result, err := exec(testUnit.task, blocker, limiter, cooldowns, newInProgress(), lease, metric, logger, time.Now)
 		assert.Equal(t, testUnit.expectedResult, result, testUnit.tcase)
 		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
 	}
@@ -127,6 +127,299 @@ func Test_exec(t *testing.T) {
 	assert.Equal(t, 0, len(hook.Entries))
 }
 
+func Test_exec_Limited(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	blocker := NewMockblocker(ctrl)
+	limiter := NewMocklimiter(ctrl)
+	cooldowns := NewMockcooldowns(ctrl)
+	metric := NewMockmetricser(ctrl)
+	lease := model.Lease{TTL: time.Minute, MaxDuration: time.Hour}
+
+	type testTableData struct {
+		tcase          execResult
+		task           *executor.MockTask
+		expectFunc     func(t *executor.MockTask, b *Mockblocker, lm *Mocklimiter, l *logrus.Logger)
+		expectedResult execResult
+		expectedLogs   []string
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: execResultRuleRateLimited,
+			task:  executor.NewMockTask(ctrl),
+			expectFunc: func(t *executor.MockTask, b *Mockblocker, lm *Mocklimiter, l *logrus.Logger) {
+				t.EXPECT().BlockTTL().Return(0 * time.Minute)
+				lm.EXPECT().Allow(t).Return("rule_rate_limited", false)
+			},
+			expectedResult: execResultRuleRateLimited,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase: execResultExecutorRateLimited,
+			task:  executor.NewMockTask(ctrl),
+			expectFunc: func(t *executor.MockTask, b *Mockblocker, lm *Mocklimiter, l *logrus.Logger) {
+				t.EXPECT().BlockTTL().Return(10 * time.Minute)
+				t.EXPECT().Fingerprint().Return("testfp1")
+				t.EXPECT().ExecutorName().Return("shell")
+				b.EXPECT().BlockInProgress(t, time.Minute).Return("owner1", nil)
+				lm.EXPECT().Allow(t).Return("executor_rate_limited", false)
+				b.EXPECT().Unblock("shell", "testfp1", "owner1")
+			},
+			expectedResult: execResultExecutorRateLimited,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase: execResultCircuitBreakerOpen,
+			task:  executor.NewMockTask(ctrl),
+			expectFunc: func(t *executor.MockTask, b *Mockblocker, lm *Mocklimiter, l *logrus.Logger) {
+				t.EXPECT().BlockTTL().Return(0 * time.Minute)
+				t.EXPECT().EventID().Return("testid1")
+				lm.EXPECT().Allow(t).Return("circuit_breaker_open", true)
+
+				notifyTask := executor.NewMockTask(ctrl)
+				notifyTask.EXPECT().Exec(l).Return(errors.New("send error"))
+				notifyTask.EXPECT().EventID().Return("testid1")
+				notifyTask.EXPECT().Rule().Return("circuit_breaker")
+				notifyTask.EXPECT().Alert().Return("WebhookerCircuitBreakerTripped")
+				notifyTask.EXPECT().ExecutorName().Return("telegram")
+				notifyTask.EXPECT().ExecutorDetails().Return("testtask1")
+				lm.EXPECT().TripTasks("testid1").Return(model.Tasks{notifyTask})
+			},
+			expectedResult: execResultCircuitBreakerOpen,
+			expectedLogs: []string{
+				`{"context":"runner","event_id":"testid1","level":"error","msg":"circuit breaker is tripped, all tasks are halted"}`,
+				`{"alert":"WebhookerCircuitBreakerTripped","context":"runner","details":"testtask1","event_id":"testid1","executor":"telegram","level":"error","msg":"circuit breaker notification error: send error","rule":"circuit_breaker"}`,
+			},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		logger, hook := test.NewNullLogger()
+		logger.Formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+
+		testUnit.expectFunc(testUnit.task, blocker, limiter, logger)
+		result, err := exec(testUnit.task, blocker, limiter, cooldowns, newInProgress(), lease, metric, logger, time.Now)
+		assert.Equal(t, testUnit.expectedResult, result, testUnit.tcase)
+		assert.Equal(t, nil, err, testUnit.tcase)
+		assert.Equal(t, expectedLogsFix(testUnit.expectedLogs), logsFromHook(t, hook), testUnit.tcase)
+	}
+}
+
+type failureBlockedTask struct {
+	*executor.MockTask
+	blockOnFailure time.Duration
+}
+
+func (task failureBlockedTask) BlockOnFailure() time.Duration {
+	return task.blockOnFailure
+}
+
+func Test_exec_BlockOnFailure(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	blocker := NewMockblocker(ctrl)
+	limiter := NewMocklimiter(ctrl)
+	limiter.EXPECT().Allow(gomock.Any()).Return("", false).AnyTimes()
+	cooldowns := NewMockcooldowns(ctrl)
+	metric := NewMockmetricser(ctrl)
+	lease := model.Lease{TTL: time.Minute, MaxDuration: time.Hour}
+	now := time.Unix(1535086351, 0)
+
+	type testTableData struct {
+		tcase          string
+		blockTTL       time.Duration
+		expectFunc     func(t failureBlockedTask, b *Mockblocker, c *Mockcooldowns, m *Mockmetricser, l *logrus.Logger)
+		expectedResult execResult
+		expectedErr    error
+		expectedLogs   []string
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:    "failure cooldown",
+			blockTTL: 10 * time.Minute,
+			expectFunc: func(t failureBlockedTask, b *Mockblocker, c *Mockcooldowns, m *Mockmetricser, l *logrus.Logger) {
+				b.EXPECT().BlockInProgress(t, time.Minute).Return("owner1", nil)
+				t.EXPECT().Exec(l).Return(errors.New("exec error"))
+				t.EXPECT().Fingerprint().Return("testfp1")
+				t.EXPECT().ExecutorName().Return("shell").Times(2)
+				c.EXPECT().Fail("shell_testfp1", 5*time.Minute, now).Return(20*time.Minute, 3)
+				b.EXPECT().BlockForTTL(t, "owner1", 20*time.Minute).Return(nil)
+				t.EXPECT().EventID().Return("testid1")
+				t.EXPECT().Rule().Return("testrule1")
+				t.EXPECT().Alert().Return("testalert1")
+				t.EXPECT().ExecutorDetails().Return("testtask1")
+			},
+			expectedResult: execResultExecError,
+			expectedErr:    errors.New("exec error"),
+			expectedLogs: []string{
+				`{"alert":"testalert1","context":"runner","details":"testtask1","event_id":"testid1","executor":"shell","level":"warning","msg":"task is blocked for 20m0s after 3 consecutive failures","rule":"testrule1"}`,
+			},
+		},
+		{
+			tcase:    "failure cooldown block error",
+			blockTTL: 0,
+			expectFunc: func(t failureBlockedTask, b *Mockblocker, c *Mockcooldowns, m *Mockmetricser, l *logrus.Logger) {
+				b.EXPECT().BlockInProgress(t, time.Minute).Return("owner1", nil)
+				t.EXPECT().Exec(l).Return(errors.New("exec error"))
+				t.EXPECT().Fingerprint().Return("testfp1").Times(2)
+				t.EXPECT().ExecutorName().Return("shell").Times(3)
+				c.EXPECT().Fail("shell_testfp1", 5*time.Minute, now).Return(5*time.Minute, 1)
+				b.EXPECT().BlockForTTL(t, "owner1", 5*time.Minute).Return(errors.New("some block error"))
+				b.EXPECT().Unblock("shell", "testfp1", "owner1")
+				t.EXPECT().EventID().Return("testid1")
+				t.EXPECT().Rule().Return("testrule1")
+				t.EXPECT().Alert().Return("testalert1")
+				t.EXPECT().ExecutorDetails().Return("testtask1")
+			},
+			expectedResult: execResultExecError,
+			expectedErr:    errors.New("exec error"),
+			expectedLogs: []string{
+				`{"alert":"testalert1","context":"runner","details":"testtask1","event_id":"testid1","executor":"shell","level":"error","msg":"failure cooldown block error: some block error","rule":"testrule1"}`,
+			},
+		},
+		{
+			tcase:    "success resets failures",
+			blockTTL: 10 * time.Minute,
+			expectFunc: func(t failureBlockedTask, b *Mockblocker, c *Mockcooldowns, m *Mockmetricser, l *logrus.Logger) {
+				b.EXPECT().BlockInProgress(t, time.Minute).Return("owner1", nil)
+				t.EXPECT().Exec(l).Return(nil)
+				t.EXPECT().Fingerprint().Return("testfp1")
+				t.EXPECT().ExecutorName().Return("shell")
+				c.EXPECT().Succeed("shell_testfp1")
+				b.EXPECT().BlockForTTL(t, "owner1", 10*time.Minute).Return(nil)
+			},
+			expectedResult: execResultSuccess,
+			expectedErr:    nil,
+			expectedLogs:   []string{},
+		},
+		{
+			tcase:    "success without block",
+			blockTTL: 0,
+			expectFunc: func(t failureBlockedTask, b *Mockblocker, c *Mockcooldowns, m *Mockmetricser, l *logrus.Logger) {
+				b.EXPECT().BlockInProgress(t, time.Minute).Return("owner1", nil)
+				t.EXPECT().Exec(l).Return(nil)
+				t.EXPECT().Fingerprint().Return("testfp1").Times(2)
+				t.EXPECT().ExecutorName().Return("shell").Times(2)
+				c.EXPECT().Succeed("shell_testfp1")
+				b.EXPECT().Unblock("shell", "testfp1", "owner1")
+			},
+			expectedResult: execResultSuccessWithoutBlock,
+			expectedErr:    nil,
+			expectedLogs:   []string{},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		logger, hook := test.NewNullLogger()
+		logger.Formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+
+		task := failureBlockedTask{MockTask: executor.NewMockTask(ctrl), blockOnFailure: 5 * time.Minute}
+		task.EXPECT().BlockTTL().Return(testUnit.blockTTL)
+		testUnit.expectFunc(task, blocker, cooldowns, metric, logger)
+
+		result, err := exec(task, blocker, limiter, cooldowns, newInProgress(), lease, metric, logger, func() time.Time { return now })
+		assert.Equal(t, testUnit.expectedResult, result, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
+		assert.Equal(t, expectedLogsFix(testUnit.expectedLogs), logsFromHook(t, hook), testUnit.tcase)
+	}
+}
+
+func Test_exec_LeaseExpiredBeforeFinish(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	limiter := NewMocklimiter(ctrl)
+	limiter.EXPECT().Allow(gomock.Any()).Return("", false).AnyTimes()
+	logger, _ := test.NewNullLogger()
+
+	type testTableData struct {
+		tcase          string
+		execErr        error
+		expectedResult execResult
+		expectedErr    error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:          "late failure",
+			execErr:        errors.New("exec error"),
+			expectedResult: execResultExecError,
+			expectedErr:    errors.New("exec error"),
+		},
+		{
+			tcase:          "late success",
+			execErr:        nil,
+			expectedResult: execResultCanNotBlock,
+			expectedErr:    errors.New("block is owned by another execution"),
+		},
+	}
+
+	for _, testUnit := range testTable {
+		blk := blc.New(blc.FreeCache{Cache: freecache.NewCache(1024 * 1024)}, time.Now)
+
+		task := executor.NewMockTask(ctrl)
+		task.EXPECT().BlockTTL().Return(10 * time.Minute).AnyTimes()
+		task.EXPECT().ExecutorName().Return("shell").AnyTimes()
+		task.EXPECT().Fingerprint().Return("testfp1").AnyTimes()
+		task.EXPECT().Rule().Return("testrule1").AnyTimes()
+		task.EXPECT().Alert().Return("testalert1").AnyTimes()
+		task.EXPECT().EventID().Return("testid1").AnyTimes()
+		task.EXPECT().ExecutorDetails().Return("testtask1").AnyTimes()
+
+		executing, finish := make(chan struct{}), make(chan struct{})
+		task.EXPECT().Exec(logger).Do(func(*logrus.Logger) {
+			close(executing)
+			<-finish
+		}).Return(testUnit.execErr)
+
+		type execReturn struct {
+			result execResult
+			err    error
+		}
+		returned := make(chan execReturn)
+		go func() {
+			result, err := exec(task, blk, limiter, NewMockcooldowns(ctrl), newInProgress(), model.Lease{}, NewMockmetricser(ctrl), logger, time.Now)
+			returned <- execReturn{result: result, err: err}
+		}()
+		<-executing
+
+		// lease of hung task expires and the next execution takes in-progress block
+		deleted, err := blk.Delete("shell", "testfp1")
+		assert.Equal(t, true, deleted, testUnit.tcase)
+		assert.Equal(t, nil, err, testUnit.tcase)
+
+		owner, err := blk.BlockInProgress(task, 0)
+		assert.NotEqual(t, "", owner, testUnit.tcase)
+		assert.Equal(t, nil, err, testUnit.tcase)
+
+		close(finish)
+		r := <-returned
+		assert.Equal(t, testUnit.expectedResult, r.result, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedErr, r.err, testUnit.tcase)
+
+		// block of the next execution is kept, so the third execution is not started
+		entries, err := blk.Blocks()
+		assert.Equal(t, nil, err, testUnit.tcase)
+		assert.Equal(t, 1, len(entries), testUnit.tcase)
+		assert.Equal(t, true, entries[0].InProgress, testUnit.tcase)
+		assert.Equal(t, owner, entries[0].Owner, testUnit.tcase)
+
+		owner, err = blk.BlockInProgress(task, 0)
+		assert.Equal(t, "", owner, testUnit.tcase)
+		assert.Equal(t, nil, err, testUnit.tcase)
+	}
+}
+
 func TestExecResult_String(t *testing.T) {
 	t.Parallel()
 
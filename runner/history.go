@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/krpn/prometheus-alert-webhooker/history"
+	"time"
+)
+
+// newExecution creates execution record of tasks group from details of its first task.
+// Details are used instead of task methods because they are already collected by scheduler.
+func newExecution(details map[string]interface{}) *history.Execution {
+	eventID, _ := details["event_id"].(string)
+	rule, _ := details["rule"].(string)
+	alert, _ := details["alert"].(string)
+
+	return &history.Execution{
+		EventID: eventID,
+		Rule:    rule,
+		Alert:   alert,
+		Tasks:   make([]history.TaskExecution, 0),
+	}
+}
+
+// addTaskExecution adds executed task to execution record, group result is a result of the last executed task.
+func addTaskExecution(execution *history.Execution, details map[string]interface{}, result execResult, err error, output map[string]interface{}, startedAt, finishedAt time.Time) {
+	executorName, _ := details["executor"].(string)
+	taskExecution := history.TaskExecution{
+		Executor:   executorName,
+		Details:    details["details"],
+		Result:     result.String(),
+		StartedAt:  startedAt,
+		FinishedAt: finishedAt,
+	}
+	// details are redacted by executor.TaskDetails, output could contain secrets too (e.g. token from response)
+	taskExecution.Output, _ = executor.Redact(output).(map[string]interface{})
+	if err != nil {
+		taskExecution.Error = err.Error()
+	}
+
+	if len(execution.Tasks) == 0 {
+		execution.StartedAt = startedAt
+	}
+	execution.FinishedAt = finishedAt
+	execution.Result = taskExecution.Result
+	execution.Error = taskExecution.Error
+	execution.Tasks = append(execution.Tasks, taskExecution)
+}
@@ -0,0 +1,56 @@
+package runner
+
+import (
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/sirupsen/logrus"
+	"sync"
+)
+
+// inProgress tracks executing tasks holding in-progress blocks, so blocks can be released on shutdown.
+type inProgress struct {
+	mt    *sync.Mutex
+	seq   int
+	tasks map[int]ownedTask
+}
+
+// ownedTask is a task with owner of its in-progress block.
+type ownedTask struct {
+	task  executor.Task
+	owner string
+}
+
+// add tracks task holding in-progress block owned by owner until done is called.
+func (p *inProgress) add(task executor.Task, owner string) (done func()) {
+	p.mt.Lock()
+	defer p.mt.Unlock()
+
+	p.seq++
+	id := p.seq
+	p.tasks[id] = ownedTask{task: task, owner: owner}
+
+	return func() {
+		p.mt.Lock()
+		defer p.mt.Unlock()
+
+		delete(p.tasks, id)
+	}
+}
+
+// release unblocks in-progress blocks of all tracked tasks.
+func (p *inProgress) release(blocker blocker, logger *logrus.Logger) {
+	p.mt.Lock()
+	defer p.mt.Unlock()
+
+	for id, t := range p.tasks {
+		blocker.Unblock(executor.BlockScope(t.task), t.task.Fingerprint(), t.owner)
+		logger.WithFields(executor.TaskDetails(t.task)).WithField("context", context).Warn("task is still executing on shutdown, in-progress block is released")
+		delete(p.tasks, id)
+	}
+}
+
+func newInProgress() *inProgress {
+	return &inProgress{
+		mt:    &sync.Mutex{},
+		tasks: make(map[int]ownedTask),
+	}
+}
@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/krpn/prometheus-alert-webhooker/model"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// leaseRenewRatio is a quantity of renew attempts within lease TTL.
+const leaseRenewRatio = 3
+
+// keepLease renews in-progress block of task owned by owner until release is called.
+// Block is not renewed longer than lease max duration, so hung task does not block forever.
+func keepLease(task executor.Task, owner string, blocker blocker, lease model.Lease, metric metricser, logger *logrus.Logger, nowFunc func() time.Time) (release func()) {
+	if lease.TTL <= 0 {
+		return func() {}
+	}
+
+	done, finished := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(finished)
+
+		ticker := time.NewTicker(lease.TTL / leaseRenewRatio)
+		defer ticker.Stop()
+
+		start := nowFunc()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			ttl := lease.TTL
+			remaining := lease.MaxDuration - nowFunc().Sub(start)
+			if remaining <= 0 {
+				leaseExpired(task, metric, logger, "in-progress block lease reached max duration and expired, task is still executing")
+				return
+			}
+			if remaining < ttl {
+				ttl = remaining
+			}
+
+			renewed, err := blocker.Renew(executor.BlockScope(task), task.Fingerprint(), owner, ttl)
+			if err != nil {
+				logger.WithFields(executor.TaskDetails(task)).WithField("context", context).Errorf("in-progress block lease renew error: %v", err)
+				continue
+			}
+
+			if !renewed {
+				leaseExpired(task, metric, logger, "in-progress block lease expired before renew, task is still executing")
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+func leaseExpired(task executor.Task, metric metricser, logger *logrus.Logger, msg string) {
+	metric.BlockLeaseExpiredInc(task.Rule(), task.Alert(), task.ExecutorName())
+	logger.WithFields(executor.TaskDetails(task)).WithField("context", context).Warn(msg)
+}
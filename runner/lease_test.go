@@ -0,0 +1,140 @@
+package runner
+
+import (
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/krpn/prometheus-alert-webhooker/model"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_keepLease(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	lease := model.Lease{TTL: 30 * time.Millisecond, MaxDuration: time.Hour}
+
+	expectTask := func(t *executor.MockTask) {
+		t.EXPECT().EventID().Return("testid1").AnyTimes()
+		t.EXPECT().Rule().Return("testrule1").AnyTimes()
+		t.EXPECT().Alert().Return("testalert1").AnyTimes()
+		t.EXPECT().ExecutorName().Return("shell").AnyTimes()
+		t.EXPECT().Fingerprint().Return("testfp1").AnyTimes()
+		t.EXPECT().ExecutorDetails().Return("testtask1").AnyTimes()
+	}
+
+	type testTableData struct {
+		tcase        string
+		lease        model.Lease
+		nowFunc      func() time.Time
+		expectFunc   func(b *Mockblocker, m *Mockmetricser)
+		expectedLogs []string
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:   "renewed",
+			lease:   lease,
+			nowFunc: time.Now,
+			expectFunc: func(b *Mockblocker, m *Mockmetricser) {
+				b.EXPECT().Renew("shell", "testfp1", "owner1", lease.TTL).Return(true, nil).MinTimes(1)
+			},
+			expectedLogs: []string{},
+		},
+		{
+			tcase:   "expired before renew",
+			lease:   lease,
+			nowFunc: time.Now,
+			expectFunc: func(b *Mockblocker, m *Mockmetricser) {
+				b.EXPECT().Renew("shell", "testfp1", "owner1", lease.TTL).Return(false, nil)
+				m.EXPECT().BlockLeaseExpiredInc("testrule1", "testalert1", "shell")
+			},
+			expectedLogs: []string{
+				`{"alert":"testalert1","context":"runner","details":"testtask1","event_id":"testid1","executor":"shell","level":"warning","msg":"in-progress block lease expired before renew, task is still executing","rule":"testrule1"}`,
+			},
+		},
+		{
+			tcase: "max duration reached",
+			lease: lease,
+			nowFunc: func() func() time.Time {
+				mt, calls, start := &sync.Mutex{}, 0, time.Unix(1535086351, 0)
+				return func() time.Time {
+					mt.Lock()
+					defer mt.Unlock()
+					calls++
+					if calls == 1 {
+						return start
+					}
+					return start.Add(2 * time.Hour)
+				}
+			}(),
+			expectFunc: func(b *Mockblocker, m *Mockmetricser) {
+				m.EXPECT().BlockLeaseExpiredInc("testrule1", "testalert1", "shell")
+			},
+			expectedLogs: []string{
+				`{"alert":"testalert1","context":"runner","details":"testtask1","event_id":"testid1","executor":"shell","level":"warning","msg":"in-progress block lease reached max duration and expired, task is still executing","rule":"testrule1"}`,
+			},
+		},
+		{
+			tcase:        "disabled",
+			lease:        model.Lease{},
+			nowFunc:      time.Now,
+			expectFunc:   func(b *Mockblocker, m *Mockmetricser) {},
+			expectedLogs: []string{},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		logger, hook := test.NewNullLogger()
+		logger.Formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+
+		task := executor.NewMockTask(ctrl)
+		expectTask(task)
+		blocker := NewMockblocker(ctrl)
+		metric := NewMockmetricser(ctrl)
+		testUnit.expectFunc(blocker, metric)
+
+		release := keepLease(task, "owner1", blocker, testUnit.lease, metric, logger, testUnit.nowFunc)
+		time.Sleep(50 * time.Millisecond)
+		release()
+
+		assert.Equal(t, expectedLogsFix(testUnit.expectedLogs), logsFromHook(t, hook), testUnit.tcase)
+	}
+}
+
+func Test_keepLease_RenewError(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	task := executor.NewMockTask(ctrl)
+	task.EXPECT().EventID().Return("testid1").AnyTimes()
+	task.EXPECT().Rule().Return("testrule1").AnyTimes()
+	task.EXPECT().Alert().Return("testalert1").AnyTimes()
+	task.EXPECT().ExecutorName().Return("shell").AnyTimes()
+	task.EXPECT().Fingerprint().Return("testfp1").AnyTimes()
+	task.EXPECT().ExecutorDetails().Return("testtask1").AnyTimes()
+
+	blocker := NewMockblocker(ctrl)
+	blocker.EXPECT().Renew("shell", "testfp1", "owner1", 30*time.Millisecond).Return(false, errors.New("connection refused")).MinTimes(2)
+
+	logger, hook := test.NewNullLogger()
+	logger.Formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+
+	release := keepLease(task, "owner1", blocker, model.Lease{TTL: 30 * time.Millisecond, MaxDuration: time.Hour}, NewMockmetricser(ctrl), logger, time.Now)
+	time.Sleep(50 * time.Millisecond)
+	release()
+
+	// renewing is continued after error
+	logs := logsFromHook(t, hook)
+	assert.Equal(t, true, len(logs) >= 2)
+	assert.Equal(t, `{"alert":"testalert1","context":"runner","details":"testtask1","event_id":"testid1","executor":"shell","level":"error","msg":"in-progress block lease renew error: connection refused","rule":"testrule1"}`+"\n", logs[0])
+}
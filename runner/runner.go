@@ -2,6 +2,7 @@ package runner
 
 import (
 	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/krpn/prometheus-alert-webhooker/history"
 	"github.com/krpn/prometheus-alert-webhooker/model"
 	"github.com/krpn/prometheus-alert-webhooker/utils"
 	"github.com/sirupsen/logrus"
@@ -10,18 +11,58 @@ import (
 )
 
 // Start starts runners for observe tasks.
-func Start(runners int, tasksCh chan model.Tasks, blocker blocker, metric metricser, logger *logrus.Logger, nowFunc func() time.Time) {
+// Tasks groups are executed by rule priority, executors and rules concurrency is limited by settings.
+// In-progress blocks are renewed by lease settings while task is executing.
+// Failed tasks with block on failure are blocked for cooldown tracked by cooldowns.
+// Queued tasks groups are marked as done in queue after executing.
+// Executions of tasks groups are recorded to history by recorder.
+// Start returns when tasksCh is closed and drained or when stop is closed.
+// On stop runners do not start new tasks and in-progress blocks of still executing tasks are released.
+// Executing tasks are not cancelled: Start waits for them for stopTimeout and then abandons them,
+// abandoned tasks keep running until process exits. Finished reports whether all runners are finished.
+func Start(runners int, tasksCh chan model.QueuedTasks, stop <-chan struct{}, stopTimeout time.Duration, settings *Settings, queue queue, recorder recorder, blocker blocker, limiter limiter, cooldowns cooldowns, lease model.Lease, metric metricser, logger *logrus.Logger, nowFunc func() time.Time) (finished bool) {
+	inProgress := newInProgress()
+	sched := newScheduler(settings, cap(tasksCh))
+
+	go func() {
+		for queued := range tasksCh {
+			sched.push(queued)
+		}
+		sched.close()
+	}()
+
 	var wg sync.WaitGroup
 	wg.Add(runners)
 	for i := 0; i < runners; i++ {
-		go runner(tasksCh, blocker, metric, logger, nowFunc, &wg)
+		go runner(sched, stop, queue, recorder, blocker, limiter, cooldowns, inProgress, lease, metric, logger, nowFunc, &wg)
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return true
+	case <-stop:
+	}
+
+	sched.stop()
+	inProgress.release(blocker, logger)
+
+	select {
+	case <-drained:
+		return true
+	case <-time.After(stopTimeout):
+		return false
 	}
-	wg.Wait()
 }
 
 const context = "runner"
 
-func runner(tasksCh chan model.Tasks, blocker blocker, metric metricser, logger *logrus.Logger, nowFunc func() time.Time, wg *sync.WaitGroup) {
+func runner(sched *scheduler, stop <-chan struct{}, queue queue, recorder recorder, blocker blocker, limiter limiter, cooldowns cooldowns, inProgress *inProgress, lease model.Lease, metric metricser, logger *logrus.Logger, nowFunc func() time.Time, wg *sync.WaitGroup) {
 	defer wg.Done()
 	var (
 		result    execResult
@@ -30,47 +71,112 @@ func runner(tasksCh chan model.Tasks, blocker blocker, metric metricser, logger
 		ctxLogger = logger.WithField("context", context)
 	)
 
-	for tasks := range tasksCh {
-		tasksLogger := ctxLogger.WithField("tasks", tasks.Details())
-		tasksLogger.Debug("runner starts executing group")
-
-		tasksQty := len(tasks)
-		for i, task := range tasks {
-			taskNum := i + 1
-			taskLogger := tasksLogger.WithFields(executor.TaskDetails(task))
-			taskLogger.Debugf("runner starts executing task #%v/%v", taskNum, tasksQty)
-
-			start = nowFunc()
-			result, err = exec(task, blocker, logger)
-			duration := nowFunc().Sub(start)
-			metric.ExecutedTaskObserve(task.Rule(), task.Alert(), task.ExecutorName(), result.String(), err, duration)
-
-			taskLogger = taskLogger.WithFields(logrus.Fields{"result": result.String(), "duration": duration.String()})
-			if err == nil {
-				taskLogger.Debugf("runner finished executing task #%v/%v", taskNum, tasksQty)
-			} else {
-				taskLogger.Errorf("runner got executing task #%v/%v error, stopping group: %v", taskNum, tasksQty, err)
-				break
-			}
+	for {
+		it, ok := sched.next()
+		if !ok {
+			return
+		}
 
-			if !utils.StringSliceContains(successfulResults, string(result)) {
-				taskLogger.Debugf("runner got executing task #%v/%v unsuccessful result, stopping group: %v", taskNum, tasksQty, result)
-				break
-			}
+		tasksLogger := ctxLogger.WithField("tasks", it.details)
+		if it.index == 0 {
+			tasksLogger.Debug("runner starts executing group")
+			it.execution = newExecution(it.details[0])
+		}
+
+		task, taskNum, tasksQty := it.task(), it.index+1, len(it.queued.Tasks)
+		taskLogger := tasksLogger.WithFields(executor.TaskDetails(task))
+		taskLogger.Debugf("runner starts executing task #%v/%v", taskNum, tasksQty)
+
+		start = nowFunc()
+		result, err = exec(task, blocker, limiter, cooldowns, inProgress, lease, metric, logger, nowFunc)
+		finished := nowFunc()
+		duration := finished.Sub(start)
+		addTaskExecution(it.execution, it.details[it.index], result, err, executor.Output(task), start, finished)
+		metric.ExecutedTaskObserve(task.Rule(), task.Alert(), task.ExecutorName(), result.String(), err, duration)
+
+		next := taskNum < tasksQty
+		taskLogger = taskLogger.WithFields(logrus.Fields{"result": result.String(), "duration": duration.String()})
+		switch {
+		case err != nil:
+			taskLogger.Errorf("runner got executing task #%v/%v error, stopping group: %v", taskNum, tasksQty, err)
+			next = false
+		case !utils.StringSliceContains(successfulResults, string(result)):
+			taskLogger.Debugf("runner finished executing task #%v/%v", taskNum, tasksQty)
+			taskLogger.Debugf("runner got executing task #%v/%v unsuccessful result, stopping group: %v", taskNum, tasksQty, result)
+			next = false
+		default:
+			taskLogger.Debugf("runner finished executing task #%v/%v", taskNum, tasksQty)
+		}
+
+		if next && stopped(stop) {
+			// group is not marked as done, so it is recovered from queue on next startup
+			tasksLogger.Warnf("runner is stopped, group is interrupted before task #%v/%v", taskNum+1, tasksQty)
+			sched.done(it, false)
+			record(it.execution, recorder, tasksLogger)
+			return
+		}
+
+		sched.done(it, next)
+		if next {
+			continue
 		}
 
 		tasksLogger.Debug("runner finished executing group")
+		record(it.execution, recorder, tasksLogger)
+
+		if len(it.queued.QueueID) > 0 {
+			err = queue.Done(it.queued.QueueID, it.queued.Group)
+			if err != nil {
+				tasksLogger.Errorf("tasks queue done error: %v", err)
+			}
+		}
+	}
+}
+
+func record(execution *history.Execution, recorder recorder, logger *logrus.Entry) {
+	err := recorder.Record(*execution)
+	if err != nil {
+		logger.Errorf("execution history error: %v", err)
+	}
+}
+
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
 	}
 }
 
 //go:generate mockgen -source=runner.go -destination=runner_mocks.go -package=runner doc github.com/golang/mock/gomock
 
+type queue interface {
+	Done(id string, group int) error
+}
+
+type recorder interface {
+	Record(execution history.Execution) error
+}
+
 type blocker interface {
-	BlockInProgress(executor, fingerprint string) (blockedSuccessfully bool, err error)
-	BlockForTTL(executor, fingerprint string, ttl time.Duration) (err error)
-	Unblock(executor, fingerprint string)
+	BlockInProgress(task executor.Task, lease time.Duration) (owner string, err error)
+	Renew(scope, fingerprint, owner string, lease time.Duration) (renewed bool, err error)
+	BlockForTTL(task executor.Task, owner string, ttl time.Duration) (err error)
+	Unblock(scope, fingerprint, owner string)
+}
+
+type limiter interface {
+	Allow(task executor.Task) (limit string, tripped bool)
+	TripTasks(eventID string) model.Tasks
+}
+
+type cooldowns interface {
+	Fail(key string, base time.Duration, now time.Time) (cooldown time.Duration, failures int)
+	Succeed(key string)
 }
 
 type metricser interface {
 	ExecutedTaskObserve(rule, alert, executor, result string, err error, duration time.Duration)
+	BlockLeaseExpiredInc(rule, alert, executor string)
 }
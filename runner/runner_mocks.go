@@ -6,10 +6,83 @@ package runner
 
 import (
 	gomock "github.com/golang/mock/gomock"
+	executor "github.com/krpn/prometheus-alert-webhooker/executor"
+	history "github.com/krpn/prometheus-alert-webhooker/history"
+	model "github.com/krpn/prometheus-alert-webhooker/model"
 	reflect "reflect"
 	time "time"
 )
 
+// Mockqueue is a mock of queue interface
+type Mockqueue struct {
+	ctrl     *gomock.Controller
+	recorder *MockqueueMockRecorder
+}
+
+// MockqueueMockRecorder is the mock recorder for Mockqueue
+type MockqueueMockRecorder struct {
+	mock *Mockqueue
+}
+
+// NewMockqueue creates a new mock instance
+func NewMockqueue(ctrl *gomock.Controller) *Mockqueue {
+	mock := &Mockqueue{ctrl: ctrl}
+	mock.recorder = &MockqueueMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *Mockqueue) EXPECT() *MockqueueMockRecorder {
+	return m.recorder
+}
+
+// Done mocks base method
+func (m *Mockqueue) Done(id string, group int) error {
+	ret := m.ctrl.Call(m, "Done", id, group)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Done indicates an expected call of Done
+func (mr *MockqueueMockRecorder) Done(id, group interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Done", reflect.TypeOf((*Mockqueue)(nil).Done), id, group)
+}
+
+// Mockrecorder is a mock of recorder interface
+type Mockrecorder struct {
+	ctrl     *gomock.Controller
+	recorder *MockrecorderMockRecorder
+}
+
+// MockrecorderMockRecorder is the mock recorder for Mockrecorder
+type MockrecorderMockRecorder struct {
+	mock *Mockrecorder
+}
+
+// NewMockrecorder creates a new mock instance
+func NewMockrecorder(ctrl *gomock.Controller) *Mockrecorder {
+	mock := &Mockrecorder{ctrl: ctrl}
+	mock.recorder = &MockrecorderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *Mockrecorder) EXPECT() *MockrecorderMockRecorder {
+	return m.recorder
+}
+
+// Record mocks base method
+func (m *Mockrecorder) Record(execution history.Execution) error {
+	ret := m.ctrl.Call(m, "Record", execution)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Record indicates an expected call of Record
+func (mr *MockrecorderMockRecorder) Record(execution interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Record", reflect.TypeOf((*Mockrecorder)(nil).Record), execution)
+}
+
 // Mockblocker is a mock of blocker interface
 type Mockblocker struct {
 	ctrl     *gomock.Controller
@@ -34,38 +107,145 @@ func (m *Mockblocker) EXPECT() *MockblockerMockRecorder {
 }
 
 // BlockInProgress mocks base method
-func (m *Mockblocker) BlockInProgress(executor, fingerprint string) (bool, error) {
-	ret := m.ctrl.Call(m, "BlockInProgress", executor, fingerprint)
-	ret0, _ := ret[0].(bool)
+func (m *Mockblocker) BlockInProgress(task executor.Task, lease time.Duration) (string, error) {
+	ret := m.ctrl.Call(m, "BlockInProgress", task, lease)
+	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // BlockInProgress indicates an expected call of BlockInProgress
-func (mr *MockblockerMockRecorder) BlockInProgress(executor, fingerprint interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockInProgress", reflect.TypeOf((*Mockblocker)(nil).BlockInProgress), executor, fingerprint)
+func (mr *MockblockerMockRecorder) BlockInProgress(task, lease interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockInProgress", reflect.TypeOf((*Mockblocker)(nil).BlockInProgress), task, lease)
+}
+
+// Renew mocks base method
+func (m *Mockblocker) Renew(scope, fingerprint, owner string, lease time.Duration) (bool, error) {
+	ret := m.ctrl.Call(m, "Renew", scope, fingerprint, owner, lease)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Renew indicates an expected call of Renew
+func (mr *MockblockerMockRecorder) Renew(scope, fingerprint, owner, lease interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Renew", reflect.TypeOf((*Mockblocker)(nil).Renew), scope, fingerprint, owner, lease)
 }
 
 // BlockForTTL mocks base method
-func (m *Mockblocker) BlockForTTL(executor, fingerprint string, ttl time.Duration) error {
-	ret := m.ctrl.Call(m, "BlockForTTL", executor, fingerprint, ttl)
+func (m *Mockblocker) BlockForTTL(task executor.Task, owner string, ttl time.Duration) error {
+	ret := m.ctrl.Call(m, "BlockForTTL", task, owner, ttl)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // BlockForTTL indicates an expected call of BlockForTTL
-func (mr *MockblockerMockRecorder) BlockForTTL(executor, fingerprint, ttl interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockForTTL", reflect.TypeOf((*Mockblocker)(nil).BlockForTTL), executor, fingerprint, ttl)
+func (mr *MockblockerMockRecorder) BlockForTTL(task, owner, ttl interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockForTTL", reflect.TypeOf((*Mockblocker)(nil).BlockForTTL), task, owner, ttl)
 }
 
 // Unblock mocks base method
-func (m *Mockblocker) Unblock(executor, fingerprint string) {
-	m.ctrl.Call(m, "Unblock", executor, fingerprint)
+func (m *Mockblocker) Unblock(scope, fingerprint, owner string) {
+	m.ctrl.Call(m, "Unblock", scope, fingerprint, owner)
 }
 
 // Unblock indicates an expected call of Unblock
-func (mr *MockblockerMockRecorder) Unblock(executor, fingerprint interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unblock", reflect.TypeOf((*Mockblocker)(nil).Unblock), executor, fingerprint)
+func (mr *MockblockerMockRecorder) Unblock(scope, fingerprint, owner interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unblock", reflect.TypeOf((*Mockblocker)(nil).Unblock), scope, fingerprint, owner)
+}
+
+// Mocklimiter is a mock of limiter interface
+type Mocklimiter struct {
+	ctrl     *gomock.Controller
+	recorder *MocklimiterMockRecorder
+}
+
+// MocklimiterMockRecorder is the mock recorder for Mocklimiter
+type MocklimiterMockRecorder struct {
+	mock *Mocklimiter
+}
+
+// NewMocklimiter creates a new mock instance
+func NewMocklimiter(ctrl *gomock.Controller) *Mocklimiter {
+	mock := &Mocklimiter{ctrl: ctrl}
+	mock.recorder = &MocklimiterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *Mocklimiter) EXPECT() *MocklimiterMockRecorder {
+	return m.recorder
+}
+
+// Allow mocks base method
+func (m *Mocklimiter) Allow(task executor.Task) (string, bool) {
+	ret := m.ctrl.Call(m, "Allow", task)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Allow indicates an expected call of Allow
+func (mr *MocklimiterMockRecorder) Allow(task interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Allow", reflect.TypeOf((*Mocklimiter)(nil).Allow), task)
+}
+
+// TripTasks mocks base method
+func (m *Mocklimiter) TripTasks(eventID string) model.Tasks {
+	ret := m.ctrl.Call(m, "TripTasks", eventID)
+	ret0, _ := ret[0].(model.Tasks)
+	return ret0
+}
+
+// TripTasks indicates an expected call of TripTasks
+func (mr *MocklimiterMockRecorder) TripTasks(eventID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TripTasks", reflect.TypeOf((*Mocklimiter)(nil).TripTasks), eventID)
+}
+
+// Mockcooldowns is a mock of cooldowns interface
+type Mockcooldowns struct {
+	ctrl     *gomock.Controller
+	recorder *MockcooldownsMockRecorder
+}
+
+// MockcooldownsMockRecorder is the mock recorder for Mockcooldowns
+type MockcooldownsMockRecorder struct {
+	mock *Mockcooldowns
+}
+
+// NewMockcooldowns creates a new mock instance
+func NewMockcooldowns(ctrl *gomock.Controller) *Mockcooldowns {
+	mock := &Mockcooldowns{ctrl: ctrl}
+	mock.recorder = &MockcooldownsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *Mockcooldowns) EXPECT() *MockcooldownsMockRecorder {
+	return m.recorder
+}
+
+// Fail mocks base method
+func (m *Mockcooldowns) Fail(key string, base time.Duration, now time.Time) (time.Duration, int) {
+	ret := m.ctrl.Call(m, "Fail", key, base, now)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(int)
+	return ret0, ret1
+}
+
+// Fail indicates an expected call of Fail
+func (mr *MockcooldownsMockRecorder) Fail(key, base, now interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fail", reflect.TypeOf((*Mockcooldowns)(nil).Fail), key, base, now)
+}
+
+// Succeed mocks base method
+func (m *Mockcooldowns) Succeed(key string) {
+	m.ctrl.Call(m, "Succeed", key)
+}
+
+// Succeed indicates an expected call of Succeed
+func (mr *MockcooldownsMockRecorder) Succeed(key interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Succeed", reflect.TypeOf((*Mockcooldowns)(nil).Succeed), key)
 }
 
 // Mockmetricser is a mock of metricser interface
@@ -100,3 +280,13 @@ func (m *Mockmetricser) ExecutedTaskObserve(rule, alert, executor, result string
 func (mr *MockmetricserMockRecorder) ExecutedTaskObserve(rule, alert, executor, result, err, duration interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecutedTaskObserve", reflect.TypeOf((*Mockmetricser)(nil).ExecutedTaskObserve), rule, alert, executor, result, err, duration)
 }
+
+// BlockLeaseExpiredInc mocks base method
+func (m *Mockmetricser) BlockLeaseExpiredInc(rule, alert, executor string) {
+	m.ctrl.Call(m, "BlockLeaseExpiredInc", rule, alert, executor)
+}
+
+// BlockLeaseExpiredInc indicates an expected call of BlockLeaseExpiredInc
+func (mr *MockmetricserMockRecorder) BlockLeaseExpiredInc(rule, alert, executor interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockLeaseExpiredInc", reflect.TypeOf((*Mockmetricser)(nil).BlockLeaseExpiredInc), rule, alert, executor)
+}
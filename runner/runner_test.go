@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/golang/mock/gomock"
 	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/krpn/prometheus-alert-webhooker/history"
 	"github.com/krpn/prometheus-alert-webhooker/model"
 	"github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/test"
@@ -20,8 +21,14 @@ func TestStart(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
+	queue := NewMockqueue(ctrl)
+	recorder := NewMockrecorder(ctrl)
 	blocker := NewMockblocker(ctrl)
+	limiter := NewMocklimiter(ctrl)
+	cooldowns := NewMockcooldowns(ctrl)
+	limiter.EXPECT().Allow(gomock.Any()).Return("", false).AnyTimes()
 	metric := NewMockmetricser(ctrl)
+	lease := model.Lease{TTL: time.Minute, MaxDuration: time.Hour}
 
 	nowFunc := func() time.Time {
 		return time.Unix(1535086351, 0)
@@ -49,9 +56,9 @@ func TestStart(t *testing.T) {
 							t.EXPECT().BlockTTL().Return(0 * time.Second)
 							t.EXPECT().Exec(l).Return(nil)
 							t.EXPECT().EventID().Return("testid1").Times(2)
-							t.EXPECT().Rule().Return("testrule1").Times(3)
+							t.EXPECT().Rule().Return("testrule1").Times(4)
 							t.EXPECT().Alert().Return("testalert1").Times(3)
-							t.EXPECT().ExecutorName().Return("shell").Times(3)
+							t.EXPECT().ExecutorName().Return("shell").Times(4)
 							t.EXPECT().ExecutorDetails().Return(map[string]string{"testtask1": "opts"}).Times(2)
 							m.EXPECT().ExecutedTaskObserve("testrule1", "testalert1", "shell", execResultSuccessWithoutBlock.String(), nil, 0*time.Second)
 						}
@@ -62,11 +69,10 @@ func TestStart(t *testing.T) {
 					expectFunc: func(ts []*executor.MockTask, b *Mockblocker, m *Mockmetricser, l *logrus.Logger) {
 						for _, t := range ts {
 							t.EXPECT().BlockTTL().Return(10 * time.Minute)
-							t.EXPECT().Fingerprint().Return("testfp2")
 							t.EXPECT().ExecutorName().Return("shell").Times(4)
-							b.EXPECT().BlockInProgress("shell", "testfp2").Return(false, nil)
+							b.EXPECT().BlockInProgress(t, time.Minute).Return("", nil)
 							t.EXPECT().EventID().Return("testid2").Times(2)
-							t.EXPECT().Rule().Return("testrule2").Times(3)
+							t.EXPECT().Rule().Return("testrule2").Times(4)
 							t.EXPECT().Alert().Return("testalert2").Times(3)
 							t.EXPECT().ExecutorDetails().Return("testtask2").Times(2)
 							m.EXPECT().ExecutedTaskObserve("testrule2", "testalert2", "shell", execResultInBlock.String(), nil, 0*time.Second)
@@ -77,14 +83,13 @@ func TestStart(t *testing.T) {
 					tasks: []*executor.MockTask{executor.NewMockTask(ctrl)},
 					expectFunc: func(ts []*executor.MockTask, b *Mockblocker, m *Mockmetricser, l *logrus.Logger) {
 						for _, t := range ts {
-							t.EXPECT().BlockTTL().Return(10 * time.Minute).Times(2)
-							t.EXPECT().Fingerprint().Return("testfp3").Times(2)
-							t.EXPECT().ExecutorName().Return("shell").Times(5)
-							b.EXPECT().BlockInProgress("shell", "testfp3").Return(true, nil)
+							t.EXPECT().BlockTTL().Return(10 * time.Minute)
+							t.EXPECT().ExecutorName().Return("shell").Times(4)
+							b.EXPECT().BlockInProgress(t, time.Minute).Return("owner1", nil)
 							t.EXPECT().Exec(l).Return(nil)
-							b.EXPECT().BlockForTTL("shell", "testfp3", 10*time.Minute).Return(nil)
+							b.EXPECT().BlockForTTL(t, "owner1", 10*time.Minute).Return(nil)
 							t.EXPECT().EventID().Return("testid3").Times(2)
-							t.EXPECT().Rule().Return("testrule3").Times(3)
+							t.EXPECT().Rule().Return("testrule3").Times(4)
 							t.EXPECT().Alert().Return("testalert3").Times(3)
 							t.EXPECT().ExecutorDetails().Return("testtask3").Times(2)
 							m.EXPECT().ExecutedTaskObserve("testrule3", "testalert3", "shell", execResultSuccess.String(), nil, 0*time.Second)
@@ -106,13 +111,13 @@ func TestStart(t *testing.T) {
 							}
 
 							t.EXPECT().BlockTTL().Return(10 * time.Minute)
-							t.EXPECT().Fingerprint().Return("testfp4").Times(2)
+							t.EXPECT().Fingerprint().Return("testfp4")
 							t.EXPECT().ExecutorName().Return("shell").Times(5)
-							b.EXPECT().BlockInProgress("shell", "testfp4").Return(true, nil)
+							b.EXPECT().BlockInProgress(t, time.Minute).Return("owner1", nil)
 							t.EXPECT().Exec(l).Return(errors.New("exec error"))
-							b.EXPECT().Unblock("shell", "testfp4")
+							b.EXPECT().Unblock("shell", "testfp4", "owner1")
 							t.EXPECT().EventID().Return("testid4").Times(2)
-							t.EXPECT().Rule().Return("testrule4").Times(3)
+							t.EXPECT().Rule().Return("testrule4").Times(4)
 							t.EXPECT().Alert().Return("testalert4").Times(3)
 							t.EXPECT().ExecutorDetails().Return("testtask4").Times(2)
 							m.EXPECT().ExecutedTaskObserve("testrule4", "testalert4", "shell", execResultExecError.String(), errors.New("exec error"), 0*time.Second)
@@ -125,28 +130,24 @@ func TestStart(t *testing.T) {
 						shift := 6
 
 						i := 0
-						ts[i].EXPECT().BlockTTL().Return(10 * time.Minute).Times(2)
-						ts[i].EXPECT().Fingerprint().Return(fmt.Sprintf("testfp%v", i+shift)).Times(2)
-						ts[i].EXPECT().ExecutorName().Return("shell").Times(2)
-						b.EXPECT().BlockInProgress("shell", fmt.Sprintf("testfp%v", i+shift)).Return(true, nil)
+						ts[i].EXPECT().BlockTTL().Return(10 * time.Minute)
+						b.EXPECT().BlockInProgress(ts[i], time.Minute).Return("owner1", nil)
 						ts[i].EXPECT().Exec(l).Return(nil)
-						b.EXPECT().BlockForTTL("shell", fmt.Sprintf("testfp%v", i+shift), 10*time.Minute).Return(nil)
+						b.EXPECT().BlockForTTL(ts[i], "owner1", 10*time.Minute).Return(nil)
 						ts[i].EXPECT().EventID().Return(fmt.Sprintf("testid%v", i+shift)).Times(2)
-						ts[i].EXPECT().Rule().Return(fmt.Sprintf("testrule%v", i+shift)).Times(3)
+						ts[i].EXPECT().Rule().Return(fmt.Sprintf("testrule%v", i+shift)).Times(4)
 						ts[i].EXPECT().Alert().Return(fmt.Sprintf("testalert%v", i+shift)).Times(3)
-						ts[i].EXPECT().ExecutorName().Return("shell").Times(3)
+						ts[i].EXPECT().ExecutorName().Return("shell").Times(4)
 						ts[i].EXPECT().ExecutorDetails().Return(fmt.Sprintf("testtask%v", i+shift)).Times(2)
 						m.EXPECT().ExecutedTaskObserve(fmt.Sprintf("testrule%v", i+shift), fmt.Sprintf("testalert%v", i+shift), "shell", execResultSuccess.String(), nil, 0*time.Second)
 
 						i = 1
 						ts[i].EXPECT().BlockTTL().Return(10 * time.Minute).Times(1)
-						ts[i].EXPECT().Fingerprint().Return(fmt.Sprintf("testfp%v", i+shift))
-						ts[i].EXPECT().ExecutorName().Return("shell")
-						b.EXPECT().BlockInProgress("shell", fmt.Sprintf("testfp%v", i+shift)).Return(false, nil)
+						b.EXPECT().BlockInProgress(ts[i], time.Minute).Return("", nil)
 						ts[i].EXPECT().EventID().Return(fmt.Sprintf("testid%v", i+shift)).Times(2)
 						ts[i].EXPECT().Rule().Return(fmt.Sprintf("testrule%v", i+shift)).Times(3)
 						ts[i].EXPECT().Alert().Return(fmt.Sprintf("testalert%v", i+shift)).Times(3)
-						ts[i].EXPECT().ExecutorName().Return("shell").Times(3)
+						ts[i].EXPECT().ExecutorName().Return("shell").Times(4)
 						ts[i].EXPECT().ExecutorDetails().Return(fmt.Sprintf("testtask%v", i+shift)).Times(2)
 						m.EXPECT().ExecutedTaskObserve(fmt.Sprintf("testrule%v", i+shift), fmt.Sprintf("testalert%v", i+shift), "shell", execResultInBlock.String(), nil, 0*time.Second)
 
@@ -193,7 +194,7 @@ func TestStart(t *testing.T) {
 		logger.SetLevel(logrus.DebugLevel)
 		logger.Formatter = &logrus.JSONFormatter{DisableTimestamp: true}
 
-		tasksCh := make(chan model.Tasks, len(testUnit.tasks))
+		tasksCh := make(chan model.QueuedTasks, len(testUnit.tasks))
 		for _, task := range testUnit.tasks {
 			task.expectFunc(task.tasks, blocker, metric, logger)
 
@@ -201,10 +202,11 @@ func TestStart(t *testing.T) {
 			for j, taskGroup := range task.tasks {
 				taskGroups[j] = taskGroup
 			}
-			tasksCh <- taskGroups
+			tasksCh <- model.QueuedTasks{Tasks: taskGroups}
 		}
 		close(tasksCh)
-		Start(len(testUnit.tasks), tasksCh, blocker, metric, logger, nowFunc)
+		recorder.EXPECT().Record(gomock.Any()).Return(nil).Times(len(testUnit.tasks))
+		Start(len(testUnit.tasks), tasksCh, make(chan struct{}), time.Minute, NewSettings(model.Rules{}, nil), queue, recorder, blocker, limiter, cooldowns, lease, metric, logger, nowFunc)
 
 		logs := logsFromHook(t, hook)
 		expectedLogs := expectedLogsFix(testUnit.expectedLogs)
@@ -217,6 +219,171 @@ func TestStart(t *testing.T) {
 	}
 }
 
+func TestStart_Queued(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	queue := NewMockqueue(ctrl)
+	limiter := NewMocklimiter(ctrl)
+	limiter.EXPECT().Allow(gomock.Any()).Return("", false).AnyTimes()
+	metric := NewMockmetricser(ctrl)
+	logger, hook := test.NewNullLogger()
+	logger.Formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+
+	task := executor.NewMockTask(ctrl)
+	task.EXPECT().BlockTTL().Return(time.Duration(0))
+	task.EXPECT().Exec(logger).Return(nil)
+	task.EXPECT().EventID().Return("testid1").AnyTimes()
+	task.EXPECT().Rule().Return("testrule1").AnyTimes()
+	task.EXPECT().Alert().Return("testalert1").AnyTimes()
+	task.EXPECT().ExecutorName().Return("shell").AnyTimes()
+	task.EXPECT().ExecutorDetails().Return("testtask1").AnyTimes()
+	metric.EXPECT().ExecutedTaskObserve("testrule1", "testalert1", "shell", execResultSuccessWithoutBlock.String(), nil, time.Duration(0))
+	queue.EXPECT().Done("16f3e2d6b0e1a5c0-1", 1).Return(errors.New("write error"))
+
+	now := time.Unix(1535086351, 0)
+	recorder := NewMockrecorder(ctrl)
+	recorder.EXPECT().Record(history.Execution{
+		EventID:    "testid1",
+		Rule:       "testrule1",
+		Alert:      "testalert1",
+		Result:     execResultSuccessWithoutBlock.String(),
+		StartedAt:  now,
+		FinishedAt: now,
+		Tasks: []history.TaskExecution{
+			{Executor: "shell", Details: "testtask1", Result: execResultSuccessWithoutBlock.String(), StartedAt: now, FinishedAt: now},
+		},
+	}).Return(errors.New("disk is full"))
+
+	tasksCh := make(chan model.QueuedTasks, 1)
+	tasksCh <- model.QueuedTasks{Tasks: model.Tasks{task}, QueueID: "16f3e2d6b0e1a5c0-1", Group: 1}
+	close(tasksCh)
+	Start(1, tasksCh, make(chan struct{}), time.Minute, NewSettings(model.Rules{}, nil), queue, recorder, NewMockblocker(ctrl), limiter, NewMockcooldowns(ctrl), model.Lease{TTL: time.Minute, MaxDuration: time.Hour}, metric, logger, func() time.Time { return now })
+
+	assert.Equal(t, expectedLogsFix([]string{
+		`{"context":"runner","level":"error","msg":"execution history error: disk is full","tasks":[{"alert":"testalert1","details":"testtask1","event_id":"testid1","executor":"shell","rule":"testrule1"}]}`,
+		`{"context":"runner","level":"error","msg":"tasks queue done error: write error","tasks":[{"alert":"testalert1","details":"testtask1","event_id":"testid1","executor":"shell","rule":"testrule1"}]}`,
+	}), logsFromHook(t, hook))
+}
+
+func TestStart_Stop(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	queue := NewMockqueue(ctrl)
+	blocker := NewMockblocker(ctrl)
+	limiter := NewMocklimiter(ctrl)
+	limiter.EXPECT().Allow(gomock.Any()).Return("", false).AnyTimes()
+	metric := NewMockmetricser(ctrl)
+	logger, hook := test.NewNullLogger()
+	logger.Formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+
+	executing, finish, groupDone := make(chan struct{}), make(chan struct{}), make(chan struct{})
+
+	task := executor.NewMockTask(ctrl)
+	task.EXPECT().BlockTTL().Return(10 * time.Minute)
+	task.EXPECT().Fingerprint().Return("testfp1").AnyTimes()
+	task.EXPECT().EventID().Return("testid1").AnyTimes()
+	task.EXPECT().Rule().Return("testrule1").AnyTimes()
+	task.EXPECT().Alert().Return("testalert1").AnyTimes()
+	task.EXPECT().ExecutorName().Return("shell").AnyTimes()
+	task.EXPECT().ExecutorDetails().Return("testtask1").AnyTimes()
+	blocker.EXPECT().BlockInProgress(task, time.Duration(0)).Return("owner1", nil)
+	task.EXPECT().Exec(logger).Do(func(*logrus.Logger) {
+		close(executing)
+		<-finish
+	}).Return(nil)
+
+	tasksCh := make(chan model.QueuedTasks, 2)
+	tasksCh <- model.QueuedTasks{Tasks: model.Tasks{task}, QueueID: "testqueueid", Group: 0}
+	// second group is not started after stop
+	notStarted := executor.NewMockTask(ctrl)
+	notStarted.EXPECT().EventID().Return("testid2").AnyTimes()
+	notStarted.EXPECT().Rule().Return("testrule2").AnyTimes()
+	notStarted.EXPECT().Alert().Return("testalert2").AnyTimes()
+	notStarted.EXPECT().ExecutorName().Return("shell").AnyTimes()
+	notStarted.EXPECT().ExecutorDetails().Return("testtask2").AnyTimes()
+	tasksCh <- model.QueuedTasks{Tasks: model.Tasks{notStarted}, QueueID: "testqueueid", Group: 1}
+
+	stop := make(chan struct{})
+	go func() {
+		<-executing
+		close(stop)
+	}()
+
+	// in-progress block is released while task is still executing
+	blocker.EXPECT().Unblock("shell", "testfp1", "owner1")
+	recorder := NewMockrecorder(ctrl)
+	finished := Start(1, tasksCh, stop, 10*time.Millisecond, NewSettings(model.Rules{}, nil), queue, recorder, blocker, limiter, NewMockcooldowns(ctrl), model.Lease{}, metric, logger, time.Now)
+	assert.False(t, finished)
+
+	assert.Equal(t, expectedLogsFix([]string{
+		`{"alert":"testalert1","context":"runner","details":"testtask1","event_id":"testid1","executor":"shell","level":"warning","msg":"task is still executing on shutdown, in-progress block is released","rule":"testrule1"}`,
+	}), logsFromHook(t, hook))
+
+	// executed group is marked as done
+	blocker.EXPECT().BlockForTTL(task, "owner1", 10*time.Minute).Return(nil)
+	metric.EXPECT().ExecutedTaskObserve("testrule1", "testalert1", "shell", execResultSuccess.String(), nil, gomock.Any())
+	recorder.EXPECT().Record(gomock.Any()).Return(nil)
+	queue.EXPECT().Done("testqueueid", 0).Do(func(string, int) { close(groupDone) }).Return(nil)
+	close(finish)
+	<-groupDone
+}
+
+func TestStart_StopWaitsForExecutingTasks(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	queue := NewMockqueue(ctrl)
+	blocker := NewMockblocker(ctrl)
+	limiter := NewMocklimiter(ctrl)
+	limiter.EXPECT().Allow(gomock.Any()).Return("", false).AnyTimes()
+	metric := NewMockmetricser(ctrl)
+	logger, _ := test.NewNullLogger()
+	recorder := NewMockrecorder(ctrl)
+
+	executing, released, finish := make(chan struct{}), make(chan struct{}), make(chan struct{})
+
+	task := executor.NewMockTask(ctrl)
+	task.EXPECT().BlockTTL().Return(10 * time.Minute)
+	task.EXPECT().Fingerprint().Return("testfp1").AnyTimes()
+	task.EXPECT().EventID().Return("testid1").AnyTimes()
+	task.EXPECT().Rule().Return("testrule1").AnyTimes()
+	task.EXPECT().Alert().Return("testalert1").AnyTimes()
+	task.EXPECT().ExecutorName().Return("shell").AnyTimes()
+	task.EXPECT().ExecutorDetails().Return("testtask1").AnyTimes()
+	blocker.EXPECT().BlockInProgress(task, time.Duration(0)).Return("owner1", nil)
+	task.EXPECT().Exec(logger).Do(func(*logrus.Logger) {
+		close(executing)
+		<-finish
+	}).Return(nil)
+	blocker.EXPECT().Unblock("shell", "testfp1", "owner1").Do(func(string, string, string) { close(released) })
+	blocker.EXPECT().BlockForTTL(task, "owner1", 10*time.Minute).Return(nil)
+	metric.EXPECT().ExecutedTaskObserve("testrule1", "testalert1", "shell", execResultSuccess.String(), nil, gomock.Any())
+	recorder.EXPECT().Record(gomock.Any()).Return(nil)
+	queue.EXPECT().Done("testqueueid", 0).Return(nil)
+
+	tasksCh := make(chan model.QueuedTasks, 1)
+	tasksCh <- model.QueuedTasks{Tasks: model.Tasks{task}, QueueID: "testqueueid", Group: 0}
+
+	stop := make(chan struct{})
+	go func() {
+		<-executing
+		close(stop)
+		<-released
+		close(finish)
+	}()
+
+	finished := Start(1, tasksCh, stop, time.Minute, NewSettings(model.Rules{}, nil), queue, recorder, blocker, limiter, NewMockcooldowns(ctrl), model.Lease{}, metric, logger, time.Now)
+	assert.True(t, finished)
+}
+
 func logsFromHook(t *testing.T, hook *test.Hook) (logs []string) {
 	if hook == nil {
 		return []string{}
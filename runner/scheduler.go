@@ -0,0 +1,207 @@
+package runner
+
+import (
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/krpn/prometheus-alert-webhooker/history"
+	"github.com/krpn/prometheus-alert-webhooker/model"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// scheduler is a priority queue of tasks groups limiting concurrency of executors and rules.
+// Groups of rules with higher priority are executed first, equal priority keeps receive order.
+// Next task of a group is scheduled after previous one is done,
+// so runners are not occupied by tasks waiting for concurrency limits.
+type scheduler struct {
+	settings *Settings
+	capacity int
+
+	mt      *sync.Mutex
+	cond    *sync.Cond
+	items   []*item
+	seq     int64
+	running int
+	byRule  map[string]int
+	byExec  map[string]int
+	closed  bool
+	stopped bool
+}
+
+// item is a task of tasks group waiting for execution.
+type item struct {
+	queued  model.QueuedTasks
+	details []map[string]interface{}
+
+	// index is an index of the task in group.
+	index int
+
+	// execution is a history record of the group shared by its tasks.
+	execution *history.Execution
+
+	seq       int64
+	priority  int
+	rule      string
+	ruleLimit int
+	executor  string
+}
+
+func (it *item) task() executor.Task {
+	return it.queued.Tasks[it.index]
+}
+
+// push adds tasks group to queue. It waits if queue is full.
+func (s *scheduler) push(queued model.QueuedTasks) {
+	if len(queued.Tasks) == 0 {
+		return
+	}
+
+	it := &item{
+		queued:   queued,
+		details:  queued.Tasks.Details(),
+		rule:     queued.Tasks[0].Rule(),
+		executor: strings.ToLower(queued.Tasks[0].ExecutorName()),
+	}
+	it.priority, it.ruleLimit = s.ruleSettings(it.rule)
+
+	s.mt.Lock()
+	defer s.mt.Unlock()
+
+	for len(s.items) >= s.capacity && !s.stopped {
+		s.cond.Wait()
+	}
+
+	if s.stopped {
+		return
+	}
+
+	s.seq++
+	it.seq = s.seq
+	s.insert(it)
+	s.cond.Broadcast()
+}
+
+// next returns task with the highest priority allowed by concurrency limits.
+// Concurrency is taken by the task until done is called.
+// Returns false when queue is closed and all tasks are done or when scheduler is stopped.
+func (s *scheduler) next() (*item, bool) {
+	s.mt.Lock()
+	defer s.mt.Unlock()
+
+	for {
+		if s.stopped {
+			return nil, false
+		}
+
+		for i, it := range s.items {
+			if !s.allowed(it) {
+				continue
+			}
+
+			s.items = append(s.items[:i], s.items[i+1:]...)
+			s.running++
+			s.byRule[it.rule]++
+			s.byExec[it.executor]++
+			s.cond.Broadcast()
+			return it, true
+		}
+
+		if s.closed && len(s.items) == 0 && s.running == 0 {
+			return nil, false
+		}
+
+		s.cond.Wait()
+	}
+}
+
+// done releases concurrency taken by task and schedules next task of the group if needed.
+func (s *scheduler) done(it *item, scheduleNext bool) {
+	var nextItem *item
+	if scheduleNext {
+		copied := *it
+		nextItem = &copied
+		nextItem.index++
+		nextItem.executor = strings.ToLower(nextItem.task().ExecutorName())
+	}
+
+	s.mt.Lock()
+	defer s.mt.Unlock()
+
+	s.running--
+	s.byRule[it.rule]--
+	s.byExec[it.executor]--
+
+	if nextItem != nil && !s.stopped {
+		s.insert(nextItem)
+	}
+
+	s.cond.Broadcast()
+}
+
+// close marks that no more groups are pushed.
+func (s *scheduler) close() {
+	s.mt.Lock()
+	defer s.mt.Unlock()
+
+	s.closed = true
+	s.cond.Broadcast()
+}
+
+// stop makes next return false and drops queued tasks.
+func (s *scheduler) stop() {
+	s.mt.Lock()
+	defer s.mt.Unlock()
+
+	s.stopped = true
+	s.cond.Broadcast()
+}
+
+func (s *scheduler) allowed(it *item) bool {
+	if it.ruleLimit > 0 && s.byRule[it.rule] >= it.ruleLimit {
+		return false
+	}
+
+	limit, ok := s.settings.load().executorLimits[it.executor]
+	return !ok || s.byExec[it.executor] < limit
+}
+
+// insert inserts item keeping items sorted by priority descending and sequence.
+func (s *scheduler) insert(it *item) {
+	i := sort.Search(len(s.items), func(i int) bool {
+		if s.items[i].priority != it.priority {
+			return s.items[i].priority < it.priority
+		}
+		return s.items[i].seq > it.seq
+	})
+
+	s.items = append(s.items, nil)
+	copy(s.items[i+1:], s.items[i:])
+	s.items[i] = it
+}
+
+// ruleSettings returns priority and max concurrency of the rule.
+// Rules are looked up on every call because they can be refreshed.
+func (s *scheduler) ruleSettings(name string) (priority, maxConcurrency int) {
+	for _, rule := range s.settings.load().rules {
+		if rule.Name == name {
+			return rule.Priority, rule.MaxConcurrency
+		}
+	}
+	return 0, 0
+}
+
+func newScheduler(settings *Settings, capacity int) *scheduler {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	mt := &sync.Mutex{}
+	return &scheduler{
+		settings: settings,
+		capacity: capacity,
+		mt:       mt,
+		cond:     sync.NewCond(mt),
+		byRule:   make(map[string]int),
+		byExec:   make(map[string]int),
+	}
+}
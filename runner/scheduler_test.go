@@ -0,0 +1,161 @@
+package runner
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/krpn/prometheus-alert-webhooker/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newSchedulerTestTasks(ctrl *gomock.Controller, rule string, executors ...string) model.QueuedTasks {
+	tasks := make(model.Tasks, len(executors))
+	for i, executorName := range executors {
+		task := executor.NewMockTask(ctrl)
+		task.EXPECT().Rule().Return(rule).AnyTimes()
+		task.EXPECT().ExecutorName().Return(executorName).AnyTimes()
+		task.EXPECT().EventID().Return("testid1").AnyTimes()
+		task.EXPECT().Alert().Return("testalert1").AnyTimes()
+		task.EXPECT().ExecutorDetails().Return(nil).AnyTimes()
+		tasks[i] = task
+	}
+	return model.QueuedTasks{Tasks: tasks}
+}
+
+func TestScheduler(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	rules := model.Rules{
+		{Name: "notify", Priority: 10},
+		{Name: "restart", MaxConcurrency: 1},
+		{Name: "build"},
+	}
+	sched := newScheduler(NewSettings(rules, map[string]int{"Jenkins": 1}), 10)
+
+	build1 := newSchedulerTestTasks(ctrl, "build", "jenkins", "telegram")
+	build2 := newSchedulerTestTasks(ctrl, "build", "jenkins")
+	restart1 := newSchedulerTestTasks(ctrl, "restart", "shell")
+	restart2 := newSchedulerTestTasks(ctrl, "restart", "shell")
+	notify := newSchedulerTestTasks(ctrl, "notify", "telegram")
+	unknown := newSchedulerTestTasks(ctrl, "unknown", "shell")
+
+	for _, queued := range []model.QueuedTasks{build1, build2, restart1, restart2, notify, unknown} {
+		sched.push(queued)
+	}
+	sched.close()
+
+	next := func() (executor.Task, *item) {
+		it, ok := sched.next()
+		assert.Equal(t, true, ok)
+		return it.task(), it
+	}
+
+	// higher priority first
+	task, notifyItem := next()
+	assert.Equal(t, notify.Tasks[0], task)
+
+	task, build1Item := next()
+	assert.Equal(t, build1.Tasks[0], task)
+
+	// jenkins concurrency is exceeded by build1
+	task, restart1Item := next()
+	assert.Equal(t, restart1.Tasks[0], task)
+
+	// rule concurrency is exceeded by restart1
+	task, unknownItem := next()
+	assert.Equal(t, unknown.Tasks[0], task)
+
+	// next task of group keeps its place in queue
+	sched.done(build1Item, true)
+	task, build1Item = next()
+	assert.Equal(t, build1.Tasks[1], task)
+	assert.Equal(t, 1, build1Item.index)
+
+	task, build2Item := next()
+	assert.Equal(t, build2.Tasks[0], task)
+
+	sched.done(restart1Item, false)
+	task, restart2Item := next()
+	assert.Equal(t, restart2.Tasks[0], task)
+
+	for _, it := range []*item{notifyItem, build1Item, unknownItem, build2Item, restart2Item} {
+		sched.done(it, false)
+	}
+
+	// queue is closed and drained
+	_, ok := sched.next()
+	assert.Equal(t, false, ok)
+}
+
+func TestScheduler_Stop(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sched := newScheduler(NewSettings(model.Rules{}, nil), 1)
+	sched.push(newSchedulerTestTasks(ctrl, "build", "jenkins"))
+
+	pushed := make(chan struct{})
+	go func() {
+		// queue is full, push waits until stop
+		sched.push(newSchedulerTestTasks(ctrl, "build", "jenkins"))
+		close(pushed)
+	}()
+
+	sched.stop()
+	<-pushed
+
+	_, ok := sched.next()
+	assert.Equal(t, false, ok)
+}
+
+func TestScheduler_SettingsUpdate(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	settings := NewSettings(model.Rules{{Name: "build"}, {Name: "notify"}}, nil)
+	sched := newScheduler(settings, 10)
+
+	build1 := newSchedulerTestTasks(ctrl, "build", "jenkins")
+	build2 := newSchedulerTestTasks(ctrl, "build", "jenkins")
+	notify := newSchedulerTestTasks(ctrl, "notify", "telegram")
+
+	sched.push(build1)
+	sched.push(build2)
+	settings.Update(model.Rules{{Name: "build"}, {Name: "notify", Priority: 10}}, map[string]int{"Jenkins": 1})
+	sched.push(notify)
+	sched.close()
+
+	// priority of refreshed rule is applied to tasks pushed after update
+	it, ok := sched.next()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, notify.Tasks[0], it.task())
+	sched.done(it, false)
+
+	it, ok = sched.next()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, build1.Tasks[0], it.task())
+
+	// refreshed jenkins concurrency is exceeded by build1
+	next := make(chan *item)
+	go func() {
+		it, _ := sched.next()
+		next <- it
+	}()
+
+	select {
+	case <-next:
+		t.Fatal("build2 is started while build1 is executing")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	sched.done(it, false)
+	assert.Equal(t, build2.Tasks[0], (<-next).task())
+}
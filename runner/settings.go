@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"github.com/krpn/prometheus-alert-webhooker/model"
+	"strings"
+	"sync/atomic"
+)
+
+// Settings are rules and executors concurrency limits used by runners.
+// They are swapped as a whole on config refresh, so runners do not see partially updated rules.
+type Settings struct {
+	// value keeps *settings
+	value *atomic.Value
+}
+
+type settings struct {
+	rules          model.Rules
+	executorLimits map[string]int
+}
+
+// Update replaces rules and executors concurrency limits, it is called on config refresh.
+// New limits are applied to tasks scheduled after update.
+func (s *Settings) Update(rules model.Rules, executorConcurrency map[string]int) {
+	limits := make(map[string]int, len(executorConcurrency))
+	for executorName, limit := range executorConcurrency {
+		limits[strings.ToLower(executorName)] = limit
+	}
+
+	s.value.Store(&settings{rules: rules, executorLimits: limits})
+}
+
+func (s *Settings) load() *settings {
+	return s.value.Load().(*settings)
+}
+
+// NewSettings creates Settings instance.
+func NewSettings(rules model.Rules, executorConcurrency map[string]int) *Settings {
+	s := &Settings{value: &atomic.Value{}}
+	s.Update(rules, executorConcurrency)
+	return s
+}
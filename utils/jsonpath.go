@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type jsonPathStep struct {
+	key   string
+	index int
+	array bool
+}
+
+// JSONPath returns value of decoded JSON data at path.
+// Path supports dot-notated object keys and array indexes, e.g. $.items[0].id
+func JSONPath(data interface{}, path string) (interface{}, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	value := data
+	for _, step := range steps {
+		if step.array {
+			array, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("value before [%v] is not an array", step.index)
+			}
+
+			if step.index < 0 || step.index >= len(array) {
+				return nil, fmt.Errorf("index %v is out of range", step.index)
+			}
+
+			value = array[step.index]
+			continue
+		}
+
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("value before .%v is not an object", step.key)
+		}
+
+		value, ok = object[step.key]
+		if !ok {
+			return nil, fmt.Errorf("key %v is not found", step.key)
+		}
+	}
+
+	return value, nil
+}
+
+// ValidateJSONPath returns error if path is not supported by JSONPath.
+func ValidateJSONPath(path string) error {
+	_, err := parseJSONPath(path)
+	return err
+}
+
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath %v should start with $", path)
+	}
+
+	var (
+		steps []jsonPathStep
+		rest  = path[1:]
+	)
+
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+
+			if end == 0 {
+				return nil, fmt.Errorf("JSONPath %v has empty key", path)
+			}
+
+			steps = append(steps, jsonPathStep{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %v has unclosed bracket", path)
+			}
+
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("JSONPath %v has invalid index %v", path, rest[1:end])
+			}
+
+			steps = append(steps, jsonPathStep{index: index, array: true})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("JSONPath %v is invalid at %v", path, rest)
+		}
+	}
+
+	return steps, nil
+}
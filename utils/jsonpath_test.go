@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	t.Parallel()
+
+	var data interface{}
+	err := json.Unmarshal([]byte(`{"status":"ok","build":{"id":42,"tags":["a","b"]},"items":[{"name":"first"}]}`), &data)
+	assert.Equal(t, nil, err)
+
+	type testTableData struct {
+		tcase       string
+		path        string
+		expected    interface{}
+		expectedErr error
+	}
+
+	testTable := []testTableData{
+		{
+			tcase:       "root",
+			path:        "$",
+			expected:    data,
+			expectedErr: nil,
+		},
+		{
+			tcase:       "key",
+			path:        "$.status",
+			expected:    "ok",
+			expectedErr: nil,
+		},
+		{
+			tcase:       "nested key",
+			path:        "$.build.id",
+			expected:    float64(42),
+			expectedErr: nil,
+		},
+		{
+			tcase:       "index",
+			path:        "$.build.tags[1]",
+			expected:    "b",
+			expectedErr: nil,
+		},
+		{
+			tcase:       "key of array element",
+			path:        "$.items[0].name",
+			expected:    "first",
+			expectedErr: nil,
+		},
+		{
+			tcase:       "key not found",
+			path:        "$.build.url",
+			expected:    nil,
+			expectedErr: errors.New("key url is not found"),
+		},
+		{
+			tcase:       "index out of range",
+			path:        "$.items[1]",
+			expected:    nil,
+			expectedErr: errors.New("index 1 is out of range"),
+		},
+		{
+			tcase:       "not an array",
+			path:        "$.status[0]",
+			expected:    nil,
+			expectedErr: errors.New("value before [0] is not an array"),
+		},
+		{
+			tcase:       "not an object",
+			path:        "$.status.code",
+			expected:    nil,
+			expectedErr: errors.New("value before .code is not an object"),
+		},
+		{
+			tcase:       "without root",
+			path:        "status",
+			expected:    nil,
+			expectedErr: errors.New("JSONPath status should start with $"),
+		},
+		{
+			tcase:       "empty key",
+			path:        "$..status",
+			expected:    nil,
+			expectedErr: errors.New("JSONPath $..status has empty key"),
+		},
+		{
+			tcase:       "unclosed bracket",
+			path:        "$.items[0",
+			expected:    nil,
+			expectedErr: errors.New("JSONPath $.items[0 has unclosed bracket"),
+		},
+		{
+			tcase:       "invalid index",
+			path:        "$.items[*]",
+			expected:    nil,
+			expectedErr: errors.New("JSONPath $.items[*] has invalid index *"),
+		},
+		{
+			tcase:       "invalid",
+			path:        "$items",
+			expected:    nil,
+			expectedErr: errors.New("JSONPath $items is invalid at items"),
+		},
+	}
+
+	for _, testUnit := range testTable {
+		actual, err := JSONPath(data, testUnit.path)
+		assert.Equal(t, testUnit.expected, actual, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedErr, err, testUnit.tcase)
+		if err == nil {
+			assert.Equal(t, nil, ValidateJSONPath(testUnit.path), testUnit.tcase)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package webhook
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Drainer rejects payloads while webhooker is shutting down.
+type Drainer struct {
+	mt       *sync.RWMutex
+	draining bool
+}
+
+// Handler wraps payload handler. Payloads are rejected with 503 after Drain is called,
+// so Alertmanager retries them later.
+func (d *Drainer) Handler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		d.mt.RLock()
+		defer d.mt.RUnlock()
+
+		if d.draining {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		handler(w, req)
+	}
+}
+
+// Drain starts rejecting payloads and waits for payloads being handled.
+// Tasks channel can be closed after Drain returns.
+func (d *Drainer) Drain() {
+	d.mt.Lock()
+	defer d.mt.Unlock()
+
+	d.draining = true
+}
+
+// NewDrainer creates Drainer instance.
+func NewDrainer() *Drainer {
+	return &Drainer{mt: &sync.RWMutex{}}
+}
@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDrainer(t *testing.T) {
+	t.Parallel()
+
+	drainer := NewDrainer()
+
+	handling, finish := make(chan struct{}), make(chan struct{})
+	handler := drainer.Handler(func(w http.ResponseWriter, _ *http.Request) {
+		close(handling)
+		<-finish
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	req := httptest.NewRequest("POST", "/webhooker", nil)
+
+	handled := httptest.NewRecorder()
+	handledCh := make(chan struct{})
+	go func() {
+		handler(handled, req)
+		close(handledCh)
+	}()
+	<-handling
+
+	drained := make(chan struct{})
+	go func() {
+		drainer.Drain()
+		close(drained)
+	}()
+
+	// Drain waits for payload being handled
+	select {
+	case <-drained:
+		t.Fatal("drained before payload is handled")
+	case <-handledCh:
+		t.Fatal("handled before finish")
+	default:
+	}
+
+	close(finish)
+	<-handledCh
+	<-drained
+	assert.Equal(t, http.StatusAccepted, handled.Code)
+
+	rejected := httptest.NewRecorder()
+	handler(rejected, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rejected.Code)
+}
@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"github.com/krpn/prometheus-alert-webhooker/model"
+	"github.com/krpn/prometheus-alert-webhooker/queue"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// Recover sends pending tasks groups of queue to runners.
+// Tasks are recreated from queued alerts with current rules, tasks groups of changed rules are marked as done.
+// Records older than expire are dropped.
+func Recover(queue recoverer, rules model.Rules, expire time.Duration, tasksCh chan model.QueuedTasks, logger *logrus.Logger, nowFunc func() time.Time) {
+	ctxLogger := logger.WithField("context", context)
+	now := nowFunc()
+
+	for _, record := range queue.Pending() {
+		recordLogger := ctxLogger.WithFields(
+			logrus.Fields{
+				"event_id": record.EventID,
+				"queue_id": record.ID,
+			},
+		)
+
+		if age := now.Sub(record.ReceivedAt); age > expire {
+			recordLogger.Warnf("queued tasks are expired after %v, dropped", age)
+			if err := queue.Drop(record.ID); err != nil {
+				recordLogger.Errorf("tasks queue drop error: %v", err)
+			}
+			continue
+		}
+
+		// received time is used to get the same tasks as before restart
+		tasksGroups, _ := record.Alerts.ToTasksGroups(rules, record.EventID, record.ReceivedAt)
+
+		for i, rule := range record.Groups {
+			if record.IsDone(i) {
+				continue
+			}
+
+			if i >= len(tasksGroups) || tasksGroups[i][0].Rule() != rule {
+				recordLogger.WithField("rule", rule).Warn("rules are changed, queued tasks are skipped")
+				if err := queue.Done(record.ID, i); err != nil {
+					recordLogger.Errorf("tasks queue done error: %v", err)
+				}
+				continue
+			}
+
+			tasksCh <- model.QueuedTasks{Tasks: tasksGroups[i], QueueID: record.ID, Group: i}
+
+			recordLogger.WithField("tasks", tasksGroups[i].Details()).Info("queued tasks are recovered")
+		}
+	}
+}
+
+//go:generate mockgen -source=recover.go -destination=recover_mocks.go -package=webhook doc github.com/golang/mock/gomock
+
+type recoverer interface {
+	Pending() []queue.Record
+	Done(id string, group int) error
+	Drop(id string) error
+}
@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/krpn/prometheus-alert-webhooker/executor"
+	"github.com/krpn/prometheus-alert-webhooker/model"
+	"github.com/krpn/prometheus-alert-webhooker/queue"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRecover(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executorMock := executor.NewMockTaskExecutor(ctrl)
+	tasksQueue := NewMockrecoverer(ctrl)
+
+	now := time.Unix(1535086351, 0)
+	nowFunc := func() time.Time {
+		return now
+	}
+
+	rules := model.Rules{
+		{
+			Name: "testrule1",
+			Conditions: model.Conditions{
+				AlertLabels: map[string]string{
+					"instance": "testinstance1",
+				},
+			},
+			Actions: model.Actions{
+				{
+					Executor: "shell",
+					Parameters: map[string]interface{}{
+						"command": "restart",
+					},
+				},
+			},
+		},
+	}
+
+	executorMock.EXPECT().ValidateParameters(map[string]interface{}{"command": "restart"}).Return(nil)
+	err := rules.Prepare(nil, map[string]executor.TaskExecutor{"shell": executorMock})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := model.Payload{}
+	err = json.Unmarshal([]byte(`{"alerts":[{"labels":{"alertname":"testalert1","instance":"testinstance1"}}],"status":"firing"}`), &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerts := payload.ToAlerts()
+
+	newTask := func(eventID string) *executor.MockTask {
+		task := executor.NewMockTask(ctrl)
+		task.EXPECT().EventID().Return(eventID).AnyTimes()
+		task.EXPECT().Rule().Return("testrule1").AnyTimes()
+		task.EXPECT().Alert().Return("testalert1").AnyTimes()
+		task.EXPECT().ExecutorName().Return("shell").AnyTimes()
+		task.EXPECT().ExecutorDetails().Return(map[string]interface{}{"command": "restart"}).AnyTimes()
+		executorMock.EXPECT().NewTask(eventID, "testrule1", "testalert1", time.Duration(0), map[string]interface{}{"command": "restart"}).Return(task)
+		return task
+	}
+	task1, task4 := newTask("ev01"), newTask("ev04")
+	// alert of record with changed rule matches other rule now
+	newTask("ev03")
+
+	tasksQueue.EXPECT().Pending().Return([]queue.Record{
+		{ID: "id1", EventID: "ev01", ReceivedAt: now.Add(-time.Minute), Alerts: alerts, Groups: []string{"testrule1"}},
+		{ID: "id2", EventID: "ev02", ReceivedAt: now.Add(-2 * time.Hour), Alerts: alerts, Groups: []string{"testrule1"}},
+		{ID: "id3", EventID: "ev03", ReceivedAt: now.Add(-time.Minute), Alerts: alerts, Groups: []string{"oldrule"}},
+		{ID: "id4", EventID: "ev04", ReceivedAt: now.Add(-time.Minute), Alerts: alerts, Groups: []string{"testrule1", "oldrule"}, Done: []int{1}},
+	})
+	tasksQueue.EXPECT().Drop("id2").Return(errors.New("disk is full"))
+	tasksQueue.EXPECT().Done("id3", 0).Return(nil)
+
+	logger, hook := test.NewNullLogger()
+	logger.Formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+
+	tasksCh := make(chan model.QueuedTasks, 2)
+	Recover(tasksQueue, rules, time.Hour, tasksCh, logger, nowFunc)
+	close(tasksCh)
+
+	tasks := make([]model.QueuedTasks, 0)
+	for queued := range tasksCh {
+		tasks = append(tasks, queued)
+	}
+
+	assert.Equal(t, []model.QueuedTasks{
+		{Tasks: model.Tasks{task1}, QueueID: "id1", Group: 0},
+		{Tasks: model.Tasks{task4}, QueueID: "id4", Group: 0},
+	}, tasks)
+	assert.Equal(t, expectedLogsFix([]string{
+		`{"context":"webhook","event_id":"ev01","level":"info","msg":"queued tasks are recovered","queue_id":"id1","tasks":[{"alert":"testalert1","details":{"command":"restart"},"event_id":"ev01","executor":"shell","rule":"testrule1"}]}`,
+		`{"context":"webhook","event_id":"ev02","level":"warning","msg":"queued tasks are expired after 2h0m0s, dropped","queue_id":"id2"}`,
+		`{"context":"webhook","event_id":"ev02","level":"error","msg":"tasks queue drop error: disk is full","queue_id":"id2"}`,
+		`{"context":"webhook","event_id":"ev03","level":"warning","msg":"rules are changed, queued tasks are skipped","queue_id":"id3","rule":"oldrule"}`,
+		`{"context":"webhook","event_id":"ev04","level":"info","msg":"queued tasks are recovered","queue_id":"id4","tasks":[{"alert":"testalert1","details":{"command":"restart"},"event_id":"ev04","executor":"shell","rule":"testrule1"}]}`,
+	}), logsFromHook(t, hook))
+}
@@ -12,7 +12,8 @@ import (
 const context = "webhook"
 
 // Webhook is a handler for Alertmanager payload.
-func Webhook(req *http.Request, rules model.Rules, tasksCh chan model.Tasks, metric metricser, logger *logrus.Logger, nowFunc func() time.Time) {
+// If queue is not nil, tasks groups are persisted to it before payload is acknowledged.
+func Webhook(w http.ResponseWriter, req *http.Request, rules model.Rules, tasksCh chan model.QueuedTasks, queue queuer, flapper flapper, metric metricser, logger *logrus.Logger, nowFunc func() time.Time) {
 	decoder := json.NewDecoder(req.Body)
 
 	payload := &model.Payload{}
@@ -22,10 +23,28 @@ func Webhook(req *http.Request, rules model.Rules, tasksCh chan model.Tasks, met
 
 	eventID := getEventID(nowFunc)
 
+	now := nowFunc()
+
 	alerts := payload.ToAlerts()
-	tasksGroups := alerts.ToTasksGroups(rules, eventID)
+	alerts.DetectFlapping(flapper, now)
+	metric.FlappingAlertsSet(flapper.FlappingQty(now))
+
+	tasksGroups, skippedRules := alerts.ToTasksGroups(rules, eventID, now)
 
 	ctxLogger := logger.WithField("context", context)
+
+	for _, skipped := range skippedRules {
+		ctxLogger.WithFields(
+			logrus.Fields{
+				"event_id": eventID,
+				"rule":     skipped.Rule,
+				"alert":    skipped.Alert,
+				"reason":   skipped.Reason,
+			},
+		).Info("rule is matched, actions are skipped")
+		metric.SkippedRuleInc(skipped.Rule, skipped.Alert, skipped.Reason)
+	}
+
 	payloadLogger := ctxLogger.WithFields(
 		logrus.Fields{
 			"event_id":     eventID,
@@ -40,11 +59,27 @@ func Webhook(req *http.Request, rules model.Rules, tasksCh chan model.Tasks, met
 
 	payloadLogger.Debug("payload is received, tasks are prepared")
 
-	for _, tasks := range tasksGroups {
+	var queueID string
+	if queue != nil {
+		groups := make([]string, len(tasksGroups))
+		for i, tasks := range tasksGroups {
+			groups[i] = tasks[0].Rule()
+		}
+
+		var err error
+		queueID, err = queue.Append(eventID, now, alerts, groups)
+		if err != nil {
+			payloadLogger.Errorf("tasks queue append error: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	for i, tasks := range tasksGroups {
 		tasksLogger := ctxLogger.WithField("tasks", tasks.Details())
 		tasksLogger.Debug("ready to send tasks to runner")
 
-		tasksCh <- tasks
+		tasksCh <- model.QueuedTasks{Tasks: tasks, QueueID: queueID, Group: i}
 
 		tasksLogger.Debug("sent tasks to runner")
 
@@ -62,6 +97,17 @@ func getEventID(nowFunc func() time.Time) string {
 
 //go:generate mockgen -source=webhook.go -destination=webhook_mocks.go -package=webhook doc github.com/golang/mock/gomock
 
+type flapper interface {
+	Observe(fingerprint, status string, now time.Time) (flapping bool)
+	FlappingQty(now time.Time) (qty int)
+}
+
+type queuer interface {
+	Append(eventID string, receivedAt time.Time, alerts model.Alerts, groups []string) (id string, err error)
+}
+
 type metricser interface {
 	IncomeTaskInc(rule, alert, executor string)
+	SkippedRuleInc(rule, alert, reason string)
+	FlappingAlertsSet(qty int)
 }
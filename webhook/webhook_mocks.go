@@ -6,9 +6,94 @@ package webhook
 
 import (
 	gomock "github.com/golang/mock/gomock"
+	model "github.com/krpn/prometheus-alert-webhooker/model"
 	reflect "reflect"
+	time "time"
 )
 
+// Mockflapper is a mock of flapper interface
+type Mockflapper struct {
+	ctrl     *gomock.Controller
+	recorder *MockflapperMockRecorder
+}
+
+// MockflapperMockRecorder is the mock recorder for Mockflapper
+type MockflapperMockRecorder struct {
+	mock *Mockflapper
+}
+
+// NewMockflapper creates a new mock instance
+func NewMockflapper(ctrl *gomock.Controller) *Mockflapper {
+	mock := &Mockflapper{ctrl: ctrl}
+	mock.recorder = &MockflapperMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *Mockflapper) EXPECT() *MockflapperMockRecorder {
+	return m.recorder
+}
+
+// Observe mocks base method
+func (m *Mockflapper) Observe(fingerprint, status string, now time.Time) bool {
+	ret := m.ctrl.Call(m, "Observe", fingerprint, status, now)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Observe indicates an expected call of Observe
+func (mr *MockflapperMockRecorder) Observe(fingerprint, status, now interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Observe", reflect.TypeOf((*Mockflapper)(nil).Observe), fingerprint, status, now)
+}
+
+// FlappingQty mocks base method
+func (m *Mockflapper) FlappingQty(now time.Time) int {
+	ret := m.ctrl.Call(m, "FlappingQty", now)
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// FlappingQty indicates an expected call of FlappingQty
+func (mr *MockflapperMockRecorder) FlappingQty(now interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FlappingQty", reflect.TypeOf((*Mockflapper)(nil).FlappingQty), now)
+}
+
+// Mockqueuer is a mock of queuer interface
+type Mockqueuer struct {
+	ctrl     *gomock.Controller
+	recorder *MockqueuerMockRecorder
+}
+
+// MockqueuerMockRecorder is the mock recorder for Mockqueuer
+type MockqueuerMockRecorder struct {
+	mock *Mockqueuer
+}
+
+// NewMockqueuer creates a new mock instance
+func NewMockqueuer(ctrl *gomock.Controller) *Mockqueuer {
+	mock := &Mockqueuer{ctrl: ctrl}
+	mock.recorder = &MockqueuerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *Mockqueuer) EXPECT() *MockqueuerMockRecorder {
+	return m.recorder
+}
+
+// Append mocks base method
+func (m *Mockqueuer) Append(eventID string, receivedAt time.Time, alerts model.Alerts, groups []string) (string, error) {
+	ret := m.ctrl.Call(m, "Append", eventID, receivedAt, alerts, groups)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Append indicates an expected call of Append
+func (mr *MockqueuerMockRecorder) Append(eventID, receivedAt, alerts, groups interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Append", reflect.TypeOf((*Mockqueuer)(nil).Append), eventID, receivedAt, alerts, groups)
+}
+
 // Mockmetricser is a mock of metricser interface
 type Mockmetricser struct {
 	ctrl     *gomock.Controller
@@ -41,3 +126,23 @@ func (m *Mockmetricser) IncomeTaskInc(rule, alert, executor string) {
 func (mr *MockmetricserMockRecorder) IncomeTaskInc(rule, alert, executor interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncomeTaskInc", reflect.TypeOf((*Mockmetricser)(nil).IncomeTaskInc), rule, alert, executor)
 }
+
+// SkippedRuleInc mocks base method
+func (m *Mockmetricser) SkippedRuleInc(rule, alert, reason string) {
+	m.ctrl.Call(m, "SkippedRuleInc", rule, alert, reason)
+}
+
+// SkippedRuleInc indicates an expected call of SkippedRuleInc
+func (mr *MockmetricserMockRecorder) SkippedRuleInc(rule, alert, reason interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SkippedRuleInc", reflect.TypeOf((*Mockmetricser)(nil).SkippedRuleInc), rule, alert, reason)
+}
+
+// FlappingAlertsSet mocks base method
+func (m *Mockmetricser) FlappingAlertsSet(qty int) {
+	m.ctrl.Call(m, "FlappingAlertsSet", qty)
+}
+
+// FlappingAlertsSet indicates an expected call of FlappingAlertsSet
+func (mr *MockmetricserMockRecorder) FlappingAlertsSet(qty interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FlappingAlertsSet", reflect.TypeOf((*Mockmetricser)(nil).FlappingAlertsSet), qty)
+}
@@ -2,6 +2,7 @@ package webhook
 
 import (
 	"bytes"
+	"errors"
 	"github.com/golang/mock/gomock"
 	"github.com/jinzhu/copier"
 	"github.com/krpn/prometheus-alert-webhooker/executor"
@@ -10,6 +11,7 @@ import (
 	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -21,6 +23,10 @@ func TestWebhook(t *testing.T) {
 	defer ctrl.Finish()
 
 	metric := NewMockmetricser(ctrl)
+	metric.EXPECT().FlappingAlertsSet(0).AnyTimes()
+	flapper := NewMockflapper(ctrl)
+	flapper.EXPECT().Observe(gomock.Any(), gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+	flapper.EXPECT().FlappingQty(gomock.Any()).Return(0).AnyTimes()
 	executorMock := executor.NewMockTaskExecutor(ctrl)
 	task := executor.NewMockTask(ctrl)
 
@@ -159,11 +165,11 @@ func TestWebhook(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		tasksCh := make(chan model.Tasks, len(testUnit.expectedTasks))
-		Webhook(req, testUnit.rules, tasksCh, metric, logger, nowFunc)
+		tasksCh := make(chan model.QueuedTasks, len(testUnit.expectedTasks))
+		Webhook(httptest.NewRecorder(), req, testUnit.rules, tasksCh, nil, flapper, metric, logger, nowFunc)
 
-		for _, expectedTask := range testUnit.expectedTasks {
-			assert.Equal(t, expectedTask, <-tasksCh, testUnit.tcase)
+		for i, expectedTask := range testUnit.expectedTasks {
+			assert.Equal(t, model.QueuedTasks{Tasks: expectedTask, Group: i}, <-tasksCh, testUnit.tcase)
 		}
 
 		assert.Equal(t, expectedLogsFix(testUnit.expectedLogs), logsFromHook(t, hook), testUnit.tcase)
@@ -177,6 +183,10 @@ func TestWebhook_RulesChanged(t *testing.T) {
 	defer ctrl.Finish()
 
 	metric := NewMockmetricser(ctrl)
+	metric.EXPECT().FlappingAlertsSet(0).AnyTimes()
+	flapper := NewMockflapper(ctrl)
+	flapper.EXPECT().Observe(gomock.Any(), gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+	flapper.EXPECT().FlappingQty(gomock.Any()).Return(0).AnyTimes()
 	executorMock := executor.NewMockTaskExecutor(ctrl)
 	task := executor.NewMockTask(ctrl)
 
@@ -299,17 +309,205 @@ func TestWebhook_RulesChanged(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		tasksCh := make(chan model.Tasks, len(testUnit.expectedTasks))
-		Webhook(req, globalRules, tasksCh, metric, logger, nowFunc)
+		tasksCh := make(chan model.QueuedTasks, len(testUnit.expectedTasks))
+		Webhook(httptest.NewRecorder(), req, globalRules, tasksCh, nil, flapper, metric, logger, nowFunc)
 
-		for _, expectedTask := range testUnit.expectedTasks {
-			assert.Equal(t, expectedTask, <-tasksCh, testUnit.tcase)
+		for i, expectedTask := range testUnit.expectedTasks {
+			assert.Equal(t, model.QueuedTasks{Tasks: expectedTask, Group: i}, <-tasksCh, testUnit.tcase)
 		}
 
 		assert.Equal(t, expectedLogsFix(testUnit.expectedLogs), logsFromHook(t, hook), testUnit.tcase)
 	}
 }
 
+func TestWebhook_MutedRule(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metric := NewMockmetricser(ctrl)
+	metric.EXPECT().FlappingAlertsSet(0).AnyTimes()
+	flapper := NewMockflapper(ctrl)
+	flapper.EXPECT().Observe(gomock.Any(), gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+	flapper.EXPECT().FlappingQty(gomock.Any()).Return(0).AnyTimes()
+	executorMock := executor.NewMockTaskExecutor(ctrl)
+
+	nowFunc := func() time.Time {
+		return time.Unix(1535086351, 0)
+	}
+
+	rules := model.Rules{
+		{
+			Name: "testrule1",
+			// empty time interval matches any time
+			MuteTimeIntervals: model.TimeIntervals{{}},
+			Conditions: model.Conditions{
+				AlertLabels: map[string]string{
+					"instance": "testinstance1",
+				},
+			},
+			Actions: model.Actions{
+				{
+					Executor: "shell",
+					Parameters: map[string]interface{}{
+						"command": "restart",
+					},
+				},
+			},
+		},
+	}
+
+	executorMock.EXPECT().ValidateParameters(map[string]interface{}{"command": "restart"}).Return(nil)
+	err := rules.Prepare(nil, map[string]executor.TaskExecutor{"shell": executorMock})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metric.EXPECT().SkippedRuleInc("testrule1", "testalert1", model.SkipReasonMuted)
+
+	body := []byte(`{
+    "alerts": [
+        {
+            "labels": {
+                "alertname": "testalert1",
+                "instance": "testinstance1"
+            }
+        }
+    ],
+    "status": "firing"
+}`)
+
+	req, err := http.NewRequest("POST", "http://prometheus-alert-webhooker.com/", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	logger.Formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+
+	Webhook(httptest.NewRecorder(), req, rules, make(chan model.QueuedTasks), nil, flapper, metric, logger, nowFunc)
+
+	assert.Equal(t, expectedLogsFix([]string{
+		`{"alert":"testalert1","context":"webhook","event_id":"dc12","level":"info","msg":"rule is matched, actions are skipped","reason":"muted","rule":"testrule1"}`,
+		`{"context":"webhook","event_id":"dc12","level":"debug","msg":"payload is received, no tasks for it","payload":{"receiver":"","status":"firing","alerts":[{"status":"","labels":{"alertname":"testalert1","instance":"testinstance1"},"annotations":null,"startsAt":"0001-01-01T00:00:00Z","endsAt":"0001-01-01T00:00:00Z","generatorURL":""}],"groupLabels":null,"commonLabels":null,"commonAnnotations":null,"externalURL":""},"tasks_groups":[]}`,
+	}), logsFromHook(t, hook))
+}
+
+func TestWebhook_Queue(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metric := NewMockmetricser(ctrl)
+	metric.EXPECT().FlappingAlertsSet(0).AnyTimes()
+	flapper := NewMockflapper(ctrl)
+	flapper.EXPECT().Observe(gomock.Any(), gomock.Any(), gomock.Any()).Return(false).AnyTimes()
+	flapper.EXPECT().FlappingQty(gomock.Any()).Return(0).AnyTimes()
+	executorMock := executor.NewMockTaskExecutor(ctrl)
+	queue := NewMockqueuer(ctrl)
+
+	task := executor.NewMockTask(ctrl)
+	task.EXPECT().EventID().Return("dc12").AnyTimes()
+	task.EXPECT().Rule().Return("testrule1").AnyTimes()
+	task.EXPECT().Alert().Return("testalert1").AnyTimes()
+	task.EXPECT().ExecutorName().Return("shell").AnyTimes()
+	task.EXPECT().ExecutorDetails().Return(map[string]interface{}{"command": "restart"}).AnyTimes()
+
+	now := time.Unix(1535086351, 0)
+	nowFunc := func() time.Time {
+		return now
+	}
+
+	rules := model.Rules{
+		{
+			Name: "testrule1",
+			Conditions: model.Conditions{
+				AlertLabels: map[string]string{
+					"instance": "testinstance1",
+				},
+			},
+			Actions: model.Actions{
+				{
+					Executor: "shell",
+					Parameters: map[string]interface{}{
+						"command": "restart",
+					},
+				},
+			},
+		},
+	}
+
+	executorMock.EXPECT().ValidateParameters(map[string]interface{}{"command": "restart"}).Return(nil)
+	err := rules.Prepare(nil, map[string]executor.TaskExecutor{"shell": executorMock})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"alerts":[{"labels":{"alertname":"testalert1","instance":"testinstance1"}}],"status":"firing"}`
+
+	type testTableData struct {
+		tcase         string
+		expectFunc    func(q *Mockqueuer, m *Mockmetricser)
+		expectedCode  int
+		expectedTasks []model.QueuedTasks
+		expectedLogs  []string
+	}
+
+	testTable := []testTableData{
+		{
+			tcase: "appended",
+			expectFunc: func(q *Mockqueuer, m *Mockmetricser) {
+				q.EXPECT().Append("dc12", now, gomock.Any(), []string{"testrule1"}).Return("testqueueid", nil)
+				m.EXPECT().IncomeTaskInc("testrule1", "testalert1", "shell")
+			},
+			expectedCode:  http.StatusOK,
+			expectedTasks: []model.QueuedTasks{{Tasks: model.Tasks{task}, QueueID: "testqueueid", Group: 0}},
+			expectedLogs:  []string{},
+		},
+		{
+			tcase: "append error",
+			expectFunc: func(q *Mockqueuer, m *Mockmetricser) {
+				q.EXPECT().Append("dc12", now, gomock.Any(), []string{"testrule1"}).Return("", errors.New("disk is full"))
+			},
+			expectedCode:  http.StatusInternalServerError,
+			expectedTasks: []model.QueuedTasks{},
+			expectedLogs: []string{
+				`{"context":"webhook","event_id":"dc12","level":"error","msg":"tasks queue append error: disk is full","payload":{"receiver":"","status":"firing","alerts":[{"status":"","labels":{"alertname":"testalert1","instance":"testinstance1"},"annotations":null,"startsAt":"0001-01-01T00:00:00Z","endsAt":"0001-01-01T00:00:00Z","generatorURL":""}],"groupLabels":null,"commonLabels":null,"commonAnnotations":null,"externalURL":""},"tasks_groups":[[{"alert":"testalert1","details":{"command":"restart"},"event_id":"dc12","executor":"shell","rule":"testrule1"}]]}`,
+			},
+		},
+	}
+
+	for _, testUnit := range testTable {
+		logger, hook := test.NewNullLogger()
+		logger.Formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+
+		executorMock.EXPECT().NewTask("dc12", "testrule1", "testalert1", time.Duration(0), map[string]interface{}{"command": "restart"}).Return(task)
+		testUnit.expectFunc(queue, metric)
+
+		req, err := http.NewRequest("POST", "http://prometheus-alert-webhooker.com/", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		tasksCh := make(chan model.QueuedTasks, 1)
+		Webhook(w, req, rules, tasksCh, queue, flapper, metric, logger, nowFunc)
+		close(tasksCh)
+
+		tasks := make([]model.QueuedTasks, 0)
+		for queued := range tasksCh {
+			tasks = append(tasks, queued)
+		}
+
+		assert.Equal(t, testUnit.expectedCode, w.Code, testUnit.tcase)
+		assert.Equal(t, testUnit.expectedTasks, tasks, testUnit.tcase)
+		assert.Equal(t, expectedLogsFix(testUnit.expectedLogs), logsFromHook(t, hook), testUnit.tcase)
+	}
+}
+
 func TestGetEventID(t *testing.T) {
 	t.Parallel()
 